@@ -17,6 +17,7 @@ import (
 	"github.com/PortNumber53/simple-social-thing/backend/internal/handlers"
 	"github.com/PortNumber53/simple-social-thing/backend/internal/socialimport"
 	"github.com/PortNumber53/simple-social-thing/backend/internal/socialimport/providers"
+	"github.com/PortNumber53/simple-social-thing/backend/internal/workers"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
@@ -84,7 +85,10 @@ Environment variables:
   GOOGLE_CLIENT_CALLBACK_URL   OAuth callback path (default: /auth/google/callback)
   STRIPE_SECRET_KEY            Stripe API secret key
   STRIPE_WEBHOOK_SECRET        Stripe webhook signing secret
-  INTERNAL_WS_SECRET           Shared secret for Worker → Backend WS auth`)
+  INTERNAL_WS_SECRET           Shared secret for Worker → Backend WS auth
+  REALTIME_FANOUT_ENABLED      Relay realtime events between instances via Postgres NOTIFY (default: true)
+  NOTIFICATION_CLEANUP_ENABLED Delete read notifications past their retention (default: false)
+  NOTIFICATION_RETENTION_HOURS How long read notifications are kept when cleanup is on (default: 24)`)
 }
 
 func runMigrate(args []string) error {
@@ -292,8 +296,24 @@ func run(d deps) error {
 		})
 	}
 
+	// Singleton background workers run under Postgres advisory-lock leadership so that with several
+	// API instances each one runs on exactly one of them (and fails over when that instance dies).
+	leader := &workers.Leader{
+		DB:            db,
+		InstanceID:    workers.DefaultInstanceID(),
+		RetryInterval: parseIntervalFromEnv(d.getenv, "WORKER_LEADER_RETRY_SECONDS", 15*time.Second),
+	}
+	h.SetWorkerLeader(leader)
+
+	// Realtime events reach browsers connected to any instance: emitEvent NOTIFYs the other instances and
+	// each one LISTENs and broadcasts to its own WebSocket connections. Must start before any worker emits.
+	if d.getenv("REALTIME_FANOUT_ENABLED") != "false" {
+		h.StartRealtimeFanout(rootCtx, databaseURL, leader.InstanceID)
+	}
+
 	// Start background workers
-	h.StartProductArchivalWorker()
+	go leader.Run(rootCtx, "product_archival", h.StartProductArchivalWorker)
+	startNotificationCleanupIfEnabled(rootCtx, db, leader, d.getenv)
 
 	// Ensure all billing plans exist and are synced
 	if count, err := h.EnsureAllPlans(rootCtx); err != nil {
@@ -325,10 +345,13 @@ func run(d deps) error {
 
 	// Background: per-provider social import workers (each with independent rate limiting/quota handling).
 	// Disabled by default; enable explicitly in prod after configuring tokens + quotas.
-	startSocialImportWorkersIfEnabled(rootCtx, db, d.getenv)
+	startSocialImportWorkersIfEnabled(rootCtx, db, h, leader, d.getenv)
 
 	// Background: scheduled post poller (publishes due posts and enqueues publish jobs).
-	startScheduledPostsWorker(rootCtx, h, d.getenv)
+	startScheduledPostsWorker(rootCtx, h, leader, d.getenv)
+
+	// Background: publish job worker pool (claims queued publish_jobs rows; safe on every instance).
+	startPublishJobWorkers(rootCtx, h, d.getenv)
 
 	go func() {
 		<-stop
@@ -445,7 +468,20 @@ func parseIntervalFromEnv(getenv func(string) string, envKey string, def time.Du
 	return def
 }
 
-func startSocialImportWorkersIfEnabled(ctx context.Context, db *sql.DB, getenv func(string) string) {
+func parseIntFromEnv(getenv func(string) string, envKey string, def int) int {
+	if getenv == nil {
+		return def
+	}
+	if v := getenv(envKey); v != "" {
+		var n int
+		if _, err := fmt.Sscanf(v, "%d", &n); err == nil && n > 0 {
+			return n
+		}
+	}
+	return def
+}
+
+func startSocialImportWorkersIfEnabled(ctx context.Context, db *sql.DB, h *handlers.Handler, leader *workers.Leader, getenv func(string) string) {
 	v := ""
 	if getenv != nil {
 		v = getenv("SOCIAL_IMPORT_WORKERS_ENABLED")
@@ -455,22 +491,83 @@ func startSocialImportWorkersIfEnabled(ctx context.Context, db *sql.DB, getenv f
 		return
 	}
 
-	runner := &socialimport.Runner{DB: db, Logger: log.Default()}
-	go runner.StartProviderWorker(ctx, providers.InstagramProvider{}, parseIntervalFromEnv(getenv, "SOCIAL_IMPORT_INSTAGRAM_INTERVAL_SECONDS", 15*time.Minute))
-	go runner.StartProviderWorker(ctx, providers.FacebookProvider{}, parseIntervalFromEnv(getenv, "SOCIAL_IMPORT_FACEBOOK_INTERVAL_SECONDS", 30*time.Minute))
-	go runner.StartProviderWorker(ctx, providers.TikTokProvider{}, parseIntervalFromEnv(getenv, "SOCIAL_IMPORT_TIKTOK_INTERVAL_SECONDS", 30*time.Minute))
-	go runner.StartProviderWorker(ctx, providers.YouTubeProvider{}, parseIntervalFromEnv(getenv, "SOCIAL_IMPORT_YOUTUBE_INTERVAL_SECONDS", 60*time.Minute))
-	go runner.StartProviderWorker(ctx, providers.PinterestProvider{}, parseIntervalFromEnv(getenv, "SOCIAL_IMPORT_PINTEREST_INTERVAL_SECONDS", 60*time.Minute))
-	go runner.StartProviderWorker(ctx, providers.ThreadsProvider{}, parseIntervalFromEnv(getenv, "SOCIAL_IMPORT_THREADS_INTERVAL_SECONDS", 60*time.Minute))
+	runner := &socialimport.Runner{
+		DB:          db,
+		Logger:      log.Default(),
+		BackoffBase: parseIntervalFromEnv(getenv, "SOCIAL_IMPORT_BACKOFF_BASE_SECONDS", 15*time.Minute),
+		BackoffMax:  parseIntervalFromEnv(getenv, "SOCIAL_IMPORT_BACKOFF_MAX_SECONDS", 24*time.Hour),
+		Concurrency: parseIntFromEnv(getenv, "SOCIAL_IMPORT_CONCURRENCY", 2),
+		UserBudget:  parseIntervalFromEnv(getenv, "SOCIAL_IMPORT_USER_BUDGET_SECONDS", 5*time.Minute),
+		Jitter:      parseIntervalFromEnv(getenv, "SOCIAL_IMPORT_JITTER_SECONDS", time.Minute),
+	}
+	if h != nil {
+		runner.OnProgress = h.EmitImportProgress
+	}
+	// Fill defaults once before the provider goroutines share the runner.
+	runner.EnsureDefaults()
+	importWorkers := []struct {
+		provider socialimport.Provider
+		envKey   string
+		def      time.Duration
+	}{
+		{providers.InstagramProvider{}, "SOCIAL_IMPORT_INSTAGRAM_INTERVAL_SECONDS", 15 * time.Minute},
+		{providers.FacebookProvider{}, "SOCIAL_IMPORT_FACEBOOK_INTERVAL_SECONDS", 30 * time.Minute},
+		{providers.TikTokProvider{}, "SOCIAL_IMPORT_TIKTOK_INTERVAL_SECONDS", 30 * time.Minute},
+		{providers.YouTubeProvider{}, "SOCIAL_IMPORT_YOUTUBE_INTERVAL_SECONDS", 60 * time.Minute},
+		{providers.PinterestProvider{}, "SOCIAL_IMPORT_PINTEREST_INTERVAL_SECONDS", 60 * time.Minute},
+		{providers.ThreadsProvider{}, "SOCIAL_IMPORT_THREADS_INTERVAL_SECONDS", 60 * time.Minute},
+		{providers.XProvider{}, "SOCIAL_IMPORT_X_INTERVAL_SECONDS", 60 * time.Minute},
+	}
+	for _, iw := range importWorkers {
+		provider := iw.provider
+		interval := parseIntervalFromEnv(getenv, iw.envKey, iw.def)
+		go leader.Run(ctx, "social_import:"+provider.Name(), func(ctx context.Context) {
+			runner.StartProviderWorker(ctx, provider, interval)
+		})
+	}
+}
+
+// startNotificationCleanupIfEnabled starts the worker that permanently deletes read notifications older than
+// NOTIFICATION_RETENTION_HOURS. It is opt-in because it deletes user data.
+func startNotificationCleanupIfEnabled(ctx context.Context, db *sql.DB, leader *workers.Leader, getenv func(string) string) bool {
+	v := ""
+	if getenv != nil {
+		v = getenv("NOTIFICATION_CLEANUP_ENABLED")
+	}
+	if v != "true" {
+		log.Printf("[NotificationCleanupWorker] disabled (set NOTIFICATION_CLEANUP_ENABLED=true to enable)")
+		return false
+	}
+	w := &workers.NotificationCleanupWorker{
+		DB:             db,
+		RetentionHours: parseIntFromEnv(getenv, "NOTIFICATION_RETENTION_HOURS", 24),
+	}
+	go leader.Run(ctx, "notification_cleanup", w.Start)
+	return true
 }
 
-func startScheduledPostsWorker(ctx context.Context, h *handlers.Handler, getenv func(string) string) {
+func startScheduledPostsWorker(ctx context.Context, h *handlers.Handler, leader *workers.Leader, getenv func(string) string) {
 	interval := parseIntervalFromEnv(getenv, "SCHEDULED_POSTS_INTERVAL_SECONDS", 60*time.Second)
 	origin := ""
 	if getenv != nil {
 		origin = getenv("FRONTEND_URL")
 	}
-	go h.StartScheduledPostsWorker(ctx, interval, origin)
+	go leader.Run(ctx, "scheduled_posts", func(ctx context.Context) {
+		h.StartScheduledPostsWorker(ctx, interval, origin)
+	})
+}
+
+func startPublishJobWorkers(ctx context.Context, h *handlers.Handler, getenv func(string) string) {
+	opts := handlers.PublishJobWorkerOptions{
+		Workers:      parseIntFromEnv(getenv, "PUBLISH_JOB_WORKERS", 4),
+		PollInterval: parseIntervalFromEnv(getenv, "PUBLISH_JOB_POLL_SECONDS", 5*time.Second),
+		Lease:        parseIntervalFromEnv(getenv, "PUBLISH_JOB_LEASE_SECONDS", 2*time.Minute),
+		MaxAttempts:  parseIntFromEnv(getenv, "PUBLISH_JOB_MAX_ATTEMPTS", 3),
+	}
+	if getenv != nil {
+		opts.Origin = getenv("FRONTEND_URL")
+	}
+	go h.StartPublishJobWorkers(ctx, opts)
 }
 
 func buildCORSHandler(r http.Handler, getenv func(string) string) http.Handler {
@@ -594,6 +691,7 @@ func buildRouter(h *handlers.Handler) *mux.Router {
 
 	// Health check
 	r.HandleFunc("/health", h.Health).Methods("GET")
+	r.HandleFunc("/api/workers/leaders/admin/user/{userId}", h.GetWorkerLeaders).Methods("GET")
 
 	// Google OAuth callback (login flow handled by backend)
 	r.HandleFunc("/auth/google/callback", h.GoogleOAuthCallback).Methods("GET")
@@ -661,6 +759,7 @@ func buildRouter(h *handlers.Handler) *mux.Router {
 	// Social library (cached copies of user created content)
 	r.HandleFunc("/api/social-libraries/user/{userId}", h.ListSocialLibrariesForUser).Methods("GET")
 	r.HandleFunc("/api/social-libraries/sync/user/{userId}", h.SyncSocialLibrariesForUser).Methods("POST")
+	r.HandleFunc("/api/social-libraries/sync-status/user/{userId}", h.GetSocialLibrarySyncStatusForUser).Methods("GET")
 	r.HandleFunc("/api/social-libraries/import/user/{userId}", h.ImportSocialLibraryForUser).Methods("POST")
 	// Batch delete cached library items for a user
 	r.HandleFunc("/api/social-libraries/delete/user/{userId}", h.DeleteSocialLibrariesForUser).Methods("POST")
@@ -675,6 +774,11 @@ func buildRouter(h *handlers.Handler) *mux.Router {
 	r.HandleFunc("/api/social-posts/publish-async/user/{userId}", h.EnqueuePublishJobForUser).Methods("POST")
 	// Publishing job status
 	r.HandleFunc("/api/social-posts/publish-jobs/{jobId}", h.GetPublishJob).Methods("GET")
+	// Re-queue a failed job; providers that already posted are skipped
+	r.HandleFunc("/api/social-posts/publish-jobs/{jobId}/retry/user/{userId}", h.RetryPublishJobForUser).Methods("POST")
+
+	// Pinterest boards and sections, for choosing where a post is pinned
+	r.HandleFunc("/api/pinterest/boards/user/{userId}", h.ListPinterestBoardsForUser).Methods("GET")
 
 	// Instagram Agent: AI content/image generation plus account analytics.
 	r.HandleFunc("/api/instagram-agent/generate/user/{userId}", h.GenerateInstagramContent).Methods("POST")
@@ -687,6 +791,19 @@ func buildRouter(h *handlers.Handler) *mux.Router {
 	r.HandleFunc("/api/posts/user/{userId}", h.CreatePostForUser).Methods("POST")
 	r.HandleFunc("/api/posts/{postId}/user/{userId}", h.UpdatePostForUser).Methods("PUT")
 	r.HandleFunc("/api/posts/{postId}/user/{userId}", h.DeletePostForUser).Methods("DELETE")
+	r.HandleFunc("/api/posts/{postId}/occurrences/user/{userId}", h.ListPostOccurrencesForUser).Methods("GET")
+	r.HandleFunc("/api/posts/{postId}/occurrences/user/{userId}", h.UpdatePostOccurrenceForUser).Methods("PUT")
+	r.HandleFunc("/api/posts/{postId}/preflight/user/{userId}", h.PreflightPostForUser).Methods("POST")
+	// Delete a published post from every network it went out on
+	r.HandleFunc("/api/posts/{postId}/retract/user/{userId}", h.RetractPostForUser).Methods("POST")
+	// Push caption/title edits of a published post to the networks that allow editing
+	r.HandleFunc("/api/posts/{postId}/update-remote/user/{userId}", h.UpdateRemotePostForUser).Methods("POST")
+	r.HandleFunc("/api/posts/{postId}/queue/user/{userId}", h.AddPostToQueueForUser).Methods("POST")
+	r.HandleFunc("/api/posts/{postId}/queue/user/{userId}", h.RemovePostFromQueueForUser).Methods("DELETE")
+	r.HandleFunc("/api/posting-queue/user/{userId}", h.GetPostingQueueForUser).Methods("GET")
+	r.HandleFunc("/api/posting-queue/user/{userId}", h.PutPostingQueueForUser).Methods("PUT")
+	r.HandleFunc("/api/scheduling-preferences/user/{userId}", h.GetSchedulingPreferencesForUser).Methods("GET")
+	r.HandleFunc("/api/scheduling-preferences/user/{userId}", h.PutSchedulingPreferencesForUser).Methods("PUT")
 	// Publish a scheduled post immediately (for testing / manual override)
 	r.HandleFunc("/api/posts/{postId}/publish-now/user/{userId}", h.PublishNowPostForUser).Methods("POST")
 
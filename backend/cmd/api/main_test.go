@@ -52,6 +52,24 @@ func TestParseIntervalFromEnv(t *testing.T) {
 	}
 }
 
+func TestParseIntFromEnv(t *testing.T) {
+	if got := parseIntFromEnv(nil, "X", 4); got != 4 {
+		t.Fatalf("expected default with nil getenv, got %d", got)
+	}
+	if got := parseIntFromEnv(func(string) string { return "" }, "X", 4); got != 4 {
+		t.Fatalf("expected default, got %d", got)
+	}
+	if got := parseIntFromEnv(func(string) string { return "0" }, "X", 4); got != 4 {
+		t.Fatalf("expected default on 0, got %d", got)
+	}
+	if got := parseIntFromEnv(func(string) string { return "abc" }, "X", 4); got != 4 {
+		t.Fatalf("expected default on non-int, got %d", got)
+	}
+	if got := parseIntFromEnv(func(string) string { return "8" }, "X", 4); got != 8 {
+		t.Fatalf("expected 8, got %d", got)
+	}
+}
+
 func TestBuildRouter_HealthOK(t *testing.T) {
 	r := buildRouter(handlers.New(nil))
 
@@ -84,6 +102,10 @@ func TestRun_Smoke_NoRealListen(t *testing.T) {
 			if k == "DATABASE_URL" {
 				return "postgres://example"
 			}
+			if k == "REALTIME_FANOUT_ENABLED" {
+				// no LISTEN connection against the fake DSN
+				return "false"
+			}
 			// keep workers disabled for deterministic tests
 			return ""
 		},
@@ -120,7 +142,7 @@ func TestStartSocialImportWorkersIfEnabled_EnabledButCanceled(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel() // ensure workers exit immediately
 
-	startSocialImportWorkersIfEnabled(ctx, nil, func(k string) string {
+	startSocialImportWorkersIfEnabled(ctx, nil, nil, nil, func(k string) string {
 		switch k {
 		case "SOCIAL_IMPORT_WORKERS_ENABLED":
 			return "true"
@@ -132,6 +154,23 @@ func TestStartSocialImportWorkersIfEnabled_EnabledButCanceled(t *testing.T) {
 	})
 }
 
+func TestStartNotificationCleanupIfEnabled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // ensure the worker exits immediately
+
+	if startNotificationCleanupIfEnabled(ctx, nil, nil, func(string) string { return "" }) {
+		t.Fatalf("expected cleanup to stay off by default")
+	}
+	if !startNotificationCleanupIfEnabled(ctx, nil, nil, func(k string) string {
+		if k == "NOTIFICATION_CLEANUP_ENABLED" {
+			return "true"
+		}
+		return ""
+	}) {
+		t.Fatalf("expected cleanup to start when enabled")
+	}
+}
+
 func TestRun_MissingOpenDB(t *testing.T) {
 	err := run(deps{
 		getenv: func(k string) string {
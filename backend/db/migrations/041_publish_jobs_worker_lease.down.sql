@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS public.idx_publish_jobs_running_lease;
+DROP INDEX IF EXISTS public.idx_publish_jobs_queued_claim;
+
+ALTER TABLE public.publish_jobs
+  DROP COLUMN IF EXISTS heartbeat_at,
+  DROP COLUMN IF EXISTS locked_until,
+  DROP COLUMN IF EXISTS locked_by,
+  DROP COLUMN IF EXISTS attempts;
@@ -0,0 +1,15 @@
+-- Publish jobs: lease columns so a pool of workers (across instances) can claim queued jobs
+-- with FOR UPDATE SKIP LOCKED and re-queue jobs whose worker died mid-run.
+ALTER TABLE public.publish_jobs
+  ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS locked_by TEXT NULL,
+  ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ NULL,
+  ADD COLUMN IF NOT EXISTS heartbeat_at TIMESTAMPTZ NULL;
+
+CREATE INDEX IF NOT EXISTS idx_publish_jobs_queued_claim
+  ON public.publish_jobs (created_at)
+  WHERE status = 'queued';
+
+CREATE INDEX IF NOT EXISTS idx_publish_jobs_running_lease
+  ON public.publish_jobs (locked_until)
+  WHERE status = 'running';
@@ -0,0 +1,2 @@
+ALTER TABLE public.social_import_states
+  DROP COLUMN IF EXISTS consecutive_failures;
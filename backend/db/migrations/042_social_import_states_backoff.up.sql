@@ -0,0 +1,4 @@
+-- Social import states: count consecutive failures so the importer can back off (next_run_at)
+-- instead of retrying a broken token on every sweep.
+ALTER TABLE public.social_import_states
+  ADD COLUMN IF NOT EXISTS consecutive_failures INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS public.social_import_runs;
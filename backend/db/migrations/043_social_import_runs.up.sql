@@ -0,0 +1,21 @@
+-- Social import run history: one row per provider sync attempt (worker sweep or manual sync),
+-- used by the sync-status API to show when each network last synced and why it failed.
+CREATE TABLE IF NOT EXISTS public.social_import_runs (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+    provider TEXT NOT NULL,
+    trigger TEXT NOT NULL DEFAULT 'worker',
+    status TEXT NOT NULL,
+    fetched INTEGER NOT NULL DEFAULT 0,
+    upserted INTEGER NOT NULL DEFAULT 0,
+    skipped_reason TEXT NULL,
+    error TEXT NULL,
+    started_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ NOT NULL,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_social_import_runs_user_started ON public.social_import_runs(user_id, started_at DESC);
+CREATE INDEX IF NOT EXISTS idx_social_import_runs_user_provider_started ON public.social_import_runs(user_id, provider, started_at DESC);
+CREATE INDEX IF NOT EXISTS idx_social_import_runs_started ON public.social_import_runs(started_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS public.idx_posts_recurring_due;
+DROP TABLE IF EXISTS public.post_occurrences;
+ALTER TABLE public.posts DROP COLUMN IF EXISTS recurrence;
@@ -0,0 +1,30 @@
+-- Recurring posts: posts.recurrence holds the repeat rule and posts.scheduled_for the next slot.
+-- Each slot becomes a post_occurrences row (publish history, or a per-occurrence skip/edit made ahead of time).
+ALTER TABLE public.posts ADD COLUMN IF NOT EXISTS recurrence JSONB NULL;
+
+CREATE TABLE IF NOT EXISTS public.post_occurrences (
+    id TEXT PRIMARY KEY,
+    post_id TEXT NOT NULL REFERENCES public.posts(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+    occurrence_at TIMESTAMPTZ NOT NULL,
+    -- pending | skipped | missed | queued | running | completed | failed
+    status TEXT NOT NULL DEFAULT 'pending',
+    -- Per-occurrence overrides (NULL: use the post's values).
+    scheduled_for TIMESTAMPTZ NULL,
+    content TEXT NULL,
+    providers TEXT[] NULL,
+    media TEXT[] NULL,
+    publish_job_id TEXT NULL,
+    error TEXT NULL,
+    attempted_at TIMESTAMPTZ NULL,
+    published_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (post_id, occurrence_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_occurrences_due ON public.post_occurrences ((COALESCE(scheduled_for, occurrence_at)))
+    WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_post_occurrences_publish_job_id ON public.post_occurrences(publish_job_id);
+CREATE INDEX IF NOT EXISTS idx_posts_recurring_due ON public.posts(scheduled_for)
+    WHERE status = 'scheduled' AND recurrence IS NOT NULL;
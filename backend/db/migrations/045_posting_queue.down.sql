@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS public.idx_posts_user_queue;
+ALTER TABLE public.posts DROP COLUMN IF EXISTS in_queue;
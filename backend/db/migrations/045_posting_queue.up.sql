@@ -0,0 +1,7 @@
+-- Posting queue: posts placed into the user's weekly slots (user_settings key 'posting_queue') are marked
+-- in_queue so they can be re-packed when the queue changes. Posts scheduled at an exact time are never moved.
+ALTER TABLE public.posts ADD COLUMN IF NOT EXISTS in_queue BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_posts_user_queue
+    ON public.posts(user_id, scheduled_for)
+    WHERE in_queue AND status = 'scheduled';
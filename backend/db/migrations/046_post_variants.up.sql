@@ -0,0 +1,2 @@
+-- Per-provider overrides of a post's caption/title/link/media/first comment, keyed by provider.
+ALTER TABLE public.posts ADD COLUMN IF NOT EXISTS variants JSONB NULL;
@@ -0,0 +1,2 @@
+-- Network-specific publish settings of a post (e.g. Instagram user tags, location, collaborators), keyed by network.
+ALTER TABLE public.posts ADD COLUMN IF NOT EXISTS options JSONB NULL;
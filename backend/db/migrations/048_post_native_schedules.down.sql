@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS public.idx_post_native_schedules_post;
+DROP TABLE IF EXISTS public.post_native_schedules;
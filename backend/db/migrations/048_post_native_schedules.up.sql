@@ -0,0 +1,20 @@
+-- Posts handed to a network's own scheduler (Meta's scheduled_publish_time): one row per page/object, so
+-- the scheduled object can be cancelled when the post is edited or deleted.
+CREATE TABLE IF NOT EXISTS public.post_native_schedules (
+    id TEXT PRIMARY KEY,
+    post_id TEXT NOT NULL REFERENCES public.posts(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+    provider TEXT NOT NULL,
+    -- Page the object lives on, and the scheduled post/photo/video id returned by the network.
+    account_id TEXT NOT NULL,
+    external_id TEXT NOT NULL,
+    scheduled_for TIMESTAMPTZ NOT NULL,
+    -- scheduled | published | cancelled | cancel_failed
+    status TEXT NOT NULL DEFAULT 'scheduled',
+    error TEXT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_native_schedules_post ON public.post_native_schedules(post_id, provider)
+    WHERE status = 'scheduled';
@@ -0,0 +1,2 @@
+ALTER TABLE public.posts DROP COLUMN IF EXISTS retract_result;
+ALTER TABLE public.posts DROP COLUMN IF EXISTS retracted_at;
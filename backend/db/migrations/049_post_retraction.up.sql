@@ -0,0 +1,4 @@
+-- Retracting a published post deletes its copies on the networks; retract_result keeps the per-provider
+-- outcome so a later retract only retries what is still live.
+ALTER TABLE public.posts ADD COLUMN IF NOT EXISTS retracted_at TIMESTAMPTZ NULL;
+ALTER TABLE public.posts ADD COLUMN IF NOT EXISTS retract_result JSONB NULL;
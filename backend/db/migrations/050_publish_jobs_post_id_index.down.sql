@@ -0,0 +1 @@
+DROP INDEX IF EXISTS public.idx_publish_jobs_user_post_id;
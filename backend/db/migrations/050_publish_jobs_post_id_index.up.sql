@@ -0,0 +1,3 @@
+-- Post-bound publish jobs record the post in request_json.postId; retract and remote edits look a post's jobs
+-- up by it (every recurring occurrence included) instead of relying on posts.last_publish_job_id.
+CREATE INDEX IF NOT EXISTS idx_publish_jobs_user_post_id ON public.publish_jobs (user_id, (request_json->>'postId'));
@@ -3251,21 +3251,26 @@ func (h *Handler) GetMigrationStatus(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, response)
 }
 
-// StartProductArchivalWorker starts a background goroutine that monitors and archives migrated products with no subscribers
-func (h *Handler) StartProductArchivalWorker() {
-	go func() {
-		ticker := time.NewTicker(1 * time.Hour)
-		defer ticker.Stop()
+// StartProductArchivalWorker monitors and archives migrated products with no subscribers until ctx is done.
+// It blocks; run it in a goroutine (main runs it under leader election so only one instance calls Stripe).
+func (h *Handler) StartProductArchivalWorker(ctx context.Context) {
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
 
-		log.Printf("[Billing][ArchivalWorker] Started product archival worker")
+	log.Printf("[Billing][ArchivalWorker] Started product archival worker")
 
-		// Run immediately on startup
-		h.archiveOldMigratedProducts()
+	// Run immediately on startup
+	h.archiveOldMigratedProducts()
 
-		for range ticker.C {
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("[Billing][ArchivalWorker] stopped")
+			return
+		case <-ticker.C:
 			h.archiveOldMigratedProducts()
 		}
-	}()
+	}
 }
 
 // archiveOldMigratedProducts finds migrated products with no active subscribers and archives them
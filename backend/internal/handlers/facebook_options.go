@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PortNumber53/simple-social-thing/backend/internal/models"
+)
+
+const (
+	// Meta accepts scheduled_publish_time between 10 minutes and 30 days ahead.
+	fbNativeScheduleMinLead = 10 * time.Minute
+	fbNativeScheduleMaxLead = 30 * 24 * time.Hour
+)
+
+// fbPublishOptions are the Facebook settings for one publish call.
+type fbPublishOptions struct {
+	// Link is attached (with LinkPreview overrides) to posts without media.
+	Link        string
+	LinkPreview *models.FacebookLinkPreview
+	Unpublished bool
+	// ScheduledAt hands the post to Meta's scheduler (scheduled_publish_time) instead of publishing now.
+	ScheduledAt time.Time
+	// SkipPages are pages an earlier run of the same job already posted to.
+	SkipPages map[string]bool
+}
+
+// facebookOptionsFor resolves the post's Facebook options for a publish call; link is the variant link.
+func facebookOptionsFor(link string, opts *models.PostOptions) fbPublishOptions {
+	out := fbPublishOptions{Link: link}
+	if opts != nil && opts.Facebook != nil {
+		out.LinkPreview = opts.Facebook.LinkPreview
+		out.Unpublished = opts.Facebook.Unpublished
+	}
+	return out
+}
+
+// scheduled reports whether the call hands the post to Meta's scheduler.
+func (o fbPublishOptions) scheduled() bool {
+	return !o.ScheduledAt.IsZero()
+}
+
+// live reports whether the post is visible as soon as the call returns (and so belongs in the library).
+func (o fbPublishOptions) live() bool {
+	return !o.scheduled() && !o.Unpublished
+}
+
+// publishFields are the visibility fields shared by /feed, /photos and /videos.
+func (o fbPublishOptions) publishFields() url.Values {
+	form := url.Values{}
+	switch {
+	case o.scheduled():
+		form.Set("published", "false")
+		form.Set("scheduled_publish_time", strconv.FormatInt(o.ScheduledAt.Unix(), 10))
+	case o.Unpublished:
+		form.Set("published", "false")
+	default:
+		form.Set("published", "true")
+	}
+	return form
+}
+
+// reelFields are the finish-phase fields for Reels, which use video_state instead of published.
+func (o fbPublishOptions) reelFields() url.Values {
+	form := url.Values{}
+	switch {
+	case o.scheduled():
+		form.Set("video_state", "SCHEDULED")
+		form.Set("scheduled_publish_time", strconv.FormatInt(o.ScheduledAt.Unix(), 10))
+	case o.Unpublished:
+		form.Set("video_state", "DRAFT")
+	default:
+		form.Set("video_state", "PUBLISHED")
+	}
+	return form
+}
+
+// setLinkFields attaches the link and any preview overrides to a /feed form.
+func (o fbPublishOptions) setLinkFields(form url.Values) {
+	if o.Link == "" {
+		return
+	}
+	form.Set("link", o.Link)
+	if p := o.LinkPreview; p != nil {
+		if p.Title != "" {
+			form.Set("name", p.Title)
+		}
+		if p.Description != "" {
+			form.Set("description", p.Description)
+		}
+		if p.Image != "" {
+			form.Set("picture", p.Image)
+		}
+	}
+}
+
+// wantsFacebookNativeSchedule reports whether a saved post should be handed to Meta's scheduler.
+func wantsFacebookNativeSchedule(status string, scheduledFor *time.Time, providers []string, opts *models.PostOptions) bool {
+	if status != "scheduled" || scheduledFor == nil || opts == nil || opts.Facebook == nil || !opts.Facebook.NativeSchedule {
+		return false
+	}
+	for _, p := range providers {
+		if p == "facebook" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFacebookNativeScheduleTime rejects times Meta won't schedule.
+func checkFacebookNativeScheduleTime(at, now time.Time) error {
+	lead := at.Sub(now)
+	if lead < fbNativeScheduleMinLead || lead > fbNativeScheduleMaxLead {
+		return fmt.Errorf("facebook native scheduling needs a time between 10 minutes and 30 days ahead")
+	}
+	return nil
+}
+
+// scheduleFacebookNative publishes the post to every connected Page with scheduled_publish_time and records
+// each scheduled object so it can be cancelled later. Pages that fail are reported in details; if none
+// succeed the post is left to our own scheduler.
+func (h *Handler) scheduleFacebookNative(ctx context.Context, userID string, post models.Post) (map[string]interface{}, error) {
+	in := publishInputFor("facebook", derefString(post.Content), post.Variants, post.Media, nil)
+	var media []uploadedMedia
+	if len(in.RelMedia) > 0 {
+		files, err := loadUploadedMediaFromRelPaths(in.RelMedia)
+		if err != nil {
+			return map[string]interface{}{"error": err.Error()}, fmt.Errorf("failed_to_load_media")
+		}
+		media = files
+	}
+	opts := facebookOptionsFor(in.Link, post.Options)
+	opts.ScheduledAt = post.ScheduledFor.UTC()
+
+	posted, err, details := h.publishFacebookPagesWithOptions(ctx, userID, in.Caption, nil, media, opts, false)
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	details["scheduledPublishTime"] = opts.ScheduledAt.Format(time.RFC3339)
+	pages, _ := details["pages"].([]fbPageResult)
+	for _, p := range pages {
+		if !p.Posted || p.PostID == "" {
+			continue
+		}
+		if _, err := h.db.ExecContext(ctx, `
+			INSERT INTO public.post_native_schedules (id, post_id, user_id, provider, account_id, external_id, scheduled_for, status, created_at, updated_at)
+			VALUES ($1, $2, $3, 'facebook', $4, $5, $6, 'scheduled', NOW(), NOW())
+		`, "pns_"+randHex(12), post.ID, userID, p.PageID, p.PostID, opts.ScheduledAt); err != nil {
+			log.Printf("[FBNativeSchedule] record_failed userId=%s postId=%s pageId=%s objectId=%s err=%v", userID, post.ID, p.PageID, p.PostID, err)
+		}
+	}
+	log.Printf("[FBNativeSchedule] scheduled userId=%s postId=%s pages=%d at=%s", userID, post.ID, posted, opts.ScheduledAt.Format(time.RFC3339))
+	return details, err
+}
+
+type nativeSchedule struct {
+	ID           string
+	AccountID    string
+	ExternalID   string
+	ScheduledFor time.Time
+}
+
+// activeNativeSchedules lists a post's objects still waiting in a network's scheduler.
+func (h *Handler) activeNativeSchedules(ctx context.Context, userID, postID, provider string) ([]nativeSchedule, error) {
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT id, account_id, external_id, scheduled_for
+		  FROM public.post_native_schedules
+		 WHERE post_id = $1 AND user_id = $2 AND provider = $3 AND status = 'scheduled'
+	`, postID, userID, provider)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []nativeSchedule
+	for rows.Next() {
+		var s nativeSchedule
+		if err := rows.Scan(&s.ID, &s.AccountID, &s.ExternalID, &s.ScheduledFor); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// cancelFacebookNativeSchedules deletes a post's scheduled Facebook objects. Rows are marked cancelled or
+// cancel_failed; the error reports objects Meta would still publish. Lookup failures are only logged so a
+// database hiccup doesn't block editing or deleting the post.
+func (h *Handler) cancelFacebookNativeSchedules(ctx context.Context, userID, postID string) (int, error) {
+	scheds, err := h.activeNativeSchedules(ctx, userID, postID, "facebook")
+	if err != nil {
+		log.Printf("[FBNativeSchedule] lookup_failed userId=%s postId=%s err=%v", userID, postID, err)
+		return 0, nil
+	}
+	if len(scheds) == 0 {
+		return 0, nil
+	}
+	tokens := map[string]string{}
+	var raw []byte
+	if err := h.db.QueryRowContext(ctx, `SELECT value FROM public.user_settings WHERE user_id=$1 AND key='facebook_oauth' AND value IS NOT NULL`, userID).Scan(&raw); err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to load facebook tokens: %w", err)
+	}
+	var tok fbOAuthPayload
+	if json.Unmarshal(raw, &tok) == nil {
+		for _, p := range tok.Pages {
+			tokens[p.ID] = p.AccessToken
+		}
+		if tok.PageID != "" && tokens[tok.PageID] == "" {
+			tokens[tok.PageID] = tok.AccessToken
+		}
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	cancelled, failed := 0, 0
+	for _, s := range scheds {
+		status, errText := "cancelled", ""
+		if token := tokens[s.AccountID]; token == "" {
+			status, errText = "cancel_failed", "page_not_connected"
+		} else if err := fbDeleteObject(ctx, client, s.ExternalID, token); err != nil {
+			status, errText = "cancel_failed", err.Error()
+		}
+		if status == "cancelled" {
+			cancelled++
+		} else {
+			failed++
+			log.Printf("[FBNativeSchedule] cancel_failed userId=%s postId=%s pageId=%s objectId=%s err=%s", userID, postID, s.AccountID, s.ExternalID, errText)
+		}
+		_, _ = h.db.ExecContext(ctx, `
+			UPDATE public.post_native_schedules SET status = $2, error = NULLIF($3, ''), updated_at = NOW() WHERE id = $1
+		`, s.ID, status, truncate(errText, 400))
+	}
+	if failed > 0 {
+		return cancelled, fmt.Errorf("failed to cancel %d natively scheduled Facebook post(s)", failed)
+	}
+	return cancelled, nil
+}
+
+// fbDeleteObject deletes a Page post, photo or video; deleting a scheduled object cancels it.
+func fbDeleteObject(ctx context.Context, client *http.Client, objectID, pageToken string) error {
+	endpoint := fmt.Sprintf("https://graph.facebook.com/v24.0/%s?access_token=%s", url.PathEscape(objectID), url.QueryEscape(pageToken))
+	req, err := http.NewRequestWithContext(ctx, "DELETE", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	_ = res.Body.Close()
+	// Already gone (published and removed, or cancelled in Meta's UI) counts as cancelled.
+	if res.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("%s", extractFacebookErrorMessage(b, strings.TrimSpace(string(b))))
+	}
+	return nil
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
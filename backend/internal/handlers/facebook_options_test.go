@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PortNumber53/simple-social-thing/backend/internal/models"
+	"github.com/gorilla/mux"
+)
+
+func TestPublishFacebookPagesWithOptions_ScheduledLinkPost(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	raw, _ := json.Marshal(fbOAuthPayload{Pages: []fbOAuthPageRow{{ID: "pg1", AccessToken: "ptok"}}})
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings.*key='facebook_oauth'`).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(raw))
+
+	var feed url.Values
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(r.Body)
+		feed, _ = url.ParseQuery(string(b))
+		return httpJSON(200, `{"id":"pg1_9"}`, nil), nil
+	}}
+
+	at := time.Date(2030, 1, 2, 3, 4, 0, 0, time.UTC)
+	opts := fbPublishOptions{
+		Link:        "https://example.com/a",
+		LinkPreview: &models.FacebookLinkPreview{Title: "T", Image: "https://example.com/a.jpg"},
+		ScheduledAt: at,
+	}
+	// Scheduled posts aren't live yet, so nothing is written to the library.
+	posted, perr, _ := h.publishFacebookPagesWithOptions(context.Background(), "u1", "cap", nil, nil, opts, false)
+	if perr != nil || posted != 1 {
+		t.Fatalf("expected posted=1 got posted=%d err=%v", posted, perr)
+	}
+	if feed.Get("link") != "https://example.com/a" || feed.Get("name") != "T" || feed.Get("picture") != "https://example.com/a.jpg" || feed.Has("description") {
+		t.Fatalf("unexpected link fields %v", feed)
+	}
+	if feed.Get("published") != "false" || feed.Get("scheduled_publish_time") != "1893553440" {
+		t.Fatalf("unexpected schedule fields %v", feed)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestFBPublishImages_ScheduledChildrenAreTemporary(t *testing.T) {
+	var photos []map[string]string
+	var feed url.Values
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		if strings.HasSuffix(r.URL.Path, "/photos") {
+			_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			form, _ := multipart.NewReader(r.Body, params["boundary"]).ReadForm(1 << 20)
+			photos = append(photos, map[string]string{"published": form.Value["published"][0], "temporary": strings.Join(form.Value["temporary"], "")})
+			return httpJSON(200, `{"id":"ph"}`, nil), nil
+		}
+		b, _ := io.ReadAll(r.Body)
+		feed, _ = url.ParseQuery(string(b))
+		return httpJSON(200, `{"id":"post1"}`, nil), nil
+	}}
+
+	media := []uploadedMedia{{Filename: "a.jpg", Bytes: []byte{1}}, {Filename: "b.jpg", Bytes: []byte{2}}}
+	postID, _, _, _, err := fbPublishImages(context.Background(), &http.Client{}, "pg1", "ptok", "cap", media, fbPublishOptions{ScheduledAt: time.Unix(2000000000, 0)})
+	if err != nil || postID != "post1" {
+		t.Fatalf("expected post1, got %q err=%v", postID, err)
+	}
+	if len(photos) != 2 || photos[0]["published"] != "false" || photos[0]["temporary"] != "true" {
+		t.Fatalf("unexpected photo fields %v", photos)
+	}
+	if feed.Get("scheduled_publish_time") != "2000000000" || feed.Get("published") != "false" {
+		t.Fatalf("unexpected feed fields %v", feed)
+	}
+}
+
+func TestDeletePostForUser_CancelsNativeSchedules(t *testing.T) {
+	raw, _ := json.Marshal(fbOAuthPayload{Pages: []fbOAuthPageRow{{ID: "pg1", AccessToken: "ptok"}}})
+	expectCancel := func(mock sqlmock.Sqlmock, status string) {
+		mock.ExpectQuery(`SELECT id, account_id, external_id, scheduled_for\s+FROM public\.post_native_schedules`).
+			WithArgs("p1", "u1", "facebook").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "account_id", "external_id", "scheduled_for"}).AddRow("pns1", "pg1", "pg1_9", time.Now().Add(time.Hour)))
+		mock.ExpectQuery(`SELECT value FROM public\.user_settings.*key='facebook_oauth'`).
+			WithArgs("u1").
+			WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(raw))
+		mock.ExpectExec(`UPDATE public\.post_native_schedules SET status`).
+			WithArgs("pns1", status, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	deleteStatus := 200
+	var deleted string
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		if r.Method != "DELETE" {
+			return httpJSON(404, `{"error":{"message":"unexpected"}}`, nil), nil
+		}
+		deleted = r.URL.Path
+		if deleteStatus != 200 {
+			return httpJSON(deleteStatus, `{"error":{"message":"Permissions error"}}`, nil), nil
+		}
+		return httpJSON(200, `{"success":true}`, nil), nil
+	}}
+	run := func(h *Handler, target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodDelete, target, nil)
+		req = mux.SetURLVars(req, map[string]string{"userId": "u1", "postId": "p1"})
+		h.DeletePostForUser(rr, req)
+		return rr
+	}
+
+	// The scheduled object is deleted at Meta before the post goes.
+	{
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("sqlmock.New: %v", err)
+		}
+		defer func() { _ = db.Close() }()
+		expectCancel(mock, "cancelled")
+		mock.ExpectExec(`DELETE FROM public\.posts`).WithArgs("p1", "u1").WillReturnResult(sqlmock.NewResult(0, 1))
+		if rr := run(New(db), "/api/posts/p1/user/u1"); rr.Code != http.StatusOK || deleted != "/v24.0/pg1_9" {
+			t.Fatalf("expected 200 after cancelling, got %d path=%q body=%s", rr.Code, deleted, rr.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("sql expectations: %v", err)
+		}
+	}
+
+	// A failed cancellation keeps the post unless force=1.
+	deleteStatus = 403
+	{
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("sqlmock.New: %v", err)
+		}
+		defer func() { _ = db.Close() }()
+		expectCancel(mock, "cancel_failed")
+		if rr := run(New(db), "/api/posts/p1/user/u1"); rr.Code != http.StatusBadGateway {
+			t.Fatalf("expected 502, got %d body=%s", rr.Code, rr.Body.String())
+		}
+		expectCancel(mock, "cancel_failed")
+		mock.ExpectExec(`DELETE FROM public\.posts`).WithArgs("p1", "u1").WillReturnResult(sqlmock.NewResult(0, 1))
+		if rr := run(New(db), "/api/posts/p1/user/u1?force=1"); rr.Code != http.StatusOK {
+			t.Fatalf("expected forced delete, got %d body=%s", rr.Code, rr.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("sql expectations: %v", err)
+		}
+	}
+}
+
+func TestFacebookNativeScheduleRules(t *testing.T) {
+	now := time.Now()
+	if err := checkFacebookNativeScheduleTime(now.Add(5*time.Minute), now); err == nil {
+		t.Fatalf("expected a time under 10 minutes ahead to be rejected")
+	}
+	if err := checkFacebookNativeScheduleTime(now.Add(24*time.Hour), now); err != nil {
+		t.Fatalf("expected a day ahead to be accepted, got %v", err)
+	}
+	when := now.Add(time.Hour)
+	opts := &models.PostOptions{Facebook: &models.FacebookPostOptions{NativeSchedule: true}}
+	if !wantsFacebookNativeSchedule("scheduled", &when, []string{"instagram", "facebook"}, opts) ||
+		wantsFacebookNativeSchedule("scheduled", &when, []string{"instagram"}, opts) ||
+		wantsFacebookNativeSchedule("draft", &when, []string{"facebook"}, opts) {
+		t.Fatalf("unexpected wantsFacebookNativeSchedule results")
+	}
+	if _, err := normalizePostOptions(&models.PostOptions{Facebook: &models.FacebookPostOptions{NativeSchedule: true, Unpublished: true}}, nil); err == nil {
+		t.Fatalf("expected nativeSchedule+unpublished to be rejected")
+	}
+	if _, err := normalizePostOptions(&models.PostOptions{Facebook: &models.FacebookPostOptions{LinkPreview: &models.FacebookLinkPreview{Image: "ftp://x/a.jpg"}}}, nil); err == nil {
+		t.Fatalf("expected non-http preview image to be rejected")
+	}
+}
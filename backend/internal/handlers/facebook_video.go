@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// Vertical clips within these bounds go out as Reels; everything else is a regular Page video.
+	fbReelMinSec = 3
+	fbReelMaxSec = 90
+)
+
+// fbPageResult is the per-page outcome recorded in publishFacebookPages' details.
+type fbPageResult struct {
+	PageID     string `json:"pageId"`
+	Posted     bool   `json:"posted"`
+	PostID     string `json:"postId,omitempty"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Body       string `json:"body,omitempty"`
+	// Video posts only: "video" or "reel", the uploaded video id and its processing status.
+	Format      string `json:"format,omitempty"`
+	VideoID     string `json:"videoId,omitempty"`
+	VideoStatus string `json:"videoStatus,omitempty"`
+}
+
+// fbVideoFormat picks Reels for vertical clips of Reel length; unknown dimensions fall back to a Page video.
+func fbVideoFormat(info preflightMedia) string {
+	if info.Width > 0 && info.Height > info.Width &&
+		(info.DurationSec == 0 || (info.DurationSec >= fbReelMinSec && info.DurationSec <= fbReelMaxSec)) {
+		return "reel"
+	}
+	return "video"
+}
+
+// fbVideoPhase POSTs one step of a video upload. With a chunk the request is multipart (video_file_chunk),
+// otherwise form-encoded.
+func fbVideoPhase(ctx context.Context, client *http.Client, endpoint string, form url.Values, chunk []byte) (map[string]interface{}, int, string, string, error) {
+	var body io.Reader
+	contentType := "application/x-www-form-urlencoded"
+	if chunk != nil {
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		for k, vs := range form {
+			for _, v := range vs {
+				_ = w.WriteField(k, v)
+			}
+		}
+		fw, err := w.CreateFormFile("video_file_chunk", "chunk")
+		if err != nil {
+			_ = w.Close()
+			return nil, 0, "", err.Error(), err
+		}
+		_, _ = fw.Write(chunk)
+		_ = w.Close()
+		body = &buf
+		contentType = w.FormDataContentType()
+	} else {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, body)
+	if err != nil {
+		return nil, 0, "", err.Error(), err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, 0, "", err.Error(), err
+	}
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	_ = res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, res.StatusCode, string(b), extractFacebookErrorMessage(b, string(b)), fmt.Errorf("facebook_non_2xx")
+	}
+	var obj map[string]interface{}
+	_ = json.Unmarshal(b, &obj)
+	return obj, res.StatusCode, string(b), "", nil
+}
+
+// fbOffset reads an upload offset, which the Graph API returns as a string.
+func fbOffset(v interface{}) int64 {
+	switch t := v.(type) {
+	case string:
+		n, _ := strconv.ParseInt(t, 10, 64)
+		return n
+	case float64:
+		return int64(t)
+	}
+	return 0
+}
+
+// fbUploadPageVideo uploads a video to a Page with the resumable start/transfer/finish flow, sending the
+// chunks Facebook asks for, and publishes (or schedules) it per opts with caption as the description.
+func fbUploadPageVideo(ctx context.Context, client *http.Client, pageID, pageToken, caption string, media uploadedMedia, opts fbPublishOptions) (videoID string, status int, bodyText string, errMsg string, err error) {
+	endpoint := fmt.Sprintf("https://graph-video.facebook.com/v24.0/%s/videos", url.PathEscape(pageID))
+	size := int64(len(media.Bytes))
+
+	start, status, bodyText, errMsg, err := fbVideoPhase(ctx, client, endpoint, url.Values{
+		"access_token": {pageToken},
+		"upload_phase": {"start"},
+		"file_size":    {strconv.FormatInt(size, 10)},
+	}, nil)
+	if err != nil {
+		return "", status, bodyText, errMsg, err
+	}
+	videoID, _ = start["video_id"].(string)
+	sessionID, _ := start["upload_session_id"].(string)
+	if videoID == "" || sessionID == "" {
+		return "", status, bodyText, "missing_upload_session", fmt.Errorf("facebook_missing_upload_session")
+	}
+
+	from, to := fbOffset(start["start_offset"]), fbOffset(start["end_offset"])
+	for from < to {
+		if from < 0 || to > size {
+			return videoID, status, bodyText, "invalid_upload_offsets", fmt.Errorf("facebook_invalid_upload_offsets")
+		}
+		next, st, body, msg, err := fbVideoPhase(ctx, client, endpoint, url.Values{
+			"access_token":      {pageToken},
+			"upload_phase":      {"transfer"},
+			"upload_session_id": {sessionID},
+			"start_offset":      {strconv.FormatInt(from, 10)},
+		}, media.Bytes[from:to])
+		if err != nil {
+			return videoID, st, body, msg, err
+		}
+		nextFrom, nextTo := fbOffset(next["start_offset"]), fbOffset(next["end_offset"])
+		if nextFrom <= from && nextFrom < nextTo {
+			return videoID, st, body, "upload_stalled", fmt.Errorf("facebook_upload_stalled")
+		}
+		from, to = nextFrom, nextTo
+	}
+
+	finish := opts.publishFields()
+	finish.Set("access_token", pageToken)
+	finish.Set("upload_phase", "finish")
+	finish.Set("upload_session_id", sessionID)
+	finish.Set("description", caption)
+	_, status, bodyText, errMsg, err = fbVideoPhase(ctx, client, endpoint, finish, nil)
+	if err != nil {
+		return videoID, status, bodyText, errMsg, err
+	}
+	return videoID, status, bodyText, "", nil
+}
+
+// fbUploadReel uploads a vertical video as a Page Reel: start a session, send the bytes to rupload, then
+// finish with the video_state opts asks for (PUBLISHED, SCHEDULED or DRAFT).
+func fbUploadReel(ctx context.Context, client *http.Client, pageID, pageToken, caption string, media uploadedMedia, opts fbPublishOptions) (videoID string, status int, bodyText string, errMsg string, err error) {
+	endpoint := fmt.Sprintf("https://graph.facebook.com/v24.0/%s/video_reels", url.PathEscape(pageID))
+	start, status, bodyText, errMsg, err := fbVideoPhase(ctx, client, endpoint, url.Values{
+		"access_token": {pageToken},
+		"upload_phase": {"start"},
+	}, nil)
+	if err != nil {
+		return "", status, bodyText, errMsg, err
+	}
+	videoID, _ = start["video_id"].(string)
+	if videoID == "" {
+		return "", status, bodyText, "missing_video_id", fmt.Errorf("facebook_missing_video_id")
+	}
+	uploadURL, _ := start["upload_url"].(string)
+	if uploadURL == "" {
+		uploadURL = "https://rupload.facebook.com/video-upload/v24.0/" + url.PathEscape(videoID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", uploadURL, bytes.NewReader(media.Bytes))
+	if err != nil {
+		return videoID, 0, "", err.Error(), err
+	}
+	req.Header.Set("Authorization", "OAuth "+pageToken)
+	req.Header.Set("offset", "0")
+	req.Header.Set("file_size", strconv.Itoa(len(media.Bytes)))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	res, err := client.Do(req)
+	if err != nil {
+		return videoID, 0, "", err.Error(), err
+	}
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	_ = res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return videoID, res.StatusCode, string(b), extractFacebookErrorMessage(b, string(b)), fmt.Errorf("facebook_non_2xx")
+	}
+
+	finish := opts.reelFields()
+	finish.Set("access_token", pageToken)
+	finish.Set("upload_phase", "finish")
+	finish.Set("video_id", videoID)
+	finish.Set("description", caption)
+	_, status, bodyText, errMsg, err = fbVideoPhase(ctx, client, endpoint, finish, nil)
+	if err != nil {
+		return videoID, status, bodyText, errMsg, err
+	}
+	return videoID, status, bodyText, "", nil
+}
+
+// fbWaitForVideo polls a video until Facebook reports it ready. It returns the last video_status and, for
+// failed videos, the processing error message.
+func fbWaitForVideo(ctx context.Context, client *http.Client, videoID, pageToken string, attempts int) (string, string, error) {
+	last := ""
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return last, "", ctx.Err()
+			case <-time.After(2 * time.Second):
+			}
+		}
+		endpoint := fmt.Sprintf("https://graph.facebook.com/v24.0/%s?fields=status&access_token=%s", url.PathEscape(videoID), url.QueryEscape(pageToken))
+		req, _ := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+		req.Header.Set("Accept", "application/json")
+		res, err := client.Do(req)
+		if err != nil {
+			last = "request_error"
+			continue
+		}
+		b, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+		_ = res.Body.Close()
+		if res.StatusCode < 200 || res.StatusCode >= 300 {
+			last = fmt.Sprintf("http_%d", res.StatusCode)
+			continue
+		}
+		var sr struct {
+			Status struct {
+				VideoStatus     string `json:"video_status"`
+				ProcessingPhase struct {
+					Status string `json:"status"`
+					Error  struct {
+						Message string `json:"message"`
+					} `json:"error"`
+				} `json:"processing_phase"`
+			} `json:"status"`
+		}
+		if err := json.Unmarshal(b, &sr); err != nil {
+			last = "bad_json"
+			continue
+		}
+		last = strings.ToLower(strings.TrimSpace(sr.Status.VideoStatus))
+		if last == "ready" {
+			return last, "", nil
+		}
+		if last == "error" || sr.Status.ProcessingPhase.Status == "error" {
+			return "error", sr.Status.ProcessingPhase.Error.Message, fmt.Errorf("facebook_video_processing_failed")
+		}
+	}
+	return last, "", fmt.Errorf("facebook_video_not_ready")
+}
+
+// publishFacebookPageVideo uploads video to one page as a Page video or Reel (format) and waits for processing.
+// A video still processing when polling gives up counts as posted: Facebook publishes it once it is ready.
+// Scheduled and unpublished videos are left out of the library until they go live.
+func (h *Handler) publishFacebookPageVideo(ctx context.Context, userID string, page fbOAuthPageRow, caption string, video uploadedMedia, format string, opts fbPublishOptions) fbPageResult {
+	out := fbPageResult{PageID: page.ID, Format: format}
+	client := &http.Client{Timeout: 10 * time.Minute}
+	upload := fbUploadPageVideo
+	if format == "reel" {
+		upload = fbUploadReel
+	}
+	videoID, status, bodyText, errMsg, err := upload(ctx, client, page.ID, page.AccessToken, caption, video, opts)
+	out.VideoID, out.StatusCode = videoID, status
+	if err != nil {
+		out.Error, out.Body = errMsg, truncate(bodyText, 1200)
+		log.Printf("[FBPublish] video_failed userId=%s pageId=%s format=%s status=%d err=%s", userID, page.ID, format, status, truncate(errMsg, 400))
+		return out
+	}
+
+	st, detail, err := fbWaitForVideo(ctx, client, videoID, page.AccessToken, 90)
+	out.VideoStatus = st
+	if err != nil && st == "error" {
+		out.Error = "facebook_video_processing_failed"
+		if detail != "" {
+			out.Error += ": " + truncate(detail, 300)
+		}
+		log.Printf("[FBPublish] video_processing_failed userId=%s pageId=%s videoId=%s detail=%s", userID, page.ID, videoID, truncate(detail, 300))
+		return out
+	}
+	out.Posted, out.PostID = true, videoID
+	log.Printf("[FBPublish] ok userId=%s pageId=%s videoId=%s format=%s videoStatus=%s live=%v", userID, page.ID, videoID, format, st, opts.live())
+	if !opts.live() {
+		return out
+	}
+
+	rawPayload := strings.ReplaceAll(bodyText, "\x00", "")
+	if !utf8.ValidString(rawPayload) {
+		rawPayload = strings.ToValidUTF8(rawPayload, "�")
+	}
+	if !json.Valid([]byte(rawPayload)) {
+		rawPayload = "{}"
+	}
+	_, _ = h.db.ExecContext(ctx, `
+		INSERT INTO public.social_libraries
+		  (id, user_id, network, content_type, title, permalink_url, media_url, thumbnail_url, posted_at, views, likes, raw_payload, external_id, created_at, updated_at)
+		VALUES
+		  ($1, $2, 'facebook', $3, NULLIF($4,''), NULL, NULL, NULL, NOW(), NULL, NULL, $5::jsonb, $6, NOW(), NOW())
+		ON CONFLICT (user_id, network, external_id)
+		DO UPDATE SET
+		  title = EXCLUDED.title,
+		  raw_payload = EXCLUDED.raw_payload,
+		  updated_at = NOW()
+	`, fmt.Sprintf("facebook:%s:%s", userID, videoID), userID, format, caption, rawPayload, videoID)
+	return out
+}
@@ -10,6 +10,7 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
@@ -37,6 +38,7 @@ import (
 	"github.com/PortNumber53/simple-social-thing/backend/internal/models"
 	"github.com/PortNumber53/simple-social-thing/backend/internal/socialimport"
 	"github.com/PortNumber53/simple-social-thing/backend/internal/socialimport/providers"
+	"github.com/PortNumber53/simple-social-thing/backend/internal/workers"
 	"github.com/golang/freetype"
 	"github.com/golang/freetype/truetype"
 	"github.com/gorilla/mux"
@@ -48,6 +50,12 @@ type Handler struct {
 	db          *sql.DB
 	rt          *realtimeHub
 	googleOAuth *GoogleOAuthConfig
+	// publishWake nudges idle publish job workers when a job is enqueued on this instance.
+	publishWake chan struct{}
+	// leader elects which instance runs each singleton background worker (nil: not configured).
+	leader *workers.Leader
+	// fanout relays realtime events to the other API instances via Postgres NOTIFY (nil: local only).
+	fanout *realtimeFanout
 }
 
 type userSetting struct {
@@ -56,7 +64,7 @@ type userSetting struct {
 }
 
 func New(db *sql.DB) *Handler {
-	return &Handler{db: db, rt: newRealtimeHub()}
+	return &Handler{db: db, rt: newRealtimeHub(), publishWake: make(chan struct{}, 1)}
 }
 
 // SetGoogleOAuth configures the Google OAuth settings for login.
@@ -68,57 +76,6 @@ func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
 }
 
-// StartNotificationCleanupWorker starts a background job that removes read notifications after the configured retention period.
-// retentionHours: how long to keep read notifications (default: 24)
-// checkIntervalMs: how often to run cleanup (default: 3600000 = 1 hour)
-func (h *Handler) StartNotificationCleanupWorker(ctx context.Context, retentionHours int, checkIntervalMs int) {
-	if h == nil || h.db == nil {
-		return
-	}
-	if retentionHours <= 0 {
-		retentionHours = 24
-	}
-	if checkIntervalMs <= 0 {
-		checkIntervalMs = 3600000 // 1 hour
-	}
-
-	ticker := time.NewTicker(time.Duration(checkIntervalMs) * time.Millisecond)
-	defer ticker.Stop()
-
-	log.Printf("[NotificationCleanup] worker started (retention=%dh, interval=%dms)", retentionHours, checkIntervalMs)
-
-	cleanup := func() {
-		cutoffTime := time.Now().Add(-time.Duration(retentionHours) * time.Hour)
-		result, err := h.db.ExecContext(ctx, `
-			DELETE FROM public.notifications
-			WHERE read_at IS NOT NULL
-			AND read_at < $1
-		`, cutoffTime)
-		if err != nil {
-			log.Printf("[NotificationCleanup] error: %v", err)
-			return
-		}
-		deleted, err := result.RowsAffected()
-		if err != nil {
-			log.Printf("[NotificationCleanup] error getting rows affected: %v", err)
-			return
-		}
-		if deleted > 0 {
-			log.Printf("[NotificationCleanup] deleted %d old read notifications", deleted)
-		}
-	}
-
-	for {
-		select {
-		case <-ctx.Done():
-			log.Printf("[NotificationCleanup] worker stopped")
-			return
-		case <-ticker.C:
-			cleanup()
-		}
-	}
-}
-
 func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	var user models.User
 	if err := decodeJSON(r, &user); err != nil {
@@ -494,6 +451,75 @@ type createOrUpdatePostRequest struct {
 	Media        []string   `json:"media,omitempty"`
 	ScheduledFor *time.Time `json:"scheduledFor,omitempty"`
 	PublishedAt  *time.Time `json:"publishedAt,omitempty"`
+	// ScheduledForLocal is a wall-clock time without offset ("2026-03-09T09:00") interpreted in Timezone, or
+	// the user's scheduling timezone when Timezone is empty. It takes precedence over ScheduledFor.
+	ScheduledForLocal *string `json:"scheduledForLocal,omitempty"`
+	Timezone          *string `json:"timezone,omitempty"`
+	// Recurrence makes a scheduled post repeat (anchored at scheduledFor); {"freq":"none"} removes it on update.
+	Recurrence *models.PostRecurrence `json:"recurrence,omitempty"`
+	// Variants replaces the per-provider overrides (omitted: no change on update, {}: remove them all).
+	Variants map[string]models.PostVariant `json:"variants,omitempty"`
+	// Options replaces the network-specific settings (omitted: no change on update, {}: remove them all).
+	Options *models.PostOptions `json:"options,omitempty"`
+	// SkipPreflight creates a scheduled post even when preflight finds errors.
+	SkipPreflight bool `json:"skipPreflight,omitempty"`
+}
+
+// writeScheduleResolveError reports a resolvePostSchedule failure (400 for bad input, 500 otherwise).
+func writeScheduleResolveError(w http.ResponseWriter, err error) {
+	var inputErr scheduleInputError
+	if errors.As(err, &inputErr) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
+
+// normalizePostProviders trims, lowercases and dedupes providers, dropping unknown ones.
+func normalizePostProviders(in []string) []string {
+	out := make([]string, 0, len(in))
+	seen := map[string]bool{}
+	for _, p := range in {
+		pp := strings.TrimSpace(strings.ToLower(p))
+		if pp == "" || seen[pp] {
+			continue
+		}
+		switch pp {
+		case "instagram", "tiktok", "facebook", "youtube", "pinterest", "threads", "x":
+			seen[pp] = true
+			out = append(out, pp)
+		default:
+			// ignore unknown providers to keep behavior tolerant
+		}
+	}
+	return out
+}
+
+// normalizePostMedia dedupes media and only keeps `/media/...` rel paths our backend can serve publicly.
+func normalizePostMedia(in []string) []string {
+	out := make([]string, 0, len(in))
+	seen := map[string]bool{}
+	for _, m := range in {
+		mm := strings.TrimSpace(m)
+		if mm == "" || seen[mm] || !strings.HasPrefix(mm, "/media/") {
+			continue
+		}
+		seen[mm] = true
+		out = append(out, mm)
+	}
+	return out
+}
+
+// postRecurrenceFromJSON decodes a posts.recurrence column (nil when unset or unreadable).
+func postRecurrenceFromJSON(raw []byte) *models.PostRecurrence {
+	if len(raw) == 0 {
+		return nil
+	}
+	var rule models.PostRecurrence
+	if err := json.Unmarshal(raw, &rule); err != nil {
+		return nil
+	}
+	return &rule
 }
 
 type uploadItem struct {
@@ -1057,6 +1083,8 @@ func (h *Handler) DeleteSocialLibrariesForUser(w http.ResponseWriter, r *http.Re
 				derr = h.deletePinterestPin(r.Context(), userID, externalID)
 			case "youtube":
 				derr = h.deleteYouTubeVideo(r.Context(), userID, externalID)
+			case "x":
+				derr = h.deleteXPost(r.Context(), userID, externalID)
 			default:
 				derr = fmt.Errorf("unsupported_network")
 			}
@@ -1204,7 +1232,7 @@ func (h *Handler) SyncSocialLibrariesForUser(w http.ResponseWriter, r *http.Requ
 		}
 	}
 
-	runner := &socialimport.Runner{DB: h.db, Logger: log.Default()}
+	runner := &socialimport.Runner{DB: h.db, Logger: log.Default(), OnProgress: h.EmitImportProgress}
 	results := runner.SyncAll(ctx, userID, selected)
 	for _, rr := range results {
 		resp.Providers[rr.Provider] = providerResult{
@@ -1392,7 +1420,7 @@ func (h *Handler) ListPostsForUser(w http.ResponseWriter, r *http.Request) {
 			        COALESCE(media, ARRAY[]::text[]),
 			        scheduled_for, published_at,
 			        last_publish_job_id, last_publish_status, last_publish_error, last_publish_attempt_at,
-			        created_at, updated_at
+			        created_at, updated_at, recurrence, variants, options
 			 FROM public.posts
 			 WHERE user_id = $1 AND status = $2
 			 ORDER BY created_at DESC
@@ -1405,7 +1433,7 @@ func (h *Handler) ListPostsForUser(w http.ResponseWriter, r *http.Request) {
 			        COALESCE(media, ARRAY[]::text[]),
 			        scheduled_for, published_at,
 			        last_publish_job_id, last_publish_status, last_publish_error, last_publish_attempt_at,
-			        created_at, updated_at
+			        created_at, updated_at, recurrence, variants, options
 			 FROM public.posts
 			 WHERE user_id = $1
 			 ORDER BY created_at DESC
@@ -1421,16 +1449,20 @@ func (h *Handler) ListPostsForUser(w http.ResponseWriter, r *http.Request) {
 
 	for rows.Next() {
 		var p models.Post
+		var recurrence, variants, options []byte
 		if err := rows.Scan(
 			&p.ID, &p.TeamID, &p.UserID, &p.Content, &p.Status, pq.Array(&p.Providers),
 			pq.Array(&p.Media),
 			&p.ScheduledFor, &p.PublishedAt,
 			&p.LastPublishJobID, &p.LastPublishStatus, &p.LastPublishError, &p.LastPublishAttemptAt,
-			&p.CreatedAt, &p.UpdatedAt,
+			&p.CreatedAt, &p.UpdatedAt, &recurrence, &variants, &options,
 		); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		p.Recurrence = postRecurrenceFromJSON(recurrence)
+		p.Variants = postVariantsFromJSON(variants)
+		p.Options = postOptionsFromJSON(options)
 		posts = append(posts, p)
 	}
 	if err := rows.Err(); err != nil {
@@ -1458,6 +1490,10 @@ func (h *Handler) CreatePostForUser(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "invalid json body")
 		return
 	}
+	if err := h.resolvePostSchedule(r.Context(), userID, &req); err != nil {
+		writeScheduleResolveError(w, err)
+		return
+	}
 
 	id := ""
 	if req.ID != nil {
@@ -1486,20 +1522,7 @@ func (h *Handler) CreatePostForUser(w http.ResponseWriter, r *http.Request) {
 	// Normalize provider list (trim+lowercase+dedupe) and validate for scheduled posts.
 	var providersList []string
 	if req.Providers != nil {
-		seen := map[string]bool{}
-		for _, p := range req.Providers {
-			pp := strings.TrimSpace(strings.ToLower(p))
-			if pp == "" || seen[pp] {
-				continue
-			}
-			switch pp {
-			case "instagram", "tiktok", "facebook", "youtube", "pinterest", "threads":
-				seen[pp] = true
-				providersList = append(providersList, pp)
-			default:
-				// ignore unknown providers to keep behavior tolerant
-			}
-		}
+		providersList = normalizePostProviders(req.Providers)
 	}
 	if status == "scheduled" && len(providersList) == 0 {
 		writeError(w, http.StatusBadRequest, "providers is required when status=scheduled")
@@ -1507,22 +1530,9 @@ func (h *Handler) CreatePostForUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Normalize media rel paths (only accept `/media/...` rel paths) and validate requirements.
-	mediaList := make([]string, 0, len(req.Media))
-	seenMedia := map[string]bool{}
-	for _, m := range req.Media {
-		mm := strings.TrimSpace(m)
-		if mm == "" || seenMedia[mm] {
-			continue
-		}
-		// Only store rel paths that our backend can serve publicly.
-		if !strings.HasPrefix(mm, "/media/") {
-			continue
-		}
-		seenMedia[mm] = true
-		mediaList = append(mediaList, mm)
-	}
+	mediaList := normalizePostMedia(req.Media)
 	// Only validate media requirement for providers that strictly require it
-	// Facebook and Threads allow text-only posts
+	// Facebook, Threads and X allow text-only posts
 	if status == "scheduled" && len(mediaList) == 0 {
 		for _, p := range providersList {
 			switch p {
@@ -1533,29 +1543,116 @@ func (h *Handler) CreatePostForUser(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Recurring posts start at scheduledFor; scheduled_for then always holds the next occurrence.
+	scheduledFor := req.ScheduledFor
+	var recurrenceArg interface{}
+	if req.Recurrence != nil && !strings.EqualFold(strings.TrimSpace(req.Recurrence.Freq), "none") {
+		if status != "scheduled" {
+			writeError(w, http.StatusBadRequest, "recurrence requires status=scheduled")
+			return
+		}
+		first, raw, err := prepareRecurrence(req.Recurrence, *req.ScheduledFor)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		scheduledFor = &first
+		recurrenceArg = raw
+	}
+
+	var variantsArg interface{}
+	var postVariants map[string]models.PostVariant
+	if len(req.Variants) > 0 {
+		variants, err := normalizePostVariants(req.Variants, mediaList)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if len(variants) > 0 {
+			raw, _ := json.Marshal(variants)
+			variantsArg = string(raw)
+			postVariants = variants
+		}
+	}
+	var optionsArg interface{}
+	options, err := normalizePostOptions(req.Options, mediaList)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if options != nil {
+		raw, _ := json.Marshal(options)
+		optionsArg = string(raw)
+	}
+	nativeSchedule := wantsFacebookNativeSchedule(status, scheduledFor, providersList, options)
+	if nativeSchedule {
+		if recurrenceArg != nil {
+			writeError(w, http.StatusBadRequest, "facebook native scheduling can't be combined with recurrence")
+			return
+		}
+		if err := checkFacebookNativeScheduleTime(*scheduledFor, time.Now()); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	// Scheduled posts are checked against each network's limits now rather than failing at publish time.
+	var preflight *preflightReport
+	if status == "scheduled" {
+		content := ""
+		if req.Content != nil {
+			content = *req.Content
+		}
+		report := preflightPost(content, providersList, mediaList, postVariants, options)
+		if !report.OK && !req.SkipPreflight {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{"ok": false, "error": "preflight_failed", "preflight": report})
+			return
+		}
+		preflight = &report
+	}
+
 	var out models.Post
+	var recurrence, variants, optionsRaw []byte
 	query := `
-		INSERT INTO public.posts (id, team_id, user_id, content, status, providers, media, scheduled_for, published_at, created_at, updated_at)
-		VALUES ($1, NULL, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW())
+		INSERT INTO public.posts (id, team_id, user_id, content, status, providers, media, scheduled_for, published_at, recurrence, variants, options, created_at, updated_at)
+		VALUES ($1, NULL, $2, $3, $4, $5, $6, $7, $8, $9::jsonb, $10::jsonb, $11::jsonb, NOW(), NOW())
 		RETURNING id, COALESCE(team_id,''), user_id, content, status, COALESCE(providers, ARRAY[]::text[]), COALESCE(media, ARRAY[]::text[]),
 		          scheduled_for, published_at,
 		          last_publish_job_id, last_publish_status, last_publish_error, last_publish_attempt_at,
-		          created_at, updated_at
+		          created_at, updated_at, recurrence, variants, options
 	`
-	err := h.db.QueryRow(query, id, userID, req.Content, status, pq.Array(providersList), pq.Array(mediaList), req.ScheduledFor, req.PublishedAt).
+	err = h.db.QueryRow(query, id, userID, req.Content, status, pq.Array(providersList), pq.Array(mediaList), scheduledFor, req.PublishedAt, recurrenceArg, variantsArg, optionsArg).
 		Scan(
 			&out.ID, &out.TeamID, &out.UserID, &out.Content, &out.Status, pq.Array(&out.Providers),
 			pq.Array(&out.Media),
 			&out.ScheduledFor, &out.PublishedAt,
 			&out.LastPublishJobID, &out.LastPublishStatus, &out.LastPublishError, &out.LastPublishAttemptAt,
-			&out.CreatedAt, &out.UpdatedAt,
+			&out.CreatedAt, &out.UpdatedAt, &recurrence, &variants, &optionsRaw,
 		)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	out.Recurrence = postRecurrenceFromJSON(recurrence)
+	out.Variants = postVariantsFromJSON(variants)
+	out.Options = postOptionsFromJSON(optionsRaw)
 
-	writeJSON(w, http.StatusOK, out)
+	// Hand the Facebook part to Meta's scheduler; if that fails our own scheduler still publishes it.
+	var native map[string]interface{}
+	if nativeSchedule {
+		details, err := h.scheduleFacebookNative(r.Context(), userID, out)
+		native = details
+		if err != nil {
+			native["error"] = err.Error()
+			log.Printf("[FBNativeSchedule] schedule_failed userId=%s postId=%s err=%v", userID, out.ID, err)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		models.Post
+		Preflight      *preflightReport       `json:"preflight,omitempty"`
+		NativeSchedule map[string]interface{} `json:"nativeSchedule,omitempty"`
+	}{out, preflight, native})
 }
 
 // UpdatePostForUser updates a local post for a given user.
@@ -1576,6 +1673,10 @@ func (h *Handler) UpdatePostForUser(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "invalid json body")
 		return
 	}
+	if err := h.resolvePostSchedule(r.Context(), userID, &req); err != nil {
+		writeScheduleResolveError(w, err)
+		return
+	}
 
 	if req.Status != nil {
 		s := strings.TrimSpace(*req.Status)
@@ -1596,45 +1697,100 @@ func (h *Handler) UpdatePostForUser(w http.ResponseWriter, r *http.Request) {
 	// Normalize provider list (trim+lowercase+dedupe). If field omitted, keep nil to represent "no change".
 	var providersArg interface{} = nil
 	if req.Providers != nil {
-		next := make([]string, 0, len(req.Providers))
-		seen := map[string]bool{}
-		for _, p := range req.Providers {
-			pp := strings.TrimSpace(strings.ToLower(p))
-			if pp == "" || seen[pp] {
-				continue
-			}
-			switch pp {
-			case "instagram", "tiktok", "facebook", "youtube", "pinterest", "threads":
-				seen[pp] = true
-				next = append(next, pp)
-			default:
-				// ignore unknown providers
-			}
-		}
-		providersArg = pq.Array(next)
+		providersArg = pq.Array(normalizePostProviders(req.Providers))
 	}
 
 	// Normalize media rel paths. If field omitted, keep nil to represent "no change".
 	var mediaArg interface{} = nil
 	if req.Media != nil {
-		next := make([]string, 0, len(req.Media))
-		seen := map[string]bool{}
-		for _, m := range req.Media {
-			mm := strings.TrimSpace(m)
-			if mm == "" || seen[mm] {
-				continue
+		mediaArg = pq.Array(normalizePostMedia(req.Media))
+	}
+
+	// Recurrence: omitted keeps the current rule, {"freq":"none"} removes it, anything else replaces it
+	// (anchored at scheduledFor, which must be sent along).
+	scheduledFor := req.ScheduledFor
+	var recurrenceArg interface{} = nil
+	if req.Recurrence != nil {
+		if strings.EqualFold(strings.TrimSpace(req.Recurrence.Freq), "none") {
+			recurrenceArg = "null"
+		} else {
+			if req.ScheduledFor == nil {
+				writeError(w, http.StatusBadRequest, "scheduledFor is required when recurrence is set")
+				return
 			}
-			if !strings.HasPrefix(mm, "/media/") {
-				continue
+			// Same rule as create: only scheduled posts repeat.
+			status := ""
+			if req.Status != nil {
+				status = strings.TrimSpace(*req.Status)
+			} else if err := h.db.QueryRowContext(r.Context(), `SELECT status FROM public.posts WHERE id = $1 AND user_id = $2`, postID, userID).Scan(&status); err != nil {
+				if err == sql.ErrNoRows {
+					writeError(w, http.StatusNotFound, "not found")
+					return
+				}
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			if status != "scheduled" {
+				writeError(w, http.StatusBadRequest, "recurrence requires status=scheduled")
+				return
 			}
-			seen[mm] = true
-			next = append(next, mm)
+			first, raw, err := prepareRecurrence(req.Recurrence, *req.ScheduledFor)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			scheduledFor = &first
+			recurrenceArg = raw
+		}
+	}
+
+	// Variants: omitted keeps them, {} removes them, anything else replaces the whole map.
+	var variantsArg interface{} = nil
+	if req.Variants != nil {
+		var postMedia []string
+		if req.Media != nil {
+			postMedia = normalizePostMedia(req.Media)
+		}
+		variants, err := normalizePostVariants(req.Variants, postMedia)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		raw, _ := json.Marshal(variants)
+		variantsArg = string(raw)
+	}
+
+	// Options: same rules as variants.
+	var optionsArg interface{} = nil
+	if req.Options != nil {
+		var postMedia []string
+		if req.Media != nil {
+			postMedia = normalizePostMedia(req.Media)
+		}
+		options, err := normalizePostOptions(req.Options, postMedia)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		optionsArg = "{}"
+		if options != nil {
+			raw, _ := json.Marshal(options)
+			optionsArg = string(raw)
 		}
-		mediaArg = pq.Array(next)
 	}
 
 	var out models.Post
-	clearPublishState := req.Content != nil || req.Status != nil || req.ScheduledFor != nil || req.Providers != nil || req.Media != nil
+	var recurrence, variants, optionsRaw []byte
+	// Edits reset the publish state so the post can go out again, except on a post that is and stays
+	// published: its copies are live and keep their job (retract and update-remote act on them).
+	clearPublishState := req.Content != nil || req.Status != nil || req.ScheduledFor != nil || req.Providers != nil || req.Media != nil || req.Recurrence != nil || req.Variants != nil || req.Options != nil
+	if clearPublishState {
+		// Copies already handed to Meta's scheduler would go out unedited; withdraw them before saving.
+		if _, err := h.cancelFacebookNativeSchedules(r.Context(), userID, postID); err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+	}
 	query := `
 		UPDATE public.posts
 		SET
@@ -1644,24 +1800,28 @@ func (h *Handler) UpdatePostForUser(w http.ResponseWriter, r *http.Request) {
 			published_at = COALESCE($6, published_at),
 			providers = COALESCE($7::text[], providers),
 			media = COALESCE($8::text[], media),
-			last_publish_job_id = CASE WHEN $9 THEN NULL ELSE last_publish_job_id END,
-			last_publish_status = CASE WHEN $9 THEN NULL ELSE last_publish_status END,
-			last_publish_error = CASE WHEN $9 THEN NULL ELSE last_publish_error END,
-			last_publish_attempt_at = CASE WHEN $9 THEN NULL ELSE last_publish_attempt_at END,
+			last_publish_job_id = CASE WHEN $9 AND NOT (status = 'published' AND COALESCE($4, status) = 'published') THEN NULL ELSE last_publish_job_id END,
+			last_publish_status = CASE WHEN $9 AND NOT (status = 'published' AND COALESCE($4, status) = 'published') THEN NULL ELSE last_publish_status END,
+			last_publish_error = CASE WHEN $9 AND NOT (status = 'published' AND COALESCE($4, status) = 'published') THEN NULL ELSE last_publish_error END,
+			last_publish_attempt_at = CASE WHEN $9 AND NOT (status = 'published' AND COALESCE($4, status) = 'published') THEN NULL ELSE last_publish_attempt_at END,
+			recurrence = CASE WHEN $10::jsonb IS NULL THEN recurrence ELSE NULLIF($10::jsonb, 'null'::jsonb) END,
+			in_queue = CASE WHEN $5::timestamptz IS NOT NULL OR COALESCE($4, status) <> 'scheduled' THEN FALSE ELSE in_queue END,
+			variants = CASE WHEN $11::jsonb IS NULL THEN variants ELSE NULLIF($11::jsonb, '{}'::jsonb) END,
+			options = CASE WHEN $12::jsonb IS NULL THEN options ELSE NULLIF($12::jsonb, '{}'::jsonb) END,
 			updated_at = NOW()
 		WHERE id = $1 AND user_id = $2
 		RETURNING id, COALESCE(team_id,''), user_id, content, status, COALESCE(providers, ARRAY[]::text[]), COALESCE(media, ARRAY[]::text[]),
 		          scheduled_for, published_at,
 		          last_publish_job_id, last_publish_status, last_publish_error, last_publish_attempt_at,
-		          created_at, updated_at
+		          created_at, updated_at, recurrence, variants, options
 	`
-	err := h.db.QueryRow(query, postID, userID, req.Content, req.Status, req.ScheduledFor, req.PublishedAt, providersArg, mediaArg, clearPublishState).
+	err := h.db.QueryRow(query, postID, userID, req.Content, req.Status, scheduledFor, req.PublishedAt, providersArg, mediaArg, clearPublishState, recurrenceArg, variantsArg, optionsArg).
 		Scan(
 			&out.ID, &out.TeamID, &out.UserID, &out.Content, &out.Status, pq.Array(&out.Providers),
 			pq.Array(&out.Media),
 			&out.ScheduledFor, &out.PublishedAt,
 			&out.LastPublishJobID, &out.LastPublishStatus, &out.LastPublishError, &out.LastPublishAttemptAt,
-			&out.CreatedAt, &out.UpdatedAt,
+			&out.CreatedAt, &out.UpdatedAt, &recurrence, &variants, &optionsRaw,
 		)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -1671,8 +1831,41 @@ func (h *Handler) UpdatePostForUser(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	out.Recurrence = postRecurrenceFromJSON(recurrence)
+	out.Variants = postVariantsFromJSON(variants)
+	out.Options = postOptionsFromJSON(optionsRaw)
+	if req.Recurrence != nil {
+		// Skips/edits were made against the old rule's slots; drop the ones that haven't run yet.
+		_, _ = h.db.Exec(`
+			DELETE FROM public.post_occurrences
+			 WHERE post_id = $1 AND user_id = $2 AND status IN ('pending', 'skipped')
+		`, postID, userID)
+	}
+	if req.Status != nil || req.ScheduledFor != nil {
+		// A post that left the posting queue (picked an exact time or unscheduled) frees its slot.
+		if _, err := h.reshufflePostingQueue(r.Context(), userID); err != nil {
+			log.Printf("[PostingQueue] reshuffle_failed userId=%s err=%v", userID, err)
+		}
+	}
 
-	writeJSON(w, http.StatusOK, out)
+	var native map[string]interface{}
+	if clearPublishState && out.Recurrence == nil && wantsFacebookNativeSchedule(out.Status, out.ScheduledFor, out.Providers, out.Options) {
+		if err := checkFacebookNativeScheduleTime(*out.ScheduledFor, time.Now()); err != nil {
+			native = map[string]interface{}{"error": err.Error()}
+		} else {
+			details, err := h.scheduleFacebookNative(r.Context(), userID, out)
+			native = details
+			if err != nil {
+				native["error"] = err.Error()
+				log.Printf("[FBNativeSchedule] schedule_failed userId=%s postId=%s err=%v", userID, out.ID, err)
+			}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		models.Post
+		NativeSchedule map[string]interface{} `json:"nativeSchedule,omitempty"`
+	}{out, native})
 }
 
 // DeletePostForUser deletes a local post for a given user.
@@ -1688,6 +1881,12 @@ func (h *Handler) DeletePostForUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Withdraw copies waiting in Meta's scheduler first; ?force=1 deletes the post even if that fails.
+	if _, err := h.cancelFacebookNativeSchedules(r.Context(), userID, postID); err != nil && r.URL.Query().Get("force") != "1" {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
 	res, err := h.db.Exec(`DELETE FROM public.posts WHERE id = $1 AND user_id = $2`, postID, userID)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
@@ -1698,6 +1897,9 @@ func (h *Handler) DeletePostForUser(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, "not found")
 		return
 	}
+	if _, err := h.reshufflePostingQueue(r.Context(), userID); err != nil {
+		log.Printf("[PostingQueue] reshuffle_failed userId=%s err=%v", userID, err)
+	}
 
 	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
 }
@@ -1726,6 +1928,8 @@ func (h *Handler) publishScheduledPostNowOnce(ctx context.Context, origin, postI
 		providers       []string
 		media           []string
 		newScheduledFor time.Time
+		variants        []byte
+		options         []byte
 	)
 	err := h.db.QueryRowContext(ctx, `
 		UPDATE public.posts
@@ -1740,8 +1944,9 @@ func (h *Handler) publishScheduledPostNowOnce(ctx context.Context, origin, postI
 		   AND status = 'scheduled'
 		   AND published_at IS NULL
 		   AND last_publish_job_id IS NULL
-		RETURNING content, COALESCE(providers, ARRAY[]::text[]), COALESCE(media, ARRAY[]::text[]), scheduled_for
-	`, postID, userID, jobID).Scan(&content, pq.Array(&providers), pq.Array(&media), &newScheduledFor)
+		   AND recurrence IS NULL
+		RETURNING content, COALESCE(providers, ARRAY[]::text[]), COALESCE(media, ARRAY[]::text[]), scheduled_for, variants, options
+	`, postID, userID, jobID).Scan(&content, pq.Array(&providers), pq.Array(&media), &newScheduledFor, &variants, &options)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return "", sql.ErrNoRows
@@ -1796,6 +2001,12 @@ func (h *Handler) publishScheduledPostNowOnce(ctx context.Context, origin, postI
 		"scheduledFor": newScheduledFor.UTC().Format(time.RFC3339),
 		"publicOrigin": origin,
 	}
+	if v := postVariantsFromJSON(variants); v != nil {
+		reqSnapshot["variants"] = v
+	}
+	if o := postOptionsFromJSON(options); o != nil {
+		reqSnapshot["options"] = o
+	}
 	reqJSON, _ := json.Marshal(reqSnapshot)
 	now := time.Now()
 
@@ -1840,7 +2051,7 @@ func (h *Handler) PublishNowPostForUser(w http.ResponseWriter, r *http.Request)
 	origin := publicOrigin(r)
 	log.Printf("[PublishNow] request userId=%s postId=%s origin=%s", userID, postID, origin)
 	jobID, err := h.publishScheduledPostNowOnce(r.Context(), origin, postID, userID, func(jobID, userID, caption string, providers []string, relMedia []string) {
-		go h.runPublishJob(jobID, userID, caption, publishPostRequest{Providers: providers}, relMedia, origin)
+		h.notifyPublishWorkers()
 	})
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -3169,6 +3380,10 @@ type publishPostRequest struct {
 	// Optional: restrict Facebook publishing to a subset of page IDs.
 	FacebookPageIDs []string `json:"facebookPageIds"`
 	DryRun          bool     `json:"dryRun"`
+	// Variants overrides caption/title/link/media/first comment per provider (snapshotted from the post).
+	Variants map[string]models.PostVariant `json:"variants,omitempty"`
+	// Options carries network-specific settings such as Instagram user tags (snapshotted from the post).
+	Options *models.PostOptions `json:"options,omitempty"`
 }
 
 type publishProviderResult struct {
@@ -3176,6 +3391,13 @@ type publishProviderResult struct {
 	Posted  int                    `json:"posted,omitempty"`
 	Error   string                 `json:"error,omitempty"`
 	Details map[string]interface{} `json:"details,omitempty"`
+	// Attempts is how many times the provider was called in the run that produced this result.
+	Attempts int `json:"attempts,omitempty"`
+	// Resumed marks a result carried over from an earlier run of the same job (the provider was not called again).
+	Resumed bool `json:"resumed,omitempty"`
+	// ManualAction marks a partial post that re-runs can't safely complete (the provider has no way to resume
+	// where it stopped), so it is left as it was instead of being posted again.
+	ManualAction bool `json:"manualAction,omitempty"`
 }
 
 type publishJob struct {
@@ -3193,8 +3415,8 @@ type publishJob struct {
 	UpdatedAt  time.Time
 }
 
-// PublishSocialPostForUser publishes a caption-only post to one or more connected networks.
-// For now, only Facebook Page posts are implemented; other providers return not_supported.
+// PublishSocialPostForUser publishes a post to one or more connected networks synchronously.
+// Facebook, Instagram, Threads and X are implemented; other providers return not_supported_yet.
 func (h *Handler) PublishSocialPostForUser(w http.ResponseWriter, r *http.Request) {
 	if !requireMethod(w, r, http.MethodPost) {
 		return
@@ -3237,6 +3459,7 @@ func (h *Handler) PublishSocialPostForUser(w http.ResponseWriter, r *http.Reques
 		want["youtube"] = true
 		want["pinterest"] = true
 		want["threads"] = true
+		want["x"] = true
 	}
 
 	start := time.Now()
@@ -3247,7 +3470,7 @@ func (h *Handler) PublishSocialPostForUser(w http.ResponseWriter, r *http.Reques
 
 	// Facebook: Post to all saved pages in facebook_oauth using page access tokens.
 	if want["facebook"] {
-		posted, err, details := h.publishFacebookPages(r.Context(), userID, caption, req.FacebookPageIDs, mediaFiles, req.DryRun)
+		posted, err, details := h.publishFacebookPagesWithOptions(r.Context(), userID, caption, req.FacebookPageIDs, mediaFiles, facebookOptionsFor("", req.Options), req.DryRun)
 		if err != nil {
 			results["facebook"] = publishProviderResult{OK: false, Posted: posted, Error: err.Error(), Details: details}
 			overallOK = false
@@ -3267,8 +3490,37 @@ func (h *Handler) PublishSocialPostForUser(w http.ResponseWriter, r *http.Reques
 		}
 	}
 
+	// Threads: uploads are stored first so Threads can fetch them from their public URLs.
+	if want["threads"] {
+		relMedia, _, err := saveUploadedMedia(userID, "", mediaFiles)
+		if err != nil {
+			results["threads"] = publishProviderResult{OK: false, Error: err.Error()}
+			overallOK = false
+		} else {
+			threadsItems := threadsMediaFromRelPaths(relMedia, mediaFiles, publicOrigin(r))
+			posted, err, details := h.publishThreads(r.Context(), userID, caption, threadsItems, req.DryRun)
+			if err != nil {
+				results["threads"] = publishProviderResult{OK: false, Posted: posted, Error: err.Error(), Details: details}
+				overallOK = false
+			} else {
+				results["threads"] = publishProviderResult{OK: true, Posted: posted, Details: details}
+			}
+		}
+	}
+
+	// X: uploads go straight to the chunked media upload; long captions continue as a reply thread.
+	if want["x"] {
+		posted, err, details := h.publishX(r.Context(), userID, caption, mediaFiles, req.DryRun)
+		if err != nil {
+			results["x"] = publishProviderResult{OK: false, Posted: posted, Error: err.Error(), Details: details}
+			overallOK = false
+		} else {
+			results["x"] = publishProviderResult{OK: true, Posted: posted, Details: details}
+		}
+	}
+
 	// Other providers: stub for now.
-	for _, p := range []string{"tiktok", "youtube", "pinterest", "threads"} {
+	for _, p := range []string{"tiktok", "youtube", "pinterest"} {
 		if want[p] {
 			results[p] = publishProviderResult{OK: false, Error: "not_supported_yet"}
 			overallOK = false
@@ -3310,6 +3562,18 @@ func (h *Handler) EnqueuePublishJobForUser(w http.ResponseWriter, r *http.Reques
 	if !utf8.ValidString(caption) {
 		caption = strings.ToValidUTF8(caption, "�")
 	}
+	// Validated before the uploads are stored; the uploads' rel paths aren't known yet, so media references
+	// are left to publish time.
+	variants, err := normalizePostVariants(reqObj.Variants, nil)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	options, err := normalizePostOptions(reqObj.Options, nil)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	// Store uploaded media immediately so the background job can reference it.
 	relMedia, saveDetails, err := saveUploadedMedia(userID, "", mediaFiles)
@@ -3331,6 +3595,12 @@ func (h *Handler) EnqueuePublishJobForUser(w http.ResponseWriter, r *http.Reques
 		"media":           relMedia,
 		"publicOrigin":    publicOrigin(r),
 	}
+	if len(variants) > 0 {
+		reqSnapshot["variants"] = variants
+	}
+	if options != nil {
+		reqSnapshot["options"] = options
+	}
 	reqJSON, _ := json.Marshal(reqSnapshot)
 
 	_, err = h.db.ExecContext(r.Context(), `
@@ -3347,8 +3617,8 @@ func (h *Handler) EnqueuePublishJobForUser(w http.ResponseWriter, r *http.Reques
 	log.Printf("[PublishJob] enqueued jobId=%s userId=%s providers=%v media=%d dryRun=%v origin=%s",
 		jobID, userID, reqObj.Providers, len(relMedia), reqObj.DryRun, publicOrigin(r))
 
-	// Fire and forget: run in background (uses DB for status, so any instance can serve status reads).
-	go h.runPublishJob(jobID, userID, caption, reqObj, relMedia, publicOrigin(r))
+	// The publish job worker pool claims the row; wake a local worker so it starts right away.
+	h.notifyPublishWorkers()
 
 	resp := map[string]interface{}{
 		"ok":     true,
@@ -3419,7 +3689,9 @@ func (h *Handler) GetPublishJob(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, resp)
 }
 
-func (h *Handler) runPublishJob(jobID, userID, caption string, req publishPostRequest, relMedia []string, origin string) {
+// runPublishJob publishes a claimed job on behalf of workerID. ctx is cancelled when the worker loses the job's
+// lease; provider calls stop and nothing more is written, since the re-queued job now belongs to another worker.
+func (h *Handler) runPublishJob(ctx context.Context, jobID, workerID, userID, caption string, req publishPostRequest, relMedia []string, origin string) {
 	start := time.Now()
 	// If this job is tied to a scheduled/manual publish-now post, fetch its id for better logging.
 	postID := ""
@@ -3453,11 +3725,18 @@ func (h *Handler) runPublishJob(jobID, userID, caption string, req publishPostRe
 		if rec := recover(); rec != nil {
 			msg := fmt.Sprintf("panic: %v", rec)
 			log.Printf("[PublishJob] panic jobId=%s userId=%s err=%s\n%s", jobID, userID, msg, string(debug.Stack()))
-			_, _ = h.db.Exec(`
+			res, err := h.db.Exec(`
 				UPDATE public.publish_jobs
 				   SET status='failed', error=$2, finished_at=NOW(), updated_at=NOW()
-				 WHERE id=$1
-			`, jobID, msg)
+				 WHERE id=$1 AND locked_by=$3
+			`, jobID, msg, workerID)
+			if err != nil {
+				return
+			}
+			if n, _ := res.RowsAffected(); n == 0 {
+				log.Printf("[PublishJob] lease_lost jobId=%s worker=%s", jobID, workerID)
+				return
+			}
 			// Best-effort: if this was triggered by a scheduled post, mark it as failed too.
 			_, _ = h.db.Exec(`
 				UPDATE public.posts
@@ -3466,6 +3745,7 @@ func (h *Handler) runPublishJob(jobID, userID, caption string, req publishPostRe
 				       updated_at=NOW()
 				 WHERE last_publish_job_id=$1
 			`, jobID, truncate(msg, 400))
+			h.setOccurrenceJobStatus(context.Background(), []string{jobID}, "failed", truncate(msg, 400))
 		}
 	}()
 
@@ -3481,6 +3761,7 @@ func (h *Handler) runPublishJob(jobID, userID, caption string, req publishPostRe
 		       updated_at=NOW()
 		 WHERE last_publish_job_id=$1
 	`, jobID)
+	h.setOccurrenceJobStatus(context.Background(), []string{jobID}, "running", "")
 
 	// Realtime: let the UI know we're actively processing.
 	// Emit a publish_job event for all jobs (including direct publishes with no
@@ -3517,14 +3798,46 @@ func (h *Handler) runPublishJob(jobID, userID, caption string, req publishPostRe
 		want["youtube"] = true
 		want["pinterest"] = true
 		want["threads"] = true
+		want["x"] = true
 	}
 
 	results := map[string]publishProviderResult{}
 	overallOK := true
 
+	// Partial-success resume: providers that already posted on an earlier run of this job are not called again.
+	// Partial posts are resumed where the provider supports it and otherwise left for the user to finish.
+	prevResults := h.loadPreviousPublishResults(jobID)
+	for p, prev := range prevResults {
+		if !want[p] {
+			continue
+		}
+		switch {
+		case prev.OK:
+			prev.Resumed = true
+			results[p] = prev
+			want[p] = false
+			log.Printf("[PublishJob] provider_skipped jobId=%s userId=%s postId=%s provider=%s reason=already_ok", jobID, userID, postID, p)
+		case prev.Posted > 0 && !canResumePartialPublish(p, prev):
+			prev.Resumed = true
+			prev.ManualAction = true
+			results[p] = prev
+			want[p] = false
+			overallOK = false
+			log.Printf("[PublishJob] provider_skipped jobId=%s userId=%s postId=%s provider=%s reason=partial_post posted=%d", jobID, userID, postID, p, prev.Posted)
+		}
+	}
+
 	var mediaFiles []uploadedMedia
 	if len(relMedia) > 0 {
-		mf, err := loadUploadedMediaFromRelPaths(relMedia)
+		// YouTube streams its video from disk, so file contents are only loaded when another provider needs them.
+		load := statUploadedMediaFromRelPaths
+		for p, ok := range want {
+			if ok && p != "youtube" {
+				load = loadUploadedMediaFromRelPaths
+				break
+			}
+		}
+		mf, err := load(relMedia)
 		if err != nil {
 			overallOK = false
 			results["media"] = publishProviderResult{OK: false, Error: "failed_to_load_media", Details: map[string]interface{}{"error": err.Error()}}
@@ -3537,20 +3850,62 @@ func (h *Handler) runPublishJob(jobID, userID, caption string, req publishPostRe
 
 	// Facebook
 	if want["facebook"] {
+		in := publishInputFor("facebook", caption, req.Variants, relMedia, mediaFiles)
+		caption, mediaFiles := in.Caption, in.MediaFiles
+		fbOpts := facebookOptionsFor(in.Link, req.Options)
 		log.Printf("[PublishJob] provider_start jobId=%s userId=%s postId=%s provider=facebook pages=%d", jobID, userID, postID, len(req.FacebookPageIDs))
-		posted, err, details := h.publishFacebookPages(context.Background(), userID, caption, req.FacebookPageIDs, mediaFiles, req.DryRun)
-		if err != nil {
-			results["facebook"] = publishProviderResult{OK: false, Posted: posted, Error: err.Error(), Details: details}
+		var nativeScheds []nativeSchedule
+		if postID != "" && !req.DryRun && req.Options != nil && req.Options.Facebook != nil && req.Options.Facebook.NativeSchedule {
+			scheds, err := h.activeNativeSchedules(ctx, userID, postID, "facebook")
+			if err != nil {
+				log.Printf("[PublishJob] native_schedule_lookup_failed jobId=%s postId=%s err=%v", jobID, postID, err)
+			}
+			nativeScheds = scheds
+		}
+		if len(nativeScheds) > 0 && nativeScheds[0].ScheduledFor.After(time.Now().Add(time.Minute)) {
+			// Published ahead of its slot ("publish now"): withdraw the scheduled copies and post directly.
+			if _, err := h.cancelFacebookNativeSchedules(ctx, userID, postID); err != nil {
+				log.Printf("[PublishJob] native_schedule_cancel_failed jobId=%s postId=%s err=%v", jobID, postID, err)
+			}
+			nativeScheds = nil
+		}
+		if len(nativeScheds) > 0 {
+			// Meta publishes these itself at the scheduled time; posting again would duplicate them.
+			ids := make([]string, 0, len(nativeScheds))
+			for _, ns := range nativeScheds {
+				ids = append(ids, ns.ExternalID)
+				_, _ = h.db.ExecContext(context.Background(), `UPDATE public.post_native_schedules SET status = 'published', updated_at = NOW() WHERE id = $1`, ns.ID)
+			}
+			results["facebook"] = publishProviderResult{OK: true, Posted: len(nativeScheds), Details: map[string]interface{}{"nativeScheduled": true, "postIds": ids}}
+			log.Printf("[PublishJob] provider_skipped jobId=%s userId=%s postId=%s provider=facebook reason=native_scheduled objects=%d", jobID, userID, postID, len(nativeScheds))
+		} else if posted, err, details, attempts := h.publishWithRetry(ctx, jobID, workerID, "facebook", func() (int, error, map[string]interface{}) {
+			// Pages an earlier run already posted to are skipped and reported as they were.
+			done := facebookPostedPages(prevResults["facebook"])
+			fbOpts.SkipPages = map[string]bool{}
+			for _, p := range done {
+				fbOpts.SkipPages[p.PageID] = true
+			}
+			posted, err, details := h.publishFacebookPagesWithOptions(ctx, userID, caption, req.FacebookPageIDs, mediaFiles, fbOpts, req.DryRun)
+			if len(done) > 0 {
+				pages, _ := details["pages"].([]fbPageResult)
+				details["pages"] = append(done, pages...)
+				posted += len(done)
+			}
+			return posted, err, details
+		}); err != nil {
+			results["facebook"] = publishProviderResult{OK: false, Posted: posted, Error: err.Error(), Details: details, Attempts: attempts}
 			overallOK = false
 			log.Printf("[PublishJob] provider_failed jobId=%s userId=%s postId=%s provider=facebook posted=%v err=%s", jobID, userID, postID, posted, truncate(err.Error(), 400))
 		} else {
-			results["facebook"] = publishProviderResult{OK: true, Posted: posted, Details: details}
+			results["facebook"] = publishProviderResult{OK: true, Posted: posted, Details: details, Attempts: attempts}
 			log.Printf("[PublishJob] provider_ok jobId=%s userId=%s postId=%s provider=facebook posted=%v", jobID, userID, postID, posted)
 		}
 	}
 
 	// Instagram (requires public image URLs)
 	if want["instagram"] {
+		in := publishInputFor("instagram", caption, req.Variants, relMedia, mediaFiles)
+		caption, relMedia, mediaFiles := in.Caption, in.RelMedia, in.MediaFiles
 		received := make([]map[string]interface{}, 0, len(mediaFiles))
 		for i := range mediaFiles {
 			received = append(received, map[string]interface{}{
@@ -3597,171 +3952,157 @@ func (h *Handler) runPublishJob(jobID, userID, caption string, req publishPostRe
 			return false
 		}
 
-		// Prefer Reels/video publish if a video is present.
-		videoIdx := -1
-		for i, rel := range relMedia {
-			ct := ""
-			fn := ""
+		mediaInfo := func(i int, rel string) (string, string) {
 			if i < len(mediaFiles) {
-				ct = mediaFiles[i].ContentType
-				fn = mediaFiles[i].Filename
-			} else {
-				fn = filepath.Base(rel)
+				return mediaFiles[i].ContentType, mediaFiles[i].Filename
 			}
-			if isVideo(ct, rel, fn) {
-				if videoIdx >= 0 && videoIdx != i {
-					results["instagram"] = publishProviderResult{OK: false, Error: "instagram_requires_single_video", Details: map[string]interface{}{"received": received}}
-					overallOK = false
-					log.Printf("[PublishJob] provider_failed jobId=%s userId=%s postId=%s provider=instagram posted=%v err=%s", jobID, userID, postID, 0, "instagram_requires_single_video")
-					goto afterInstagramProvider
-				}
-				videoIdx = i
+			return "", filepath.Base(rel)
+		}
+		videoIdxs := []int{}
+		for i, rel := range relMedia {
+			if ct, fn := mediaInfo(i, rel); isVideo(ct, rel, fn) {
+				videoIdxs = append(videoIdxs, i)
 			}
 		}
 
-		if videoIdx >= 0 {
-			ct := ""
-			fn := ""
-			sz := 0
-			videoBytes := []byte{}
-			if videoIdx < len(mediaFiles) {
-				ct = mediaFiles[videoIdx].ContentType
-				fn = mediaFiles[videoIdx].Filename
-				videoBytes = mediaFiles[videoIdx].Bytes
-				sz = len(videoBytes)
-			} else {
-				fn = filepath.Base(relMedia[videoIdx])
-				// If video is not in mediaFiles, try to load it from disk
-				if videoIdx < len(relMedia) {
-					rel := strings.TrimSpace(relMedia[videoIdx])
-					if rel != "" {
-						local := strings.TrimPrefix(rel, "/media/")
-						path, perr := safeMediaJoin(local)
-						if perr != nil {
-							log.Printf("[PublishJob] blocked path traversal: jobId=%s userId=%s postId=%s rel=%s err=%v", jobID, userID, postID, rel, perr)
-						} else if b, err := os.ReadFile(path); err == nil {
-							videoBytes = b
-							sz = len(videoBytes)
-							ct = http.DetectContentType(b)
-							// Prefer MIME type from extension
-							if strings.HasPrefix(strings.ToLower(ct), "application/octet-stream") {
-								if ext := strings.ToLower(filepath.Ext(fn)); ext != "" {
-									if byExt := mime.TypeByExtension(ext); byExt != "" {
-										ct = byExt
-									}
-								}
-							}
-							log.Printf("[PublishJob] loaded video from disk: jobId=%s userId=%s postId=%s path=%s size=%d", jobID, userID, postID, path, sz)
-						} else {
-							log.Printf("[PublishJob] failed to load video from disk: jobId=%s userId=%s postId=%s path=%s err=%v", jobID, userID, postID, path, err)
-						}
-					}
+		// Story mode: each image or video becomes its own Story, in the post's media order.
+		if instagramStoryMode(req.Options) {
+			letterbox := req.Options.Instagram.StoryLetterbox
+			stories := []map[string]interface{}{}
+			posted, attempts, skipped := 0, 0, 0
+			var storyErr error
+			summary := func() map[string]interface{} {
+				out := map[string]interface{}{"mediaType": "STORIES", "stories": stories, "skipped": skipped}
+				if strings.TrimSpace(caption) != "" {
+					out["captionIgnored"] = true
 				}
-			}
-			ctLower := strings.ToLower(strings.TrimSpace(ct))
-			if semi := strings.Index(ctLower, ";"); semi >= 0 {
-				ctLower = strings.TrimSpace(ctLower[:semi])
-			}
-			relLower := strings.ToLower(relMedia[videoIdx])
-			fnLower := strings.ToLower(fn)
-
-			// Check if video needs transcoding
-			needsTranscode := !(ctLower == "video/mp4" || strings.HasSuffix(relLower, ".mp4") || strings.HasSuffix(fnLower, ".mp4"))
-			log.Printf("[PublishJob] video format check: jobId=%s userId=%s postId=%s filename=%s contentType=%s isMp4=%v videoBytes=%d", jobID, userID, postID, fn, ctLower, !needsTranscode, len(videoBytes))
-
-			// For MP4 files, validate codec compatibility
-			var codecCheckNeeded bool
-			if !needsTranscode && len(videoBytes) > 0 {
-				log.Printf("[PublishJob] checking mp4 codecs: jobId=%s userId=%s postId=%s", jobID, userID, postID)
-				// Save to temp file to check codecs
-				tmpFile, err := os.CreateTemp("", "video_codec_check_*.mp4")
-				if err != nil {
-					log.Printf("[PublishJob] codec check failed to create temp file: %v", err)
-				} else {
-					defer os.Remove(tmpFile.Name())
-					defer tmpFile.Close()
-					if _, err := tmpFile.Write(videoBytes); err != nil {
-						log.Printf("[PublishJob] codec check failed to write temp file: %v", err)
-					} else {
-						tmpFile.Close()
-						hasH264, hasAAC, err := validateVideoCodecs(tmpFile.Name())
-						if err != nil {
-							log.Printf("[PublishJob] codec check failed: %v", err)
-						} else if !hasH264 || !hasAAC {
-							log.Printf("[PublishJob] mp4 codec check: jobId=%s userId=%s postId=%s hasH264=%v hasAAC=%v - needs transcode", jobID, userID, postID, hasH264, hasAAC)
-							needsTranscode = true
-							codecCheckNeeded = true
-						} else {
-							log.Printf("[PublishJob] mp4 codec check: jobId=%s userId=%s postId=%s hasH264=%v hasAAC=%v - compatible", jobID, userID, postID, hasH264, hasAAC)
-						}
-					}
+				return out
+			}
+			// Stories an earlier run already published are kept; the set resumes from the first one that wasn't.
+			done := instagramPostedStories(prevResults["instagram"])
+			log.Printf("[PublishJob] provider_start jobId=%s userId=%s postId=%s provider=instagram stories=%d resumed=%d letterbox=%v origin=%s", jobID, userID, postID, len(relMedia), len(done), letterbox, origin)
+			for i, rel := range relMedia {
+				ct, fn := mediaInfo(i, rel)
+				video := isVideo(ct, rel, fn)
+				if (!video && !isImage(ct, rel, fn)) || len(stories) >= instagramMaxStoryItems {
+					skipped++
+					continue
 				}
-			}
-
-			// If video needs transcoding (either not MP4 or MP4 with incompatible codecs)
-			if needsTranscode && len(videoBytes) > 0 {
-				if codecCheckNeeded {
-					log.Printf("[PublishJob] transcoding mp4 with incompatible codecs: jobId=%s userId=%s postId=%s filename=%s", jobID, userID, postID, fn)
-				} else {
-					log.Printf("[PublishJob] transcoding video for instagram: jobId=%s userId=%s postId=%s filename=%s contentType=%s", jobID, userID, postID, fn, ctLower)
+				if prev, ok := done[i]; ok {
+					stories = append(stories, prev)
+					posted++
+					continue
+				}
+				if code, errDetails := prepareInstagramStoryMedia(jobID, userID, postID, i, video, letterbox, relMedia, mediaFiles, received); code != "" {
+					errDetails["index"] = i
+					stories = append(stories, errDetails)
+					storyErr = fmt.Errorf("%s", code)
+					break
+				}
+				mediaURL := strings.TrimRight(origin, "/") + relMedia[i]
+				// Each Story is retried on its own, but progress is checkpointed for the set as a whole.
+				n, err, details, tries := callWithRetry(ctx, jobID, "instagram", func() (int, error, map[string]interface{}) {
+					return h.publishInstagramStory(ctx, userID, mediaURL, video, req.DryRun)
+				})
+				attempts += tries
+				if details == nil {
+					details = map[string]interface{}{}
 				}
-				transcodedBytes, err := preprocessVideoForInstagram(videoBytes, fn)
+				details["index"] = i
+				stories = append(stories, details)
+				posted += n
 				if err != nil {
-					results["instagram"] = publishProviderResult{
-						OK:      false,
-						Error:   "instagram_video_transcode_failed",
-						Details: map[string]interface{}{"received": received, "contentType": ctLower, "filename": fn, "error": err.Error()},
-					}
-					overallOK = false
-					log.Printf("[PublishJob] provider_failed jobId=%s userId=%s postId=%s provider=instagram posted=%v err=%s", jobID, userID, postID, 0, "instagram_video_transcode_failed")
-					goto afterInstagramProvider
+					storyErr = err
+					break
 				}
-				// Update media file with transcoded video
-				mediaFiles[videoIdx].Bytes = transcodedBytes
-				mediaFiles[videoIdx].ContentType = "video/mp4"
-				mediaFiles[videoIdx].Filename = strings.TrimSuffix(fn, filepath.Ext(fn)) + ".mp4"
-				sz = len(transcodedBytes)
-				log.Printf("[PublishJob] video transcoded: jobId=%s userId=%s postId=%s original_size=%d transcoded_size=%d", jobID, userID, postID, len(videoBytes), sz)
-
-				// Re-save the transcoded video to disk
-				savedPaths, _, err := saveUploadedMedia(userID, "", []uploadedMedia{{
-					Filename:    mediaFiles[videoIdx].Filename,
-					ContentType: "video/mp4",
-					Bytes:       transcodedBytes,
-				}})
-				if err != nil || len(savedPaths) == 0 {
-					results["instagram"] = publishProviderResult{
-						OK:      false,
-						Error:   "instagram_video_save_failed",
-						Details: map[string]interface{}{"received": received, "error": err.Error()},
-					}
-					overallOK = false
-					log.Printf("[PublishJob] provider_failed jobId=%s userId=%s postId=%s provider=instagram posted=%v err=%s", jobID, userID, postID, 0, "instagram_video_save_failed")
-					goto afterInstagramProvider
+				if n > 0 {
+					h.checkpointPublishResult(jobID, workerID, "instagram", publishProviderResult{OK: false, Posted: posted, Error: "instagram_stories_incomplete", Details: summary(), Attempts: attempts})
 				}
-				relMedia[videoIdx] = savedPaths[0]
 			}
-
-			if sz > 0 && sz > instagramMaxReelsBytes {
-				results["instagram"] = publishProviderResult{
-					OK:      false,
-					Error:   "instagram_video_too_large",
-					Details: map[string]interface{}{"sizeBytes": sz, "maxBytes": instagramMaxReelsBytes, "received": received},
-				}
+			switch {
+			case storyErr != nil:
+				results["instagram"] = publishProviderResult{OK: false, Posted: posted, Error: storyErr.Error(), Details: summary(), Attempts: attempts}
 				overallOK = false
-				log.Printf("[PublishJob] provider_failed jobId=%s userId=%s postId=%s provider=instagram posted=%v err=%s", jobID, userID, postID, 0, "instagram_video_too_large")
-				goto afterInstagramProvider
-			}
+				log.Printf("[PublishJob] provider_failed jobId=%s userId=%s postId=%s provider=instagram posted=%v err=%s", jobID, userID, postID, posted, truncate(storyErr.Error(), 400))
+			case len(stories) == 0:
+				results["instagram"] = publishProviderResult{OK: false, Error: "instagram_requires_image_or_video", Details: map[string]interface{}{"received": received}}
+				overallOK = false
+				log.Printf("[PublishJob] provider_failed jobId=%s userId=%s postId=%s provider=instagram posted=%v err=%s", jobID, userID, postID, 0, "instagram_requires_image_or_video")
+			default:
+				results["instagram"] = publishProviderResult{OK: true, Posted: posted, Details: summary(), Attempts: attempts}
+				log.Printf("[PublishJob] provider_ok jobId=%s userId=%s postId=%s provider=instagram posted=%v", jobID, userID, postID, posted)
+			}
+			if posted > 0 {
+				h.checkpointPublishResult(jobID, workerID, "instagram", results["instagram"])
+			}
+			goto afterInstagramProvider
+		}
+
+		// Videos mixed with other media go out as one carousel, in the post's media order.
+		if len(videoIdxs) > 0 && len(relMedia) > 1 {
+			for _, idx := range videoIdxs {
+				if code, errDetails := prepareInstagramVideo(jobID, userID, postID, idx, relMedia, mediaFiles, received); code != "" {
+					errDetails["index"] = idx
+					results["instagram"] = publishProviderResult{OK: false, Error: code, Details: errDetails}
+					overallOK = false
+					log.Printf("[PublishJob] provider_failed jobId=%s userId=%s postId=%s provider=instagram posted=%v err=%s", jobID, userID, postID, 0, code)
+					goto afterInstagramProvider
+				}
+			}
+			items := make([]instagramCarouselItem, 0, len(relMedia))
+			itemRels := make([]string, 0, len(relMedia))
+			skipped := 0
+			for i, rel := range relMedia {
+				ct, fn := mediaInfo(i, rel)
+				switch {
+				case isVideo(ct, rel, fn):
+					items = append(items, instagramCarouselItem{URL: strings.TrimRight(origin, "/") + rel, IsVideo: true})
+					itemRels = append(itemRels, rel)
+				case isImage(ct, rel, fn):
+					items = append(items, instagramCarouselItem{URL: strings.TrimRight(origin, "/") + rel})
+					itemRels = append(itemRels, rel)
+				default:
+					skipped++
+				}
+			}
+			igOpts := instagramOptionsFor(req.Options, itemRels)
+			log.Printf("[PublishJob] provider_start jobId=%s userId=%s postId=%s provider=instagram carousel=%d videos=%d skipped=%d origin=%s", jobID, userID, postID, len(items), len(videoIdxs), skipped, origin)
+			posted, err, details, attempts := h.publishWithRetry(ctx, jobID, workerID, "instagram", func() (int, error, map[string]interface{}) {
+				return h.publishInstagramCarousel(ctx, userID, caption, items, igOpts, req.DryRun)
+			})
+			if err != nil {
+				results["instagram"] = publishProviderResult{OK: false, Posted: posted, Error: err.Error(), Details: details, Attempts: attempts}
+				overallOK = false
+				log.Printf("[PublishJob] provider_failed jobId=%s userId=%s postId=%s provider=instagram posted=%v err=%s", jobID, userID, postID, posted, truncate(err.Error(), 400))
+			} else {
+				results["instagram"] = publishProviderResult{OK: true, Posted: posted, Details: details, Attempts: attempts}
+				log.Printf("[PublishJob] provider_ok jobId=%s userId=%s postId=%s provider=instagram posted=%v", jobID, userID, postID, posted)
+			}
+			goto afterInstagramProvider
+		}
+
+		// A single video is published as a Reel.
+		if len(videoIdxs) == 1 {
+			videoIdx := videoIdxs[0]
+			if code, errDetails := prepareInstagramVideo(jobID, userID, postID, videoIdx, relMedia, mediaFiles, received); code != "" {
+				results["instagram"] = publishProviderResult{OK: false, Error: code, Details: errDetails}
+				overallOK = false
+				log.Printf("[PublishJob] provider_failed jobId=%s userId=%s postId=%s provider=instagram posted=%v err=%s", jobID, userID, postID, 0, code)
+				goto afterInstagramProvider
+			}
 
 			videoURL := strings.TrimRight(origin, "/") + relMedia[videoIdx]
+			igOpts := instagramOptionsFor(req.Options, []string{relMedia[videoIdx]})
 			log.Printf("[PublishJob] provider_start jobId=%s userId=%s postId=%s provider=instagram reels=1 origin=%s videoURL=%s", jobID, userID, postID, origin, videoURL)
-			posted, err, details := h.publishInstagramReelWithVideoURL(context.Background(), userID, caption, videoURL, req.DryRun)
+			posted, err, details, attempts := h.publishWithRetry(ctx, jobID, workerID, "instagram", func() (int, error, map[string]interface{}) {
+				return h.publishInstagramReel(ctx, userID, caption, videoURL, igOpts, req.DryRun)
+			})
 			if err != nil {
-				results["instagram"] = publishProviderResult{OK: false, Posted: posted, Error: err.Error(), Details: details}
+				results["instagram"] = publishProviderResult{OK: false, Posted: posted, Error: err.Error(), Details: details, Attempts: attempts}
 				overallOK = false
 				log.Printf("[PublishJob] provider_failed jobId=%s userId=%s postId=%s provider=instagram posted=%v err=%s", jobID, userID, postID, posted, truncate(err.Error(), 400))
 			} else {
-				results["instagram"] = publishProviderResult{OK: true, Posted: posted, Details: details}
+				results["instagram"] = publishProviderResult{OK: true, Posted: posted, Details: details, Attempts: attempts}
 				log.Printf("[PublishJob] provider_ok jobId=%s userId=%s postId=%s provider=instagram posted=%v", jobID, userID, postID, posted)
 			}
 			goto afterInstagramProvider
@@ -3769,6 +4110,7 @@ func (h *Handler) runPublishJob(jobID, userID, caption string, req publishPostRe
 
 		// Image publishing path (photos/carousels)
 		imageURLs := make([]string, 0, len(relMedia))
+		imageRels := make([]string, 0, len(relMedia))
 		skipped := 0
 		for i, rel := range relMedia {
 			ct := ""
@@ -3781,6 +4123,7 @@ func (h *Handler) runPublishJob(jobID, userID, caption string, req publishPostRe
 			}
 			if isImage(ct, rel, fn) {
 				imageURLs = append(imageURLs, strings.TrimRight(origin, "/")+rel)
+				imageRels = append(imageRels, rel)
 			} else {
 				skipped++
 			}
@@ -3796,21 +4139,25 @@ func (h *Handler) runPublishJob(jobID, userID, caption string, req publishPostRe
 			overallOK = false
 			log.Printf("[PublishJob] provider_failed jobId=%s userId=%s postId=%s provider=instagram posted=%v err=%s", jobID, userID, postID, 0, "instagram_requires_image_or_video")
 		} else {
-			posted, err, details := h.publishInstagramWithImageURLs(context.Background(), userID, caption, imageURLs, req.DryRun)
+			posted, err, details, attempts := h.publishWithRetry(ctx, jobID, workerID, "instagram", func() (int, error, map[string]interface{}) {
+				return h.publishInstagramImages(ctx, userID, caption, imageURLs, instagramOptionsFor(req.Options, imageRels), req.DryRun)
+			})
 			if err != nil {
-				results["instagram"] = publishProviderResult{OK: false, Posted: posted, Error: err.Error(), Details: details}
+				results["instagram"] = publishProviderResult{OK: false, Posted: posted, Error: err.Error(), Details: details, Attempts: attempts}
 				overallOK = false
 				log.Printf("[PublishJob] provider_failed jobId=%s userId=%s postId=%s provider=instagram posted=%v err=%s", jobID, userID, postID, posted, truncate(err.Error(), 400))
 			} else {
-				results["instagram"] = publishProviderResult{OK: true, Posted: posted, Details: details}
+				results["instagram"] = publishProviderResult{OK: true, Posted: posted, Details: details, Attempts: attempts}
 				log.Printf("[PublishJob] provider_ok jobId=%s userId=%s postId=%s provider=instagram posted=%v", jobID, userID, postID, posted)
 			}
 		}
 	}
 afterInstagramProvider:
 
-	// TikTok (requires a public video URL)
+	// TikTok (a public video URL, or images for a photo post)
 	if want["tiktok"] {
+		in := publishInputFor("tiktok", caption, req.Variants, relMedia, mediaFiles)
+		caption, relMedia, mediaFiles := in.Caption, in.RelMedia, in.MediaFiles
 		videoURL := ""
 		for i, rel := range relMedia {
 			ct := ""
@@ -3822,28 +4169,46 @@ afterInstagramProvider:
 				break
 			}
 		}
-		posted, err, details := h.publishTikTokWithVideoURL(context.Background(), userID, caption, videoURL, req.DryRun)
+		media := tiktokMedia{VideoURL: videoURL}
+		if videoURL == "" {
+			// No video: post the images as a photo carousel.
+			photoRels, converted := tiktokPhotoRels(jobID, userID, postID, relMedia, mediaFiles)
+			for _, rel := range photoRels {
+				media.PhotoURLs = append(media.PhotoURLs, strings.TrimRight(origin, "/")+rel)
+			}
+			log.Printf("[PublishJob] provider_start jobId=%s userId=%s postId=%s provider=tiktok photos=%d converted=%d", jobID, userID, postID, len(photoRels), converted)
+		}
+		opts := tiktokOptionsFor(req.Options)
+		posted, err, details, attempts := h.publishWithRetry(ctx, jobID, workerID, "tiktok", func() (int, error, map[string]interface{}) {
+			return h.publishTikTok(ctx, userID, caption, media, opts, req.DryRun)
+		})
 		if err != nil {
-			results["tiktok"] = publishProviderResult{OK: false, Posted: posted, Error: err.Error(), Details: details}
+			results["tiktok"] = publishProviderResult{OK: false, Posted: posted, Error: err.Error(), Details: details, Attempts: attempts}
 			overallOK = false
 			log.Printf("[PublishJob] provider_failed jobId=%s userId=%s postId=%s provider=tiktok posted=%v err=%s", jobID, userID, postID, posted, truncate(err.Error(), 400))
 		} else {
-			results["tiktok"] = publishProviderResult{OK: true, Posted: posted, Details: details}
+			results["tiktok"] = publishProviderResult{OK: true, Posted: posted, Details: details, Attempts: attempts}
 			log.Printf("[PublishJob] provider_ok jobId=%s userId=%s postId=%s provider=tiktok posted=%v", jobID, userID, postID, posted)
 		}
 	}
 
-	// YouTube (requires a video upload; currently supports small uploads due to request size limits)
+	// YouTube (requires a video upload)
 	if want["youtube"] {
+		in := publishInputFor("youtube", caption, req.Variants, relMedia, mediaFiles)
+		caption, mediaFiles := in.Caption, in.MediaFiles
 		received := make([]map[string]interface{}, 0, len(mediaFiles))
 		for i := range mediaFiles {
-			received = append(received, map[string]interface{}{
+			entry := map[string]interface{}{
 				"filename":    mediaFiles[i].Filename,
 				"contentType": mediaFiles[i].ContentType,
-				"size":        len(mediaFiles[i].Bytes),
-			})
+			}
+			if len(mediaFiles[i].Bytes) > 0 {
+				entry["size"] = len(mediaFiles[i].Bytes)
+			}
+			received = append(received, entry)
 		}
 		var video uploadedMedia
+		videoRel := ""
 		found := false
 		for i := range mediaFiles {
 			ct := strings.ToLower(strings.TrimSpace(mediaFiles[i].ContentType))
@@ -3859,6 +4224,9 @@ afterInstagramProvider:
 				strings.HasSuffix(fn, ".avi") ||
 				strings.HasSuffix(fn, ".mkv") {
 				video = mediaFiles[i]
+				if i < len(in.RelMedia) {
+					videoRel = in.RelMedia[i]
+				}
 				found = true
 				break
 			}
@@ -3871,19 +4239,41 @@ afterInstagramProvider:
 			}
 			overallOK = false
 		} else {
-			posted, err, details := h.publishYouTubeWithVideoBytes(context.Background(), userID, caption, video, req.DryRun)
-			if err != nil {
-				results["youtube"] = publishProviderResult{OK: false, Posted: posted, Error: err.Error(), Details: details}
+			// Stream the upload from disk; loaded bytes are only a fallback (and absent when YouTube is the
+			// only provider).
+			var src youtubeVideoSource
+			if videoRel != "" {
+				if fileSrc, f, err := openYouTubeSource(videoRel); err == nil {
+					defer func() { _ = f.Close() }()
+					src = fileSrc
+				}
+			}
+			if src.Reader == nil && len(video.Bytes) > 0 {
+				src = youtubeSourceFromMedia(video)
+			}
+			if src.Reader == nil {
+				results["youtube"] = publishProviderResult{OK: false, Error: "youtube_video_unavailable", Details: map[string]interface{}{"media": videoRel}}
 				overallOK = false
 			} else {
-				results["youtube"] = publishProviderResult{OK: true, Posted: posted, Details: details}
+				ytOpts := youtubeOptionsFor(in.Title, req.Options)
+				posted, err, details, attempts := h.publishWithRetry(ctx, jobID, workerID, "youtube", func() (int, error, map[string]interface{}) {
+					return h.publishYouTubeSource(ctx, userID, caption, src, ytOpts, req.DryRun)
+				})
+				if err != nil {
+					results["youtube"] = publishProviderResult{OK: false, Posted: posted, Error: err.Error(), Details: details, Attempts: attempts}
+					overallOK = false
+				} else {
+					results["youtube"] = publishProviderResult{OK: true, Posted: posted, Details: details, Attempts: attempts}
+				}
 			}
 		}
 	}
 
-	// Pinterest (requires a public image URL)
+	// Pinterest (a public image URL, several for a carousel pin, or a video upload)
 	if want["pinterest"] {
-		imageURL := ""
+		in := publishInputFor("pinterest", caption, req.Variants, relMedia, mediaFiles)
+		caption, relMedia, mediaFiles := in.Caption, in.RelMedia, in.MediaFiles
+		var media pinterestPinMedia
 		for i, rel := range relMedia {
 			ct := ""
 			fn := ""
@@ -3891,8 +4281,11 @@ afterInstagramProvider:
 				ct = strings.ToLower(strings.TrimSpace(mediaFiles[i].ContentType))
 				fn = strings.ToLower(strings.TrimSpace(mediaFiles[i].Filename))
 			}
-			if strings.Contains(ct, "multipart/") || strings.Contains(ct, "application/") {
-				// ignore
+			if strings.HasPrefix(ct, "video/") || strings.HasSuffix(strings.ToLower(rel), ".mp4") || strings.HasSuffix(strings.ToLower(rel), ".mov") || strings.HasSuffix(strings.ToLower(rel), ".webm") {
+				if media.Video == nil && i < len(mediaFiles) {
+					media.Video = &mediaFiles[i]
+				}
+				continue
 			}
 			if strings.HasPrefix(ct, "image/") ||
 				strings.HasSuffix(strings.ToLower(rel), ".jpg") ||
@@ -3901,30 +4294,72 @@ afterInstagramProvider:
 				strings.HasSuffix(strings.ToLower(rel), ".webp") ||
 				strings.HasSuffix(strings.ToLower(rel), ".gif") ||
 				strings.HasSuffix(fn, ".jpg") || strings.HasSuffix(fn, ".jpeg") || strings.HasSuffix(fn, ".png") || strings.HasSuffix(fn, ".webp") || strings.HasSuffix(fn, ".gif") {
-				imageURL = strings.TrimRight(origin, "/") + rel
-				break
+				if len(media.ImageURLs) < pinterestMaxCarouselImages {
+					media.ImageURLs = append(media.ImageURLs, strings.TrimRight(origin, "/")+rel)
+				}
 			}
 		}
-		posted, err, details := h.publishPinterestWithImageURL(context.Background(), userID, caption, imageURL, req.DryRun)
+		if media.Video != nil && len(media.ImageURLs) > 1 {
+			// A video pin only uses the first image, as its cover.
+			media.ImageURLs = media.ImageURLs[:1]
+		}
+		opts := pinterestOptionsFor(in, req.Options)
+		posted, err, details, attempts := h.publishWithRetry(ctx, jobID, workerID, "pinterest", func() (int, error, map[string]interface{}) {
+			return h.publishPinterestPin(ctx, userID, caption, media, opts, req.DryRun)
+		})
 		if err != nil {
-			results["pinterest"] = publishProviderResult{OK: false, Posted: posted, Error: err.Error(), Details: details}
+			results["pinterest"] = publishProviderResult{OK: false, Posted: posted, Error: err.Error(), Details: details, Attempts: attempts}
 			overallOK = false
 			detJSON, _ := json.Marshal(details)
 			log.Printf("[PublishJob] provider_failed jobId=%s userId=%s postId=%s provider=pinterest posted=%v err=%s details=%s",
 				jobID, userID, postID, posted, truncate(err.Error(), 400), truncate(string(detJSON), 1600))
 		} else {
-			results["pinterest"] = publishProviderResult{OK: true, Posted: posted, Details: details}
+			results["pinterest"] = publishProviderResult{OK: true, Posted: posted, Details: details, Attempts: attempts}
 			log.Printf("[PublishJob] provider_ok jobId=%s userId=%s postId=%s provider=pinterest posted=%v", jobID, userID, postID, posted)
 		}
 	}
 
-	for _, p := range []string{"threads"} {
-		if want[p] {
-			results[p] = publishProviderResult{OK: false, Error: "not_supported_yet"}
+	// Threads (text, or public image/video URLs; several items become a carousel)
+	if want["threads"] {
+		in := publishInputFor("threads", caption, req.Variants, relMedia, mediaFiles)
+		caption, relMedia, mediaFiles := in.Caption, in.RelMedia, in.MediaFiles
+		threadsItems := threadsMediaFromRelPaths(relMedia, mediaFiles, origin)
+		log.Printf("[PublishJob] provider_start jobId=%s userId=%s postId=%s provider=threads media=%d origin=%s", jobID, userID, postID, len(threadsItems), origin)
+		posted, err, details, attempts := h.publishWithRetry(ctx, jobID, workerID, "threads", func() (int, error, map[string]interface{}) {
+			return h.publishThreads(ctx, userID, caption, threadsItems, req.DryRun)
+		})
+		if err != nil {
+			results["threads"] = publishProviderResult{OK: false, Posted: posted, Error: err.Error(), Details: details, Attempts: attempts}
+			overallOK = false
+			log.Printf("[PublishJob] provider_failed jobId=%s userId=%s postId=%s provider=threads posted=%v err=%s", jobID, userID, postID, posted, truncate(err.Error(), 400))
+		} else {
+			results["threads"] = publishProviderResult{OK: true, Posted: posted, Details: details, Attempts: attempts}
+			log.Printf("[PublishJob] provider_ok jobId=%s userId=%s postId=%s provider=threads posted=%v", jobID, userID, postID, posted)
+		}
+	}
+
+	// X (chunked media upload; long captions continue as a reply thread)
+	if want["x"] {
+		in := publishInputFor("x", caption, req.Variants, relMedia, mediaFiles)
+		caption, mediaFiles := in.Caption, in.MediaFiles
+		// A thread an earlier run broke off continues from its last posted tweet.
+		done := xPostedTweets(prevResults["x"])
+		log.Printf("[PublishJob] provider_start jobId=%s userId=%s postId=%s provider=x media=%d resumedParts=%d", jobID, userID, postID, len(mediaFiles), len(done))
+		posted, err, details, attempts := h.publishWithRetry(ctx, jobID, workerID, "x", func() (int, error, map[string]interface{}) {
+			return h.resumeXThread(ctx, userID, caption, mediaFiles, done, req.DryRun)
+		})
+		if err != nil {
+			results["x"] = publishProviderResult{OK: false, Posted: posted, Error: err.Error(), Details: details, Attempts: attempts}
 			overallOK = false
+			log.Printf("[PublishJob] provider_failed jobId=%s userId=%s postId=%s provider=x posted=%v err=%s", jobID, userID, postID, posted, truncate(err.Error(), 400))
+		} else {
+			results["x"] = publishProviderResult{OK: true, Posted: posted, Details: details, Attempts: attempts}
+			log.Printf("[PublishJob] provider_ok jobId=%s userId=%s postId=%s provider=x posted=%v", jobID, userID, postID, posted)
 		}
 	}
 
+	h.postFirstComments(jobID, userID, req.Variants, results, req.DryRun)
+
 	resp := map[string]interface{}{
 		"ok":         overallOK,
 		"jobId":      jobID,
@@ -3942,11 +4377,18 @@ afterInstagramProvider:
 		errText = "one_or_more_providers_failed"
 	}
 
-	_, _ = h.db.Exec(`
+	// Only the worker still holding the lease records the outcome; a job re-queued under it keeps its own results.
+	res, err := h.db.Exec(`
 		UPDATE public.publish_jobs
 		   SET status=$2, result_json=$3::jsonb, error=COALESCE($4, error), finished_at=NOW(), updated_at=NOW()
-		 WHERE id=$1
-	`, jobID, finalStatus, string(resJSON), errText)
+		 WHERE id=$1 AND locked_by=$5
+	`, jobID, finalStatus, string(resJSON), errText, workerID)
+	if err == nil {
+		if n, _ := res.RowsAffected(); n == 0 {
+			log.Printf("[PublishJob] lease_lost jobId=%s userId=%s postId=%s worker=%s status=%s", jobID, userID, postID, workerID, finalStatus)
+			return
+		}
+	}
 
 	// If this job was spawned by a scheduled post, update that post's publish tracking and mark it published on success.
 	postErr := ""
@@ -3962,6 +4404,10 @@ afterInstagramProvider:
 		       updated_at=NOW()
 		 WHERE last_publish_job_id=$1
 	`, jobID, finalStatus, postErr)
+	// Recurring posts track each run on the occurrence instead.
+	if occPostID := h.finishOccurrenceJob(jobID, finalStatus, postErr); occPostID != "" && postID == "" {
+		postID = occPostID
+	}
 
 	// Summarize failures (no provider details to avoid leaking sensitive payloads).
 	failures := make([]string, 0, 6)
@@ -4313,6 +4759,12 @@ func validateVideoCodecs(inputPath string) (bool, bool, error) {
 // Instagram requires: H.264 video codec, AAC audio codec, MP4 container, 9:16 aspect ratio.
 // Returns the path to the transcoded video or error if transcoding fails.
 func preprocessVideoForInstagram(inputBytes []byte, inputFilename string) ([]byte, error) {
+	return transcodeVideoForInstagram(inputBytes, inputFilename, "")
+}
+
+// transcodeVideoForInstagram is preprocessVideoForInstagram with an optional ffmpeg -vf filter
+// (e.g. letterboxing Stories to 9:16).
+func transcodeVideoForInstagram(inputBytes []byte, inputFilename string, videoFilter string) ([]byte, error) {
 	// Check if ffmpeg is available
 	if _, err := exec.LookPath("ffmpeg"); err != nil {
 		return nil, fmt.Errorf("ffmpeg_not_available")
@@ -4366,6 +4818,11 @@ func preprocessVideoForInstagram(inputBytes []byte, inputFilename string) ([]byt
 	args := []string{
 		"-y", "-hide_banner", "-loglevel", "error",
 		"-i", inputPath,
+	}
+	if videoFilter != "" {
+		args = append(args, "-vf", videoFilter)
+	}
+	args = append(args,
 		"-c:v", "libx264",
 		"-profile:v", "high",
 		"-level:v", "4.0",
@@ -4382,9 +4839,9 @@ func preprocessVideoForInstagram(inputBytes []byte, inputFilename string) ([]byt
 		"-movflags", "+faststart",
 		"-r", "30",
 		outputPath,
-	}
+	)
 
-	log.Printf("[VideoPreprocess] transcoding video: input=%s hasH264=%v hasAAC=%v", inputFilename, hasH264, hasAAC)
+	log.Printf("[VideoPreprocess] transcoding video: input=%s hasH264=%v hasAAC=%v filter=%q", inputFilename, hasH264, hasAAC, videoFilter)
 	cmd := exec.Command("ffmpeg", args...)
 	if err := cmd.Run(); err != nil {
 		return nil, fmt.Errorf("ffmpeg_transcode_failed: %w", err)
@@ -4477,21 +4934,54 @@ func loadUploadedMediaFromRelPaths(relPaths []string) ([]uploadedMedia, error) {
 			return nil, err
 		}
 		fn := filepath.Base(path)
-		ct := http.DetectContentType(b)
-		// `DetectContentType` often falls back to octet-stream for formats like `.mov`.
-		// Prefer MIME type derived from file extension when available.
-		if strings.HasPrefix(strings.ToLower(ct), "application/octet-stream") {
-			if ext := strings.ToLower(filepath.Ext(fn)); ext != "" {
-				if byExt := mime.TypeByExtension(ext); byExt != "" {
-					ct = byExt
-				}
-			}
+		out = append(out, uploadedMedia{Filename: fn, ContentType: uploadedMediaContentType(fn, b), Bytes: b})
+	}
+	return out, nil
+}
+
+// statUploadedMediaFromRelPaths is loadUploadedMediaFromRelPaths without the file contents: Bytes is left
+// empty and the content type is sniffed from the first 512 bytes only.
+func statUploadedMediaFromRelPaths(relPaths []string) ([]uploadedMedia, error) {
+	out := make([]uploadedMedia, 0, len(relPaths))
+	for _, rel := range relPaths {
+		rel = strings.TrimSpace(rel)
+		if rel == "" {
+			continue
+		}
+		path, perr := safeMediaJoin(strings.TrimPrefix(rel, "/media/"))
+		if perr != nil {
+			return nil, perr
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		head := make([]byte, 512)
+		n, err := io.ReadFull(f, head)
+		_ = f.Close()
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, err
 		}
-		out = append(out, uploadedMedia{Filename: fn, ContentType: ct, Bytes: b})
+		fn := filepath.Base(path)
+		out = append(out, uploadedMedia{Filename: fn, ContentType: uploadedMediaContentType(fn, head[:n])})
 	}
 	return out, nil
 }
 
+func uploadedMediaContentType(filename string, head []byte) string {
+	ct := http.DetectContentType(head)
+	// `DetectContentType` often falls back to octet-stream for formats like `.mov`.
+	// Prefer MIME type derived from file extension when available.
+	if strings.HasPrefix(strings.ToLower(ct), "application/octet-stream") {
+		if ext := strings.ToLower(filepath.Ext(filename)); ext != "" {
+			if byExt := mime.TypeByExtension(ext); byExt != "" {
+				ct = byExt
+			}
+		}
+	}
+	return ct
+}
+
 func nullTimePtr(nt sql.NullTime) *time.Time {
 	if !nt.Valid {
 		return nil
@@ -4552,6 +5042,18 @@ func parsePublishPostRequest(r *http.Request) (publishPostRequest, []uploadedMed
 			}
 		}
 
+		// variants/options: JSON objects, same shape as the JSON body.
+		if raw := strings.TrimSpace(getStr("variants")); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &req.Variants); err != nil {
+				return publishPostRequest{}, nil, fmt.Errorf("invalid variants: %w", err)
+			}
+		}
+		if raw := strings.TrimSpace(getStr("options")); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &req.Options); err != nil {
+				return publishPostRequest{}, nil, fmt.Errorf("invalid options: %w", err)
+			}
+		}
+
 		// media files
 		files := []*multipart.FileHeader{}
 		if r.MultipartForm != nil && r.MultipartForm.File != nil {
@@ -4598,6 +5100,12 @@ func parsePublishPostRequest(r *http.Request) (publishPostRequest, []uploadedMed
 }
 
 func (h *Handler) publishFacebookPages(ctx context.Context, userID string, caption string, pageIDs []string, media []uploadedMedia, dryRun bool) (int, error, map[string]interface{}) {
+	return h.publishFacebookPagesWithOptions(ctx, userID, caption, pageIDs, media, fbPublishOptions{}, dryRun)
+}
+
+// publishFacebookPagesWithOptions publishes to the selected pages, honouring the link, visibility and
+// scheduling in opts. Scheduled and unpublished posts are not added to the library.
+func (h *Handler) publishFacebookPagesWithOptions(ctx context.Context, userID string, caption string, pageIDs []string, media []uploadedMedia, opts fbPublishOptions, dryRun bool) (int, error, map[string]interface{}) {
 	details := map[string]interface{}{}
 	var raw []byte
 	if err := h.db.QueryRowContext(ctx, `SELECT value FROM public.user_settings WHERE user_id=$1 AND key='facebook_oauth' AND value IS NOT NULL`, userID).Scan(&raw); err != nil {
@@ -4650,16 +5158,35 @@ func (h *Handler) publishFacebookPages(ctx context.Context, userID string, capti
 	if len(pages) == 0 {
 		return 0, fmt.Errorf("no_selected_pages"), details
 	}
+	if len(opts.SkipPages) > 0 {
+		remaining := make([]fbOAuthPageRow, 0, len(pages))
+		for _, p := range pages {
+			if !opts.SkipPages[p.ID] {
+				remaining = append(remaining, p)
+			}
+		}
+		pages = remaining
+	}
 
-	type pageResult struct {
-		PageID     string `json:"pageId"`
-		Posted     bool   `json:"posted"`
-		PostID     string `json:"postId,omitempty"`
-		StatusCode int    `json:"statusCode,omitempty"`
-		Error      string `json:"error,omitempty"`
-		Body       string `json:"body,omitempty"`
+	pageResults := make([]fbPageResult, 0, len(pages))
+
+	// Facebook posts can't mix videos and photos: the first video wins and the rest of the media is skipped.
+	var video *uploadedMedia
+	videoFormat := ""
+	for i := range media {
+		if _, isVideo, ok := xMediaKind(media[i]); ok && isVideo {
+			video = &media[i]
+			break
+		}
+	}
+	if video != nil {
+		info := analyzePreflightMedia(*video)
+		videoFormat = fbVideoFormat(info)
+		details["video"] = map[string]interface{}{"filename": video.Filename, "format": videoFormat, "width": info.Width, "height": info.Height, "durationSec": info.DurationSec}
+		if len(media) > 1 {
+			details["skippedMedia"] = len(media) - 1
+		}
 	}
-	pageResults := make([]pageResult, 0, len(pages))
 
 	client := &http.Client{Timeout: 60 * time.Second}
 	postedCount := 0
@@ -4675,7 +5202,7 @@ func (h *Handler) publishFacebookPages(ctx context.Context, userID string, capti
 				}
 			}
 			if !canPost {
-				pageResults = append(pageResults, pageResult{
+				pageResults = append(pageResults, fbPageResult{
 					PageID: page.ID,
 					Posted: false,
 					Error:  "insufficient_page_role",
@@ -4686,7 +5213,16 @@ func (h *Handler) publishFacebookPages(ctx context.Context, userID string, capti
 		}
 
 		if dryRun {
-			pageResults = append(pageResults, pageResult{PageID: page.ID, Posted: false})
+			pageResults = append(pageResults, fbPageResult{PageID: page.ID, Posted: false, Format: videoFormat})
+			continue
+		}
+
+		if video != nil {
+			res := h.publishFacebookPageVideo(ctx, userID, page, caption, *video, videoFormat, opts)
+			pageResults = append(pageResults, res)
+			if res.Posted {
+				postedCount++
+			}
 			continue
 		}
 		var endpoint string
@@ -4695,13 +5231,13 @@ func (h *Handler) publishFacebookPages(ctx context.Context, userID string, capti
 
 		// If media is included, publish as a photo post (single) or feed post with attached media (multi).
 		if len(media) > 0 {
-			postID, status, bodyText, errMsg, err := fbPublishWithImages(ctx, client, page.ID, page.AccessToken, caption, media)
+			postID, status, bodyText, errMsg, err := fbPublishImages(ctx, client, page.ID, page.AccessToken, caption, media, opts)
 			if err != nil {
-				pageResults = append(pageResults, pageResult{PageID: page.ID, Posted: false, StatusCode: status, Error: errMsg, Body: truncate(bodyText, 1200)})
+				pageResults = append(pageResults, fbPageResult{PageID: page.ID, Posted: false, StatusCode: status, Error: errMsg, Body: truncate(bodyText, 1200)})
 				log.Printf("[FBPublish] non_2xx userId=%s pageId=%s status=%d body=%s", userID, page.ID, status, truncate(bodyText, 600))
 				continue
 			}
-			pageResults = append(pageResults, pageResult{PageID: page.ID, Posted: true, PostID: postID, StatusCode: status})
+			pageResults = append(pageResults, fbPageResult{PageID: page.ID, Posted: true, PostID: postID, StatusCode: status})
 			postedCount++
 			log.Printf("[FBPublish] ok userId=%s pageId=%s postId=%s", userID, page.ID, postID)
 
@@ -4710,7 +5246,7 @@ func (h *Handler) publishFacebookPages(ctx context.Context, userID string, capti
 			if !utf8.ValidString(rawPayload) {
 				rawPayload = strings.ToValidUTF8(rawPayload, "�")
 			}
-			if postID != "" {
+			if postID != "" && opts.live() {
 				rowID := fmt.Sprintf("facebook:%s:%s", userID, postID)
 				_, _ = h.db.ExecContext(ctx, `
 					INSERT INTO public.social_libraries
@@ -4727,14 +5263,20 @@ func (h *Handler) publishFacebookPages(ctx context.Context, userID string, capti
 			continue
 		}
 
-		// Caption-only post
+		// Caption-only post (with the variant link attached, when there is one)
 		form := url.Values{}
 		form.Set("message", caption)
 		form.Set("access_token", page.AccessToken)
+		opts.setLinkFields(form)
+		if !opts.live() {
+			for k, v := range opts.publishFields() {
+				form[k] = v
+			}
+		}
 		endpoint = fmt.Sprintf("https://graph.facebook.com/v24.0/%s/feed", url.PathEscape(page.ID))
 		req, err = http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
 		if err != nil {
-			pageResults = append(pageResults, pageResult{PageID: page.ID, Posted: false, Error: err.Error()})
+			pageResults = append(pageResults, fbPageResult{PageID: page.ID, Posted: false, Error: err.Error()})
 			continue
 		}
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
@@ -4742,7 +5284,7 @@ func (h *Handler) publishFacebookPages(ctx context.Context, userID string, capti
 
 		res, err := client.Do(req)
 		if err != nil {
-			pageResults = append(pageResults, pageResult{PageID: page.ID, Posted: false, Error: err.Error()})
+			pageResults = append(pageResults, fbPageResult{PageID: page.ID, Posted: false, Error: err.Error()})
 			log.Printf("[FBPublish] failed userId=%s pageId=%s err=%v", userID, page.ID, err)
 			continue
 		}
@@ -4760,7 +5302,7 @@ func (h *Handler) publishFacebookPages(ctx context.Context, userID string, capti
 					}
 				}
 			}
-			pageResults = append(pageResults, pageResult{
+			pageResults = append(pageResults, fbPageResult{
 				PageID:     page.ID,
 				Posted:     false,
 				StatusCode: res.StatusCode,
@@ -4773,12 +5315,12 @@ func (h *Handler) publishFacebookPages(ctx context.Context, userID string, capti
 		var obj map[string]interface{}
 		_ = json.Unmarshal(body, &obj)
 		postID, _ := obj["id"].(string)
-		pageResults = append(pageResults, pageResult{PageID: page.ID, Posted: true, PostID: postID, StatusCode: res.StatusCode})
+		pageResults = append(pageResults, fbPageResult{PageID: page.ID, Posted: true, PostID: postID, StatusCode: res.StatusCode})
 		postedCount++
 		log.Printf("[FBPublish] ok userId=%s pageId=%s postId=%s", userID, page.ID, postID)
 
 		// Store this in SocialLibraries as "created content"
-		if postID != "" {
+		if postID != "" && opts.live() {
 			rawPayload := strings.ReplaceAll(string(body), "\x00", "")
 			if !utf8.ValidString(rawPayload) {
 				rawPayload = strings.ToValidUTF8(rawPayload, "�")
@@ -4799,20 +5341,38 @@ func (h *Handler) publishFacebookPages(ctx context.Context, userID string, capti
 	}
 
 	details["pages"] = pageResults
-	// If *every* page errored, surface an error
-	if postedCount == 0 && !dryRun {
+	if dryRun {
+		return postedCount, nil, details
+	}
+	// Pages the user can't post to are reported but don't fail the run; any other failed page does, so the
+	// job can be retried for it.
+	failed := 0
+	for _, pr := range pageResults {
+		if !pr.Posted && pr.Error != "insufficient_page_role" {
+			failed++
+		}
+	}
+	if postedCount == 0 && (failed > 0 || len(opts.SkipPages) == 0) {
 		return 0, fmt.Errorf("no_posts_created"), details
 	}
+	if failed > 0 {
+		return postedCount, fmt.Errorf("partial_failure"), details
+	}
 	return postedCount, nil, details
 }
 
 func fbPublishWithImages(ctx context.Context, client *http.Client, pageID, pageToken, caption string, media []uploadedMedia) (postID string, status int, bodyText string, errMsg string, err error) {
+	return fbPublishImages(ctx, client, pageID, pageToken, caption, media, fbPublishOptions{})
+}
+
+// fbPublishImages is fbPublishWithImages with visibility and scheduling from opts.
+func fbPublishImages(ctx context.Context, client *http.Client, pageID, pageToken, caption string, media []uploadedMedia, opts fbPublishOptions) (postID string, status int, bodyText string, errMsg string, err error) {
 	if client == nil {
 		client = &http.Client{Timeout: 60 * time.Second}
 	}
 	// One image: publish directly via /photos (creates a photo post).
 	if len(media) == 1 {
-		photoID, createdPostID, status, bodyText, errMsg, err := fbUploadPhoto(ctx, client, pageID, pageToken, caption, media[0], true)
+		photoID, createdPostID, status, bodyText, errMsg, err := fbUploadPhoto(ctx, client, pageID, pageToken, caption, media[0], opts.publishFields())
 		if err != nil {
 			return "", status, bodyText, errMsg, err
 		}
@@ -4823,9 +5383,14 @@ func fbPublishWithImages(ctx context.Context, client *http.Client, pageID, pageT
 	}
 
 	// Multiple: upload unpublished photos, then create a feed post with attached_media.
+	// Photos attached to a scheduled post must be uploaded as temporary.
+	child := url.Values{"published": {"false"}}
+	if opts.scheduled() {
+		child.Set("temporary", "true")
+	}
 	mediaIDs := make([]string, 0, len(media))
 	for _, m := range media {
-		photoID, _, status, bodyText, errMsg, err := fbUploadPhoto(ctx, client, pageID, pageToken, "", m, false)
+		photoID, _, status, bodyText, errMsg, err := fbUploadPhoto(ctx, client, pageID, pageToken, "", m, child)
 		if err != nil {
 			return "", status, bodyText, errMsg, err
 		}
@@ -4844,6 +5409,11 @@ func fbPublishWithImages(ctx context.Context, client *http.Client, pageID, pageT
 		// Each value must be a JSON object string
 		form.Set(fmt.Sprintf("attached_media[%d]", i), fmt.Sprintf(`{"media_fbid":"%s"}`, id))
 	}
+	if !opts.live() {
+		for k, v := range opts.publishFields() {
+			form[k] = v
+		}
+	}
 	endpoint := fmt.Sprintf("https://graph.facebook.com/v24.0/%s/feed", url.PathEscape(pageID))
 	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
 	if err != nil {
@@ -4884,7 +5454,8 @@ func extractFacebookErrorMessage(body []byte, fallback string) string {
 	return truncate(errMsg, 400)
 }
 
-func fbUploadPhoto(ctx context.Context, client *http.Client, pageID, pageToken, caption string, media uploadedMedia, published bool) (photoID string, postID string, status int, bodyText string, errMsg string, err error) {
+// fbUploadPhoto uploads a photo with the given visibility fields (published, scheduled_publish_time, temporary).
+func fbUploadPhoto(ctx context.Context, client *http.Client, pageID, pageToken, caption string, media uploadedMedia, fields url.Values) (photoID string, postID string, status int, bodyText string, errMsg string, err error) {
 	var buf bytes.Buffer
 	w := multipart.NewWriter(&buf)
 
@@ -4893,10 +5464,10 @@ func fbUploadPhoto(ctx context.Context, client *http.Client, pageID, pageToken,
 	if caption != "" {
 		_ = w.WriteField("message", caption)
 	}
-	if published {
-		_ = w.WriteField("published", "true")
-	} else {
-		_ = w.WriteField("published", "false")
+	for k, vs := range fields {
+		for _, v := range vs {
+			_ = w.WriteField(k, v)
+		}
 	}
 
 	fw, err := w.CreateFormFile("source", media.Filename)
@@ -4972,6 +5543,12 @@ type pinterestOAuth struct {
 }
 
 func (h *Handler) publishInstagramWithImageURLs(ctx context.Context, userID, caption string, imageURLs []string, dryRun bool) (int, error, map[string]interface{}) {
+	return h.publishInstagramImages(ctx, userID, caption, imageURLs, instagramPublishOptions{}, dryRun)
+}
+
+// publishInstagramImages publishes one image or an image carousel; opts adds alt text and user tags per
+// image plus the post's location and collaborators.
+func (h *Handler) publishInstagramImages(ctx context.Context, userID, caption string, imageURLs []string, opts instagramPublishOptions, dryRun bool) (int, error, map[string]interface{}) {
 	details := map[string]interface{}{"imageUrls": imageURLs}
 	if len(imageURLs) == 0 {
 		return 0, fmt.Errorf("instagram_requires_image"), details
@@ -5055,14 +5632,17 @@ func (h *Handler) publishInstagramWithImageURLs(ctx context.Context, userID, cap
 
 	// Create media containers (children for carousel, or the single post container).
 	containerIDs := []string{}
-	for _, img := range imageURLs {
+	for i, img := range imageURLs {
 		form := url.Values{}
 		form.Set("image_url", img)
 		form.Set("access_token", accessToken)
+		alt, tags := opts.item(i)
+		setInstagramItemFields(form, alt, tags, false)
 		if len(imageURLs) > 1 {
 			form.Set("is_carousel_item", "true")
 		} else {
 			form.Set("caption", caption)
+			setInstagramPostFields(form, opts)
 		}
 		endpoint := fmt.Sprintf("https://graph.facebook.com/v24.0/%s/media", url.PathEscape(igID))
 		req, _ := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
@@ -5102,6 +5682,7 @@ func (h *Handler) publishInstagramWithImageURLs(ctx context.Context, userID, cap
 		form.Set("children", strings.Join(containerIDs, ","))
 		form.Set("caption", caption)
 		form.Set("access_token", accessToken)
+		setInstagramPostFields(form, opts)
 		endpoint := fmt.Sprintf("https://graph.facebook.com/v24.0/%s/media", url.PathEscape(igID))
 		req, _ := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
@@ -5181,6 +5762,11 @@ func (h *Handler) publishInstagramWithImageURLs(ctx context.Context, userID, cap
 }
 
 func (h *Handler) publishInstagramReelWithVideoURL(ctx context.Context, userID, caption string, videoURL string, dryRun bool) (int, error, map[string]interface{}) {
+	return h.publishInstagramReel(ctx, userID, caption, videoURL, instagramPublishOptions{}, dryRun)
+}
+
+// publishInstagramReel publishes a video as a Reel; opts adds user tags, location and collaborators.
+func (h *Handler) publishInstagramReel(ctx context.Context, userID, caption string, videoURL string, opts instagramPublishOptions, dryRun bool) (int, error, map[string]interface{}) {
 	details := map[string]interface{}{"videoUrl": videoURL, "mediaType": "REELS"}
 	videoURL = strings.TrimSpace(videoURL)
 	if videoURL == "" {
@@ -5274,6 +5860,9 @@ func (h *Handler) publishInstagramReelWithVideoURL(ctx context.Context, userID,
 	form.Set("caption", caption)
 	form.Set("share_to_feed", "true")
 	form.Set("access_token", accessToken)
+	_, tags := opts.item(0)
+	setInstagramItemFields(form, "", tags, true)
+	setInstagramPostFields(form, opts)
 	endpoint := fmt.Sprintf("https://graph.facebook.com/v24.0/%s/media", url.PathEscape(igID))
 	req, _ := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
@@ -5544,120 +6133,51 @@ func (h *Handler) deleteYouTubeVideo(ctx context.Context, userID string, videoID
 }
 
 func (h *Handler) publishTikTokWithVideoURL(ctx context.Context, userID, caption string, videoURL string, dryRun bool) (int, error, map[string]interface{}) {
-	details := map[string]interface{}{"videoUrl": videoURL}
-	if strings.TrimSpace(videoURL) == "" {
-		return 0, fmt.Errorf("tiktok_requires_video"), details
-	}
-
-	// Load token from UserSettings
-	var raw []byte
-	if err := h.db.QueryRowContext(ctx, `SELECT value FROM public.user_settings WHERE user_id=$1 AND key='tiktok_oauth' AND value IS NOT NULL`, userID).Scan(&raw); err != nil {
-		if err == sql.ErrNoRows {
-			return 0, fmt.Errorf("not_connected"), details
-		}
-		return 0, err, details
-	}
-	if len(raw) == 0 || string(raw) == "null" {
-		return 0, fmt.Errorf("not_connected"), details
-	}
-	var tok tiktokOAuth
-	if err := json.Unmarshal(raw, &tok); err != nil {
-		return 0, fmt.Errorf("invalid_oauth_payload"), map[string]interface{}{"raw": truncate(string(raw), 800)}
-	}
-	if strings.TrimSpace(tok.AccessToken) == "" || strings.TrimSpace(tok.OpenID) == "" {
-		return 0, fmt.Errorf("not_connected"), details
-	}
-
-	// Guard: publishing requires BOTH video upload + publish scopes (TikTok Content Posting API).
-	// TikTok can return scopes as space-delimited; we normalize spaces/commas and then check exact tokens.
-	scopeSet := map[string]bool{}
-	for _, s := range strings.FieldsFunc(tok.Scope, func(r rune) bool {
-		return r == ' ' || r == ',' || r == '\n' || r == '\r' || r == '\t'
-	}) {
-		s = strings.TrimSpace(s)
-		if s != "" {
-			scopeSet[s] = true
-		}
-	}
-	if !(scopeSet["video.upload"] && scopeSet["video.publish"]) {
-		return 0, fmt.Errorf("missing_scope"), map[string]interface{}{
-			"scope":    tok.Scope,
-			"required": []string{"video.upload", "video.publish"},
-		}
-	}
+	return h.publishTikTok(ctx, userID, caption, tiktokMedia{VideoURL: videoURL}, models.TikTokPostOptions{}, dryRun)
+}
 
-	if dryRun {
-		return 0, nil, map[string]interface{}{"dryRun": true, "videoUrl": videoURL}
-	}
+func (h *Handler) publishPinterestWithImageURL(ctx context.Context, userID, caption, imageURL string, dryRun bool) (int, error, map[string]interface{}) {
+	return h.publishPinterestPin(ctx, userID, caption, pinterestPinMedia{ImageURLs: []string{imageURL}}, pinterestPinOptions{}, dryRun)
+}
 
-	payload := map[string]interface{}{
-		"post_info": map[string]interface{}{
-			"title":           truncate(caption, 150),
-			"privacy_level":   "PUBLIC_TO_EVERYONE",
-			"disable_comment": false,
-			"disable_duet":    false,
-			"disable_stitch":  false,
-		},
-		"source_info": map[string]interface{}{
-			"source":    "PULL_FROM_URL",
-			"video_url": videoURL,
-		},
-	}
-	body, _ := json.Marshal(payload)
+// pinterestPinOptions carries per-post pin fields; empty values keep the defaults (title from the caption, no link,
+// the first board).
+type pinterestPinOptions struct {
+	Title     string
+	Link      string
+	BoardID   string
+	SectionID string
+	AltText   string
+}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://open.tiktokapis.com/v2/post/publish/inbox/video/init/", bytes.NewReader(body))
-	if err != nil {
-		return 0, err, details
+func (h *Handler) publishPinterestPin(ctx context.Context, userID, caption string, media pinterestPinMedia, opts pinterestPinOptions, dryRun bool) (int, error, map[string]interface{}) {
+	imageURLs := make([]string, 0, len(media.ImageURLs))
+	for _, u := range media.ImageURLs {
+		if strings.TrimSpace(u) != "" {
+			imageURLs = append(imageURLs, u)
+		}
 	}
-	req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-
-	client := &http.Client{Timeout: 60 * time.Second}
-	res, err := client.Do(req)
-	if err != nil {
-		return 0, err, details
+	media.ImageURLs = imageURLs
+	details := map[string]interface{}{}
+	coverURL := ""
+	if len(imageURLs) > 0 {
+		coverURL = imageURLs[0]
+		details["imageUrl"] = coverURL
 	}
-	b, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
-	_ = res.Body.Close()
-
-	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		return 0, fmt.Errorf("tiktok_non_2xx"), map[string]interface{}{"status": res.StatusCode, "body": truncate(string(b), 2000)}
+	if len(imageURLs) > 1 && media.Video == nil {
+		details["imageUrls"] = imageURLs
 	}
-
-	// Response shape varies; keep raw for debugging.
-	details["response"] = json.RawMessage(b)
-	log.Printf("[TTPost] ok userId=%s", userID)
-	// NOTE: inbox flow may require user to finalize in TikTok app. We still mark as "posted 1" for now.
-	return 1, nil, details
-}
-
-func (h *Handler) publishPinterestWithImageURL(ctx context.Context, userID, caption, imageURL string, dryRun bool) (int, error, map[string]interface{}) {
-	details := map[string]interface{}{
-		"imageUrl": imageURL,
+	if media.Video != nil {
+		details["video"] = media.Video.Filename
 	}
-	if strings.TrimSpace(imageURL) == "" {
+	if len(imageURLs) == 0 && media.Video == nil {
 		return 0, fmt.Errorf("pinterest_requires_image"), details
 	}
 
-	// Load token
-	var raw []byte
-	if err := h.db.QueryRowContext(ctx, `SELECT value FROM public.user_settings WHERE user_id=$1 AND key='pinterest_oauth' AND value IS NOT NULL`, userID).Scan(&raw); err != nil {
-		if err == sql.ErrNoRows {
-			return 0, fmt.Errorf("not_connected"), details
-		}
+	tok, err := h.loadPinterestOAuth(ctx, userID)
+	if err != nil {
 		return 0, err, details
 	}
-	if len(raw) == 0 || string(raw) == "null" {
-		return 0, fmt.Errorf("not_connected"), details
-	}
-	var tok pinterestOAuth
-	if err := json.Unmarshal(raw, &tok); err != nil {
-		return 0, fmt.Errorf("invalid_oauth_payload"), map[string]interface{}{"raw": truncate(string(raw), 800)}
-	}
-	if strings.TrimSpace(tok.AccessToken) == "" {
-		return 0, fmt.Errorf("not_connected"), details
-	}
 
 	// Basic scope guard (Pinterest returns comma-delimited scopes; be permissive).
 	scope := strings.ToLower(tok.Scope)
@@ -5720,9 +6240,9 @@ func (h *Handler) publishPinterestWithImageURL(ctx context.Context, userID, capt
 	publishOnce := func(apiBase string) (int, error, map[string]interface{}, bool) {
 		local := map[string]interface{}{"apiBase": apiBase}
 
-		// 1) Find a board (or create a default one)
-		boardID := ""
-		{
+		// 1) Use the chosen board, or find one (creating a default one if needed)
+		boardID := opts.BoardID
+		if boardID == "" {
 			reqURL := strings.TrimRight(apiBase, "/") + "/v5/boards?page_size=25"
 			req, _ := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
 			req.Header.Set("Authorization", authHeader)
@@ -5781,20 +6301,39 @@ func (h *Handler) publishPinterestWithImageURL(ctx context.Context, userID, capt
 			return 0, fmt.Errorf("pinterest_no_board"), local, false
 		}
 		local["boardId"] = boardID
-
-		// 2) Create pin
-		title := strings.TrimSpace(caption)
-		if title == "" {
-			title = "New pin"
+		if opts.SectionID != "" {
+			local["sectionId"] = opts.SectionID
 		}
-		if len(title) > 95 {
-			title = truncate(title, 95)
+
+		// 2) Upload the video, if any
+		videoMediaID := ""
+		if media.Video != nil {
+			id, err, det := pinterestUploadVideo(ctx, client, apiBase, tok.AccessToken, *media.Video)
+			for k, v := range det {
+				local[k] = v
+			}
+			if err != nil {
+				return 0, err, local, false
+			}
+			videoMediaID = id
 		}
+
+		// 3) Create pin
+		title := pinterestPinTitle(caption, opts)
 		pinReq := map[string]interface{}{
 			"board_id":     boardID,
 			"title":        title,
 			"description":  caption,
-			"media_source": map[string]interface{}{"source_type": "image_url", "url": imageURL},
+			"media_source": pinterestMediaSource(media, videoMediaID, opts.Link),
+		}
+		if opts.SectionID != "" {
+			pinReq["board_section_id"] = opts.SectionID
+		}
+		if opts.Link != "" {
+			pinReq["link"] = opts.Link
+		}
+		if opts.AltText != "" {
+			pinReq["alt_text"] = opts.AltText
 		}
 		pinBytes, _ := json.Marshal(pinReq)
 		req, _ := http.NewRequestWithContext(ctx, "POST", strings.TrimRight(apiBase, "/")+"/v5/pins", bytes.NewReader(pinBytes))
@@ -5844,7 +6383,7 @@ func (h *Handler) publishPinterestWithImageURL(ctx context.Context, userID, capt
 				  media_url = EXCLUDED.media_url,
 				  raw_payload = EXCLUDED.raw_payload,
 				  updated_at = NOW()
-			`, rowID, userID, title, link, imageURL, rawPayload, pinID)
+			`, rowID, userID, title, link, coverURL, rawPayload, pinID)
 		}
 
 		log.Printf("[PINPublish] ok userId=%s pinId=%s apiBase=%s", userID, pinID, apiBase)
@@ -5852,13 +6391,7 @@ func (h *Handler) publishPinterestWithImageURL(ctx context.Context, userID, capt
 	}
 
 	// Allow overriding the Pinterest API base (useful for trial apps that must use api-sandbox).
-	apiBase := strings.TrimSpace(os.Getenv("PINTEREST_API_BASE"))
-	if apiBase == "" {
-		apiBase = "https://api.pinterest.com"
-	}
-	if strings.EqualFold(apiBase, "sandbox") {
-		apiBase = "https://api-sandbox.pinterest.com"
-	}
+	apiBase := pinterestAPIBase()
 	details["apiBaseConfigured"] = apiBase
 
 	// Trial apps cannot create pins using the production API host; Pinterest returns code=29 and instructs using api-sandbox.
@@ -5900,151 +6433,7 @@ func (h *Handler) publishPinterestWithImageURL(ctx context.Context, userID, capt
 }
 
 func (h *Handler) publishYouTubeWithVideoBytes(ctx context.Context, userID, caption string, video uploadedMedia, dryRun bool) (int, error, map[string]interface{}) {
-	details := map[string]interface{}{
-		"contentType": video.ContentType,
-		"size":        len(video.Bytes),
-	}
-	if len(video.Bytes) == 0 {
-		return 0, fmt.Errorf("youtube_requires_video"), details
-	}
-
-	// Load token
-	var raw []byte
-	if err := h.db.QueryRowContext(ctx, `SELECT value FROM public.user_settings WHERE user_id=$1 AND key='youtube_oauth' AND value IS NOT NULL`, userID).Scan(&raw); err != nil {
-		if err == sql.ErrNoRows {
-			return 0, fmt.Errorf("not_connected"), details
-		}
-		return 0, err, details
-	}
-	if len(raw) == 0 || string(raw) == "null" {
-		return 0, fmt.Errorf("not_connected"), details
-	}
-	var tok youtubeOAuth
-	if err := json.Unmarshal(raw, &tok); err != nil {
-		return 0, fmt.Errorf("invalid_oauth_payload"), map[string]interface{}{"raw": truncate(string(raw), 800)}
-	}
-	if strings.TrimSpace(tok.AccessToken) == "" {
-		return 0, fmt.Errorf("not_connected"), details
-	}
-
-	// Guard: publishing requires youtube.upload
-	if !strings.Contains(tok.Scope, "youtube.upload") {
-		return 0, fmt.Errorf("missing_scope"), map[string]interface{}{"scope": tok.Scope, "required": []string{"https://www.googleapis.com/auth/youtube.upload"}}
-	}
-
-	// Best-effort: detect expiration (we don't refresh tokens server-side yet).
-	if strings.TrimSpace(tok.ExpiresAt) != "" {
-		if t, err := time.Parse(time.RFC3339, tok.ExpiresAt); err == nil {
-			if time.Now().After(t.Add(-30 * time.Second)) {
-				return 0, fmt.Errorf("token_expired_reconnect"), map[string]interface{}{"expiresAt": tok.ExpiresAt}
-			}
-		}
-	}
-
-	if dryRun {
-		return 0, nil, map[string]interface{}{"dryRun": true}
-	}
-
-	title := strings.TrimSpace(caption)
-	if title == "" {
-		title = "New video"
-	}
-	if len(title) > 95 {
-		title = truncate(title, 95)
-	}
-
-	meta := map[string]interface{}{
-		"snippet": map[string]interface{}{
-			"title":       title,
-			"description": caption,
-			"categoryId":  "22",
-		},
-		"status": map[string]interface{}{
-			"privacyStatus": "public",
-		},
-	}
-	metaBytes, _ := json.Marshal(meta)
-
-	client := &http.Client{Timeout: 120 * time.Second}
-	initURL := "https://www.googleapis.com/upload/youtube/v3/videos?uploadType=resumable&part=snippet,status"
-	initReq, err := http.NewRequestWithContext(ctx, "POST", initURL, bytes.NewReader(metaBytes))
-	if err != nil {
-		return 0, err, details
-	}
-	initReq.Header.Set("Authorization", "Bearer "+tok.AccessToken)
-	initReq.Header.Set("Content-Type", "application/json; charset=UTF-8")
-	initReq.Header.Set("Accept", "application/json")
-	initReq.Header.Set("X-Upload-Content-Length", fmt.Sprintf("%d", len(video.Bytes)))
-	if video.ContentType != "" {
-		initReq.Header.Set("X-Upload-Content-Type", video.ContentType)
-	}
-
-	initRes, err := client.Do(initReq)
-	if err != nil {
-		return 0, err, details
-	}
-	initBody, _ := io.ReadAll(io.LimitReader(initRes.Body, 1<<20))
-	_ = initRes.Body.Close()
-	if initRes.StatusCode < 200 || initRes.StatusCode >= 300 {
-		return 0, fmt.Errorf("youtube_init_non_2xx"), map[string]interface{}{"status": initRes.StatusCode, "body": truncate(string(initBody), 2000)}
-	}
-	uploadURL := initRes.Header.Get("Location")
-	if uploadURL == "" {
-		return 0, fmt.Errorf("youtube_missing_upload_url"), map[string]interface{}{"headers": initRes.Header}
-	}
-
-	putReq, err := http.NewRequestWithContext(ctx, "PUT", uploadURL, bytes.NewReader(video.Bytes))
-	if err != nil {
-		return 0, err, details
-	}
-	putReq.Header.Set("Authorization", "Bearer "+tok.AccessToken)
-	if video.ContentType != "" {
-		putReq.Header.Set("Content-Type", video.ContentType)
-	} else {
-		putReq.Header.Set("Content-Type", "application/octet-stream")
-	}
-	putReq.Header.Set("Content-Length", fmt.Sprintf("%d", len(video.Bytes)))
-
-	putRes, err := client.Do(putReq)
-	if err != nil {
-		return 0, err, details
-	}
-	putBody, _ := io.ReadAll(io.LimitReader(putRes.Body, 4<<20))
-	_ = putRes.Body.Close()
-	if putRes.StatusCode < 200 || putRes.StatusCode >= 300 {
-		return 0, fmt.Errorf("youtube_upload_non_2xx"), map[string]interface{}{"status": putRes.StatusCode, "body": truncate(string(putBody), 3000)}
-	}
-
-	var published map[string]interface{}
-	_ = json.Unmarshal(putBody, &published)
-	videoID, _ := published["id"].(string)
-	details["videoId"] = videoID
-	details["response"] = json.RawMessage(putBody)
-
-	if videoID != "" {
-		permalink := fmt.Sprintf("https://www.youtube.com/watch?v=%s", videoID)
-		rawPayload := strings.ReplaceAll(string(putBody), "\x00", "")
-		if !utf8.ValidString(rawPayload) {
-			rawPayload = strings.ToValidUTF8(rawPayload, "�")
-		}
-		rowID := fmt.Sprintf("youtube:%s:%s", userID, videoID)
-		_, _ = h.db.ExecContext(ctx, `
-			INSERT INTO public.social_libraries
-			  (id, user_id, network, content_type, title, permalink_url, media_url, thumbnail_url, posted_at, views, likes, raw_payload, external_id, created_at, updated_at)
-			VALUES
-			  ($1, $2, 'youtube', 'video', NULLIF($3,''), $4, $4, NULL, NOW(), NULL, NULL, $5::jsonb, $6, NOW(), NOW())
-			ON CONFLICT (user_id, network, external_id)
-			DO UPDATE SET
-			  title = EXCLUDED.title,
-			  permalink_url = EXCLUDED.permalink_url,
-			  media_url = EXCLUDED.media_url,
-			  raw_payload = EXCLUDED.raw_payload,
-			  updated_at = NOW()
-		`, rowID, userID, title, permalink, rawPayload, videoID)
-	}
-
-	log.Printf("[YTPublish] ok userId=%s videoId=%s", userID, videoID)
-	return 1, nil, details
+	return h.publishYouTubeVideo(ctx, userID, caption, video, youtubeVideoOptions{}, dryRun)
 }
 
 func (h *Handler) publishInstagram(ctx context.Context, r *http.Request, userID, caption string, media []uploadedMedia, dryRun bool) (int, error, map[string]interface{}) {
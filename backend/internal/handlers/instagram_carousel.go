@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	instagramMaxCarouselItems    = 10
+	instagramMaxCarouselVideoSec = 60
+)
+
+// instagramCarouselItem is one child of a carousel: a public image or video URL.
+type instagramCarouselItem struct {
+	URL     string
+	IsVideo bool
+}
+
+// pollInstagramContainer waits for a media container to reach FINISHED (videos take a while to process).
+// It returns the last status_code seen and the status detail Instagram gives for ERROR containers.
+func pollInstagramContainer(ctx context.Context, client *http.Client, containerID, accessToken string, attempts int) (string, string, error) {
+	var last, detail string
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return last, detail, ctx.Err()
+			case <-time.After(2 * time.Second):
+			}
+		}
+		endpoint := fmt.Sprintf("https://graph.facebook.com/v24.0/%s?fields=status_code,status&access_token=%s",
+			url.PathEscape(containerID),
+			url.QueryEscape(accessToken),
+		)
+		req, _ := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+		req.Header.Set("Accept", "application/json")
+		res, err := client.Do(req)
+		if err != nil {
+			last = "request_error"
+			continue
+		}
+		b, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+		_ = res.Body.Close()
+		if res.StatusCode < 200 || res.StatusCode >= 300 {
+			last = fmt.Sprintf("http_%d", res.StatusCode)
+			continue
+		}
+		var sr struct {
+			StatusCode string `json:"status_code"`
+			Status     string `json:"status"`
+		}
+		if err := json.Unmarshal(b, &sr); err != nil {
+			last = "bad_json"
+			continue
+		}
+		last, detail = strings.ToUpper(strings.TrimSpace(sr.StatusCode)), sr.Status
+		if last == "FINISHED" {
+			return last, detail, nil
+		}
+		if last == "ERROR" || last == "EXPIRED" {
+			return last, detail, fmt.Errorf("instagram_container_%s", strings.ToLower(last))
+		}
+	}
+	return last, detail, fmt.Errorf("instagram_container_not_ready")
+}
+
+// createInstagramContainer POSTs a /media container and returns its id.
+func createInstagramContainer(ctx context.Context, client *http.Client, igID string, form url.Values) (string, int, []byte, error) {
+	endpoint := fmt.Sprintf("https://graph.facebook.com/v24.0/%s/media", url.PathEscape(igID))
+	req, _ := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	_ = res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return "", res.StatusCode, b, fmt.Errorf("%s", extractFacebookErrorMessage(b, string(b)))
+	}
+	var obj struct {
+		ID string `json:"id"`
+	}
+	_ = json.Unmarshal(b, &obj)
+	if obj.ID == "" {
+		return "", res.StatusCode, b, fmt.Errorf("missing_container_id")
+	}
+	return obj.ID, res.StatusCode, b, nil
+}
+
+// publishInstagramCarousel publishes images and videos as one carousel, keeping the order of items
+// (opts is aligned with items).
+// Every child is created and polled until FINISHED before the parent is built; details.children reports
+// each child's container id and status so a single bad item is easy to spot.
+func (h *Handler) publishInstagramCarousel(ctx context.Context, userID, caption string, items []instagramCarouselItem, opts instagramPublishOptions, dryRun bool) (int, error, map[string]interface{}) {
+	details := map[string]interface{}{"mediaType": "CAROUSEL", "itemCount": len(items)}
+	if len(items) < 2 {
+		return 0, fmt.Errorf("instagram_carousel_requires_multiple_items"), details
+	}
+	if len(items) > instagramMaxCarouselItems {
+		details["maxItems"] = instagramMaxCarouselItems
+		return 0, fmt.Errorf("instagram_carousel_too_many_items"), details
+	}
+	tok, err := h.loadInstagramOAuth(ctx, userID)
+	if err != nil {
+		return 0, err, details
+	}
+	if dryRun {
+		details["dryRun"] = true
+		return 0, nil, details
+	}
+
+	client := &http.Client{Timeout: 120 * time.Second}
+	igID := tok.IGBusinessID
+	children := make([]map[string]interface{}, len(items))
+	details["children"] = children
+	childIDs := make([]string, 0, len(items))
+
+	for i, it := range items {
+		child := map[string]interface{}{"index": i, "url": it.URL, "mediaType": "IMAGE"}
+		children[i] = child
+		form := url.Values{}
+		form.Set("is_carousel_item", "true")
+		form.Set("access_token", tok.AccessToken)
+		if it.IsVideo {
+			child["mediaType"] = "VIDEO"
+			form.Set("media_type", "VIDEO")
+			form.Set("video_url", it.URL)
+		} else {
+			form.Set("image_url", it.URL)
+		}
+		alt, tags := opts.item(i)
+		setInstagramItemFields(form, alt, tags, it.IsVideo)
+		id, status, body, err := createInstagramContainer(ctx, client, igID, form)
+		if err != nil {
+			child["error"] = err.Error()
+			if status != 0 {
+				child["status"] = status
+				child["body"] = truncate(string(body), 600)
+			}
+			details["failedIndex"] = i
+			return 0, fmt.Errorf("instagram_carousel_child_failed"), details
+		}
+		child["containerId"] = id
+
+		// Videos need up to a couple of minutes to process; images are usually ready on the first poll.
+		attempts := 30
+		if it.IsVideo {
+			attempts = 90
+		}
+		st, detail, err := pollInstagramContainer(ctx, client, id, tok.AccessToken, attempts)
+		child["containerStatus"] = st
+		if err != nil {
+			child["error"] = err.Error()
+			if detail != "" {
+				child["statusDetail"] = detail
+			}
+			details["failedIndex"] = i
+			log.Printf("[IGCarousel] child_failed userId=%s index=%d video=%v containerId=%s status=%s detail=%s", userID, i, it.IsVideo, id, st, truncate(detail, 200))
+			return 0, fmt.Errorf("instagram_carousel_child_failed"), details
+		}
+		childIDs = append(childIDs, id)
+	}
+
+	form := url.Values{}
+	form.Set("media_type", "CAROUSEL")
+	form.Set("children", strings.Join(childIDs, ","))
+	form.Set("caption", caption)
+	form.Set("access_token", tok.AccessToken)
+	setInstagramPostFields(form, opts)
+	parentID, status, body, err := createInstagramContainer(ctx, client, igID, form)
+	if err != nil {
+		return 0, fmt.Errorf("instagram_carousel_failed"), map[string]interface{}{"status": status, "error": err.Error(), "body": truncate(string(body), 1200), "children": children}
+	}
+	details["containerId"] = parentID
+	if st, detail, err := pollInstagramContainer(ctx, client, parentID, tok.AccessToken, 60); err != nil {
+		details["containerStatus"] = st
+		details["statusDetail"] = detail
+		return 0, fmt.Errorf("instagram_container_not_ready"), details
+	}
+
+	pubForm := url.Values{}
+	pubForm.Set("creation_id", parentID)
+	pubForm.Set("access_token", tok.AccessToken)
+	pubEndpoint := fmt.Sprintf("https://graph.facebook.com/v24.0/%s/media_publish", url.PathEscape(igID))
+	req, _ := http.NewRequestWithContext(ctx, "POST", pubEndpoint, strings.NewReader(pubForm.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err, details
+	}
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	_ = res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		msg := extractFacebookErrorMessage(b, string(b))
+		return 0, fmt.Errorf("instagram_publish_failed"), map[string]interface{}{"status": res.StatusCode, "error": msg, "body": truncate(string(b), 1200), "children": children}
+	}
+	var pub struct {
+		ID string `json:"id"`
+	}
+	_ = json.Unmarshal(b, &pub)
+	details["publishedId"] = pub.ID
+
+	if pub.ID != "" {
+		rawPayload := strings.ReplaceAll(string(b), "\x00", "")
+		if !utf8.ValidString(rawPayload) {
+			rawPayload = strings.ToValidUTF8(rawPayload, "�")
+		}
+		_, _ = h.db.ExecContext(ctx, `
+			INSERT INTO public.social_libraries
+			  (id, user_id, network, content_type, title, permalink_url, media_url, thumbnail_url, posted_at, views, likes, raw_payload, external_id, created_at, updated_at)
+			VALUES
+			  ($1, $2, 'instagram', 'post', NULLIF($3,''), NULL, NULL, NULL, NOW(), NULL, NULL, $4::jsonb, $5, NOW(), NOW())
+			ON CONFLICT (user_id, network, external_id)
+			DO UPDATE SET
+			  title = EXCLUDED.title,
+			  raw_payload = EXCLUDED.raw_payload,
+			  updated_at = NOW()
+		`, fmt.Sprintf("instagram:%s:%s", userID, pub.ID), userID, caption, rawPayload, pub.ID)
+	}
+
+	log.Printf("[IGPublish] ok userId=%s igBusinessId=%s mediaId=%s mediaType=CAROUSEL items=%d", userID, igID, pub.ID, len(items))
+	return 1, nil, details
+}
+
+// prepareInstagramVideo makes relMedia[idx] publishable on Instagram: the video is loaded (from mediaFiles or
+// disk), transcoded when it is not an H.264/AAC MP4 and re-saved so Instagram can fetch the new file.
+// relMedia and mediaFiles are updated in place. On failure it returns the provider error code and details.
+func prepareInstagramVideo(jobID, userID, postID string, idx int, relMedia []string, mediaFiles []uploadedMedia, received []map[string]interface{}) (string, map[string]interface{}) {
+	ct := ""
+	fn := ""
+	sz := 0
+	videoBytes := []byte{}
+	if idx < len(mediaFiles) {
+		ct = mediaFiles[idx].ContentType
+		fn = mediaFiles[idx].Filename
+		videoBytes = mediaFiles[idx].Bytes
+		sz = len(videoBytes)
+	} else {
+		fn = filepath.Base(relMedia[idx])
+		// If video is not in mediaFiles, try to load it from disk
+		if idx < len(relMedia) {
+			rel := strings.TrimSpace(relMedia[idx])
+			if rel != "" {
+				local := strings.TrimPrefix(rel, "/media/")
+				path, perr := safeMediaJoin(local)
+				if perr != nil {
+					log.Printf("[PublishJob] blocked path traversal: jobId=%s userId=%s postId=%s rel=%s err=%v", jobID, userID, postID, rel, perr)
+				} else if b, err := os.ReadFile(path); err == nil {
+					videoBytes = b
+					sz = len(videoBytes)
+					ct = http.DetectContentType(b)
+					// Prefer MIME type from extension
+					if strings.HasPrefix(strings.ToLower(ct), "application/octet-stream") {
+						if ext := strings.ToLower(filepath.Ext(fn)); ext != "" {
+							if byExt := mime.TypeByExtension(ext); byExt != "" {
+								ct = byExt
+							}
+						}
+					}
+					log.Printf("[PublishJob] loaded video from disk: jobId=%s userId=%s postId=%s path=%s size=%d", jobID, userID, postID, path, sz)
+				} else {
+					log.Printf("[PublishJob] failed to load video from disk: jobId=%s userId=%s postId=%s path=%s err=%v", jobID, userID, postID, path, err)
+				}
+			}
+		}
+	}
+	ctLower := strings.ToLower(strings.TrimSpace(ct))
+	if semi := strings.Index(ctLower, ";"); semi >= 0 {
+		ctLower = strings.TrimSpace(ctLower[:semi])
+	}
+	relLower := strings.ToLower(relMedia[idx])
+	fnLower := strings.ToLower(fn)
+
+	// Check if video needs transcoding
+	needsTranscode := !(ctLower == "video/mp4" || strings.HasSuffix(relLower, ".mp4") || strings.HasSuffix(fnLower, ".mp4"))
+	log.Printf("[PublishJob] video format check: jobId=%s userId=%s postId=%s filename=%s contentType=%s isMp4=%v videoBytes=%d", jobID, userID, postID, fn, ctLower, !needsTranscode, len(videoBytes))
+
+	// For MP4 files, validate codec compatibility
+	var codecCheckNeeded bool
+	if !needsTranscode && len(videoBytes) > 0 {
+		log.Printf("[PublishJob] checking mp4 codecs: jobId=%s userId=%s postId=%s", jobID, userID, postID)
+		// Save to temp file to check codecs
+		tmpFile, err := os.CreateTemp("", "video_codec_check_*.mp4")
+		if err != nil {
+			log.Printf("[PublishJob] codec check failed to create temp file: %v", err)
+		} else {
+			defer os.Remove(tmpFile.Name())
+			defer tmpFile.Close()
+			if _, err := tmpFile.Write(videoBytes); err != nil {
+				log.Printf("[PublishJob] codec check failed to write temp file: %v", err)
+			} else {
+				tmpFile.Close()
+				hasH264, hasAAC, err := validateVideoCodecs(tmpFile.Name())
+				if err != nil {
+					log.Printf("[PublishJob] codec check failed: %v", err)
+				} else if !hasH264 || !hasAAC {
+					log.Printf("[PublishJob] mp4 codec check: jobId=%s userId=%s postId=%s hasH264=%v hasAAC=%v - needs transcode", jobID, userID, postID, hasH264, hasAAC)
+					needsTranscode = true
+					codecCheckNeeded = true
+				} else {
+					log.Printf("[PublishJob] mp4 codec check: jobId=%s userId=%s postId=%s hasH264=%v hasAAC=%v - compatible", jobID, userID, postID, hasH264, hasAAC)
+				}
+			}
+		}
+	}
+
+	// If video needs transcoding (either not MP4 or MP4 with incompatible codecs)
+	if needsTranscode && len(videoBytes) > 0 {
+		if codecCheckNeeded {
+			log.Printf("[PublishJob] transcoding mp4 with incompatible codecs: jobId=%s userId=%s postId=%s filename=%s", jobID, userID, postID, fn)
+		} else {
+			log.Printf("[PublishJob] transcoding video for instagram: jobId=%s userId=%s postId=%s filename=%s contentType=%s", jobID, userID, postID, fn, ctLower)
+		}
+		transcodedBytes, err := preprocessVideoForInstagram(videoBytes, fn)
+		if err != nil {
+			return "instagram_video_transcode_failed", map[string]interface{}{"received": received, "contentType": ctLower, "filename": fn, "error": err.Error()}
+		}
+		// Update media file with transcoded video
+		fn = strings.TrimSuffix(fn, filepath.Ext(fn)) + ".mp4"
+		if idx < len(mediaFiles) {
+			mediaFiles[idx].Bytes = transcodedBytes
+			mediaFiles[idx].ContentType = "video/mp4"
+			mediaFiles[idx].Filename = fn
+		}
+		sz = len(transcodedBytes)
+		log.Printf("[PublishJob] video transcoded: jobId=%s userId=%s postId=%s original_size=%d transcoded_size=%d", jobID, userID, postID, len(videoBytes), sz)
+
+		// Re-save the transcoded video to disk
+		savedPaths, _, err := saveUploadedMedia(userID, "", []uploadedMedia{{
+			Filename:    fn,
+			ContentType: "video/mp4",
+			Bytes:       transcodedBytes,
+		}})
+		if err != nil || len(savedPaths) == 0 {
+			return "instagram_video_save_failed", map[string]interface{}{"received": received, "error": err.Error()}
+		}
+		relMedia[idx] = savedPaths[0]
+	}
+
+	if sz > 0 && sz > instagramMaxReelsBytes {
+		return "instagram_video_too_large", map[string]interface{}{"sizeBytes": sz, "maxBytes": instagramMaxReelsBytes, "received": received}
+	}
+	return "", nil
+}
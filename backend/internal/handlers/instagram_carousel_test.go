@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func expectInstagramOAuth(t *testing.T, mock sqlmock.Sqlmock) {
+	t.Helper()
+	raw, _ := json.Marshal(instagramOAuth{AccessToken: "tok", IGBusinessID: "ig1"})
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings.*key='instagram_oauth'`).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(raw))
+}
+
+func TestPublishInstagramCarousel_MixedChildrenKeepOrder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+	expectInstagramOAuth(t, mock)
+	mock.ExpectExec(`INSERT INTO public\.social_libraries`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	var parentChildren, videoForm string
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		if r.Method == "GET" {
+			return httpJSON(200, `{"status_code":"FINISHED"}`, nil), nil
+		}
+		b, _ := io.ReadAll(r.Body)
+		form, _ := url.ParseQuery(string(b))
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/media_publish"):
+			return httpJSON(200, `{"id":"ig_media_1"}`, nil), nil
+		case form.Get("media_type") == "CAROUSEL":
+			parentChildren = form.Get("children")
+			return httpJSON(200, `{"id":"parent"}`, nil), nil
+		case form.Get("media_type") == "VIDEO":
+			videoForm = string(b)
+			return httpJSON(200, `{"id":"c_video"}`, nil), nil
+		case strings.Contains(form.Get("image_url"), "a.png"):
+			return httpJSON(200, `{"id":"c_a"}`, nil), nil
+		default:
+			return httpJSON(200, `{"id":"c_b"}`, nil), nil
+		}
+	}}
+
+	posted, perr, details := h.publishInstagramCarousel(context.Background(), "u1", "caption", []instagramCarouselItem{
+		{URL: "https://x/a.png"},
+		{URL: "https://x/clip.mp4", IsVideo: true},
+		{URL: "https://x/b.png"},
+	}, instagramPublishOptions{}, false)
+	if perr != nil || posted != 1 {
+		t.Fatalf("expected success, got posted=%d err=%v details=%v", posted, perr, details)
+	}
+	if parentChildren != "c_a,c_video,c_b" {
+		t.Fatalf("children out of order: %q", parentChildren)
+	}
+	if !strings.Contains(videoForm, "is_carousel_item=true") || !strings.Contains(videoForm, "video_url=") {
+		t.Fatalf("unexpected video child form %q", videoForm)
+	}
+	if details["publishedId"] != "ig_media_1" {
+		t.Fatalf("unexpected details %v", details)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestPublishInstagramCarousel_ReportsFailedChild(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+	expectInstagramOAuth(t, mock)
+
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		if r.Method == "GET" {
+			if strings.HasSuffix(r.URL.Path, "/c_video") {
+				return httpJSON(200, `{"status_code":"ERROR","status":"Unsupported video codec"}`, nil), nil
+			}
+			return httpJSON(200, `{"status_code":"FINISHED"}`, nil), nil
+		}
+		b, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(b), "media_type=VIDEO") {
+			return httpJSON(200, `{"id":"c_video"}`, nil), nil
+		}
+		return httpJSON(200, `{"id":"c_img"}`, nil), nil
+	}}
+
+	_, perr, details := h.publishInstagramCarousel(context.Background(), "u1", "caption", []instagramCarouselItem{
+		{URL: "https://x/a.png"},
+		{URL: "https://x/clip.mp4", IsVideo: true},
+	}, instagramPublishOptions{}, false)
+	if perr == nil || perr.Error() != "instagram_carousel_child_failed" || details["failedIndex"] != 1 {
+		t.Fatalf("expected child failure at index 1, got err=%v details=%v", perr, details)
+	}
+	children, _ := details["children"].([]map[string]interface{})
+	if len(children) != 2 || children[1]["statusDetail"] != "Unsupported video codec" || children[0]["containerStatus"] != "FINISHED" {
+		t.Fatalf("unexpected children %v", children)
+	}
+
+	// Over the item limit fails before any API call.
+	items := make([]instagramCarouselItem, instagramMaxCarouselItems+1)
+	if _, perr, _ := h.publishInstagramCarousel(context.Background(), "u1", "caption", items, instagramPublishOptions{}, false); perr == nil || perr.Error() != "instagram_carousel_too_many_items" {
+		t.Fatalf("expected too_many_items, got %v", perr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
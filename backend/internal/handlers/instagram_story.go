@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	instagramStoryWidth       = 1080
+	instagramStoryHeight      = 1920
+	instagramStoryMinVideoSec = 3
+	instagramStoryMaxVideoSec = 60
+	instagramMaxStoryItems    = 10
+)
+
+// instagramStoryLetterboxFilter fits media inside a 1080x1920 frame and pads the rest with black.
+var instagramStoryLetterboxFilter = fmt.Sprintf(
+	"scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2:color=black,setsar=1",
+	instagramStoryWidth, instagramStoryHeight, instagramStoryWidth, instagramStoryHeight,
+)
+
+// isInstagramStoryAspect reports whether width x height is close enough to 9:16 to fill a Story.
+func isInstagramStoryAspect(width, height int) bool {
+	if width <= 0 || height <= 0 {
+		return false
+	}
+	return math.Abs(float64(width)/float64(height)-9.0/16.0) <= 0.02
+}
+
+// letterboxImageForInstagramStory pads an image onto a 9:16 canvas with ffmpeg and returns it as JPEG.
+func letterboxImageForInstagramStory(inputBytes []byte, inputFilename string) ([]byte, error) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return nil, fmt.Errorf("ffmpeg_not_available")
+	}
+	tmpDir := os.TempDir()
+	inputPath := filepath.Join(tmpDir, "story_input_"+randHex(8)+filepath.Ext(inputFilename))
+	outputPath := filepath.Join(tmpDir, "story_output_"+randHex(8)+".jpg")
+	defer func() {
+		_ = os.Remove(inputPath)
+		_ = os.Remove(outputPath)
+	}()
+	if err := os.WriteFile(inputPath, inputBytes, 0o644); err != nil {
+		return nil, fmt.Errorf("failed_to_write_temp_input: %w", err)
+	}
+	args := []string{
+		"-y", "-hide_banner", "-loglevel", "error",
+		"-i", inputPath,
+		"-vf", instagramStoryLetterboxFilter,
+		"-frames:v", "1",
+		"-q:v", "2",
+		outputPath,
+	}
+	if err := exec.Command("ffmpeg", args...).Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg_letterbox_failed: %w", err)
+	}
+	out, err := os.ReadFile(outputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed_to_read_letterboxed_image: %w", err)
+	}
+	return out, nil
+}
+
+// prepareInstagramStoryMedia checks relMedia[idx] against Story limits (9:16, 3–60s videos). Media with
+// another aspect ratio is letterboxed when letterbox is set and rejected otherwise; videos are then
+// transcoded like Reels. relMedia and mediaFiles are updated in place. On failure it returns the provider
+// error code and details.
+func prepareInstagramStoryMedia(jobID, userID, postID string, idx int, isVideo, letterbox bool, relMedia []string, mediaFiles []uploadedMedia, received []map[string]interface{}) (string, map[string]interface{}) {
+	var file uploadedMedia
+	if idx < len(mediaFiles) && len(mediaFiles[idx].Bytes) > 0 {
+		file = mediaFiles[idx]
+	} else {
+		files, err := loadUploadedMediaFromRelPaths([]string{relMedia[idx]})
+		if err != nil || len(files) == 0 {
+			return "instagram_story_media_unavailable", map[string]interface{}{"media": relMedia[idx], "received": received}
+		}
+		file = files[0]
+	}
+
+	info := analyzePreflightMedia(file)
+	details := map[string]interface{}{"media": relMedia[idx], "width": info.Width, "height": info.Height}
+	if isVideo && info.DurationSec > 0 {
+		details["durationSec"] = info.DurationSec
+		if info.DurationSec < instagramStoryMinVideoSec {
+			details["min"] = instagramStoryMinVideoSec
+			return "instagram_story_video_too_short", details
+		}
+		if info.DurationSec > instagramStoryMaxVideoSec {
+			details["max"] = instagramStoryMaxVideoSec
+			return "instagram_story_video_too_long", details
+		}
+	}
+
+	// Unknown dimensions (ffprobe unavailable) are left for Instagram to judge.
+	if info.Width == 0 || isInstagramStoryAspect(info.Width, info.Height) {
+		if isVideo {
+			return prepareInstagramVideo(jobID, userID, postID, idx, relMedia, mediaFiles, received)
+		}
+		return "", nil
+	}
+	if !letterbox {
+		return "instagram_story_aspect_ratio_unsupported", details
+	}
+
+	base := strings.TrimSuffix(file.Filename, filepath.Ext(file.Filename))
+	boxed := uploadedMedia{Filename: base + "_story.jpg", ContentType: "image/jpeg"}
+	var err error
+	if isVideo {
+		boxed = uploadedMedia{Filename: base + "_story.mp4", ContentType: "video/mp4"}
+		boxed.Bytes, err = transcodeVideoForInstagram(file.Bytes, file.Filename, instagramStoryLetterboxFilter)
+	} else {
+		boxed.Bytes, err = letterboxImageForInstagramStory(file.Bytes, file.Filename)
+	}
+	if err != nil {
+		details["error"] = err.Error()
+		return "instagram_story_letterbox_failed", details
+	}
+	if isVideo && len(boxed.Bytes) > instagramMaxReelsBytes {
+		return "instagram_video_too_large", map[string]interface{}{"sizeBytes": len(boxed.Bytes), "maxBytes": instagramMaxReelsBytes, "received": received}
+	}
+	savedPaths, _, err := saveUploadedMedia(userID, "", []uploadedMedia{boxed})
+	if err != nil || len(savedPaths) == 0 {
+		if err != nil {
+			details["error"] = err.Error()
+		}
+		return "instagram_story_letterbox_failed", details
+	}
+	log.Printf("[PublishJob] story media letterboxed: jobId=%s userId=%s postId=%s index=%d from=%dx%d rel=%s", jobID, userID, postID, idx, info.Width, info.Height, savedPaths[0])
+	relMedia[idx] = savedPaths[0]
+	if idx < len(mediaFiles) {
+		mediaFiles[idx] = boxed
+	}
+	return "", nil
+}
+
+// publishInstagramStory publishes one image or video (public URL) as a Story. Stories carry no caption.
+func (h *Handler) publishInstagramStory(ctx context.Context, userID, mediaURL string, isVideo bool, dryRun bool) (int, error, map[string]interface{}) {
+	details := map[string]interface{}{"mediaType": "STORIES", "url": mediaURL, "isVideo": isVideo}
+	mediaURL = strings.TrimSpace(mediaURL)
+	if mediaURL == "" {
+		return 0, fmt.Errorf("instagram_requires_image_or_video"), details
+	}
+	tok, err := h.loadInstagramOAuth(ctx, userID)
+	if err != nil {
+		return 0, err, details
+	}
+	if dryRun {
+		details["dryRun"] = true
+		return 0, nil, details
+	}
+
+	client := &http.Client{Timeout: 120 * time.Second}
+	igID := tok.IGBusinessID
+	form := url.Values{}
+	form.Set("media_type", "STORIES")
+	form.Set("access_token", tok.AccessToken)
+	if isVideo {
+		form.Set("video_url", mediaURL)
+	} else {
+		form.Set("image_url", mediaURL)
+	}
+	containerID, status, body, err := createInstagramContainer(ctx, client, igID, form)
+	if err != nil {
+		return 0, fmt.Errorf("instagram_container_failed"), map[string]interface{}{"status": status, "error": err.Error(), "body": truncate(string(body), 1200)}
+	}
+	details["containerId"] = containerID
+
+	attempts := 30
+	if isVideo {
+		attempts = 90
+	}
+	if st, detail, err := pollInstagramContainer(ctx, client, containerID, tok.AccessToken, attempts); err != nil {
+		details["containerStatus"] = st
+		if detail != "" {
+			details["statusDetail"] = detail
+		}
+		return 0, fmt.Errorf("instagram_container_not_ready"), details
+	}
+
+	pubForm := url.Values{}
+	pubForm.Set("creation_id", containerID)
+	pubForm.Set("access_token", tok.AccessToken)
+	pubEndpoint := fmt.Sprintf("https://graph.facebook.com/v24.0/%s/media_publish", url.PathEscape(igID))
+	req, _ := http.NewRequestWithContext(ctx, "POST", pubEndpoint, strings.NewReader(pubForm.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err, details
+	}
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	_ = res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		msg := extractFacebookErrorMessage(b, string(b))
+		return 0, fmt.Errorf("instagram_publish_failed"), map[string]interface{}{"status": res.StatusCode, "error": msg, "body": truncate(string(b), 1200)}
+	}
+	var pub struct {
+		ID string `json:"id"`
+	}
+	_ = json.Unmarshal(b, &pub)
+	details["publishedId"] = pub.ID
+
+	if pub.ID != "" {
+		rawPayload := strings.ReplaceAll(string(b), "\x00", "")
+		if !utf8.ValidString(rawPayload) {
+			rawPayload = strings.ToValidUTF8(rawPayload, "�")
+		}
+		_, _ = h.db.ExecContext(ctx, `
+			INSERT INTO public.social_libraries
+			  (id, user_id, network, content_type, title, permalink_url, media_url, thumbnail_url, posted_at, views, likes, raw_payload, external_id, created_at, updated_at)
+			VALUES
+			  ($1, $2, 'instagram', 'story', NULL, NULL, $3, NULL, NOW(), NULL, NULL, $4::jsonb, $5, NOW(), NOW())
+			ON CONFLICT (user_id, network, external_id)
+			DO UPDATE SET
+			  raw_payload = EXCLUDED.raw_payload,
+			  updated_at = NOW()
+		`, fmt.Sprintf("instagram:%s:%s", userID, pub.ID), userID, mediaURL, rawPayload, pub.ID)
+	}
+
+	log.Printf("[IGPublish] ok userId=%s igBusinessId=%s mediaId=%s mediaType=STORIES video=%v", userID, igID, pub.ID, isVideo)
+	return 1, nil, details
+}
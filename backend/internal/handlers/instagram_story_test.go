@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PortNumber53/simple-social-thing/backend/internal/models"
+)
+
+func TestPublishInstagramStory_CreatesStoriesContainer(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+	expectInstagramOAuth(t, mock)
+	mock.ExpectExec(`INSERT INTO public\.social_libraries`).
+		WithArgs("instagram:u1:story_1", "u1", "https://x/clip.mp4", sqlmock.AnyArg(), "story_1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	var container url.Values
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		if r.Method == "GET" {
+			return httpJSON(200, `{"status_code":"FINISHED"}`, nil), nil
+		}
+		if strings.HasSuffix(r.URL.Path, "/media_publish") {
+			return httpJSON(200, `{"id":"story_1"}`, nil), nil
+		}
+		b, _ := io.ReadAll(r.Body)
+		container, _ = url.ParseQuery(string(b))
+		return httpJSON(200, `{"id":"c1"}`, nil), nil
+	}}
+
+	posted, perr, details := h.publishInstagramStory(context.Background(), "u1", "https://x/clip.mp4", true, false)
+	if perr != nil || posted != 1 || details["publishedId"] != "story_1" {
+		t.Fatalf("expected success, got posted=%d err=%v details=%v", posted, perr, details)
+	}
+	if container.Get("media_type") != "STORIES" || container.Get("video_url") != "https://x/clip.mp4" || container.Has("caption") {
+		t.Fatalf("unexpected container form %v", container)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestBuildPreflightReport_InstagramStoryMode(t *testing.T) {
+	media := map[string]preflightMedia{
+		"/media/u/story.png":  {Kind: "image", Width: 1080, Height: 1920},
+		"/media/u/square.png": {Kind: "image", Width: 1080, Height: 1080},
+		"/media/u/long.mp4":   {Kind: "video", Width: 1080, Height: 1920, DurationSec: 90, Probed: true, HasH264: true, HasAAC: true},
+	}
+	story := &models.PostOptions{Instagram: &models.InstagramPostOptions{Mode: "story"}}
+	report := buildPreflightReport("caption", []string{"instagram"}, []string{"/media/u/story.png", "/media/u/square.png", "/media/u/long.mp4"}, media, nil, story)
+	p := report.Providers["instagram"]
+	if got := preflightCodes(p.Errors); got != "video_too_long,aspect_ratio_unsupported" {
+		t.Fatalf("unexpected story errors %q", got)
+	}
+	if got := preflightCodes(p.Warnings); got != "caption_ignored" {
+		t.Fatalf("unexpected story warnings %q", got)
+	}
+
+	story.Instagram.StoryLetterbox = true
+	report = buildPreflightReport("", []string{"instagram"}, []string{"/media/u/story.png", "/media/u/square.png"}, media, nil, story)
+	if p := report.Providers["instagram"]; !p.OK || preflightCodes(p.Warnings) != "media_letterboxed" {
+		t.Fatalf("expected letterbox warning, got %#v", p)
+	}
+}
+
+func TestPrepareInstagramStoryMedia_RejectsWrongAspectWithoutLetterbox(t *testing.T) {
+	encode := func(w, h int) []byte {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+			t.Fatalf("png.Encode: %v", err)
+		}
+		return buf.Bytes()
+	}
+	rel := []string{"/media/u/square.png", "/media/u/story.png"}
+	files := []uploadedMedia{
+		{Filename: "square.png", ContentType: "image/png", Bytes: encode(40, 40)},
+		{Filename: "story.png", ContentType: "image/png", Bytes: encode(90, 160)},
+	}
+	if code, details := prepareInstagramStoryMedia("j1", "u1", "p1", 0, false, false, rel, files, nil); code != "instagram_story_aspect_ratio_unsupported" || details["width"] != 40 {
+		t.Fatalf("expected aspect rejection, got %q %v", code, details)
+	}
+	if code, _ := prepareInstagramStoryMedia("j1", "u1", "p1", 1, false, false, rel, files, nil); code != "" || rel[1] != "/media/u/story.png" {
+		t.Fatalf("expected 9:16 image to pass untouched, got %q %v", code, rel)
+	}
+
+	if _, err := normalizePostOptions(&models.PostOptions{Instagram: &models.InstagramPostOptions{Mode: "story", LocationID: "1"}}, nil); err == nil {
+		t.Fatalf("expected story mode to reject a location")
+	}
+	if _, err := normalizePostOptions(&models.PostOptions{Instagram: &models.InstagramPostOptions{Mode: "reel"}}, nil); err == nil {
+		t.Fatalf("expected unknown mode to be rejected")
+	}
+}
+
+func TestRunPublishJob_InstagramStoriesResumeFromFirstUnposted(t *testing.T) {
+	tmp := t.TempDir()
+	cwd, _ := os.Getwd()
+	_ = os.Chdir(tmp)
+	defer func() { _ = os.Chdir(cwd) }()
+	dir := filepath.Join("media", "uploads", "u1")
+	_ = os.MkdirAll(dir, 0o755)
+	_ = os.WriteFile(filepath.Join(dir, "a.jpg"), []byte{0xff, 0xd8, 0xff, 0xdb}, 0o644)
+	_ = os.WriteFile(filepath.Join(dir, "b.jpg"), []byte{0xff, 0xd8, 0xff, 0xdb}, 0o644)
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	// The first run published story 0 and died before story 1.
+	prev := `{"instagram":{"ok":false,"posted":1,"error":"instagram_stories_incomplete","details":{"mediaType":"STORIES","skipped":0,"stories":[{"index":0,"publishedId":"story_0"}]}}}`
+	mock.ExpectExec(`UPDATE public\.publish_jobs.*status='running'`).WithArgs("job1").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE public\.posts.*last_publish_status='running'`).WithArgs("job1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT COALESCE\(result_json->'results'`).
+		WithArgs("job1").
+		WillReturnRows(sqlmock.NewRows([]string{"results"}).AddRow([]byte(prev)))
+	expectInstagramOAuth(t, mock)
+	mock.ExpectExec(`INSERT INTO public\.social_libraries`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE public\.publish_jobs\s+SET result_json = COALESCE`).
+		WithArgs("job1", "instagram", sqlmock.AnyArg(), "w1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	var checkpoint string
+	mock.ExpectExec(`UPDATE public\.publish_jobs\s+SET result_json = COALESCE`).
+		WithArgs("job1", "instagram", captureArg{&checkpoint}, "w1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE public\.publish_jobs.*SET status=\$2`).
+		WithArgs("job1", "completed", sqlmock.AnyArg(), sqlmock.AnyArg(), "w1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE public\.posts.*SET last_publish_status=\$2`).
+		WithArgs("job1", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	var containers []string
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		if r.Method == "GET" {
+			return httpJSON(200, `{"status_code":"FINISHED"}`, nil), nil
+		}
+		if strings.HasSuffix(r.URL.Path, "/media_publish") {
+			return httpJSON(200, `{"id":"story_1"}`, nil), nil
+		}
+		b, _ := io.ReadAll(r.Body)
+		form, _ := url.ParseQuery(string(b))
+		containers = append(containers, form.Get("image_url"))
+		return httpJSON(200, `{"id":"c1"}`, nil), nil
+	}}
+
+	req := publishPostRequest{Providers: []string{"instagram"}, Options: &models.PostOptions{Instagram: &models.InstagramPostOptions{Mode: "story"}}}
+	h.runPublishJob(context.Background(), "job1", "w1", "u1", "", req, []string{"/media/uploads/u1/a.jpg", "/media/uploads/u1/b.jpg"}, "https://app.test")
+
+	if len(containers) != 1 || containers[0] != "https://app.test/media/uploads/u1/b.jpg" {
+		t.Fatalf("expected only the second story to be published, got %v", containers)
+	}
+	var res publishProviderResult
+	_ = json.Unmarshal([]byte(checkpoint), &res)
+	stories, _ := res.Details["stories"].([]interface{})
+	if !res.OK || res.Posted != 2 || len(stories) != 2 {
+		t.Fatalf("expected both stories in the checkpointed set, got %s", checkpoint)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
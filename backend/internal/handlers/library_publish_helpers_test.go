@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"net/http"
@@ -120,7 +121,7 @@ func TestSyncSocialLibrariesForUser_SelectNone(t *testing.T) {
 }
 
 func TestSyncSocialLibrariesForUser_XProvider(t *testing.T) {
-	// providers=x selects the stub provider which runs without DB/network and yields an entry in response map.
+	// providers=x selects only the X provider; with no DB it reports an error but still yields an entry in the response map.
 	h := New(nil)
 	rr := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodPost, "/api/social-libraries/sync/user/u1?providers=x", nil)
@@ -193,7 +194,7 @@ func TestPublishSocialPostForUser_NotSupportedProvidersPath(t *testing.T) {
 	// Choose providers that are stubbed as not_supported_yet to avoid DB dependencies.
 	h := New(nil)
 	rr := httptest.NewRecorder()
-	req := httptest.NewRequest(http.MethodPost, "/api/social-posts/publish/user/u1", bytes.NewBufferString(`{"caption":"hi","providers":["tiktok","threads"]}`))
+	req := httptest.NewRequest(http.MethodPost, "/api/social-posts/publish/user/u1", bytes.NewBufferString(`{"caption":"hi","providers":["tiktok","pinterest"]}`))
 	req.Header.Set("Content-Type", "application/json")
 	req = mux.SetURLVars(req, map[string]string{"userId": "u1"})
 	h.PublishSocialPostForUser(rr, req)
@@ -243,13 +244,13 @@ func TestRunPublishJob_NoKnownProviders(t *testing.T) {
 		WithArgs("job1").
 		WillReturnResult(sqlmock.NewResult(0, 0))
 	mock.ExpectExec(`UPDATE public\.publish_jobs.*SET status=\$2`).
-		WithArgs("job1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs("job1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "w1").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectExec(`UPDATE public\.posts.*SET last_publish_status=\$2`).
 		WithArgs("job1", sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(0, 0))
 
-	h.runPublishJob("job1", "u1", "cap", publishPostRequest{Providers: []string{"unknown"}}, nil, "https://app.test")
+	h.runPublishJob(context.Background(), "job1", "w1", "u1", "cap", publishPostRequest{Providers: []string{"unknown"}}, nil, "https://app.test")
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Fatalf("sql expectations: %v", err)
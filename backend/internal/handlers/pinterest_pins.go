@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/PortNumber53/simple-social-thing/backend/internal/models"
+)
+
+const (
+	// pinterestMaxCarouselImages is Pinterest's limit for multi-image pins (at least 2).
+	pinterestMaxCarouselImages = 5
+	pinterestMaxBoardPages     = 10
+	pinterestMediaPollAttempts = 60
+)
+
+// pinterestMediaPollInterval is a var so tests don't wait while an uploaded video is processed.
+var pinterestMediaPollInterval = 3 * time.Second
+
+// pinterestPinMedia is what one pin shows: a video, several images (a carousel pin) or one image.
+// Images are public URLs; the video is uploaded through Pinterest's media API.
+type pinterestPinMedia struct {
+	ImageURLs []string
+	Video     *uploadedMedia
+}
+
+// pinterestBoard is a board with its sections, as listed for the composer.
+type pinterestBoard struct {
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description,omitempty"`
+	Privacy     string             `json:"privacy,omitempty"`
+	Sections    []pinterestSection `json:"sections"`
+}
+
+type pinterestSection struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// pinterestOptionsFor merges the post's Pinterest options over the variant's title and link.
+func pinterestOptionsFor(in providerPublishInput, opts *models.PostOptions) pinterestPinOptions {
+	out := pinterestPinOptions{Title: in.Title, Link: in.Link}
+	if opts == nil || opts.Pinterest == nil {
+		return out
+	}
+	pin := opts.Pinterest
+	if pin.Title != "" {
+		out.Title = pin.Title
+	}
+	if pin.Link != "" {
+		out.Link = pin.Link
+	}
+	out.BoardID = pin.BoardID
+	out.SectionID = pin.SectionID
+	out.AltText = pin.AltText
+	return out
+}
+
+// pinterestPinTitle is the pin title: the chosen one, else the caption (cut to 95 characters).
+func pinterestPinTitle(caption string, opts pinterestPinOptions) string {
+	title := strings.TrimSpace(opts.Title)
+	if title == "" {
+		title = strings.TrimSpace(caption)
+	}
+	if title == "" {
+		title = "New pin"
+	}
+	if len(title) > 95 {
+		title = truncate(title, 95)
+	}
+	return title
+}
+
+// pinterestAPIBase returns the configured API host. PINTEREST_API_BASE may be a URL or "sandbox"
+// (trial apps must use api-sandbox).
+func pinterestAPIBase() string {
+	apiBase := strings.TrimSpace(os.Getenv("PINTEREST_API_BASE"))
+	if apiBase == "" {
+		apiBase = "https://api.pinterest.com"
+	}
+	if strings.EqualFold(apiBase, "sandbox") {
+		apiBase = "https://api-sandbox.pinterest.com"
+	}
+	return apiBase
+}
+
+func (h *Handler) loadPinterestOAuth(ctx context.Context, userID string) (pinterestOAuth, error) {
+	var raw []byte
+	if err := h.db.QueryRowContext(ctx, `SELECT value FROM public.user_settings WHERE user_id=$1 AND key='pinterest_oauth' AND value IS NOT NULL`, userID).Scan(&raw); err != nil {
+		if err == sql.ErrNoRows {
+			return pinterestOAuth{}, fmt.Errorf("not_connected")
+		}
+		return pinterestOAuth{}, err
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return pinterestOAuth{}, fmt.Errorf("not_connected")
+	}
+	var tok pinterestOAuth
+	if err := json.Unmarshal(raw, &tok); err != nil {
+		return pinterestOAuth{}, fmt.Errorf("invalid_oauth_payload")
+	}
+	if strings.TrimSpace(tok.AccessToken) == "" {
+		return pinterestOAuth{}, fmt.Errorf("not_connected")
+	}
+	return tok, nil
+}
+
+// pinterestGet GETs a v5 API path and decodes the JSON response into out.
+func pinterestGet(ctx context.Context, client *http.Client, apiBase, accessToken, path string, out interface{}) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(apiBase, "/")+path, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 2<<20))
+	_ = res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, b, fmt.Errorf("pinterest_non_2xx")
+	}
+	if out != nil {
+		_ = json.Unmarshal(b, out)
+	}
+	return res.StatusCode, b, nil
+}
+
+// listPinterestBoards pages through the user's boards and lists each board's sections.
+func listPinterestBoards(ctx context.Context, client *http.Client, apiBase, accessToken string) ([]pinterestBoard, error) {
+	boards := []pinterestBoard{}
+	bookmark := ""
+	for page := 0; page < pinterestMaxBoardPages; page++ {
+		q := url.Values{"page_size": {"100"}}
+		if bookmark != "" {
+			q.Set("bookmark", bookmark)
+		}
+		var parsed struct {
+			Items    []pinterestBoard `json:"items"`
+			Bookmark string           `json:"bookmark"`
+		}
+		if status, b, err := pinterestGet(ctx, client, apiBase, accessToken, "/v5/boards?"+q.Encode(), &parsed); err != nil {
+			return nil, fmt.Errorf("pinterest_boards_failed status=%d body=%s", status, truncate(string(b), 300))
+		}
+		boards = append(boards, parsed.Items...)
+		if parsed.Bookmark == "" {
+			break
+		}
+		bookmark = parsed.Bookmark
+	}
+	for i := range boards {
+		var parsed struct {
+			Items []pinterestSection `json:"items"`
+		}
+		path := "/v5/boards/" + url.PathEscape(boards[i].ID) + "/sections?page_size=100"
+		if status, b, err := pinterestGet(ctx, client, apiBase, accessToken, path, &parsed); err != nil {
+			return nil, fmt.Errorf("pinterest_sections_failed status=%d body=%s", status, truncate(string(b), 300))
+		}
+		boards[i].Sections = append([]pinterestSection{}, parsed.Items...)
+	}
+	return boards, nil
+}
+
+// ListPinterestBoardsForUser lists the user's Pinterest boards and their sections, for choosing where a
+// post is pinned.
+//
+// URL: GET /api/pinterest/boards/user/{userId}
+func (h *Handler) ListPinterestBoardsForUser(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	userID := strings.TrimSpace(pathVar(r, "userId"))
+	if userID == "" {
+		writeError(w, http.StatusBadRequest, "userId is required")
+		return
+	}
+	tok, err := h.loadPinterestOAuth(r.Context(), userID)
+	if err != nil {
+		if err.Error() == "not_connected" {
+			writeError(w, http.StatusConflict, "pinterest_not_connected")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	boards, err := listPinterestBoards(r.Context(), client, pinterestAPIBase(), tok.AccessToken)
+	if err != nil {
+		log.Printf("[PINBoards] list failed userId=%s err=%v", userID, err)
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"boards": boards})
+}
+
+// pinterestUploadVideo registers a video with the media API, uploads it to the returned upload URL and waits
+// for Pinterest to finish processing it. It returns the media id to pin.
+func pinterestUploadVideo(ctx context.Context, client *http.Client, apiBase, accessToken string, video uploadedMedia) (string, error, map[string]interface{}) {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(apiBase, "/")+"/v5/media", strings.NewReader(`{"media_type":"video"}`))
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return "", err, nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	_ = res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return "", fmt.Errorf("pinterest_media_register_non_2xx"), map[string]interface{}{"status": res.StatusCode, "body": truncate(string(body), 2000)}
+	}
+	var reg struct {
+		MediaID          string            `json:"media_id"`
+		UploadURL        string            `json:"upload_url"`
+		UploadParameters map[string]string `json:"upload_parameters"`
+	}
+	_ = json.Unmarshal(body, &reg)
+	if reg.MediaID == "" || reg.UploadURL == "" {
+		return "", fmt.Errorf("pinterest_media_register_failed"), map[string]interface{}{"body": truncate(string(body), 2000)}
+	}
+
+	// The upload URL is a presigned form POST: every parameter goes first, then the file.
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	for k, v := range reg.UploadParameters {
+		_ = mw.WriteField(k, v)
+	}
+	fw, _ := mw.CreateFormFile("file", video.Filename)
+	_, _ = fw.Write(video.Bytes)
+	_ = mw.Close()
+	up, _ := http.NewRequestWithContext(ctx, http.MethodPost, reg.UploadURL, &form)
+	up.Header.Set("Content-Type", mw.FormDataContentType())
+	res, err = (&http.Client{Timeout: 10 * time.Minute}).Do(up)
+	if err != nil {
+		return "", err, map[string]interface{}{"mediaId": reg.MediaID}
+	}
+	body, _ = io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	_ = res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return "", fmt.Errorf("pinterest_media_upload_non_2xx"), map[string]interface{}{"mediaId": reg.MediaID, "status": res.StatusCode, "body": truncate(string(body), 2000)}
+	}
+
+	status := ""
+	for i := 0; i < pinterestMediaPollAttempts; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err(), map[string]interface{}{"mediaId": reg.MediaID, "mediaStatus": status}
+			case <-time.After(pinterestMediaPollInterval):
+			}
+		}
+		var parsed struct {
+			Status string `json:"status"`
+		}
+		if _, _, err := pinterestGet(ctx, client, apiBase, accessToken, "/v5/media/"+url.PathEscape(reg.MediaID), &parsed); err != nil {
+			continue
+		}
+		status = parsed.Status
+		switch status {
+		case "succeeded":
+			return reg.MediaID, nil, map[string]interface{}{"mediaId": reg.MediaID}
+		case "failed":
+			return "", fmt.Errorf("pinterest_media_processing_failed"), map[string]interface{}{"mediaId": reg.MediaID}
+		}
+	}
+	return "", fmt.Errorf("pinterest_media_processing_timeout"), map[string]interface{}{"mediaId": reg.MediaID, "mediaStatus": status}
+}
+
+// pinterestMediaSource builds the pin's media_source. Videos use the first image as cover when the post
+// has one, otherwise the first frame.
+func pinterestMediaSource(media pinterestPinMedia, videoMediaID string, link string) map[string]interface{} {
+	if videoMediaID != "" {
+		source := map[string]interface{}{"source_type": "video_id", "media_id": videoMediaID}
+		if len(media.ImageURLs) > 0 {
+			source["cover_image_url"] = media.ImageURLs[0]
+		} else {
+			source["cover_image_key_frame_time"] = 0
+		}
+		return source
+	}
+	if len(media.ImageURLs) > 1 {
+		items := make([]map[string]interface{}, 0, len(media.ImageURLs))
+		for _, u := range media.ImageURLs {
+			item := map[string]interface{}{"url": u}
+			if link != "" {
+				item["link"] = link
+			}
+			items = append(items, item)
+		}
+		return map[string]interface{}{"source_type": "multiple_image_urls", "items": items, "index": 0}
+	}
+	imageURL := ""
+	if len(media.ImageURLs) == 1 {
+		imageURL = media.ImageURLs[0]
+	}
+	return map[string]interface{}{"source_type": "image_url", "url": imageURL}
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PortNumber53/simple-social-thing/backend/internal/models"
+	"github.com/gorilla/mux"
+)
+
+func expectPinterestToken(mock sqlmock.Sqlmock) {
+	raw, _ := json.Marshal(pinterestOAuth{AccessToken: "ptok", Scope: "pins:write,boards:read"})
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings.*key='pinterest_oauth'`).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(raw))
+}
+
+func TestListPinterestBoardsForUser_PagesBoardsWithSections(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+	expectPinterestToken(mock)
+
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		switch {
+		case r.URL.Path == "/v5/boards" && r.URL.Query().Get("bookmark") == "":
+			return httpJSON(200, `{"items":[{"id":"1","name":"Recipes","privacy":"PUBLIC"}],"bookmark":"next"}`, nil), nil
+		case r.URL.Path == "/v5/boards" && r.URL.Query().Get("bookmark") == "next":
+			return httpJSON(200, `{"items":[{"id":"2","name":"Travel"}]}`, nil), nil
+		case r.URL.Path == "/v5/boards/1/sections":
+			return httpJSON(200, `{"items":[{"id":"11","name":"Desserts"}]}`, nil), nil
+		case r.URL.Path == "/v5/boards/2/sections":
+			return httpJSON(200, `{"items":[]}`, nil), nil
+		}
+		return httpJSON(404, `{}`, nil), nil
+	}}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/pinterest/boards/user/u1", nil)
+	req = mux.SetURLVars(req, map[string]string{"userId": "u1"})
+	rr := httptest.NewRecorder()
+	h.ListPinterestBoardsForUser(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d body=%s", rr.Code, rr.Body.String())
+	}
+	var out struct {
+		Boards []pinterestBoard `json:"boards"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &out)
+	if len(out.Boards) != 2 || out.Boards[0].Name != "Recipes" || len(out.Boards[0].Sections) != 1 || out.Boards[0].Sections[0].ID != "11" || out.Boards[1].Sections == nil {
+		t.Fatalf("unexpected boards %+v", out.Boards)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestPublishPinterestPin_CarouselOnChosenBoardSection(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+	expectPinterestToken(mock)
+	mock.ExpectExec(`INSERT INTO public\.social_libraries`).
+		WithArgs("pinterest:u1:pin1", "u1", "Pin title", "https://www.pinterest.com/pin/pin1/", "https://x/a.jpg", sqlmock.AnyArg(), "pin1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	var pin map[string]interface{}
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path == "/v5/pins" && r.Method == http.MethodPost {
+			_ = json.NewDecoder(r.Body).Decode(&pin)
+			return httpJSON(201, `{"id":"pin1"}`, nil), nil
+		}
+		// The chosen board is used as is; boards are not listed.
+		return httpJSON(404, `{}`, nil), nil
+	}}
+
+	in := providerPublishInput{Title: "Variant title", Link: "https://shop.example/a"}
+	opts := pinterestOptionsFor(in, &models.PostOptions{Pinterest: &models.PinterestPostOptions{BoardID: "1", SectionID: "11", Title: "Pin title", AltText: "Two cakes"}})
+	media := pinterestPinMedia{ImageURLs: []string{"https://x/a.jpg", "https://x/b.jpg"}}
+	posted, perr, details := h.publishPinterestPin(context.Background(), "u1", "caption", media, opts, false)
+	if perr != nil || posted != 1 {
+		t.Fatalf("expected success, got posted=%d err=%v details=%v", posted, perr, details)
+	}
+	source, _ := pin["media_source"].(map[string]interface{})
+	items, _ := source["items"].([]interface{})
+	if pin["board_id"] != "1" || pin["board_section_id"] != "11" || pin["title"] != "Pin title" || pin["link"] != "https://shop.example/a" || pin["alt_text"] != "Two cakes" ||
+		source["source_type"] != "multiple_image_urls" || len(items) != 2 {
+		t.Fatalf("unexpected pin request %v", pin)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestPublishPinterestPin_UploadsVideoWithImageCover(t *testing.T) {
+	origInterval := pinterestMediaPollInterval
+	defer func() { pinterestMediaPollInterval = origInterval }()
+	pinterestMediaPollInterval = 0
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+	expectPinterestToken(mock)
+	mock.ExpectExec(`INSERT INTO public\.social_libraries`).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	var uploaded string
+	var pin map[string]interface{}
+	polls := 0
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		switch {
+		case r.URL.Path == "/v5/boards":
+			return httpJSON(200, `{"items":[{"id":"b1"}]}`, nil), nil
+		case r.URL.Path == "/v5/media" && r.Method == http.MethodPost:
+			return httpJSON(201, `{"media_id":"m1","upload_url":"https://uploads.example/","upload_parameters":{"key":"k1"}}`, nil), nil
+		case r.URL.Host == "uploads.example":
+			b, _ := io.ReadAll(r.Body)
+			uploaded = string(b)
+			return httpJSON(204, ``, nil), nil
+		case r.URL.Path == "/v5/media/m1":
+			polls++
+			if polls == 1 {
+				return httpJSON(200, `{"status":"processing"}`, nil), nil
+			}
+			return httpJSON(200, `{"status":"succeeded"}`, nil), nil
+		case r.URL.Path == "/v5/pins":
+			_ = json.NewDecoder(r.Body).Decode(&pin)
+			return httpJSON(201, `{"id":"pin2"}`, nil), nil
+		}
+		return httpJSON(404, `{}`, nil), nil
+	}}
+
+	media := pinterestPinMedia{ImageURLs: []string{"https://x/cover.jpg"}, Video: &uploadedMedia{Filename: "v.mp4", ContentType: "video/mp4", Bytes: []byte("video-bytes")}}
+	posted, perr, details := h.publishPinterestPin(context.Background(), "u1", "caption", media, pinterestPinOptions{}, false)
+	if perr != nil || posted != 1 || details["mediaId"] != "m1" {
+		t.Fatalf("expected success, got posted=%d err=%v details=%v", posted, perr, details)
+	}
+	if !strings.Contains(uploaded, `name="key"`) || !strings.Contains(uploaded, "video-bytes") || polls != 2 {
+		t.Fatalf("unexpected upload %q polls=%d", uploaded, polls)
+	}
+	source, _ := pin["media_source"].(map[string]interface{})
+	if source["source_type"] != "video_id" || source["media_id"] != "m1" || source["cover_image_url"] != "https://x/cover.jpg" {
+		t.Fatalf("unexpected media source %v", source)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestNormalizePinterestOptions(t *testing.T) {
+	bad := []models.PinterestPostOptions{
+		{BoardID: "abc"},
+		{SectionID: "11"},
+		{Link: "ftp://x/y"},
+		{Title: strings.Repeat("t", pinterestMaxTitle+1)},
+	}
+	for _, o := range bad {
+		o := o
+		if _, err := normalizePostOptions(&models.PostOptions{Pinterest: &o}, nil); err == nil {
+			t.Fatalf("expected %+v to be rejected", o)
+		}
+	}
+	got, err := normalizePostOptions(&models.PostOptions{Pinterest: &models.PinterestPostOptions{BoardID: " 1 ", AltText: " alt "}}, nil)
+	if err != nil || got.Pinterest == nil || got.Pinterest.BoardID != "1" || got.Pinterest.AltText != "alt" {
+		t.Fatalf("unexpected normalization %+v err=%v", got, err)
+	}
+}
@@ -0,0 +1,384 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PortNumber53/simple-social-thing/backend/internal/models"
+)
+
+const (
+	instagramMaxUserTags      = 20
+	instagramMaxCollaborators = 3
+	instagramMaxAltText       = 1000
+	facebookMaxPreviewTitle   = 255
+	facebookMaxPreviewDesc    = 1000
+	youtubeMaxTitle           = 100
+	youtubeMaxDescriptionLen  = 5000
+	youtubeMaxTagsLen         = 500
+	tiktokMaxPhotos           = 35
+	pinterestMaxTitle         = 100
+	pinterestMaxAltText       = 500
+	pinterestMaxLink          = 2048
+)
+
+var (
+	instagramUsernameRe = regexp.MustCompile(`^[A-Za-z0-9._]{1,30}$`)
+	numericIDRe         = regexp.MustCompile(`^[0-9]{1,30}$`)
+	youtubePlaylistIDRe = regexp.MustCompile(`^[A-Za-z0-9_-]{2,64}$`)
+)
+
+// normalizePostOptions validates network-specific options. Media references must come from the post's
+// media (checked only when postMedia is known). It returns nil when nothing is set.
+func normalizePostOptions(in *models.PostOptions, postMedia []string) (*models.PostOptions, error) {
+	if in == nil {
+		return nil, nil
+	}
+	var allowed map[string]bool
+	if postMedia != nil {
+		allowed = map[string]bool{}
+		for _, m := range postMedia {
+			allowed[m] = true
+		}
+	}
+	out := &models.PostOptions{}
+	if ig := in.Instagram; ig != nil {
+		norm := &models.InstagramPostOptions{LocationID: strings.TrimSpace(ig.LocationID)}
+		if norm.LocationID != "" && !numericIDRe.MatchString(norm.LocationID) {
+			return nil, fmt.Errorf("instagram locationId must be a numeric page id")
+		}
+		perMedia := map[string]int{}
+		for _, tag := range ig.UserTags {
+			tag.Username = strings.TrimPrefix(strings.TrimSpace(tag.Username), "@")
+			tag.Media = strings.TrimSpace(tag.Media)
+			if !instagramUsernameRe.MatchString(tag.Username) {
+				return nil, fmt.Errorf("instagram user tag %q is not a valid username", tag.Username)
+			}
+			if tag.X < 0 || tag.X > 1 || tag.Y < 0 || tag.Y > 1 {
+				return nil, fmt.Errorf("instagram user tag %s: x and y must be between 0 and 1", tag.Username)
+			}
+			if tag.Media != "" && allowed != nil && !allowed[tag.Media] {
+				return nil, fmt.Errorf("instagram user tag %s: media must be one of the post's media", tag.Username)
+			}
+			perMedia[tag.Media]++
+			if perMedia[tag.Media] > instagramMaxUserTags {
+				return nil, fmt.Errorf("instagram allows at most %d user tags per item", instagramMaxUserTags)
+			}
+			norm.UserTags = append(norm.UserTags, tag)
+		}
+		seen := map[string]bool{}
+		for _, c := range ig.Collaborators {
+			c = strings.TrimPrefix(strings.TrimSpace(c), "@")
+			if c == "" || seen[strings.ToLower(c)] {
+				continue
+			}
+			if !instagramUsernameRe.MatchString(c) {
+				return nil, fmt.Errorf("instagram collaborator %q is not a valid username", c)
+			}
+			seen[strings.ToLower(c)] = true
+			norm.Collaborators = append(norm.Collaborators, c)
+		}
+		if len(norm.Collaborators) > instagramMaxCollaborators {
+			return nil, fmt.Errorf("instagram allows at most %d collaborators", instagramMaxCollaborators)
+		}
+		for rel, text := range ig.AltText {
+			rel, text = strings.TrimSpace(rel), strings.TrimSpace(text)
+			if text == "" {
+				continue
+			}
+			if allowed != nil && !allowed[rel] {
+				return nil, fmt.Errorf("instagram alt text must be keyed by one of the post's media")
+			}
+			if len([]rune(text)) > instagramMaxAltText {
+				return nil, fmt.Errorf("instagram alt text is too long (max %d)", instagramMaxAltText)
+			}
+			if norm.AltText == nil {
+				norm.AltText = map[string]string{}
+			}
+			norm.AltText[rel] = text
+		}
+		switch mode := strings.ToLower(strings.TrimSpace(ig.Mode)); mode {
+		case "", "feed":
+		case "story":
+			if len(norm.UserTags) > 0 || norm.LocationID != "" || len(norm.Collaborators) > 0 || len(norm.AltText) > 0 {
+				return nil, fmt.Errorf("instagram stories do not support user tags, location, collaborators or alt text")
+			}
+			norm.Mode = mode
+			norm.StoryLetterbox = ig.StoryLetterbox
+		default:
+			return nil, fmt.Errorf("instagram mode must be feed or story")
+		}
+		if len(norm.UserTags) > 0 || norm.LocationID != "" || len(norm.Collaborators) > 0 || len(norm.AltText) > 0 || norm.Mode != "" {
+			out.Instagram = norm
+		}
+	}
+	if fb := in.Facebook; fb != nil {
+		norm := &models.FacebookPostOptions{NativeSchedule: fb.NativeSchedule, Unpublished: fb.Unpublished}
+		if norm.NativeSchedule && norm.Unpublished {
+			return nil, fmt.Errorf("facebook nativeSchedule and unpublished cannot be combined")
+		}
+		if p := fb.LinkPreview; p != nil {
+			preview := models.FacebookLinkPreview{
+				Title:       strings.TrimSpace(p.Title),
+				Description: strings.TrimSpace(p.Description),
+				Image:       strings.TrimSpace(p.Image),
+			}
+			if len([]rune(preview.Title)) > facebookMaxPreviewTitle {
+				return nil, fmt.Errorf("facebook link preview title is too long (max %d)", facebookMaxPreviewTitle)
+			}
+			if len([]rune(preview.Description)) > facebookMaxPreviewDesc {
+				return nil, fmt.Errorf("facebook link preview description is too long (max %d)", facebookMaxPreviewDesc)
+			}
+			if preview.Image != "" {
+				u, err := url.Parse(preview.Image)
+				if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+					return nil, fmt.Errorf("facebook link preview image must be an http(s) URL")
+				}
+			}
+			if preview != (models.FacebookLinkPreview{}) {
+				norm.LinkPreview = &preview
+			}
+		}
+		if *norm != (models.FacebookPostOptions{}) {
+			out.Facebook = norm
+		}
+	}
+	if yt := in.YouTube; yt != nil {
+		norm, err := normalizeYouTubeOptions(yt)
+		if err != nil {
+			return nil, err
+		}
+		out.YouTube = norm
+	}
+	if tt := in.TikTok; tt != nil {
+		norm := *tt
+		norm.Privacy = strings.ToUpper(strings.TrimSpace(tt.Privacy))
+		norm.Mode = strings.ToLower(strings.TrimSpace(tt.Mode))
+		switch norm.Privacy {
+		case "", "PUBLIC_TO_EVERYONE", "MUTUAL_FOLLOW_FRIENDS", "FOLLOWER_OF_CREATOR", "SELF_ONLY":
+		default:
+			return nil, fmt.Errorf("tiktok privacy must be PUBLIC_TO_EVERYONE, MUTUAL_FOLLOW_FRIENDS, FOLLOWER_OF_CREATOR or SELF_ONLY")
+		}
+		switch norm.Mode {
+		case "", "direct":
+			norm.Mode = ""
+		case "draft":
+		default:
+			return nil, fmt.Errorf("tiktok mode must be direct or draft")
+		}
+		// TikTok doesn't allow branded content to be private.
+		if norm.BrandContent && norm.Privacy == "SELF_ONLY" {
+			return nil, fmt.Errorf("tiktok branded content cannot be posted with privacy SELF_ONLY")
+		}
+		if norm.CoverTimestampMs < 0 {
+			return nil, fmt.Errorf("tiktok coverTimestampMs cannot be negative")
+		}
+		if norm.PhotoCoverIndex < 0 || norm.PhotoCoverIndex >= tiktokMaxPhotos {
+			return nil, fmt.Errorf("tiktok photoCoverIndex must be between 0 and %d", tiktokMaxPhotos-1)
+		}
+		if norm != (models.TikTokPostOptions{}) {
+			out.TikTok = &norm
+		}
+	}
+	if pin := in.Pinterest; pin != nil {
+		norm := models.PinterestPostOptions{
+			BoardID:   strings.TrimSpace(pin.BoardID),
+			SectionID: strings.TrimSpace(pin.SectionID),
+			Title:     strings.TrimSpace(pin.Title),
+			Link:      strings.TrimSpace(pin.Link),
+			AltText:   strings.TrimSpace(pin.AltText),
+		}
+		if (norm.BoardID != "" && !numericIDRe.MatchString(norm.BoardID)) || (norm.SectionID != "" && !numericIDRe.MatchString(norm.SectionID)) {
+			return nil, fmt.Errorf("pinterest boardId and sectionId must be numeric ids")
+		}
+		if norm.SectionID != "" && norm.BoardID == "" {
+			return nil, fmt.Errorf("pinterest sectionId requires boardId")
+		}
+		if len([]rune(norm.Title)) > pinterestMaxTitle {
+			return nil, fmt.Errorf("pinterest title is too long (max %d)", pinterestMaxTitle)
+		}
+		if len([]rune(norm.AltText)) > pinterestMaxAltText {
+			return nil, fmt.Errorf("pinterest altText is too long (max %d)", pinterestMaxAltText)
+		}
+		if norm.Link != "" {
+			u, err := url.Parse(norm.Link)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(norm.Link) > pinterestMaxLink {
+				return nil, fmt.Errorf("pinterest link must be an http(s) URL (max %d characters)", pinterestMaxLink)
+			}
+		}
+		if norm != (models.PinterestPostOptions{}) {
+			out.Pinterest = &norm
+		}
+	}
+	if *out == (models.PostOptions{}) {
+		return nil, nil
+	}
+	return out, nil
+}
+
+// normalizeYouTubeOptions applies YouTube's metadata limits; it returns nil when nothing is set.
+func normalizeYouTubeOptions(yt *models.YouTubePostOptions) (*models.YouTubePostOptions, error) {
+	norm := &models.YouTubePostOptions{
+		Title:       strings.TrimSpace(yt.Title),
+		Description: strings.TrimSpace(yt.Description),
+		Privacy:     strings.ToLower(strings.TrimSpace(yt.Privacy)),
+		MadeForKids: yt.MadeForKids,
+		CategoryID:  strings.TrimSpace(yt.CategoryID),
+		Thumbnail:   strings.TrimSpace(yt.Thumbnail),
+		PlaylistID:  strings.TrimSpace(yt.PlaylistID),
+	}
+	// YouTube rejects angle brackets in titles and descriptions.
+	if strings.ContainsAny(norm.Title, "<>") || strings.ContainsAny(norm.Description, "<>") {
+		return nil, fmt.Errorf("youtube title and description cannot contain < or >")
+	}
+	if len([]rune(norm.Title)) > youtubeMaxTitle {
+		return nil, fmt.Errorf("youtube title is too long (max %d)", youtubeMaxTitle)
+	}
+	if len(norm.Description) > youtubeMaxDescriptionLen {
+		return nil, fmt.Errorf("youtube description is too long (max %d bytes)", youtubeMaxDescriptionLen)
+	}
+	seen := map[string]bool{}
+	tagsLen := 0
+	for _, tag := range yt.Tags {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "#")
+		if tag == "" || seen[strings.ToLower(tag)] {
+			continue
+		}
+		if strings.ContainsAny(tag, "<>,") {
+			return nil, fmt.Errorf("youtube tag %q cannot contain <, > or commas", tag)
+		}
+		seen[strings.ToLower(tag)] = true
+		// Tags with spaces count their surrounding quotes, plus a separator between tags.
+		tagsLen += len([]rune(tag)) + 1
+		if strings.Contains(tag, " ") {
+			tagsLen += 2
+		}
+		norm.Tags = append(norm.Tags, tag)
+	}
+	if tagsLen-1 > youtubeMaxTagsLen {
+		return nil, fmt.Errorf("youtube tags are too long (max %d characters in total)", youtubeMaxTagsLen)
+	}
+	switch norm.Privacy {
+	case "", "public", "unlisted", "private":
+	default:
+		return nil, fmt.Errorf("youtube privacy must be public, unlisted or private")
+	}
+	if yt.PublishAt != nil {
+		if norm.Privacy != "" && norm.Privacy != "private" {
+			return nil, fmt.Errorf("youtube publishAt requires privacy private")
+		}
+		at := yt.PublishAt.UTC()
+		norm.PublishAt = &at
+	}
+	if norm.CategoryID != "" && !numericIDRe.MatchString(norm.CategoryID) {
+		return nil, fmt.Errorf("youtube categoryId must be numeric")
+	}
+	if norm.Thumbnail != "" && !strings.HasPrefix(norm.Thumbnail, "/media/") {
+		return nil, fmt.Errorf("youtube thumbnail must be an uploaded /media/ path")
+	}
+	if norm.PlaylistID != "" && !youtubePlaylistIDRe.MatchString(norm.PlaylistID) {
+		return nil, fmt.Errorf("youtube playlistId is not valid")
+	}
+	if norm.Title == "" && norm.Description == "" && len(norm.Tags) == 0 && norm.Privacy == "" && norm.PublishAt == nil &&
+		!norm.MadeForKids && norm.CategoryID == "" && norm.Thumbnail == "" && norm.PlaylistID == "" {
+		return nil, nil
+	}
+	return norm, nil
+}
+
+// postOptionsFromJSON decodes a posts.options column (nil when unset or unreadable).
+func postOptionsFromJSON(raw []byte) *models.PostOptions {
+	if len(raw) == 0 {
+		return nil
+	}
+	var o models.PostOptions
+	if err := json.Unmarshal(raw, &o); err != nil || o == (models.PostOptions{}) {
+		return nil
+	}
+	return &o
+}
+
+// instagramStoryMode reports whether the post's Instagram media should go out as Stories.
+func instagramStoryMode(opts *models.PostOptions) bool {
+	return opts != nil && opts.Instagram != nil && opts.Instagram.Mode == "story"
+}
+
+// instagramPublishOptions are the Instagram options resolved for one publish call. AltText and UserTags
+// are aligned with the media URLs passed alongside them.
+type instagramPublishOptions struct {
+	LocationID    string
+	Collaborators []string
+	AltText       []string
+	UserTags      [][]models.InstagramUserTag
+}
+
+// instagramOptionsFor resolves post options for the media (rel paths) actually being published.
+// Tags without a media reference go on the first item.
+func instagramOptionsFor(opts *models.PostOptions, rels []string) instagramPublishOptions {
+	out := instagramPublishOptions{AltText: make([]string, len(rels)), UserTags: make([][]models.InstagramUserTag, len(rels))}
+	if opts == nil || opts.Instagram == nil {
+		return out
+	}
+	ig := opts.Instagram
+	out.LocationID = ig.LocationID
+	out.Collaborators = ig.Collaborators
+	for i, rel := range rels {
+		out.AltText[i] = ig.AltText[rel]
+	}
+	for _, tag := range ig.UserTags {
+		for i, rel := range rels {
+			if (tag.Media == "" && i == 0) || tag.Media == rel {
+				out.UserTags[i] = append(out.UserTags[i], tag)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// item returns the alt text and user tags for the i-th media URL.
+func (o instagramPublishOptions) item(i int) (string, []models.InstagramUserTag) {
+	alt := ""
+	var tags []models.InstagramUserTag
+	if i < len(o.AltText) {
+		alt = o.AltText[i]
+	}
+	if i < len(o.UserTags) {
+		tags = o.UserTags[i]
+	}
+	return alt, tags
+}
+
+// setInstagramItemFields adds alt text and user tags to a container form. Videos take tags without a position.
+func setInstagramItemFields(form url.Values, alt string, tags []models.InstagramUserTag, isVideo bool) {
+	if alt != "" && !isVideo {
+		form.Set("alt_text", alt)
+	}
+	if len(tags) == 0 {
+		return
+	}
+	out := make([]map[string]interface{}, 0, len(tags))
+	for _, t := range tags {
+		if isVideo {
+			out = append(out, map[string]interface{}{"username": t.Username})
+		} else {
+			out = append(out, map[string]interface{}{"username": t.Username, "x": t.X, "y": t.Y})
+		}
+	}
+	b, _ := json.Marshal(out)
+	form.Set("user_tags", string(b))
+}
+
+// setInstagramPostFields adds the post-level location and collaborators (single media or carousel parent).
+func setInstagramPostFields(form url.Values, o instagramPublishOptions) {
+	if o.LocationID != "" {
+		form.Set("location_id", o.LocationID)
+	}
+	if len(o.Collaborators) > 0 {
+		b, _ := json.Marshal(o.Collaborators)
+		form.Set("collaborators", string(b))
+	}
+}
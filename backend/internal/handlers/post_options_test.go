@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PortNumber53/simple-social-thing/backend/internal/models"
+)
+
+func TestNormalizePostOptions_Instagram(t *testing.T) {
+	media := []string{"/media/u/a.png", "/media/u/b.png"}
+	out, err := normalizePostOptions(&models.PostOptions{Instagram: &models.InstagramPostOptions{
+		UserTags:      []models.InstagramUserTag{{Username: " @alice ", X: 0.5, Y: 0.25, Media: "/media/u/b.png"}},
+		LocationID:    " 12345 ",
+		Collaborators: []string{"@bob", "Bob", ""},
+		AltText:       map[string]string{"/media/u/a.png": " a cat ", "/media/u/b.png": "  "},
+	}}, media)
+	if err != nil {
+		t.Fatalf("normalizePostOptions: %v", err)
+	}
+	ig := out.Instagram
+	if ig.LocationID != "12345" || len(ig.Collaborators) != 1 || ig.Collaborators[0] != "bob" {
+		t.Fatalf("unexpected options %#v", ig)
+	}
+	if ig.UserTags[0].Username != "alice" || len(ig.AltText) != 1 || ig.AltText["/media/u/a.png"] != "a cat" {
+		t.Fatalf("unexpected tags/alt %#v", ig)
+	}
+
+	if out, err := normalizePostOptions(&models.PostOptions{Instagram: &models.InstagramPostOptions{}}, media); err != nil || out != nil {
+		t.Fatalf("expected empty options to normalize to nil, got %#v %v", out, err)
+	}
+
+	bad := []*models.InstagramPostOptions{
+		{LocationID: "paris"},
+		{UserTags: []models.InstagramUserTag{{Username: "alice", X: 1.5}}},
+		{UserTags: []models.InstagramUserTag{{Username: "bad name"}}},
+		{UserTags: []models.InstagramUserTag{{Username: "alice", Media: "/media/u/other.png"}}},
+		{Collaborators: []string{"a", "b", "c", "d"}},
+		{AltText: map[string]string{"/media/u/other.png": "x"}},
+	}
+	for i, ig := range bad {
+		if _, err := normalizePostOptions(&models.PostOptions{Instagram: ig}, media); err == nil {
+			t.Fatalf("case %d: expected error", i)
+		}
+	}
+}
+
+func TestInstagramOptionsFor_AlignsWithPublishedMedia(t *testing.T) {
+	opts := &models.PostOptions{Instagram: &models.InstagramPostOptions{
+		UserTags: []models.InstagramUserTag{
+			{Username: "first"},
+			{Username: "onb", Media: "/media/u/b.png"},
+			{Username: "dropped", Media: "/media/u/gone.png"},
+		},
+		AltText: map[string]string{"/media/u/b.png": "bee"},
+	}}
+	got := instagramOptionsFor(opts, []string{"/media/u/a.png", "/media/u/b.png"})
+	if len(got.UserTags[0]) != 1 || got.UserTags[0][0].Username != "first" {
+		t.Fatalf("unexpected first item tags %#v", got.UserTags[0])
+	}
+	if alt, tags := got.item(1); alt != "bee" || len(tags) != 1 || tags[0].Username != "onb" {
+		t.Fatalf("unexpected second item alt=%q tags=%#v", alt, tags)
+	}
+	if alt, tags := got.item(5); alt != "" || tags != nil {
+		t.Fatalf("expected nothing out of range, got %q %#v", alt, tags)
+	}
+}
+
+func TestPublishInstagramImages_SendsOptions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+	expectInstagramOAuth(t, mock)
+	mock.ExpectExec(`INSERT INTO public\.social_libraries`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	var container url.Values
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		if r.Method == "GET" {
+			return httpJSON(200, `{"status_code":"FINISHED"}`, nil), nil
+		}
+		if strings.HasSuffix(r.URL.Path, "/media_publish") {
+			return httpJSON(200, `{"id":"ig_media_1"}`, nil), nil
+		}
+		b, _ := io.ReadAll(r.Body)
+		container, _ = url.ParseQuery(string(b))
+		return httpJSON(200, `{"id":"c1"}`, nil), nil
+	}}
+
+	opts := instagramOptionsFor(&models.PostOptions{Instagram: &models.InstagramPostOptions{
+		UserTags:      []models.InstagramUserTag{{Username: "alice", X: 0.5, Y: 0.5}},
+		LocationID:    "12345",
+		Collaborators: []string{"bob"},
+		AltText:       map[string]string{"/media/u/a.png": "a cat"},
+	}}, []string{"/media/u/a.png"})
+	posted, perr, details := h.publishInstagramImages(context.Background(), "u1", "caption", []string{"https://x/media/u/a.png"}, opts, false)
+	if perr != nil || posted != 1 {
+		t.Fatalf("expected success, got posted=%d err=%v details=%v", posted, perr, details)
+	}
+	var tags []map[string]interface{}
+	_ = json.Unmarshal([]byte(container.Get("user_tags")), &tags)
+	if len(tags) != 1 || tags[0]["username"] != "alice" || tags[0]["x"] != 0.5 {
+		t.Fatalf("unexpected user_tags %q", container.Get("user_tags"))
+	}
+	if container.Get("alt_text") != "a cat" || container.Get("location_id") != "12345" || container.Get("collaborators") != `["bob"]` {
+		t.Fatalf("unexpected container form %v", container)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
@@ -0,0 +1,429 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PortNumber53/simple-social-thing/backend/internal/models"
+	"github.com/lib/pq"
+	_ "golang.org/x/image/webp"
+)
+
+// preflightIssue is one problem found before publishing. Errors would make the provider fail;
+// warnings mean the post goes out but not exactly as written (e.g. trimmed media, a split caption).
+type preflightIssue struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Media   string                 `json:"media,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+type providerPreflight struct {
+	OK       bool             `json:"ok"`
+	Errors   []preflightIssue `json:"errors"`
+	Warnings []preflightIssue `json:"warnings"`
+}
+
+func (p *providerPreflight) fail(issue preflightIssue) {
+	p.OK = false
+	p.Errors = append(p.Errors, issue)
+}
+
+func (p *providerPreflight) warn(issue preflightIssue) {
+	p.Warnings = append(p.Warnings, issue)
+}
+
+type preflightReport struct {
+	OK        bool                          `json:"ok"`
+	Providers map[string]*providerPreflight `json:"providers"`
+}
+
+// preflightRules are the per-network limits checked generically; anything provider-specific
+// (X threading, Instagram carousel videos, Pinterest's single image) is handled in checkProviderPreflight.
+type preflightRules struct {
+	MaxCaption  int // characters; 0 means no limit
+	MaxHashtags int
+	MaxMentions int
+	MaxMedia    int
+	Needs       string // "", "media", "image" or "video"
+	MinVideoSec float64
+	MaxVideoSec float64
+	// MinAspect/MaxAspect bound image width/height; outside the range the network rejects the image.
+	MinAspect float64
+	MaxAspect float64
+	// VerticalVideo warns about landscape videos on vertical-first feeds.
+	VerticalVideo bool
+}
+
+var preflightProviderRules = map[string]preflightRules{
+	"facebook":  {MaxCaption: 63206},
+	"instagram": {MaxCaption: 2200, MaxHashtags: 30, MaxMentions: 20, MaxMedia: instagramMaxCarouselItems, Needs: "media", MinVideoSec: 3, MaxVideoSec: 900, MinAspect: 0.8, MaxAspect: 1.91, VerticalVideo: true},
+	"tiktok":    {MaxCaption: 2200, Needs: "media", MinVideoSec: 3, MaxVideoSec: 600, VerticalVideo: true},
+	"youtube":   {MaxCaption: 5000, Needs: "video"},
+	"pinterest": {MaxCaption: 500, Needs: "media"},
+	"threads":   {MaxCaption: threadsMaxTextChars, MaxMedia: threadsMaxCarouselItems, MaxVideoSec: 300},
+	"x":         {MaxVideoSec: 140},
+}
+
+// instagramStoryPreflightRules replace Instagram's feed rules in story mode; the 9:16 check is done separately
+// because letterboxing can fix it.
+var instagramStoryPreflightRules = preflightRules{MaxMedia: instagramMaxStoryItems, Needs: "media", MinVideoSec: instagramStoryMinVideoSec, MaxVideoSec: instagramStoryMaxVideoSec}
+
+var (
+	preflightHashtagRe = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&])#[\p{L}\p{N}_]+`)
+	preflightMentionRe = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_])@[A-Za-z0-9_.]+`)
+)
+
+// preflightMedia is what preflight learned about one media file.
+type preflightMedia struct {
+	Kind        string // image, video or other
+	Missing     bool
+	Width       int
+	Height      int
+	DurationSec float64
+	// Codec fields are only meaningful when Probed is true (ffprobe ran).
+	Probed  bool
+	HasH264 bool
+	HasAAC  bool
+}
+
+func (m preflightMedia) aspect() float64 {
+	if m.Width <= 0 || m.Height <= 0 {
+		return 0
+	}
+	return float64(m.Width) / float64(m.Height)
+}
+
+// analyzePreflightMedia reads image dimensions, and for videos probes duration, size and codecs with ffprobe.
+func analyzePreflightMedia(m uploadedMedia) preflightMedia {
+	out := preflightMedia{Kind: "other"}
+	_, isVideo, ok := xMediaKind(m)
+	switch {
+	case ok && isVideo:
+		out.Kind = "video"
+	case ok:
+		out.Kind = "image"
+	}
+	if out.Kind == "image" {
+		if cfg, _, err := image.DecodeConfig(bytes.NewReader(m.Bytes)); err == nil {
+			out.Width, out.Height = cfg.Width, cfg.Height
+		}
+		return out
+	}
+	if out.Kind != "video" || len(m.Bytes) == 0 {
+		return out
+	}
+	if _, err := exec.LookPath("ffprobe"); err != nil {
+		return out
+	}
+	tmp, err := os.CreateTemp("", "preflight_*"+filepath.Ext(m.Filename))
+	if err != nil {
+		return out
+	}
+	defer os.Remove(tmp.Name())
+	_, werr := tmp.Write(m.Bytes)
+	_ = tmp.Close()
+	if werr != nil {
+		return out
+	}
+	return probePreflightVideo(tmp.Name(), m.Filename, out)
+}
+
+// probePreflightVideo fills in a video's dimensions, duration and codecs from the file at path.
+func probePreflightVideo(path, filename string, out preflightMedia) preflightMedia {
+	probeOut, err := exec.Command("ffprobe", "-v", "error", "-show_entries", "format=duration:stream=codec_type,width,height", "-of", "json", path).Output()
+	if err != nil {
+		log.Printf("[Preflight] ffprobe failed file=%s err=%v", filename, err)
+		return out
+	}
+	var probe struct {
+		Streams []struct {
+			CodecType string `json:"codec_type"`
+			Width     int    `json:"width"`
+			Height    int    `json:"height"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(probeOut, &probe); err != nil {
+		return out
+	}
+	for _, s := range probe.Streams {
+		if s.CodecType == "video" && out.Width == 0 {
+			out.Width, out.Height = s.Width, s.Height
+		}
+	}
+	out.DurationSec, _ = strconv.ParseFloat(strings.TrimSpace(probe.Format.Duration), 64)
+	if hasH264, hasAAC, err := validateVideoCodecs(path); err == nil {
+		out.Probed, out.HasH264, out.HasAAC = true, hasH264, hasAAC
+	}
+	return out
+}
+
+// loadPreflightMedia loads and analyzes each rel path; files that can no longer be read are marked Missing.
+func loadPreflightMedia(relMedia []string) map[string]preflightMedia {
+	out := map[string]preflightMedia{}
+	for _, rel := range relMedia {
+		if _, ok := out[rel]; ok {
+			continue
+		}
+		files, err := loadUploadedMediaFromRelPaths([]string{rel})
+		if err != nil || len(files) == 0 {
+			out[rel] = preflightMedia{Kind: "other", Missing: true}
+			continue
+		}
+		out[rel] = analyzePreflightMedia(files[0])
+	}
+	return out
+}
+
+// preflightPost checks a post against every selected network without contacting any of them.
+func preflightPost(caption string, providers, relMedia []string, variants map[string]models.PostVariant, options *models.PostOptions) preflightReport {
+	return buildPreflightReport(caption, providers, relMedia, loadPreflightMedia(relMedia), variants, options)
+}
+
+func buildPreflightReport(caption string, providers, relMedia []string, media map[string]preflightMedia, variants map[string]models.PostVariant, options *models.PostOptions) preflightReport {
+	if len(providers) == 0 {
+		// Same default as runPublishJob: no providers means all of them.
+		providers = []string{"facebook", "instagram", "tiktok", "youtube", "pinterest", "threads", "x"}
+	}
+	report := preflightReport{OK: true, Providers: map[string]*providerPreflight{}}
+	for _, provider := range providers {
+		in := publishInputFor(provider, caption, variants, relMedia, nil)
+		res := &providerPreflight{OK: true, Errors: []preflightIssue{}, Warnings: []preflightIssue{}}
+		checkProviderPreflight(provider, in.Caption, in.RelMedia, media, options, res)
+		if !res.OK {
+			report.OK = false
+		}
+		report.Providers[provider] = res
+	}
+	return report
+}
+
+func checkProviderPreflight(provider, caption string, relMedia []string, media map[string]preflightMedia, options *models.PostOptions, res *providerPreflight) {
+	rules := preflightProviderRules[provider]
+	story := provider == "instagram" && instagramStoryMode(options)
+	if story {
+		rules = instagramStoryPreflightRules
+	}
+	caption = strings.TrimSpace(caption)
+
+	if n := utf8.RuneCountInString(caption); rules.MaxCaption > 0 && n > rules.MaxCaption {
+		res.fail(preflightIssue{Code: "caption_too_long", Message: fmt.Sprintf("Caption is %d characters; %s allows %d.", n, provider, rules.MaxCaption),
+			Details: map[string]interface{}{"length": n, "max": rules.MaxCaption}})
+	}
+	hashtags := len(preflightHashtagRe.FindAllString(caption, -1))
+	if rules.MaxHashtags > 0 && hashtags > rules.MaxHashtags {
+		res.fail(preflightIssue{Code: "too_many_hashtags", Message: fmt.Sprintf("%d hashtags; %s allows %d.", hashtags, provider, rules.MaxHashtags),
+			Details: map[string]interface{}{"count": hashtags, "max": rules.MaxHashtags}})
+	}
+	mentions := len(preflightMentionRe.FindAllString(caption, -1))
+	if rules.MaxMentions > 0 && mentions > rules.MaxMentions {
+		res.fail(preflightIssue{Code: "too_many_mentions", Message: fmt.Sprintf("%d mentions; %s allows %d.", mentions, provider, rules.MaxMentions),
+			Details: map[string]interface{}{"count": mentions, "max": rules.MaxMentions}})
+	}
+
+	var images, videos []string
+	for _, rel := range relMedia {
+		m := media[rel]
+		if m.Missing {
+			res.fail(preflightIssue{Code: "media_missing", Message: "Media file is no longer available.", Media: rel})
+			continue
+		}
+		switch m.Kind {
+		case "image":
+			images = append(images, rel)
+		case "video":
+			videos = append(videos, rel)
+		default:
+			res.warn(preflightIssue{Code: "media_unsupported", Message: "Unsupported media type; it will be skipped.", Media: rel})
+		}
+	}
+	if rules.MaxMedia > 0 && len(images)+len(videos) > rules.MaxMedia {
+		res.fail(preflightIssue{Code: "too_many_media", Message: fmt.Sprintf("%d media items; %s allows %d.", len(images)+len(videos), provider, rules.MaxMedia),
+			Details: map[string]interface{}{"count": len(images) + len(videos), "max": rules.MaxMedia}})
+	}
+	switch {
+	case rules.Needs == "media" && len(images)+len(videos) == 0:
+		res.fail(preflightIssue{Code: "media_required", Message: provider + " requires an image or video."})
+	case rules.Needs == "image" && len(images) == 0:
+		res.fail(preflightIssue{Code: "image_required", Message: provider + " requires an image."})
+	case rules.Needs == "video" && len(videos) == 0:
+		res.fail(preflightIssue{Code: "video_required", Message: provider + " requires a video."})
+	}
+
+	for _, rel := range images {
+		m := media[rel]
+		if a := m.aspect(); a > 0 && rules.MinAspect > 0 && (a < rules.MinAspect-0.01 || a > rules.MaxAspect+0.01) {
+			res.fail(preflightIssue{Code: "aspect_ratio_unsupported", Message: fmt.Sprintf("Image aspect ratio %.2f is outside %s's %.2f–%.2f range.", a, provider, rules.MinAspect, rules.MaxAspect),
+				Media: rel, Details: map[string]interface{}{"width": m.Width, "height": m.Height}})
+		}
+	}
+	for _, rel := range videos {
+		m := media[rel]
+		if !m.Probed {
+			res.warn(preflightIssue{Code: "video_not_probed", Message: "Could not inspect the video (ffprobe unavailable or failed); duration and codecs were not checked.", Media: rel})
+			continue
+		}
+		if rules.MinVideoSec > 0 && m.DurationSec > 0 && m.DurationSec < rules.MinVideoSec {
+			res.fail(preflightIssue{Code: "video_too_short", Message: fmt.Sprintf("Video is %.1fs; %s needs at least %.0fs.", m.DurationSec, provider, rules.MinVideoSec),
+				Media: rel, Details: map[string]interface{}{"durationSec": m.DurationSec, "min": rules.MinVideoSec}})
+		}
+		if rules.MaxVideoSec > 0 && m.DurationSec > rules.MaxVideoSec {
+			res.fail(preflightIssue{Code: "video_too_long", Message: fmt.Sprintf("Video is %.0fs; %s allows %.0fs.", m.DurationSec, provider, rules.MaxVideoSec),
+				Media: rel, Details: map[string]interface{}{"durationSec": m.DurationSec, "max": rules.MaxVideoSec}})
+		}
+		if rules.VerticalVideo && m.Width > m.Height && m.Height > 0 {
+			res.warn(preflightIssue{Code: "video_not_vertical", Message: provider + " shows vertical (9:16) video best; this one is landscape.",
+				Media: rel, Details: map[string]interface{}{"width": m.Width, "height": m.Height}})
+		}
+	}
+
+	switch provider {
+	case "instagram":
+		if story {
+			if caption != "" {
+				res.warn(preflightIssue{Code: "caption_ignored", Message: "Stories have no caption; it is not published."})
+			}
+			letterbox := options.Instagram.StoryLetterbox
+			for _, rel := range append(append([]string{}, images...), videos...) {
+				m := media[rel]
+				if m.Width == 0 || isInstagramStoryAspect(m.Width, m.Height) {
+					continue
+				}
+				if letterbox {
+					res.warn(preflightIssue{Code: "media_letterboxed", Message: "Media is not 9:16; it will be letterboxed for the Story.",
+						Media: rel, Details: map[string]interface{}{"width": m.Width, "height": m.Height}})
+				} else {
+					res.fail(preflightIssue{Code: "aspect_ratio_unsupported", Message: fmt.Sprintf("Stories need 9:16 media; this one is %.2f. Enable letterboxing or crop it.", m.aspect()),
+						Media: rel, Details: map[string]interface{}{"width": m.Width, "height": m.Height}})
+				}
+			}
+			break
+		}
+		// Several items (videos included) are published as one carousel, where videos are capped shorter than Reels.
+		carousel := len(images)+len(videos) > 1
+		for _, rel := range videos {
+			m := media[rel]
+			if m.Probed && (!m.HasH264 || !m.HasAAC) {
+				res.warn(preflightIssue{Code: "video_will_be_transcoded", Message: "Video is not H.264/AAC; it will be transcoded before publishing.", Media: rel})
+			}
+			if carousel && m.DurationSec > instagramMaxCarouselVideoSec {
+				res.fail(preflightIssue{Code: "video_too_long", Message: fmt.Sprintf("Carousel videos can be at most %ds; this one is %.0fs.", instagramMaxCarouselVideoSec, m.DurationSec),
+					Media: rel, Details: map[string]interface{}{"durationSec": m.DurationSec, "max": instagramMaxCarouselVideoSec}})
+			}
+		}
+	case "facebook":
+		if len(videos) > 0 && len(images)+len(videos) > 1 {
+			res.warn(preflightIssue{Code: "media_ignored", Message: "Facebook posts carry one video or photos; only the first video is published.", Details: map[string]interface{}{"skipped": len(images) + len(videos) - 1}})
+		}
+	case "tiktok", "youtube":
+		if provider == "tiktok" && len(videos) == 0 {
+			// No video: the images become a photo post.
+			if len(images) > tiktokMaxPhotos {
+				res.warn(preflightIssue{Code: "media_ignored", Message: fmt.Sprintf("TikTok photo posts carry up to %d images; the rest is skipped.", tiktokMaxPhotos),
+					Details: map[string]interface{}{"skipped": len(images) - tiktokMaxPhotos}})
+			}
+			for _, rel := range images {
+				if ext := strings.ToLower(filepath.Ext(rel)); ext != ".jpg" && ext != ".jpeg" && ext != ".webp" {
+					res.warn(preflightIssue{Code: "image_converted", Message: "TikTok photo posts take JPEG or WebP; this image will be converted to JPEG.", Media: rel})
+				}
+			}
+			break
+		}
+		if len(videos) > 1 || (len(videos) == 1 && len(images) > 0) {
+			res.warn(preflightIssue{Code: "media_ignored", Message: "Only the first video is published.", Details: map[string]interface{}{"skipped": len(images) + len(videos) - 1}})
+		}
+		if provider == "youtube" && hashtags > 15 {
+			res.warn(preflightIssue{Code: "too_many_hashtags", Message: "YouTube ignores every hashtag when a video has more than 15.",
+				Details: map[string]interface{}{"count": hashtags, "max": 15}})
+		}
+	case "pinterest":
+		// A video pin takes the first image as its cover; otherwise up to 5 images make a carousel pin.
+		if len(videos) > 1 || (len(videos) == 1 && len(images) > 1) {
+			res.warn(preflightIssue{Code: "media_ignored", Message: "Video pins carry one video (and one cover image); the rest is skipped.",
+				Details: map[string]interface{}{"skipped": len(images) + len(videos) - 1 - min(len(images), 1)}})
+		} else if len(videos) == 0 && len(images) > pinterestMaxCarouselImages {
+			res.warn(preflightIssue{Code: "media_ignored", Message: fmt.Sprintf("Carousel pins carry up to %d images; the rest is skipped.", pinterestMaxCarouselImages),
+				Details: map[string]interface{}{"skipped": len(images) - pinterestMaxCarouselImages}})
+		}
+		if len(images) > 0 {
+			if m := media[images[0]]; m.aspect() > 1 {
+				res.warn(preflightIssue{Code: "aspect_ratio_not_recommended", Message: "Pinterest recommends vertical 2:3 images; wide pins are shown small.",
+					Media: images[0], Details: map[string]interface{}{"width": m.Width, "height": m.Height}})
+			}
+		}
+	case "threads":
+		if hashtags > 1 {
+			res.warn(preflightIssue{Code: "too_many_hashtags", Message: "Threads only links the first hashtag as a topic.", Details: map[string]interface{}{"count": hashtags, "max": 1}})
+		}
+	case "x":
+		if parts := splitXThread(caption); len(parts) > xMaxThreadLength {
+			res.fail(preflightIssue{Code: "caption_too_long", Message: fmt.Sprintf("Caption needs %d posts; X threads are capped at %d.", len(parts), xMaxThreadLength),
+				Details: map[string]interface{}{"parts": len(parts), "max": xMaxThreadLength}})
+		} else if len(parts) > 1 {
+			res.warn(preflightIssue{Code: "caption_threaded", Message: fmt.Sprintf("Caption is over %d characters and will be posted as a %d-part thread.", xMaxTweetWeight, len(parts)),
+				Details: map[string]interface{}{"parts": len(parts)}})
+		}
+		if caption == "" && len(images)+len(videos) == 0 {
+			res.fail(preflightIssue{Code: "text_or_media_required", Message: "X posts need text or media."})
+		}
+		if len(videos) > 1 || (len(videos) == 1 && len(images) > 0) || (len(videos) == 0 && len(images) > xMaxImagesPerPost) {
+			res.warn(preflightIssue{Code: "media_ignored", Message: fmt.Sprintf("X posts carry one video or up to %d images; the rest is skipped.", xMaxImagesPerPost)})
+		}
+	}
+}
+
+// PreflightPostForUser checks a saved post (with its per-provider variants and options) against each network's limits.
+//
+// URL: POST /api/posts/{postId}/preflight/user/{userId}
+func (h *Handler) PreflightPostForUser(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	userID := strings.TrimSpace(pathVar(r, "userId"))
+	postID := strings.TrimSpace(pathVar(r, "postId"))
+	if userID == "" || postID == "" {
+		writeError(w, http.StatusBadRequest, "userId and postId are required")
+		return
+	}
+
+	var (
+		content   sql.NullString
+		providers []string
+		media     []string
+		variants  []byte
+		options   []byte
+	)
+	err := h.db.QueryRowContext(r.Context(), `
+		SELECT content, COALESCE(providers, ARRAY[]::text[]), COALESCE(media, ARRAY[]::text[]), variants, options
+		  FROM public.posts
+		 WHERE id = $1 AND user_id = $2
+	`, postID, userID).Scan(&content, pq.Array(&providers), pq.Array(&media), &variants, &options)
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	report := preflightPost(content.String, providers, media, postVariantsFromJSON(variants), postOptionsFromJSON(options))
+	writeJSON(w, http.StatusOK, report)
+}
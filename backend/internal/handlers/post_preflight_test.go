@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PortNumber53/simple-social-thing/backend/internal/models"
+	"github.com/gorilla/mux"
+)
+
+func preflightCodes(issues []preflightIssue) string {
+	codes := make([]string, 0, len(issues))
+	for _, i := range issues {
+		codes = append(codes, i.Code)
+	}
+	return strings.Join(codes, ",")
+}
+
+func TestBuildPreflightReport_PerProviderChecks(t *testing.T) {
+	media := map[string]preflightMedia{
+		"/media/u/wide.png": {Kind: "image", Width: 3000, Height: 1000},
+		"/media/u/tall.png": {Kind: "image", Width: 1080, Height: 1350},
+		"/media/u/clip.mp4": {Kind: "video"},
+	}
+	caption := strings.Repeat("word ", 100) + strings.Repeat("#tag ", 31)
+	report := buildPreflightReport(caption, []string{"instagram", "tiktok", "pinterest", "x", "youtube"},
+		[]string{"/media/u/wide.png", "/media/u/tall.png"}, media,
+		map[string]models.PostVariant{"pinterest": {Caption: "short", Media: []string{"/media/u/tall.png"}}}, nil)
+
+	if report.OK {
+		t.Fatalf("expected report to fail")
+	}
+	if got := preflightCodes(report.Providers["instagram"].Errors); got != "too_many_hashtags,aspect_ratio_unsupported" {
+		t.Fatalf("unexpected instagram errors %q", got)
+	}
+	if p := report.Providers["tiktok"]; !p.OK || preflightCodes(p.Warnings) != "image_converted,image_converted" {
+		t.Fatalf("expected tiktok photo post with converted images, got %#v", p)
+	}
+	if p := report.Providers["pinterest"]; !p.OK || len(p.Warnings) != 0 {
+		t.Fatalf("expected pinterest variant to pass cleanly, got %#v", p)
+	}
+	if p := report.Providers["x"]; !p.OK || preflightCodes(p.Warnings) != "caption_threaded" {
+		t.Fatalf("expected x caption to be threaded, got %#v", p)
+	}
+	if got := preflightCodes(report.Providers["youtube"].Warnings); got != "too_many_hashtags" {
+		t.Fatalf("unexpected youtube warnings %q", got)
+	}
+
+	// Unprobed videos are only a warning.
+	report = buildPreflightReport("hi", []string{"instagram"}, []string{"/media/u/clip.mp4", "/media/u/tall.png"}, media, nil, nil)
+	if p := report.Providers["instagram"]; !p.OK || preflightCodes(p.Warnings) != "video_not_probed" {
+		t.Fatalf("unexpected instagram video result %#v", p)
+	}
+}
+
+func TestAnalyzePreflightMedia_ReadsImageSize(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 40, 30))); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	m := analyzePreflightMedia(uploadedMedia{Filename: "a.png", ContentType: "image/png", Bytes: buf.Bytes()})
+	if m.Kind != "image" || m.Width != 40 || m.Height != 30 {
+		t.Fatalf("unexpected media %#v", m)
+	}
+}
+
+func TestCreatePostForUser_ScheduledRunsPreflight(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"content":      strings.Repeat("x", threadsMaxTextChars+1),
+		"status":       "scheduled",
+		"providers":    []string{"threads"},
+		"scheduledFor": "2030-01-01T09:00:00Z",
+	})
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/posts/user/u1", bytes.NewReader(body))
+	req = mux.SetURLVars(req, map[string]string{"userId": "u1"})
+	h.CreatePostForUser(rr, req)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 got %d %s", rr.Code, rr.Body.String())
+	}
+	var out struct {
+		Error     string          `json:"error"`
+		Preflight preflightReport `json:"preflight"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out.Error != "preflight_failed" || preflightCodes(out.Preflight.Providers["threads"].Errors) != "caption_too_long" {
+		t.Fatalf("unexpected body %s", rr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestPreflightPostForUser_UsesSavedPost(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	mock.ExpectQuery(`SELECT content, COALESCE\(providers`).
+		WithArgs("p1", "u1").
+		WillReturnRows(sqlmock.NewRows([]string{"content", "providers", "media", "variants", "options"}).
+			AddRow("hello", "{facebook,tiktok}", "{}", []byte(`{"facebook":{"caption":"hi fb"}}`), nil))
+	mock.ExpectQuery(`SELECT content, COALESCE\(providers`).
+		WithArgs("missing", "u1").
+		WillReturnRows(sqlmock.NewRows([]string{"content", "providers", "media", "variants", "options"}))
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/posts/p1/preflight/user/u1", nil)
+	req = mux.SetURLVars(req, map[string]string{"userId": "u1", "postId": "p1"})
+	h.PreflightPostForUser(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d %s", rr.Code, rr.Body.String())
+	}
+	var report preflightReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if report.OK || !report.Providers["facebook"].OK || report.Providers["tiktok"].OK {
+		t.Fatalf("unexpected report %s", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/api/posts/missing/preflight/user/u1", nil)
+	req = mux.SetURLVars(req, map[string]string{"userId": "u1", "postId": "missing"})
+	h.PreflightPostForUser(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 got %d", rr.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
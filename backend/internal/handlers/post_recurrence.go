@@ -0,0 +1,863 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PortNumber53/simple-social-thing/backend/internal/models"
+	"github.com/lib/pq"
+)
+
+const (
+	// recurrenceMaxPeriods bounds how many days/weeks/months a rule is enumerated over (~50 years of daily posts).
+	recurrenceMaxPeriods = 20000
+	// recurringMissedAfter: a slot found this long after it was due (e.g. the worker was down) is recorded as
+	// missed instead of being published late.
+	recurringMissedAfter = time.Hour
+)
+
+var recurrenceWeekdays = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+var recurrenceWeekdayCodes = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// normalizeRecurrence validates rule and anchors it at start (the post's first scheduled time).
+func normalizeRecurrence(rule *models.PostRecurrence, start time.Time) error {
+	rule.Freq = strings.ToLower(strings.TrimSpace(rule.Freq))
+	switch rule.Freq {
+	case "daily", "weekly", "monthly":
+	default:
+		return fmt.Errorf("invalid recurrence freq")
+	}
+	if rule.Interval <= 0 {
+		rule.Interval = 1
+	}
+	if rule.Interval > 366 {
+		return fmt.Errorf("invalid recurrence interval")
+	}
+	if rule.Count < 0 {
+		return fmt.Errorf("invalid recurrence count")
+	}
+	rule.Timezone = strings.TrimSpace(rule.Timezone)
+	if rule.Timezone == "" {
+		rule.Timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(rule.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid recurrence timezone")
+	}
+	st := start.UTC()
+	rule.Start = &st
+	if rule.Until != nil {
+		u := rule.Until.UTC()
+		if u.Before(st) {
+			return fmt.Errorf("recurrence until is before scheduledFor")
+		}
+		rule.Until = &u
+	}
+	local := st.In(loc)
+
+	rule.ByWeekday = normalizeWeekdays(rule.ByWeekday)
+	if rule.Freq == "weekly" && len(rule.ByWeekday) == 0 {
+		rule.ByWeekday = []string{recurrenceWeekdayCodes[local.Weekday()]}
+	}
+	if rule.Freq != "weekly" && len(rule.ByWeekday) > 0 {
+		return fmt.Errorf("byWeekday is only supported for weekly recurrence")
+	}
+	for _, code := range rule.ByWeekday {
+		if _, ok := recurrenceWeekdays[code]; !ok {
+			return fmt.Errorf("invalid recurrence byWeekday")
+		}
+	}
+
+	if rule.Freq != "monthly" && len(rule.ByMonthDay) > 0 {
+		return fmt.Errorf("byMonthDay is only supported for monthly recurrence")
+	}
+	if rule.Freq == "monthly" {
+		days := make([]int, 0, len(rule.ByMonthDay))
+		seen := map[int]bool{}
+		for _, d := range rule.ByMonthDay {
+			if d < 1 || d > 31 {
+				return fmt.Errorf("invalid recurrence byMonthDay")
+			}
+			if !seen[d] {
+				seen[d] = true
+				days = append(days, d)
+			}
+		}
+		if len(days) == 0 {
+			days = []int{local.Day()}
+		}
+		sort.Ints(days)
+		rule.ByMonthDay = days
+	}
+	return nil
+}
+
+func normalizeWeekdays(in []string) []string {
+	seen := map[string]bool{}
+	out := make([]string, 0, len(in))
+	for _, raw := range in {
+		code := strings.ToUpper(strings.TrimSpace(raw))
+		if len(code) > 2 {
+			code = code[:2]
+		}
+		if code == "" || seen[code] {
+			continue
+		}
+		seen[code] = true
+		out = append(out, code)
+	}
+	return out
+}
+
+// recurrenceNext returns the first occurrence of rule strictly after `after`, or false when the series is over.
+func recurrenceNext(rule models.PostRecurrence, after time.Time) (time.Time, bool) {
+	if rule.Start == nil {
+		return time.Time{}, false
+	}
+	loc, err := time.LoadLocation(rule.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	s := rule.Start.In(loc)
+	hh, mm, ss := s.Clock()
+	interval := rule.Interval
+	if interval <= 0 {
+		interval = 1
+	}
+
+	idx := 0
+	// check returns (result, found, stop).
+	check := func(t time.Time) (time.Time, bool, bool) {
+		if t.Before(*rule.Start) {
+			return time.Time{}, false, false
+		}
+		idx++
+		if rule.Count > 0 && idx > rule.Count {
+			return time.Time{}, false, true
+		}
+		if rule.Until != nil && t.After(*rule.Until) {
+			return time.Time{}, false, true
+		}
+		if t.After(after) {
+			return t.UTC(), true, true
+		}
+		return time.Time{}, false, false
+	}
+
+	k0 := recurrenceFirstPeriod(rule, s, after.In(loc), interval)
+	switch rule.Freq {
+	case "daily":
+		for k := k0; k < k0+recurrenceMaxPeriods; k++ {
+			t := resolveLocalTime(s.Year(), s.Month(), s.Day()+k*interval, hh, mm, ss, loc)
+			if res, found, stop := check(t); stop {
+				return res, found
+			}
+		}
+	case "weekly":
+		offsets := make([]int, 0, len(rule.ByWeekday))
+		for _, code := range rule.ByWeekday {
+			if wd, ok := recurrenceWeekdays[code]; ok {
+				offsets = append(offsets, (int(wd)+6)%7) // Monday-based
+			}
+		}
+		sort.Ints(offsets)
+		if len(offsets) == 0 {
+			return time.Time{}, false
+		}
+		weekStart := s.Day() - (int(s.Weekday())+6)%7
+		for k := k0; k < k0+recurrenceMaxPeriods; k++ {
+			for _, off := range offsets {
+				t := resolveLocalTime(s.Year(), s.Month(), weekStart+k*7*interval+off, hh, mm, ss, loc)
+				if res, found, stop := check(t); stop {
+					return res, found
+				}
+			}
+		}
+	case "monthly":
+		if len(rule.ByMonthDay) == 0 {
+			return time.Time{}, false
+		}
+		for k := k0; k < k0+recurrenceMaxPeriods; k++ {
+			first := time.Date(s.Year(), s.Month()+time.Month(k*interval), 1, 0, 0, 0, 0, loc)
+			daysIn := time.Date(first.Year(), first.Month()+1, 0, 0, 0, 0, 0, loc).Day()
+			for _, d := range rule.ByMonthDay {
+				if d > daysIn {
+					continue
+				}
+				t := resolveLocalTime(first.Year(), first.Month(), d, hh, mm, ss, loc)
+				if res, found, stop := check(t); stop {
+					return res, found
+				}
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// recurrenceFirstPeriod returns the period (day/week/month from the rule's start) recurrenceNext starts
+// scanning at. Without a count the periods are independent, so the walk starts one period before the one
+// holding `after` (in practice the last materialized occurrence) instead of at the start of the series. With
+// a count the earlier slots have to be counted, but then the series is at most count slots long anyway.
+func recurrenceFirstPeriod(rule models.PostRecurrence, s, after time.Time, interval int) int {
+	if rule.Count > 0 || !after.After(s) {
+		return 0
+	}
+	civil := func(t time.Time) int64 {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400
+	}
+	days := int(civil(after) - civil(s))
+	var k int
+	switch rule.Freq {
+	case "daily":
+		k = days / interval
+	case "weekly":
+		k = (days + (int(s.Weekday())+6)%7) / 7 / interval
+	case "monthly":
+		k = ((after.Year()-s.Year())*12 + int(after.Month()) - int(s.Month())) / interval
+	}
+	if k--; k < 0 {
+		return 0
+	}
+	return k
+}
+
+// prepareRecurrence validates a rule sent with a post and returns its first occurrence (at or after start)
+// together with the JSON stored in posts.recurrence.
+func prepareRecurrence(rule *models.PostRecurrence, start time.Time) (time.Time, string, error) {
+	if err := normalizeRecurrence(rule, start); err != nil {
+		return time.Time{}, "", err
+	}
+	first, ok := recurrenceNext(*rule, rule.Start.Add(-time.Nanosecond))
+	if !ok {
+		return time.Time{}, "", fmt.Errorf("recurrence has no occurrences")
+	}
+	raw, err := json.Marshal(rule)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	return first, string(raw), nil
+}
+
+func isRecurrenceOccurrence(rule models.PostRecurrence, t time.Time) bool {
+	got, ok := recurrenceNext(rule, t.Add(-time.Nanosecond))
+	return ok && got.Equal(t)
+}
+
+// scheduledPublishProblem reports why a scheduled post/occurrence can't be enqueued (empty when it can).
+func scheduledPublishProblem(caption string, providers []string, media []string) string {
+	if strings.TrimSpace(caption) == "" {
+		return "empty_content"
+	}
+	if len(providers) == 0 {
+		return "missing_providers"
+	}
+	if len(media) == 0 {
+		for _, p := range providers {
+			switch p {
+			case "instagram", "pinterest", "tiktok", "youtube":
+				return "missing_media"
+			}
+		}
+	}
+	return ""
+}
+
+// processDueRecurringPostsOnce turns due slots of recurring posts into post_occurrences rows (advancing the
+// post's scheduled_for to the next slot) and then enqueues a PublishJob per due pending occurrence.
+func (h *Handler) processDueRecurringPostsOnce(ctx context.Context, origin string, limit int, startJob startPublishJobFunc) (int, error) {
+	if err := h.expandDueRecurringPosts(ctx, limit); err != nil {
+		return 0, err
+	}
+	return h.enqueueDueOccurrences(ctx, origin, limit, startJob)
+}
+
+func (h *Handler) expandDueRecurringPosts(ctx context.Context, limit int) error {
+	type cand struct {
+		id, userID string
+		slot       time.Time
+		rule       []byte
+	}
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT id, user_id, scheduled_for, recurrence
+		  FROM public.posts
+		 WHERE status = 'scheduled'
+		   AND recurrence IS NOT NULL
+		   AND scheduled_for IS NOT NULL
+		   AND scheduled_for <= NOW()
+		 ORDER BY scheduled_for ASC, user_id, id
+		 LIMIT $1
+	`, limit)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	cands := make([]cand, 0)
+	for rows.Next() {
+		var c cand
+		if err := rows.Scan(&c.id, &c.userID, &c.slot, &c.rule); err != nil {
+			return err
+		}
+		cands = append(cands, c)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, c := range cands {
+		var rule models.PostRecurrence
+		if err := json.Unmarshal(c.rule, &rule); err != nil || rule.Start == nil {
+			_, _ = h.db.ExecContext(ctx, `
+				UPDATE public.posts
+				   SET scheduled_for = NULL,
+				       last_publish_status = 'failed',
+				       last_publish_error = 'invalid_recurrence',
+				       updated_at = NOW()
+				 WHERE id = $1 AND user_id = $2
+			`, c.id, c.userID)
+			log.Printf("[RecurringPosts] invalid_recurrence postId=%s userId=%s err=%v", c.id, c.userID, err)
+			continue
+		}
+
+		now := time.Now().UTC()
+		status := "pending"
+		if now.Sub(c.slot) > recurringMissedAfter {
+			status = "missed"
+		}
+		// Jump past now: slots that fell between this one and now (worker downtime) are not replayed.
+		from := c.slot
+		if now.After(from) {
+			from = now
+		}
+		var nextArg interface{}
+		if next, ok := recurrenceNext(rule, from); ok {
+			nextArg = next
+		}
+
+		if err := h.materializeOccurrence(ctx, c.id, c.userID, c.slot, status, nextArg); err != nil {
+			log.Printf("[RecurringPosts] expand_failed postId=%s userId=%s slot=%s err=%v",
+				c.id, c.userID, c.slot.UTC().Format(time.RFC3339), err)
+			continue
+		}
+		log.Printf("[RecurringPosts] occurrence postId=%s userId=%s slot=%s status=%s next=%v",
+			c.id, c.userID, c.slot.UTC().Format(time.RFC3339), status, nextArg)
+	}
+	return nil
+}
+
+// materializeOccurrence records slot as an occurrence (keeping any skip/edit made ahead of time) and moves the
+// post on to next (nil when the series is over), atomically so two instances can't both claim the slot.
+func (h *Handler) materializeOccurrence(ctx context.Context, postID, userID string, slot time.Time, status string, next interface{}) error {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE public.posts
+		   SET scheduled_for = $4,
+		       updated_at = NOW()
+		 WHERE id = $1
+		   AND user_id = $2
+		   AND scheduled_for = $3
+		   AND status = 'scheduled'
+	`, postID, userID, slot, next)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO public.post_occurrences (id, post_id, user_id, occurrence_at, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		ON CONFLICT (post_id, occurrence_at) DO UPDATE
+		   SET status = CASE WHEN public.post_occurrences.status = 'pending' THEN EXCLUDED.status ELSE public.post_occurrences.status END,
+		       updated_at = NOW()
+	`, "occ_"+randHex(12), postID, userID, slot, status); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (h *Handler) enqueueDueOccurrences(ctx context.Context, origin string, limit int, startJob startPublishJobFunc) (int, error) {
+	type cand struct {
+		id, postID, userID string
+		occurrenceAt       time.Time
+	}
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT o.id, o.post_id, o.user_id, o.occurrence_at
+		  FROM public.post_occurrences o
+		  JOIN public.posts p ON p.id = o.post_id
+		 WHERE o.status = 'pending'
+		   AND COALESCE(o.scheduled_for, o.occurrence_at) <= NOW()
+		   AND p.status = 'scheduled'
+		   AND `+providerSpacingClear("o.user_id", "COALESCE(o.providers, p.providers)")+`
+		 ORDER BY COALESCE(o.scheduled_for, o.occurrence_at) ASC, o.id
+		 LIMIT $1
+	`, limit)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	cands := make([]cand, 0)
+	for rows.Next() {
+		var c cand
+		if err := rows.Scan(&c.id, &c.postID, &c.userID, &c.occurrenceAt); err != nil {
+			return 0, err
+		}
+		cands = append(cands, c)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	enqueued := 0
+	prefs := map[string]*schedulingPreferences{}
+	for _, c := range cands {
+		if until, quiet := h.quietHoursDeferral(ctx, prefs, c.userID, time.Now()); quiet {
+			// occurrence_at keeps the slot; scheduled_for holds when it may actually go out.
+			if _, err := h.db.ExecContext(ctx, `
+				UPDATE public.post_occurrences
+				   SET scheduled_for = $2, updated_at = NOW()
+				 WHERE id = $1 AND status = 'pending'
+			`, c.id, until); err != nil {
+				log.Printf("[RecurringPosts] defer_failed occurrenceId=%s postId=%s err=%v", c.id, c.postID, err)
+				continue
+			}
+			log.Printf("[RecurringPosts] deferred occurrenceId=%s postId=%s userId=%s until=%s reason=quiet_hours",
+				c.id, c.postID, c.userID, until.UTC().Format(time.RFC3339))
+			continue
+		}
+		jobID := fmt.Sprintf("pub_%s", randHex(12))
+		res, err := h.db.ExecContext(ctx, `
+			UPDATE public.post_occurrences
+			   SET status = 'queued',
+			       publish_job_id = $2,
+			       error = NULL,
+			       attempted_at = NOW(),
+			       updated_at = NOW()
+			 WHERE id = $1
+			   AND status = 'pending'
+			   AND `+providerSpacingClear("post_occurrences.user_id",
+			"COALESCE(post_occurrences.providers, (SELECT providers FROM public.posts WHERE id = post_occurrences.post_id))")+`
+		`, c.id, jobID)
+		if err != nil {
+			log.Printf("[RecurringPosts] claim_failed occurrenceId=%s postId=%s err=%v", c.id, c.postID, err)
+			continue
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue
+		}
+
+		fail := func(reason string) {
+			_, _ = h.db.ExecContext(ctx, `
+				UPDATE public.post_occurrences
+				   SET status = 'failed', error = $3, updated_at = NOW()
+				 WHERE id = $1 AND publish_job_id = $2
+			`, c.id, jobID, reason)
+			log.Printf("[RecurringPosts] skipped occurrenceId=%s postId=%s jobId=%s reason=%s", c.id, c.postID, jobID, reason)
+		}
+
+		var (
+			content   sql.NullString
+			providers []string
+			media     []string
+			variants  []byte
+			options   []byte
+		)
+		if err := h.db.QueryRowContext(ctx, `
+			SELECT COALESCE(o.content, p.content),
+			       COALESCE(o.providers, p.providers, ARRAY[]::text[]),
+			       COALESCE(o.media, p.media, ARRAY[]::text[]),
+			       p.variants,
+			       p.options
+			  FROM public.post_occurrences o
+			  JOIN public.posts p ON p.id = o.post_id
+			 WHERE o.id = $1
+			   AND o.publish_job_id = $2
+		`, c.id, jobID).Scan(&content, pq.Array(&providers), pq.Array(&media), &variants, &options); err != nil {
+			fail("load_failed")
+			continue
+		}
+		caption := strings.TrimSpace(content.String)
+		if reason := scheduledPublishProblem(caption, providers, media); reason != "" {
+			fail(reason)
+			continue
+		}
+
+		reqSnapshot := map[string]interface{}{
+			"source":       "recurring_post",
+			"postId":       c.postID,
+			"occurrenceId": c.id,
+			"occurrenceAt": c.occurrenceAt.UTC().Format(time.RFC3339),
+			"userId":       c.userID,
+			"providers":    providers,
+			"media":        media,
+			"publicOrigin": origin,
+		}
+		if v := postVariantsFromJSON(variants); v != nil {
+			reqSnapshot["variants"] = v
+		}
+		if o := postOptionsFromJSON(options); o != nil {
+			reqSnapshot["options"] = o
+		}
+		reqJSON, _ := json.Marshal(reqSnapshot)
+		now := time.Now()
+		if _, err := h.db.ExecContext(ctx, `
+			INSERT INTO public.publish_jobs
+			  (id, user_id, status, providers, caption, request_json, created_at, updated_at)
+			VALUES
+			  ($1, $2, 'queued', $3, $4, $5::jsonb, $6, $6)
+		`, jobID, c.userID, pq.Array(providers), caption, string(reqJSON), now); err != nil {
+			// Undo the claim so the next sweep retries (nothing published yet).
+			_, _ = h.db.ExecContext(ctx, `
+				UPDATE public.post_occurrences
+				   SET status = 'pending', publish_job_id = NULL, error = $3, attempted_at = NULL, updated_at = NOW()
+				 WHERE id = $1 AND publish_job_id = $2
+			`, c.id, jobID, truncate(err.Error(), 300))
+			log.Printf("[RecurringPosts] enqueue_failed occurrenceId=%s postId=%s jobId=%s err=%v", c.id, c.postID, jobID, err)
+			continue
+		}
+
+		enqueued++
+		log.Printf("[RecurringPosts] enqueued occurrenceId=%s postId=%s userId=%s jobId=%s providers=%v media=%d",
+			c.id, c.postID, c.userID, jobID, providers, len(media))
+		h.emitEvent(c.userID, realtimeEvent{
+			Type:   "post.updated",
+			PostID: c.postID,
+			JobID:  jobID,
+			Status: "queued",
+			At:     time.Now().UTC().Format(time.RFC3339),
+		})
+		startJob(jobID, c.userID, caption, providers, media)
+	}
+	return enqueued, nil
+}
+
+// setOccurrenceJobStatus mirrors publish job state onto the recurring-post occurrences spawned for those jobs.
+func (h *Handler) setOccurrenceJobStatus(ctx context.Context, jobIDs []string, status, errText string) {
+	if h == nil || h.db == nil || len(jobIDs) == 0 {
+		return
+	}
+	_, _ = h.db.ExecContext(ctx, `
+		UPDATE public.post_occurrences
+		   SET status = $2,
+		       error = NULLIF($3, ''),
+		       updated_at = NOW()
+		 WHERE publish_job_id = ANY($1)
+	`, pq.Array(jobIDs), status, errText)
+}
+
+// finishOccurrenceJob records a finished publish job on its occurrence and, once the last occurrence of a
+// finished series is published, marks the post itself published. It returns the post id ("" if the job was not
+// spawned by a recurring post).
+func (h *Handler) finishOccurrenceJob(jobID, status, errText string) string {
+	if h == nil || h.db == nil {
+		return ""
+	}
+	var postID string
+	err := h.db.QueryRow(`
+		WITH o AS (
+			UPDATE public.post_occurrences
+			   SET status = $2,
+			       error = CASE WHEN $2 = 'failed' THEN NULLIF($3, '') ELSE NULL END,
+			       published_at = CASE WHEN $2 = 'completed' THEN NOW() ELSE published_at END,
+			       updated_at = NOW()
+			 WHERE publish_job_id = $1
+			RETURNING post_id
+		)
+		UPDATE public.posts p
+		   SET status = CASE WHEN $2 = 'completed' AND p.scheduled_for IS NULL AND NOT EXISTS (
+		                    SELECT 1 FROM public.post_occurrences x
+		                     WHERE x.post_id = p.id
+		                       AND x.status IN ('pending', 'queued', 'running')
+		                       AND x.publish_job_id IS DISTINCT FROM $1
+		                ) THEN 'published' ELSE p.status END,
+		       published_at = CASE WHEN $2 = 'completed' THEN NOW() ELSE p.published_at END,
+		       updated_at = NOW()
+		  FROM o
+		 WHERE p.id = o.post_id
+		RETURNING p.id
+	`, jobID, status, errText).Scan(&postID)
+	if err != nil {
+		return ""
+	}
+	return postID
+}
+
+type postOccurrence struct {
+	ID           string     `json:"id,omitempty"`
+	OccurrenceAt time.Time  `json:"occurrenceAt"`
+	ScheduledFor *time.Time `json:"scheduledFor,omitempty"`
+	Status       string     `json:"status"`
+	Content      *string    `json:"content,omitempty"`
+	Providers    []string   `json:"providers,omitempty"`
+	Media        []string   `json:"media,omitempty"`
+	PublishJobID *string    `json:"publishJobId,omitempty"`
+	Error        *string    `json:"error,omitempty"`
+	AttemptedAt  *time.Time `json:"attemptedAt,omitempty"`
+	PublishedAt  *time.Time `json:"publishedAt,omitempty"`
+	// Edited is true when the occurrence overrides the post's time, content, providers or media.
+	Edited bool `json:"edited"`
+}
+
+const postOccurrenceColumns = `id, occurrence_at, scheduled_for, status, content, providers, media,
+	       publish_job_id, error, attempted_at, published_at`
+
+func scanPostOccurrence(sc interface{ Scan(...any) error }) (postOccurrence, error) {
+	var (
+		o                                    postOccurrence
+		scheduledFor, attemptedAt, published sql.NullTime
+		content, jobID, errText              sql.NullString
+		providers, media                     []string
+	)
+	if err := sc.Scan(&o.ID, &o.OccurrenceAt, &scheduledFor, &o.Status, &content, pq.Array(&providers), pq.Array(&media),
+		&jobID, &errText, &attemptedAt, &published); err != nil {
+		return o, err
+	}
+	o.OccurrenceAt = o.OccurrenceAt.UTC()
+	o.ScheduledFor = nullTimePtr(scheduledFor)
+	o.AttemptedAt = nullTimePtr(attemptedAt)
+	o.PublishedAt = nullTimePtr(published)
+	if content.Valid {
+		o.Content = &content.String
+	}
+	if jobID.Valid {
+		o.PublishJobID = &jobID.String
+	}
+	if errText.Valid {
+		o.Error = &errText.String
+	}
+	o.Providers = providers
+	o.Media = media
+	o.Edited = o.ScheduledFor != nil || o.Content != nil || providers != nil || media != nil
+	return o, nil
+}
+
+// loadRecurringPost returns the post's rule and next scheduled slot; sql.ErrNoRows if the post doesn't exist.
+func (h *Handler) loadRecurringPost(ctx context.Context, postID, userID string) (*models.PostRecurrence, sql.NullTime, error) {
+	var (
+		raw  []byte
+		next sql.NullTime
+	)
+	if err := h.db.QueryRowContext(ctx, `
+		SELECT recurrence, scheduled_for FROM public.posts WHERE id = $1 AND user_id = $2
+	`, postID, userID).Scan(&raw, &next); err != nil {
+		return nil, next, err
+	}
+	if len(raw) == 0 {
+		return nil, next, nil
+	}
+	var rule models.PostRecurrence
+	if err := json.Unmarshal(raw, &rule); err != nil || rule.Start == nil {
+		return nil, next, nil
+	}
+	return &rule, next, nil
+}
+
+// ListPostOccurrencesForUser returns a recurring post's occurrence history plus its upcoming occurrences
+// (with any skips/edits already applied to them).
+//
+// URL: GET /api/posts/{postId}/occurrences/user/{userId}?limit=10 (upcoming count, max 100)
+func (h *Handler) ListPostOccurrencesForUser(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	userID := strings.TrimSpace(pathVar(r, "userId"))
+	postID := strings.TrimSpace(pathVar(r, "postId"))
+	if userID == "" || postID == "" {
+		writeError(w, http.StatusBadRequest, "userId and postId are required")
+		return
+	}
+	limit := parseLimit(r, 10, 1, 100)
+	if limit < 0 {
+		writeError(w, http.StatusBadRequest, "invalid limit")
+		return
+	}
+
+	rule, next, err := h.loadRecurringPost(r.Context(), postID, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	rows, err := h.db.QueryContext(r.Context(), `
+		SELECT `+postOccurrenceColumns+`
+		  FROM public.post_occurrences
+		 WHERE post_id = $1 AND user_id = $2
+		 ORDER BY occurrence_at DESC
+		 LIMIT 500
+	`, postID, userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rows.Close()
+	history := make([]postOccurrence, 0)
+	ahead := map[int64]postOccurrence{} // skips/edits recorded for slots not reached yet
+	for rows.Next() {
+		o, err := scanPostOccurrence(rows)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if next.Valid && !o.OccurrenceAt.Before(next.Time) && (o.Status == "pending" || o.Status == "skipped") {
+			ahead[o.OccurrenceAt.Unix()] = o
+			continue
+		}
+		history = append(history, o)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	upcoming := make([]postOccurrence, 0, limit)
+	if rule != nil && next.Valid {
+		slot, ok := next.Time.UTC(), true
+		for ok && len(upcoming) < limit {
+			o, found := ahead[slot.Unix()]
+			if !found {
+				o = postOccurrence{OccurrenceAt: slot, Status: "pending"}
+			}
+			upcoming = append(upcoming, o)
+			slot, ok = recurrenceNext(*rule, slot)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"postId":     postID,
+		"recurrence": rule,
+		"upcoming":   upcoming,
+		"history":    history,
+	})
+}
+
+type updatePostOccurrenceRequest struct {
+	OccurrenceAt *time.Time `json:"occurrenceAt"`
+	// Skip true skips the occurrence, false restores a skipped one; omitted keeps the current state.
+	Skip         *bool      `json:"skip,omitempty"`
+	ScheduledFor *time.Time `json:"scheduledFor,omitempty"`
+	Content      *string    `json:"content,omitempty"`
+	Providers    []string   `json:"providers,omitempty"`
+	Media        []string   `json:"media,omitempty"`
+}
+
+// UpdatePostOccurrenceForUser skips or edits a single upcoming occurrence of a recurring post without
+// changing the rest of the series.
+//
+// URL: PUT /api/posts/{postId}/occurrences/user/{userId}
+// Body: {"occurrenceAt": "...", "skip": true} or {"occurrenceAt": "...", "content": "...", "scheduledFor": "..."}
+func (h *Handler) UpdatePostOccurrenceForUser(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPut) {
+		return
+	}
+	userID := strings.TrimSpace(pathVar(r, "userId"))
+	postID := strings.TrimSpace(pathVar(r, "postId"))
+	if userID == "" || postID == "" {
+		writeError(w, http.StatusBadRequest, "userId and postId are required")
+		return
+	}
+	var req updatePostOccurrenceRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+	if req.OccurrenceAt == nil {
+		writeError(w, http.StatusBadRequest, "occurrenceAt is required")
+		return
+	}
+	at := req.OccurrenceAt.UTC()
+
+	rule, next, err := h.loadRecurringPost(r.Context(), postID, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if rule == nil {
+		writeError(w, http.StatusBadRequest, "not_recurring")
+		return
+	}
+	if !isRecurrenceOccurrence(*rule, at) {
+		writeError(w, http.StatusBadRequest, "not_an_occurrence")
+		return
+	}
+
+	var providersArg, mediaArg interface{}
+	if req.Providers != nil {
+		providersArg = pq.Array(normalizePostProviders(req.Providers))
+	}
+	if req.Media != nil {
+		mediaArg = pq.Array(normalizePostMedia(req.Media))
+	}
+
+	// Slots the series hasn't reached yet get a row on first edit; earlier slots were already materialized,
+	// so only their still-pending (or skipped) rows may change.
+	var row *sql.Row
+	if next.Valid && !at.Before(next.Time.UTC()) {
+		row = h.db.QueryRowContext(r.Context(), `
+			INSERT INTO public.post_occurrences
+			  (id, post_id, user_id, occurrence_at, status, scheduled_for, content, providers, media, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, CASE WHEN $5::boolean THEN 'skipped' ELSE 'pending' END, $6, $7, $8::text[], $9::text[], NOW(), NOW())
+			ON CONFLICT (post_id, occurrence_at) DO UPDATE
+			   SET status = CASE WHEN $5::boolean IS NULL THEN public.post_occurrences.status
+			                     WHEN $5::boolean THEN 'skipped' ELSE 'pending' END,
+			       scheduled_for = COALESCE($6, public.post_occurrences.scheduled_for),
+			       content = COALESCE($7, public.post_occurrences.content),
+			       providers = COALESCE($8::text[], public.post_occurrences.providers),
+			       media = COALESCE($9::text[], public.post_occurrences.media),
+			       updated_at = NOW()
+			 WHERE public.post_occurrences.status IN ('pending', 'skipped')
+			RETURNING `+postOccurrenceColumns,
+			"occ_"+randHex(12), postID, userID, at, req.Skip, req.ScheduledFor, req.Content, providersArg, mediaArg)
+	} else {
+		row = h.db.QueryRowContext(r.Context(), `
+			UPDATE public.post_occurrences
+			   SET status = CASE WHEN $3::boolean IS NULL THEN status WHEN $3::boolean THEN 'skipped' ELSE 'pending' END,
+			       scheduled_for = COALESCE($4, scheduled_for),
+			       content = COALESCE($5, content),
+			       providers = COALESCE($6::text[], providers),
+			       media = COALESCE($7::text[], media),
+			       updated_at = NOW()
+			 WHERE post_id = $1
+			   AND occurrence_at = $2
+			   AND status IN ('pending', 'skipped')
+			RETURNING `+postOccurrenceColumns,
+			postID, at, req.Skip, req.ScheduledFor, req.Content, providersArg, mediaArg)
+	}
+	o, err := scanPostOccurrence(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			// Already dispatched/published (or a past slot that was never recorded).
+			writeError(w, http.StatusConflict, "occurrence_already_processed")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("[RecurringPosts] occurrence_updated postId=%s userId=%s occurrenceAt=%s status=%s edited=%v",
+		postID, userID, at.Format(time.RFC3339), o.Status, o.Edited)
+	h.emitEvent(userID, realtimeEvent{Type: "post.updated", PostID: postID, Status: "occurrence_updated"})
+	writeJSON(w, http.StatusOK, o)
+}
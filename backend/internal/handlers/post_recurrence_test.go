@@ -0,0 +1,414 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PortNumber53/simple-social-thing/backend/internal/models"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+func mustRecurrence(t *testing.T, rule models.PostRecurrence, start time.Time) models.PostRecurrence {
+	t.Helper()
+	if err := normalizeRecurrence(&rule, start); err != nil {
+		t.Fatalf("normalizeRecurrence: %v", err)
+	}
+	return rule
+}
+
+func collectOccurrences(rule models.PostRecurrence, n int) []time.Time {
+	out := []time.Time{}
+	cur := rule.Start.Add(-time.Nanosecond)
+	for len(out) < n {
+		next, ok := recurrenceNext(rule, cur)
+		if !ok {
+			break
+		}
+		out = append(out, next)
+		cur = next
+	}
+	return out
+}
+
+func TestRecurrenceNext_DailyIntervalAndCount(t *testing.T) {
+	start := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	rule := mustRecurrence(t, models.PostRecurrence{Freq: "daily", Interval: 2, Count: 3}, start)
+	got := collectOccurrences(rule, 10)
+	if len(got) != 3 || !got[0].Equal(start) || !got[2].Equal(start.AddDate(0, 0, 4)) {
+		t.Fatalf("unexpected occurrences %v", got)
+	}
+}
+
+func TestRecurrenceNext_WeeklyByWeekdayUntil(t *testing.T) {
+	// Thursday 2026-01-01; MO/WE/FR starting that week.
+	start := time.Date(2026, 1, 1, 15, 30, 0, 0, time.UTC)
+	until := time.Date(2026, 1, 12, 0, 0, 0, 0, time.UTC)
+	rule := mustRecurrence(t, models.PostRecurrence{Freq: "weekly", ByWeekday: []string{"fr", "MO", "wednesday"}, Until: &until}, start)
+	got := collectOccurrences(rule, 10)
+	want := []string{"2026-01-02", "2026-01-05", "2026-01-07", "2026-01-09"}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected occurrences %v", got)
+	}
+	for i, w := range want {
+		if got[i].Format("2006-01-02") != w || got[i].Hour() != 15 || got[i].Minute() != 30 {
+			t.Fatalf("occurrence %d = %v want %s 15:30", i, got[i], w)
+		}
+	}
+}
+
+func TestRecurrenceNext_MonthlySkipsShortMonths(t *testing.T) {
+	start := time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+	rule := mustRecurrence(t, models.PostRecurrence{Freq: "monthly"}, start)
+	got := collectOccurrences(rule, 3)
+	want := []string{"2026-01-31", "2026-03-31", "2026-05-31"}
+	for i, w := range want {
+		if got[i].Format("2006-01-02") != w {
+			t.Fatalf("occurrence %d = %v want %s", i, got[i], w)
+		}
+	}
+}
+
+func TestRecurrenceNext_KeepsLocalTimeAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("tzdata unavailable: %v", err)
+	}
+	// 09:00 EST is 14:00 UTC; after the March 8 switch 09:00 EDT is 13:00 UTC.
+	start := time.Date(2026, 3, 6, 9, 0, 0, 0, loc)
+	rule := mustRecurrence(t, models.PostRecurrence{Freq: "daily", Timezone: "America/New_York"}, start)
+	got := collectOccurrences(rule, 4)
+	if got[0].UTC().Hour() != 14 || got[3].UTC().Hour() != 13 {
+		t.Fatalf("unexpected UTC hours %v", got)
+	}
+	for _, o := range got {
+		if o.In(loc).Hour() != 9 {
+			t.Fatalf("expected 09:00 local, got %v", o.In(loc))
+		}
+	}
+	if !isRecurrenceOccurrence(rule, got[3]) || isRecurrenceOccurrence(rule, got[3].Add(time.Hour)) {
+		t.Fatalf("isRecurrenceOccurrence mismatch")
+	}
+}
+
+func TestNormalizeRecurrence_Validation(t *testing.T) {
+	start := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	before := start.Add(-time.Hour)
+	for name, rule := range map[string]models.PostRecurrence{
+		"freq":       {Freq: "hourly"},
+		"timezone":   {Freq: "daily", Timezone: "Mars/Olympus"},
+		"weekday":    {Freq: "weekly", ByWeekday: []string{"XX"}},
+		"weekdayDay": {Freq: "daily", ByWeekday: []string{"MO"}},
+		"monthDay":   {Freq: "monthly", ByMonthDay: []int{32}},
+		"until":      {Freq: "daily", Until: &before},
+		"count":      {Freq: "daily", Count: -1},
+	} {
+		r := rule
+		if err := normalizeRecurrence(&r, start); err == nil {
+			t.Fatalf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestProcessDueRecurringPostsOnce_ExpandsAndEnqueues(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	slot := time.Now().UTC().Add(-2 * time.Minute).Truncate(time.Second)
+	rule := mustRecurrence(t, models.PostRecurrence{Freq: "daily"}, slot.AddDate(0, 0, -3))
+	raw, _ := json.Marshal(rule)
+	next, _ := recurrenceNext(rule, time.Now())
+
+	mock.ExpectQuery(`FROM public\.posts\s+WHERE status = 'scheduled'\s+AND recurrence IS NOT NULL`).
+		WithArgs(25).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "scheduled_for", "recurrence"}).AddRow("p1", "u1", slot, raw))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE public\.posts\s+SET scheduled_for = \$4`).
+		WithArgs("p1", "u1", slot, next).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO public\.post_occurrences`).
+		WithArgs(sqlmock.AnyArg(), "p1", "u1", slot, "pending").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectQuery(`FROM public\.post_occurrences o\s+JOIN public\.posts p`).
+		WithArgs(25).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "post_id", "user_id", "occurrence_at"}).AddRow("occ1", "p1", "u1", slot))
+	mock.ExpectExec(`UPDATE public\.post_occurrences\s+SET status = 'queued'`).
+		WithArgs("occ1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT COALESCE\(o\.content, p\.content\)`).
+		WithArgs("occ1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"content", "providers", "media", "variants", "options"}).
+			AddRow("edited caption", pq.StringArray{"facebook"}, pq.StringArray{}, nil, nil))
+	mock.ExpectExec(`INSERT INTO public\.publish_jobs`).
+		WithArgs(sqlmock.AnyArg(), "u1", sqlmock.AnyArg(), "edited caption", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	started := []string{}
+	n, err := h.processDueRecurringPostsOnce(context.Background(), "https://app.test", 25, func(jobID, userID, caption string, providers []string, relMedia []string) {
+		started = append(started, caption)
+	})
+	if err != nil || n != 1 || len(started) != 1 {
+		t.Fatalf("expected 1 enqueued got n=%d err=%v started=%v", n, err, started)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestProcessDueRecurringPostsOnce_StaleSlotRecordedAsMissed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	slot := time.Now().UTC().Add(-3 * time.Hour).Truncate(time.Second)
+	rule := mustRecurrence(t, models.PostRecurrence{Freq: "daily", Count: 1}, slot)
+	raw, _ := json.Marshal(rule)
+
+	mock.ExpectQuery(`AND recurrence IS NOT NULL`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "scheduled_for", "recurrence"}).AddRow("p1", "u1", slot, raw))
+	mock.ExpectBegin()
+	// Count=1: the series is over, so scheduled_for is cleared.
+	mock.ExpectExec(`UPDATE public\.posts\s+SET scheduled_for = \$4`).
+		WithArgs("p1", "u1", slot, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO public\.post_occurrences`).
+		WithArgs(sqlmock.AnyArg(), "p1", "u1", slot, "missed").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`FROM public\.post_occurrences o`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "post_id", "user_id", "occurrence_at"}))
+
+	if n, err := h.processDueRecurringPostsOnce(context.Background(), "", 25, nil); err != nil || n != 0 {
+		t.Fatalf("expected nothing enqueued got n=%d err=%v", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+var occurrenceCols = []string{"id", "occurrence_at", "scheduled_for", "status", "content", "providers", "media",
+	"publish_job_id", "error", "attempted_at", "published_at"}
+
+func TestUpdatePostOccurrenceForUser_SkipAndValidation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	start := time.Date(2030, 1, 7, 9, 0, 0, 0, time.UTC) // Monday
+	rule := mustRecurrence(t, models.PostRecurrence{Freq: "weekly"}, start)
+	raw, _ := json.Marshal(rule)
+	call := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/api/posts/p1/occurrences/user/u1", bytes.NewBufferString(body))
+		req = mux.SetURLVars(req, map[string]string{"userId": "u1", "postId": "p1"})
+		h.UpdatePostOccurrenceForUser(rr, req)
+		return rr
+	}
+	expectPost := func() {
+		mock.ExpectQuery(`SELECT recurrence, scheduled_for FROM public\.posts`).
+			WithArgs("p1", "u1").
+			WillReturnRows(sqlmock.NewRows([]string{"recurrence", "scheduled_for"}).AddRow(raw, start))
+	}
+
+	if rr := call(`{"skip":true}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without occurrenceAt got %d", rr.Code)
+	}
+
+	expectPost()
+	if rr := call(`{"occurrenceAt":"2030-01-15T09:00:00Z","skip":true}`); rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "not_an_occurrence") {
+		t.Fatalf("expected not_an_occurrence got %d %s", rr.Code, rr.Body.String())
+	}
+
+	second := start.AddDate(0, 0, 7)
+	expectPost()
+	mock.ExpectQuery(`INSERT INTO public\.post_occurrences`).
+		WithArgs(sqlmock.AnyArg(), "p1", "u1", second, true, nil, nil, nil, nil).
+		WillReturnRows(sqlmock.NewRows(occurrenceCols).
+			AddRow("occ1", second, nil, "skipped", nil, nil, nil, nil, nil, nil, nil))
+	rr := call(`{"occurrenceAt":"2030-01-14T09:00:00Z","skip":true}`)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"status":"skipped"`) {
+		t.Fatalf("expected skipped occurrence got %d %s", rr.Code, rr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestListPostOccurrencesForUser_MergesSkipsIntoUpcoming(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	start := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+	rule := mustRecurrence(t, models.PostRecurrence{Freq: "daily", Count: 5}, start)
+	raw, _ := json.Marshal(rule)
+	next := start.AddDate(0, 0, 2)
+
+	mock.ExpectQuery(`SELECT recurrence, scheduled_for FROM public\.posts`).
+		WithArgs("p1", "u1").
+		WillReturnRows(sqlmock.NewRows([]string{"recurrence", "scheduled_for"}).AddRow(raw, next))
+	mock.ExpectQuery(`FROM public\.post_occurrences\s+WHERE post_id = \$1 AND user_id = \$2`).
+		WithArgs("p1", "u1").
+		WillReturnRows(sqlmock.NewRows(occurrenceCols).
+			AddRow("occ3", start.AddDate(0, 0, 3), nil, "skipped", nil, nil, nil, nil, nil, nil, nil).
+			AddRow("occ1", start.AddDate(0, 0, 1), nil, "completed", nil, nil, nil, "pub_1", nil, start, start).
+			AddRow("occ0", start, nil, "failed", nil, nil, nil, "pub_0", "one_or_more_providers_failed", start, nil))
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/posts/p1/occurrences/user/u1", nil)
+	req = mux.SetURLVars(req, map[string]string{"userId": "u1", "postId": "p1"})
+	h.ListPostOccurrencesForUser(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d %s", rr.Code, rr.Body.String())
+	}
+	var out struct {
+		Upcoming []postOccurrence `json:"upcoming"`
+		History  []postOccurrence `json:"history"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &out)
+	// Count=5 leaves slots 2, 3 and 4 upcoming; slot 3 is skipped.
+	if len(out.Upcoming) != 3 || out.Upcoming[1].Status != "skipped" || out.Upcoming[0].Status != "pending" {
+		t.Fatalf("unexpected upcoming %#v", out.Upcoming)
+	}
+	if len(out.History) != 2 || out.History[0].ID != "occ1" {
+		t.Fatalf("unexpected history %#v", out.History)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestCreatePostForUser_RecurringAnchorsFirstOccurrence(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	// Sunday; weekly on Mondays starts the next day.
+	start := time.Date(2030, 1, 6, 9, 0, 0, 0, time.UTC)
+	first := start.AddDate(0, 0, 1)
+	now := time.Now().UTC()
+	// No scheduling preferences: the rule repeats in UTC.
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings`).
+		WithArgs("u1", schedulingSettingKey).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}))
+	mock.ExpectQuery(`INSERT INTO public\.posts`).
+		WithArgs(sqlmock.AnyArg(), "u1", sqlmock.AnyArg(), "scheduled", sqlmock.AnyArg(), sqlmock.AnyArg(), &first, (*time.Time)(nil), sqlmock.AnyArg(), nil, nil).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "teamId", "userId", "content", "status", "providers", "media",
+			"scheduledFor", "publishedAt",
+			"lastPublishJobId", "lastPublishStatus", "lastPublishError", "lastPublishAttemptAt",
+			"createdAt", "updatedAt", "recurrence", "variants", "options",
+		}).
+			AddRow("p1", "", "u1", "hi", "scheduled", pq.StringArray{"facebook"}, pq.StringArray{}, first, sql.NullTime{}, sql.NullString{}, sql.NullString{}, sql.NullString{}, sql.NullTime{}, now, now,
+				[]byte(`{"freq":"weekly","interval":1,"byWeekday":["MO"],"timezone":"UTC","start":"2030-01-06T09:00:00Z"}`), nil, nil))
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/posts/user/u1", bytes.NewBufferString(
+		`{"content":"hi","status":"scheduled","providers":["facebook"],"scheduledFor":"2030-01-06T09:00:00Z","recurrence":{"freq":"weekly","byWeekday":["MO"]}}`))
+	req = mux.SetURLVars(req, map[string]string{"userId": "u1"})
+	h.CreatePostForUser(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"byWeekday":["MO"]`) {
+		t.Fatalf("expected 200 with recurrence got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/api/posts/user/u1", bytes.NewBufferString(
+		`{"content":"hi","status":"draft","recurrence":{"freq":"daily","timezone":"UTC"}}`))
+	req = mux.SetURLVars(req, map[string]string{"userId": "u1"})
+	h.CreatePostForUser(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for recurring draft got %d", rr.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestRecurrenceNext_SkipsAheadWithoutChangingResults(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	start := time.Date(2001, 3, 31, 8, 15, 0, 0, ny)
+	rules := []models.PostRecurrence{
+		{Freq: "daily", Interval: 3, Timezone: "America/New_York"},
+		{Freq: "weekly", Interval: 2, ByWeekday: []string{"SU", "TH"}, Timezone: "America/New_York"},
+		{Freq: "monthly", ByMonthDay: []int{31, 1}, Timezone: "America/New_York"},
+	}
+	for _, r := range rules {
+		rule := mustRecurrence(t, r, start)
+		// A count the series never reaches makes recurrenceNext walk from the start, as it used to.
+		walked := rule
+		walked.Count = 1 << 30
+		for _, after := range []time.Time{
+			start.Add(-time.Hour), start, start.AddDate(0, 0, 1),
+			time.Date(2026, 11, 1, 6, 0, 0, 0, time.UTC), time.Date(2040, 2, 29, 23, 59, 0, 0, time.UTC),
+		} {
+			got, ok := recurrenceNext(rule, after)
+			want, wantOK := recurrenceNext(walked, after)
+			if ok != wantOK || !got.Equal(want) {
+				t.Fatalf("%s after %s: got %s/%v want %s/%v", rule.Freq, after, got, ok, want, wantOK)
+			}
+		}
+	}
+}
+
+func TestUpdatePostForUser_RecurrenceRequiresScheduled(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	// Scheduling preferences (recurrence timezone default) are not set.
+	mock.ExpectQuery(`FROM public\.user_settings`).WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`SELECT status FROM public\.posts`).
+		WithArgs("p1", "u1").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("draft"))
+	body := `{"scheduledFor":"2030-01-01T09:00:00Z","recurrence":{"freq":"daily"}}`
+	req := httptest.NewRequest(http.MethodPut, "/api/posts/p1/user/u1", strings.NewReader(body))
+	req = mux.SetURLVars(req, map[string]string{"userId": "u1", "postId": "p1"})
+	rr := httptest.NewRecorder()
+	h.UpdatePostForUser(rr, req)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "status=scheduled") {
+		t.Fatalf("expected 400 got %d body=%s", rr.Code, rr.Body.String())
+	}
+
+	// An explicit non-scheduled status is rejected without a lookup.
+	mock.ExpectQuery(`FROM public\.user_settings`).WillReturnError(sql.ErrNoRows)
+	body = `{"status":"published","scheduledFor":"2030-01-01T09:00:00Z","recurrence":{"freq":"daily"}}`
+	req = httptest.NewRequest(http.MethodPut, "/api/posts/p1/user/u1", strings.NewReader(body))
+	req = mux.SetURLVars(req, map[string]string{"userId": "u1", "postId": "p1"})
+	rr = httptest.NewRecorder()
+	h.UpdatePostForUser(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 got %d body=%s", rr.Code, rr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
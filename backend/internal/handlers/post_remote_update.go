@@ -0,0 +1,403 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// errRemoteEditUnsupported marks networks that don't allow editing a published post (X, TikTok, Threads).
+var errRemoteEditUnsupported = errors.New("edit_not_supported")
+
+// remoteUpdateResult is what pushing an edit did on one network.
+type remoteUpdateResult struct {
+	OK      bool              `json:"ok"`
+	Updated []string          `json:"updated,omitempty"`
+	Failed  map[string]string `json:"failed,omitempty"` // external id -> reason
+	Error   string            `json:"error,omitempty"`
+}
+
+// remoteEdit is the caption/title one provider should show after the edit.
+type remoteEdit struct {
+	Caption   string
+	YouTube   youtubeVideoOptions
+	Pinterest pinterestPinOptions
+}
+
+// graphPostForm POSTs form fields to a Graph API object (edits a Page post/video or an Instagram media).
+func graphPostForm(ctx context.Context, client *http.Client, objectID string, form url.Values) error {
+	endpoint := fmt.Sprintf("https://graph.facebook.com/v24.0/%s", url.PathEscape(objectID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	_ = res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		msg := extractFacebookErrorMessage(b, string(b))
+		if strings.TrimSpace(msg) == "" {
+			msg = truncate(string(b), 300)
+		}
+		return fmt.Errorf("graph_update_non_2xx status=%d msg=%s", res.StatusCode, truncate(msg, 220))
+	}
+	return nil
+}
+
+// updateFacebookPosts edits the message of every Page post (or the description of every Page video) the job
+// created, with that page's token.
+func (h *Handler) updateFacebookPosts(ctx context.Context, userID, caption string, details map[string]interface{}) remoteUpdateResult {
+	out := remoteUpdateResult{}
+	var raw []byte
+	if err := h.db.QueryRowContext(ctx, `SELECT value FROM public.user_settings WHERE user_id=$1 AND key='facebook_oauth' AND value IS NOT NULL`, userID).Scan(&raw); err != nil {
+		out.Error = "not_connected"
+		return out
+	}
+	var tok fbOAuthPayload
+	_ = json.Unmarshal(raw, &tok)
+	tokens := map[string]string{}
+	for _, p := range tok.Pages {
+		tokens[p.ID] = p.AccessToken
+	}
+	if tok.PageID != "" && tokens[tok.PageID] == "" {
+		tokens[tok.PageID] = tok.AccessToken
+	}
+
+	var pages []fbPageResult
+	if b, err := json.Marshal(details["pages"]); err == nil {
+		_ = json.Unmarshal(b, &pages)
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	for _, p := range pages {
+		id, field := p.PostID, "message"
+		if id == "" {
+			id, field = p.VideoID, "description"
+		}
+		if id == "" {
+			continue
+		}
+		pageToken := tokens[p.PageID]
+		if pageToken == "" {
+			out.fail(id, "missing_page_token")
+			continue
+		}
+		if err := graphPostForm(ctx, client, id, url.Values{field: {caption}, "access_token": {pageToken}}); err != nil {
+			out.fail(id, err.Error())
+			continue
+		}
+		out.Updated = append(out.Updated, id)
+	}
+	// Posts handed to Meta's scheduler are recorded as "{pageId}_{postId}".
+	if ids, ok := details["postIds"].([]interface{}); ok {
+		for _, v := range ids {
+			id, _ := v.(string)
+			if id == "" {
+				continue
+			}
+			pageID, _, _ := strings.Cut(id, "_")
+			pageToken := tokens[pageID]
+			if pageToken == "" {
+				out.fail(id, "missing_page_token")
+				continue
+			}
+			if err := graphPostForm(ctx, client, id, url.Values{"message": {caption}, "access_token": {pageToken}}); err != nil {
+				out.fail(id, err.Error())
+				continue
+			}
+			out.Updated = append(out.Updated, id)
+		}
+	}
+	return out.finish()
+}
+
+// updateInstagramCaption edits the caption of the published media. Meta only accepts this for some media;
+// a refusal is reported like any other failure.
+func (h *Handler) updateInstagramCaption(ctx context.Context, userID, caption string, ids []string) remoteUpdateResult {
+	out := remoteUpdateResult{}
+	tok, err := h.loadInstagramOAuth(ctx, userID)
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	for _, id := range ids {
+		if err := graphPostForm(ctx, client, id, url.Values{"caption": {caption}, "access_token": {tok.AccessToken}}); err != nil {
+			out.fail(id, err.Error())
+			continue
+		}
+		out.Updated = append(out.Updated, id)
+	}
+	return out.finish()
+}
+
+// updateYouTubeVideo rewrites the video's title and description. videos.update replaces the whole snippet,
+// so the current one is read first and its category and tags are kept unless the post sets them.
+func (h *Handler) updateYouTubeVideo(ctx context.Context, userID, caption string, opts youtubeVideoOptions, videoID string) remoteUpdateResult {
+	out := remoteUpdateResult{}
+	var raw []byte
+	if err := h.db.QueryRowContext(ctx, `SELECT value FROM public.user_settings WHERE user_id=$1 AND key='youtube_oauth' AND value IS NOT NULL`, userID).Scan(&raw); err != nil {
+		out.Error = "not_connected"
+		return out
+	}
+	var tok youtubeOAuth
+	if err := json.Unmarshal(raw, &tok); err != nil || strings.TrimSpace(tok.AccessToken) == "" {
+		out.Error = "not_connected"
+		return out
+	}
+	if !youtubeHasScope(tok.Scope, "youtube", "youtube.force-ssl") {
+		out.Error = "missing_scope"
+		return out
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://www.googleapis.com/youtube/v3/videos?part=snippet&id="+url.QueryEscape(videoID), nil)
+	req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+	req.Header.Set("Accept", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		out.fail(videoID, err.Error())
+		return out.finish()
+	}
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	_ = res.Body.Close()
+	var current struct {
+		Items []struct {
+			Snippet map[string]interface{} `json:"snippet"`
+		} `json:"items"`
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 || json.Unmarshal(b, &current) != nil || len(current.Items) == 0 {
+		out.fail(videoID, fmt.Sprintf("youtube_video_not_found status=%d", res.StatusCode))
+		return out.finish()
+	}
+	snippet := current.Items[0].Snippet
+	// Keep a Short's #Shorts tag if the old title or description carried it.
+	oldTitle, _ := snippet["title"].(string)
+	oldDesc, _ := snippet["description"].(string)
+	short := strings.Contains(strings.ToLower(oldTitle+" "+oldDesc), "#shorts")
+	_, meta := youtubeVideoMetadata(caption, opts, short, time.Now())
+	next := meta["snippet"].(map[string]interface{})
+	snippet["title"] = next["title"]
+	snippet["description"] = next["description"]
+	if opts.CategoryID != "" || snippet["categoryId"] == nil {
+		snippet["categoryId"] = next["categoryId"]
+	}
+	if len(opts.Tags) > 0 {
+		snippet["tags"] = opts.Tags
+	}
+	body, _ := json.Marshal(map[string]interface{}{"id": videoID, "snippet": snippet})
+	req, _ = http.NewRequestWithContext(ctx, http.MethodPut, "https://www.googleapis.com/youtube/v3/videos?part=snippet", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+	req.Header.Set("Content-Type", "application/json")
+	if err := youtubeDo(client, req); err != nil {
+		out.fail(videoID, err.Error())
+		return out.finish()
+	}
+	out.Updated = append(out.Updated, videoID)
+	return out.finish()
+}
+
+// updatePinterestPin rewrites the pin's title and description (and link/alt text when the post sets them).
+func (h *Handler) updatePinterestPin(ctx context.Context, userID, caption string, opts pinterestPinOptions, pinID string) remoteUpdateResult {
+	out := remoteUpdateResult{}
+	tok, err := h.loadPinterestOAuth(ctx, userID)
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	patch := map[string]interface{}{"title": pinterestPinTitle(caption, opts), "description": caption}
+	if opts.Link != "" {
+		patch["link"] = opts.Link
+	}
+	if opts.AltText != "" {
+		patch["alt_text"] = opts.AltText
+	}
+	body, _ := json.Marshal(patch)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPatch, strings.TrimRight(pinterestAPIBase(), "/")+"/v5/pins/"+url.PathEscape(pinID), bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	res, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		out.fail(pinID, err.Error())
+		return out.finish()
+	}
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	_ = res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		out.fail(pinID, fmt.Sprintf("pinterest_update_non_2xx status=%d body=%s", res.StatusCode, truncate(string(b), 300)))
+		return out.finish()
+	}
+	out.Updated = append(out.Updated, pinID)
+	return out.finish()
+}
+
+func (r *remoteUpdateResult) fail(id, reason string) {
+	if r.Failed == nil {
+		r.Failed = map[string]string{}
+	}
+	r.Failed[id] = truncate(reason, 220)
+}
+
+func (r remoteUpdateResult) finish() remoteUpdateResult {
+	r.OK = len(r.Failed) == 0 && r.Error == "" && len(r.Updated) > 0
+	if !r.OK && r.Error == "" {
+		if len(r.Failed) > 0 {
+			r.Error = "update_failed"
+		} else {
+			r.Error = "missing_published_id"
+		}
+	}
+	return r
+}
+
+// updateRemoteProvider pushes the edit to one provider's published objects.
+func (h *Handler) updateRemoteProvider(ctx context.Context, userID, provider string, edit remoteEdit, details map[string]interface{}) remoteUpdateResult {
+	ids := publishedObjectIDs(provider, details)
+	switch provider {
+	case "facebook":
+		return h.updateFacebookPosts(ctx, userID, edit.Caption, details)
+	case "instagram":
+		if len(ids) == 0 {
+			return remoteUpdateResult{}.finish()
+		}
+		return h.updateInstagramCaption(ctx, userID, edit.Caption, ids)
+	case "youtube":
+		if len(ids) == 0 {
+			return remoteUpdateResult{}.finish()
+		}
+		return h.updateYouTubeVideo(ctx, userID, edit.Caption, edit.YouTube, ids[0])
+	case "pinterest":
+		if len(ids) == 0 {
+			return remoteUpdateResult{}.finish()
+		}
+		return h.updatePinterestPin(ctx, userID, edit.Caption, edit.Pinterest, ids[0])
+	}
+	return remoteUpdateResult{Error: errRemoteEditUnsupported.Error()}
+}
+
+// merge folds another job's result for the same provider into r.
+func (r remoteUpdateResult) merge(next remoteUpdateResult) remoteUpdateResult {
+	r.Updated = append(r.Updated, next.Updated...)
+	for id, reason := range next.Failed {
+		r.fail(id, reason)
+	}
+	if r.Error == "" {
+		r.Error = next.Error
+	}
+	r.OK = r.OK && next.OK
+	return r
+}
+
+// UpdateRemotePostForUser pushes a published post's current caption and titles (after its variants and
+// options are applied) to the networks it went out on, using the remote ids recorded by its publish jobs.
+// Facebook, Instagram, YouTube and Pinterest are edited in place; networks that don't allow edits are listed
+// in notEditable. A recurring post's occurrences are all updated.
+//
+// URL: POST /api/posts/{postId}/update-remote/user/{userId}
+func (h *Handler) UpdateRemotePostForUser(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	userID := strings.TrimSpace(pathVar(r, "userId"))
+	postID := strings.TrimSpace(pathVar(r, "postId"))
+	if userID == "" || postID == "" {
+		writeError(w, http.StatusBadRequest, "userId and postId are required")
+		return
+	}
+
+	var (
+		content   sql.NullString
+		status    string
+		recurring bool
+		media     []string
+		variants  []byte
+		options   []byte
+	)
+	err := h.db.QueryRowContext(r.Context(), `
+		SELECT content, status, recurrence IS NOT NULL, COALESCE(media, ARRAY[]::text[]), variants, options
+		  FROM public.posts
+		 WHERE id = $1 AND user_id = $2
+	`, postID, userID).Scan(&content, &status, &recurring, pq.Array(&media), &variants, &options)
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if status != "published" && !recurring {
+		writeError(w, http.StatusConflict, "only published posts can be updated remotely")
+		return
+	}
+	jobs, err := h.loadPostPublishJobs(r.Context(), userID, postID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	providers, _ := publishedProviders(jobs)
+	if len(providers) == 0 {
+		writeError(w, http.StatusConflict, "post has nothing published to update")
+		return
+	}
+
+	postVariants := postVariantsFromJSON(variants)
+	postOptions := postOptionsFromJSON(options)
+	results := map[string]remoteUpdateResult{}
+	notEditable := []string{}
+	allOK := true
+	for _, provider := range providers {
+		in := publishInputFor(provider, content.String, postVariants, media, nil)
+		edit := remoteEdit{
+			Caption:   in.Caption,
+			YouTube:   youtubeOptionsFor(in.Title, postOptions),
+			Pinterest: pinterestOptionsFor(in, postOptions),
+		}
+		res := remoteUpdateResult{OK: true}
+		for _, job := range jobs {
+			jr, ok := job.Results[provider]
+			if !ok || (!jr.OK && jr.Posted == 0) {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+			res = res.merge(h.updateRemoteProvider(ctx, userID, provider, edit, jr.Details))
+			cancel()
+		}
+		results[provider] = res
+		if res.Error == errRemoteEditUnsupported.Error() {
+			notEditable = append(notEditable, provider)
+		} else if !res.OK {
+			allOK = false
+		}
+		log.Printf("[RemoteUpdate] provider userId=%s postId=%s provider=%s ok=%v updated=%d failed=%d err=%s",
+			userID, postID, provider, res.OK, len(res.Updated), len(res.Failed), res.Error)
+	}
+
+	jobIDs := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		jobIDs = append(jobIDs, job.ID)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"ok":          allOK,
+		"postId":      postID,
+		"jobIds":      jobIDs,
+		"results":     results,
+		"notEditable": notEditable,
+	})
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+)
+
+func updateRemoteRequest(h *Handler) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/posts/p1/update-remote/user/u1", nil)
+	req = mux.SetURLVars(req, map[string]string{"postId": "p1", "userId": "u1"})
+	rr := httptest.NewRecorder()
+	h.UpdateRemotePostForUser(rr, req)
+	return rr
+}
+
+func expectRemoteUpdatePost(mock sqlmock.Sqlmock, variants, options []byte, results string) {
+	mock.ExpectQuery(`SELECT content, status, recurrence IS NOT NULL, COALESCE\(media, ARRAY\[\]::text\[\]\), variants, options\s+FROM public\.posts`).
+		WithArgs("p1", "u1").
+		WillReturnRows(sqlmock.NewRows([]string{"content", "status", "recurring", "media", "variants", "options"}).
+			AddRow("New caption", "published", false, "{}", variants, options))
+	mock.ExpectQuery(`SELECT id, result_json\s+FROM public\.publish_jobs\s+WHERE user_id = \$1 AND request_json->>'postId' = \$2`).
+		WithArgs("u1", "p1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "result_json"}).AddRow("job1", []byte(results)))
+}
+
+func TestUpdateRemotePostForUser_EditsSupportedNetworks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	results := `{"results":{
+		"facebook":{"ok":true,"posted":1,"details":{"pages":[{"pageId":"pg1","posted":true,"postId":"pg1_1"}]}},
+		"pinterest":{"ok":true,"posted":1,"details":{"pinId":"pin1"}},
+		"x":{"ok":true,"posted":1,"details":{"tweetIds":["t1"]}}
+	}}`
+	variants := []byte(`{"facebook":{"caption":"Facebook caption"}}`)
+	options := []byte(`{"pinterest":{"title":"Pin title"}}`)
+	expectRemoteUpdatePost(mock, variants, options, results)
+
+	fbTok, _ := json.Marshal(fbOAuthPayload{Pages: []fbOAuthPageRow{{ID: "pg1", AccessToken: "pagetok"}}})
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings.*key='facebook_oauth'`).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(fbTok))
+	expectPinterestToken(mock)
+
+	var fbForm url.Values
+	var pinPatch map[string]interface{}
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		switch {
+		case r.URL.Host == "graph.facebook.com" && r.URL.Path == "/v24.0/pg1_1":
+			b, _ := io.ReadAll(r.Body)
+			fbForm, _ = url.ParseQuery(string(b))
+			return httpJSON(200, `{"success":true}`, nil), nil
+		case r.Method == http.MethodPatch && r.URL.Path == "/v5/pins/pin1":
+			_ = json.NewDecoder(r.Body).Decode(&pinPatch)
+			return httpJSON(200, `{"id":"pin1"}`, nil), nil
+		}
+		t.Fatalf("unexpected request %s %s", r.Method, r.URL)
+		return nil, nil
+	}}
+
+	rr := updateRemoteRequest(h)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d body=%s", rr.Code, rr.Body.String())
+	}
+	var out struct {
+		OK          bool                          `json:"ok"`
+		Results     map[string]remoteUpdateResult `json:"results"`
+		NotEditable []string                      `json:"notEditable"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &out)
+	if !out.OK || !out.Results["facebook"].OK || !out.Results["pinterest"].OK || strings.Join(out.NotEditable, ",") != "x" {
+		t.Fatalf("unexpected response %s", rr.Body.String())
+	}
+	if fbForm.Get("message") != "Facebook caption" || fbForm.Get("access_token") != "pagetok" {
+		t.Fatalf("unexpected facebook edit %v", fbForm)
+	}
+	if pinPatch["title"] != "Pin title" || pinPatch["description"] != "New caption" {
+		t.Fatalf("unexpected pin edit %v", pinPatch)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestUpdateRemotePostForUser_KeepsYouTubeSnippetFields(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	expectRemoteUpdatePost(mock, nil, []byte(`{"youtube":{"title":"Better title"}}`), `{"results":{"youtube":{"ok":true,"posted":1,"details":{"videoId":"v1"}}}}`)
+	ytTok, _ := json.Marshal(youtubeOAuth{AccessToken: "ytok", Scope: "https://www.googleapis.com/auth/youtube.force-ssl"})
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings.*key='youtube_oauth'`).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(ytTok))
+
+	var put struct {
+		ID      string                 `json:"id"`
+		Snippet map[string]interface{} `json:"snippet"`
+	}
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		if r.Method == http.MethodGet {
+			return httpJSON(200, `{"items":[{"snippet":{"title":"Old #Shorts","description":"old","categoryId":"10","tags":["music"]}}]}`, nil), nil
+		}
+		_ = json.NewDecoder(r.Body).Decode(&put)
+		return httpJSON(200, `{"id":"v1"}`, nil), nil
+	}}
+
+	rr := updateRemoteRequest(h)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"ok":true`) {
+		t.Fatalf("expected success got %d body=%s", rr.Code, rr.Body.String())
+	}
+	tags, _ := put.Snippet["tags"].([]interface{})
+	if put.ID != "v1" || put.Snippet["title"] != "Better title" || put.Snippet["description"] != "New caption\n\n#Shorts" ||
+		put.Snippet["categoryId"] != "10" || len(tags) != 1 {
+		t.Fatalf("unexpected videos.update body %+v", put)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestUpdateRemotePostForUser_RejectsUnpublishedPosts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	mock.ExpectQuery(`FROM public\.posts`).
+		WithArgs("p1", "u1").
+		WillReturnRows(sqlmock.NewRows([]string{"content", "status", "recurring", "media", "variants", "options"}).
+			AddRow("caption", "draft", false, "{}", nil, nil))
+	if rr := updateRemoteRequest(h); rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 got %d body=%s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestUpdateRemotePostForUser_PushesEditSavedThroughUpdatePost(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	// Edit the caption of the published post first.
+	mock.ExpectQuery(`FROM public\.post_native_schedules`).
+		WithArgs("p1", "u1", "facebook").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "account_id", "external_id", "scheduled_for"}))
+	mock.ExpectQuery(`UPDATE public\.posts\s+SET.*last_publish_job_id = CASE WHEN \$9 AND NOT \(status = 'published' AND COALESCE\(\$4, status\) = 'published'\)`).
+		WithArgs("p1", "u1", "Edited caption", nil, nil, nil, nil, nil, true, nil, nil, nil).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "teamId", "userId", "content", "status", "providers", "media",
+			"scheduledFor", "publishedAt",
+			"lastPublishJobId", "lastPublishStatus", "lastPublishError", "lastPublishAttemptAt",
+			"createdAt", "updatedAt", "recurrence", "variants", "options",
+		}).AddRow("p1", "", "u1", "Edited caption", "published", "{pinterest}", "{}", nil, time.Now(), "job1", "completed", nil, time.Now(), time.Now(), time.Now(), nil, nil, nil))
+	req := httptest.NewRequest(http.MethodPut, "/api/posts/p1/user/u1", strings.NewReader(`{"content":"Edited caption"}`))
+	req = mux.SetURLVars(req, map[string]string{"postId": "p1", "userId": "u1"})
+	rr := httptest.NewRecorder()
+	h.UpdatePostForUser(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("update post: expected 200 got %d body=%s", rr.Code, rr.Body.String())
+	}
+
+	// Then push it: the published job is found by postId, whatever the post's last_publish_job_id says.
+	mock.ExpectQuery(`SELECT content, status, recurrence IS NOT NULL`).
+		WithArgs("p1", "u1").
+		WillReturnRows(sqlmock.NewRows([]string{"content", "status", "recurring", "media", "variants", "options"}).
+			AddRow("Edited caption", "published", false, "{}", nil, nil))
+	mock.ExpectQuery(`FROM public\.publish_jobs`).
+		WithArgs("u1", "p1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "result_json"}).AddRow("job1", []byte(`{"results":{"pinterest":{"ok":true,"posted":1,"details":{"pinId":"pin1"}}}}`)))
+	expectPinterestToken(mock)
+
+	var pinPatch map[string]interface{}
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		_ = json.NewDecoder(r.Body).Decode(&pinPatch)
+		return httpJSON(200, `{"id":"pin1"}`, nil), nil
+	}}
+
+	rr = updateRemoteRequest(h)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"ok":true`) {
+		t.Fatalf("update remote: expected success got %d body=%s", rr.Code, rr.Body.String())
+	}
+	if pinPatch["description"] != "Edited caption" {
+		t.Fatalf("unexpected pin edit %v", pinPatch)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// errRetractUnsupported marks networks whose API can't delete what we published (TikTok, Threads).
+var errRetractUnsupported = errors.New("delete_not_supported")
+
+// retractProviderResult is what retracting did on one network. Objects that are still live (failed or
+// unsupported deletes) are left to the user, who gets a notification to delete them by hand.
+type retractProviderResult struct {
+	OK      bool              `json:"ok"`
+	Deleted []string          `json:"deleted,omitempty"`
+	Failed  map[string]string `json:"failed,omitempty"` // external id -> reason
+	Manual  bool              `json:"manual,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// publishedObjectIDs returns the remote ids a provider's publish result recorded, in publish order.
+func publishedObjectIDs(provider string, details map[string]interface{}) []string {
+	var d struct {
+		PublishedID string         `json:"publishedId"`
+		VideoID     string         `json:"videoId"`
+		PinID       string         `json:"pinId"`
+		TweetIDs    []string       `json:"tweetIds"`
+		PostIDs     []string       `json:"postIds"`
+		Pages       []fbPageResult `json:"pages"`
+		Stories     []struct {
+			PublishedID string `json:"publishedId"`
+		} `json:"stories"`
+	}
+	b, _ := json.Marshal(details)
+	_ = json.Unmarshal(b, &d)
+
+	var ids []string
+	add := func(id string) {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	switch provider {
+	case "facebook":
+		for _, p := range d.Pages {
+			if p.PostID != "" {
+				add(p.PostID)
+			} else {
+				add(p.VideoID)
+			}
+		}
+		// Posts handed to Meta's scheduler.
+		for _, id := range d.PostIDs {
+			add(id)
+		}
+	case "instagram", "threads":
+		add(d.PublishedID)
+		for _, s := range d.Stories {
+			add(s.PublishedID)
+		}
+	case "youtube":
+		add(d.VideoID)
+	case "pinterest":
+		add(d.PinID)
+	case "x":
+		// Replies first, so a thread is never left without its head while it's being deleted.
+		for i := len(d.TweetIDs) - 1; i >= 0; i-- {
+			add(d.TweetIDs[i])
+		}
+	case "tiktok":
+		for _, id := range d.PostIDs {
+			add(id)
+		}
+	}
+	return ids
+}
+
+// deletePublishedObject deletes one published object through the network's API.
+func (h *Handler) deletePublishedObject(ctx context.Context, userID, provider, externalID string) error {
+	switch provider {
+	case "instagram":
+		return h.deleteInstagramMedia(ctx, userID, externalID)
+	case "facebook":
+		return h.deleteFacebookObject(ctx, userID, externalID)
+	case "pinterest":
+		return h.deletePinterestPin(ctx, userID, externalID)
+	case "youtube":
+		return h.deleteYouTubeVideo(ctx, userID, externalID)
+	case "x":
+		return h.deleteXPost(ctx, userID, externalID)
+	}
+	return errRetractUnsupported
+}
+
+// retractProvider deletes what one provider published, skipping ids an earlier retract already deleted.
+func (h *Handler) retractProvider(ctx context.Context, userID, provider string, ids []string, prev retractProviderResult) retractProviderResult {
+	out := retractProviderResult{Deleted: prev.Deleted}
+	done := map[string]bool{}
+	for _, id := range prev.Deleted {
+		done[id] = true
+	}
+	if len(ids) == 0 {
+		out.Error = "missing_published_id"
+		out.Manual = true
+		return out
+	}
+	for _, id := range ids {
+		if done[id] {
+			continue
+		}
+		dctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+		err := h.deletePublishedObject(dctx, userID, provider, id)
+		cancel()
+		if err == nil {
+			out.Deleted = append(out.Deleted, id)
+			continue
+		}
+		if out.Failed == nil {
+			out.Failed = map[string]string{}
+		}
+		out.Failed[id] = truncate(err.Error(), 220)
+		if errors.Is(err, errRetractUnsupported) {
+			out.Error = errRetractUnsupported.Error()
+		}
+	}
+	out.OK = len(out.Failed) == 0
+	out.Manual = !out.OK
+	if !out.OK && out.Error == "" {
+		out.Error = "delete_failed"
+	}
+	return out
+}
+
+// notifyManualRetract asks the user to delete objects we couldn't delete, linking each one when its
+// permalink is known from the library.
+func (h *Handler) notifyManualRetract(ctx context.Context, userID, postID, provider string, res retractProviderResult) {
+	name := providerDisplayName(provider)
+	title := "Manual action required: delete on " + name
+	if len(res.Failed) == 0 {
+		body := fmt.Sprintf("We couldn't find what post %s published on %s. Please delete it from %s.", postID, name, name)
+		h.createNotificationOnce(userID, "manual_delete."+provider, title, &body, nil)
+		return
+	}
+	ids := make([]string, 0, len(res.Failed))
+	for id := range res.Failed {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		var permalink sql.NullString
+		_ = h.db.QueryRowContext(ctx, `SELECT permalink_url FROM public.social_libraries WHERE user_id=$1 AND network=$2 AND external_id=$3`, userID, provider, id).Scan(&permalink)
+		var urlStr *string
+		if u := strings.TrimSpace(permalink.String); u != "" {
+			urlStr = &u
+		}
+		body := fmt.Sprintf("We couldn't delete this post via the %s API. Please open it and delete it from %s. (reason=%s)", name, name, res.Failed[id])
+		h.createNotificationOnce(userID, "manual_delete."+provider, title, &body, urlStr)
+	}
+}
+
+func providerDisplayName(provider string) string {
+	switch provider {
+	case "youtube":
+		return "YouTube"
+	case "tiktok":
+		return "TikTok"
+	case "x":
+		return "X"
+	}
+	return strings.ToUpper(provider[:1]) + provider[1:]
+}
+
+// postPublishJob is one publish job of a post: a one-off publish or one occurrence of a recurring post.
+type postPublishJob struct {
+	ID      string
+	Results map[string]publishProviderResult
+}
+
+// loadPostPublishJobs returns the post's publish jobs that have results, oldest first. Jobs are matched on the
+// postId their request recorded rather than posts.last_publish_job_id, which editing a post clears and which
+// recurring occurrences never set.
+func (h *Handler) loadPostPublishJobs(ctx context.Context, userID, postID string) ([]postPublishJob, error) {
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT id, result_json
+		  FROM public.publish_jobs
+		 WHERE user_id = $1 AND request_json->>'postId' = $2 AND result_json IS NOT NULL
+		 ORDER BY created_at ASC
+	`, userID, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var jobs []postPublishJob
+	for rows.Next() {
+		var (
+			job postPublishJob
+			raw []byte
+		)
+		if err := rows.Scan(&job.ID, &raw); err != nil {
+			return nil, err
+		}
+		var res struct {
+			Results map[string]publishProviderResult `json:"results"`
+		}
+		_ = json.Unmarshal(raw, &res)
+		job.Results = res.Results
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// publishedProviders returns the providers something went out on across jobs, sorted, with the remote ids
+// each job recorded for them.
+func publishedProviders(jobs []postPublishJob) ([]string, map[string][]string) {
+	ids := map[string][]string{}
+	var providers []string
+	for _, job := range jobs {
+		for p, res := range job.Results {
+			// Nothing went out on providers that failed without posting.
+			if !res.OK && res.Posted == 0 {
+				continue
+			}
+			if _, ok := ids[p]; !ok {
+				providers = append(providers, p)
+				ids[p] = []string{}
+			}
+			ids[p] = append(ids[p], publishedObjectIDs(p, res.Details)...)
+		}
+	}
+	sort.Strings(providers)
+	return providers, ids
+}
+
+// RetractPostForUser deletes a published post from every network it went out on, using the remote ids
+// recorded by its publish jobs (every occurrence, for a recurring post), and moves the post to status
+// "retracted", which also stops a recurrence. Networks that can't delete (or failed to) are reported in
+// results and the user is notified to delete them by hand; retracting again retries only those.
+//
+// URL: POST /api/posts/{postId}/retract/user/{userId}
+func (h *Handler) RetractPostForUser(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	userID := strings.TrimSpace(pathVar(r, "userId"))
+	postID := strings.TrimSpace(pathVar(r, "postId"))
+	if userID == "" || postID == "" {
+		writeError(w, http.StatusBadRequest, "userId and postId are required")
+		return
+	}
+
+	var (
+		status    string
+		recurring bool
+		prevRaw   []byte
+	)
+	err := h.db.QueryRowContext(r.Context(), `
+		SELECT status, recurrence IS NOT NULL, retract_result
+		  FROM public.posts
+		 WHERE id = $1 AND user_id = $2
+	`, postID, userID).Scan(&status, &recurring, &prevRaw)
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if status != "published" && status != "retracted" && !recurring {
+		writeError(w, http.StatusConflict, "only published posts can be retracted")
+		return
+	}
+	jobs, err := h.loadPostPublishJobs(r.Context(), userID, postID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	providers, ids := publishedProviders(jobs)
+	if len(providers) == 0 && status != "retracted" {
+		writeError(w, http.StatusConflict, "post has nothing published to retract")
+		return
+	}
+	prev := map[string]retractProviderResult{}
+	if len(prevRaw) > 0 {
+		_ = json.Unmarshal(prevRaw, &prev)
+	}
+
+	results := map[string]retractProviderResult{}
+	allOK := true
+	for _, provider := range providers {
+		if p, ok := prev[provider]; ok && p.OK && containsAll(p.Deleted, ids[provider]) {
+			results[provider] = p
+			continue
+		}
+		res := h.retractProvider(r.Context(), userID, provider, ids[provider], prev[provider])
+		results[provider] = res
+		if len(res.Deleted) > 0 {
+			if _, err := h.db.ExecContext(r.Context(), `DELETE FROM public.social_libraries WHERE user_id = $1 AND network = $2 AND external_id = ANY($3)`,
+				userID, provider, pq.Array(res.Deleted)); err != nil {
+				log.Printf("[Retract] library_cleanup_failed userId=%s postId=%s provider=%s err=%v", userID, postID, provider, err)
+			}
+		}
+		if !res.OK {
+			allOK = false
+			h.notifyManualRetract(r.Context(), userID, postID, provider, res)
+		}
+		log.Printf("[Retract] provider userId=%s postId=%s provider=%s ok=%v deleted=%d failed=%d", userID, postID, provider, res.OK, len(res.Deleted), len(res.Failed))
+	}
+
+	resJSON, _ := json.Marshal(results)
+	if _, err := h.db.ExecContext(r.Context(), `
+		UPDATE public.posts
+		   SET status = 'retracted', retracted_at = COALESCE(retracted_at, NOW()), retract_result = $3::jsonb, in_queue = FALSE, updated_at = NOW()
+		 WHERE id = $1 AND user_id = $2
+	`, postID, userID, string(resJSON)); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	jobIDs := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		jobIDs = append(jobIDs, job.ID)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"ok":      allOK,
+		"postId":  postID,
+		"jobIds":  jobIDs,
+		"status":  "retracted",
+		"results": results,
+	})
+}
+
+func containsAll(have, want []string) bool {
+	set := map[string]bool{}
+	for _, s := range have {
+		set[s] = true
+	}
+	for _, s := range want {
+		if !set[s] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+)
+
+func retractRequest(h *Handler) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/posts/p1/retract/user/u1", nil)
+	req = mux.SetURLVars(req, map[string]string{"postId": "p1", "userId": "u1"})
+	rr := httptest.NewRecorder()
+	h.RetractPostForUser(rr, req)
+	return rr
+}
+
+func expectRetractPost(mock sqlmock.Sqlmock, status string, recurring bool, prev []byte) {
+	mock.ExpectQuery(`SELECT status, recurrence IS NOT NULL, retract_result\s+FROM public\.posts`).
+		WithArgs("p1", "u1").
+		WillReturnRows(sqlmock.NewRows([]string{"status", "recurring", "retract_result"}).AddRow(status, recurring, prev))
+}
+
+func TestRetractPostForUser_DeletesAndNotifiesForManualDeletes(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	results := `{"results":{
+		"instagram":{"ok":true,"posted":1,"details":{"publishedId":"ig1"}},
+		"pinterest":{"ok":true,"posted":1,"details":{"pinId":"pin1"}},
+		"tiktok":{"ok":false,"error":"not_connected"}
+	}}`
+	expectRetractPost(mock, "published", false, nil)
+	mock.ExpectQuery(`SELECT id, result_json\s+FROM public\.publish_jobs\s+WHERE user_id = \$1 AND request_json->>'postId' = \$2`).
+		WithArgs("u1", "p1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "result_json"}).AddRow("job1", []byte(results)))
+
+	// Instagram refuses the delete: the user is asked to delete it by hand.
+	igTok, _ := json.Marshal(instagramOAuth{AccessToken: "igtok", IGBusinessID: "biz"})
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings.*key='instagram_oauth'`).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(igTok))
+	mock.ExpectQuery(`SELECT permalink_url FROM public\.social_libraries`).
+		WithArgs("u1", "instagram", "ig1").
+		WillReturnRows(sqlmock.NewRows([]string{"permalink_url"}).AddRow("https://instagram.com/p/abc"))
+	mock.ExpectQuery(`SELECT id\s+FROM public\.notifications`).
+		WithArgs("u1", "manual_delete.instagram", "https://instagram.com/p/abc").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec(`INSERT INTO public\.notifications`).
+		WithArgs(sqlmock.AnyArg(), "u1", "manual_delete.instagram", "Manual action required: delete on Instagram", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	pinTok, _ := json.Marshal(pinterestOAuth{AccessToken: "ptok"})
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings.*key='pinterest_oauth'`).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(pinTok))
+	mock.ExpectExec(`DELETE FROM public\.social_libraries`).
+		WithArgs("u1", "pinterest", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE public\.posts\s+SET status = 'retracted'`).
+		WithArgs("p1", "u1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	var deleted []string
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		deleted = append(deleted, r.Method+" "+r.URL.Host+r.URL.Path)
+		if r.URL.Host == "graph.facebook.com" {
+			return httpJSON(400, `{"error":{"message":"Unsupported delete request"}}`, nil), nil
+		}
+		return httpJSON(204, ``, nil), nil
+	}}
+
+	rr := retractRequest(h)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d body=%s", rr.Code, rr.Body.String())
+	}
+	var out struct {
+		OK      bool                             `json:"ok"`
+		Status  string                           `json:"status"`
+		Results map[string]retractProviderResult `json:"results"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &out)
+	ig, pin := out.Results["instagram"], out.Results["pinterest"]
+	if out.OK || out.Status != "retracted" || ig.OK || !ig.Manual || ig.Failed["ig1"] == "" || !pin.OK || len(pin.Deleted) != 1 || pin.Deleted[0] != "pin1" {
+		t.Fatalf("unexpected response %s", rr.Body.String())
+	}
+	// TikTok never posted, so there is nothing to retract there.
+	if _, ok := out.Results["tiktok"]; ok {
+		t.Fatalf("expected tiktok to be skipped, got %v", out.Results)
+	}
+	if got := strings.Join(deleted, ","); got != "DELETE graph.facebook.com/v24.0/ig1,DELETE api.pinterest.com/v5/pins/pin1" {
+		t.Fatalf("unexpected delete requests %s", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestRetractPostForUser_OnlyRetriesWhatIsStillLive(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	prev := `{"pinterest":{"ok":true,"deleted":["pin1"]},"youtube":{"ok":false,"failed":{"v1":"timeout"},"manual":true,"error":"delete_failed"}}`
+	expectRetractPost(mock, "retracted", false, []byte(prev))
+	mock.ExpectQuery(`FROM public\.publish_jobs`).
+		WithArgs("u1", "p1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "result_json"}).AddRow("job1", []byte(`{"results":{"pinterest":{"ok":true,"details":{"pinId":"pin1"}},"youtube":{"ok":true,"details":{"videoId":"v1"}}}}`)))
+	ytTok, _ := json.Marshal(youtubeOAuth{AccessToken: "ytok", Scope: "https://www.googleapis.com/auth/youtube.force-ssl"})
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings.*key='youtube_oauth'`).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(ytTok))
+	mock.ExpectExec(`DELETE FROM public\.social_libraries`).
+		WithArgs("u1", "youtube", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE public\.posts`).
+		WithArgs("p1", "u1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	calls := 0
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		calls++
+		if r.Method == http.MethodDelete && r.URL.Query().Get("id") == "v1" {
+			return httpJSON(204, ``, nil), nil
+		}
+		return httpJSON(404, `{}`, nil), nil
+	}}
+
+	rr := retractRequest(h)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"ok":true`) || calls != 1 {
+		t.Fatalf("expected only youtube to be retried, got %d calls=%d body=%s", rr.Code, calls, rr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestRetractPostForUser_RejectsUnpublishedPosts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	expectRetractPost(mock, "draft", false, nil)
+	if rr := retractRequest(h); rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 got %d body=%s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestRetractPostForUser_DeletesEveryRecurringOccurrence(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	// A recurring post stays "scheduled" and never gets last_publish_job_id; its occurrences' jobs are found by postId.
+	expectRetractPost(mock, "scheduled", true, nil)
+	mock.ExpectQuery(`FROM public\.publish_jobs`).
+		WithArgs("u1", "p1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "result_json"}).
+			AddRow("occ1", []byte(`{"results":{"pinterest":{"ok":true,"posted":1,"details":{"pinId":"pin1"}}}}`)).
+			AddRow("occ2", []byte(`{"results":{"pinterest":{"ok":true,"posted":1,"details":{"pinId":"pin2"}}}}`)))
+	expectPinterestToken(mock)
+	expectPinterestToken(mock)
+	mock.ExpectExec(`DELETE FROM public\.social_libraries`).
+		WithArgs("u1", "pinterest", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`UPDATE public\.posts\s+SET status = 'retracted'`).
+		WithArgs("p1", "u1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	var deleted []string
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		deleted = append(deleted, r.Method+" "+r.URL.Path)
+		return httpJSON(204, ``, nil), nil
+	}}
+
+	rr := retractRequest(h)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"jobIds":["occ1","occ2"]`) {
+		t.Fatalf("expected 200 got %d body=%s", rr.Code, rr.Body.String())
+	}
+	if got := strings.Join(deleted, ","); got != "DELETE /v5/pins/pin1,DELETE /v5/pins/pin2" {
+		t.Fatalf("unexpected delete requests %s", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestPublishedObjectIDs(t *testing.T) {
+	fb := map[string]interface{}{"pages": []fbPageResult{{PageID: "pg1", Posted: true, PostID: "pg1_1"}, {PageID: "pg2", Posted: true, VideoID: "vid"}}}
+	if got := strings.Join(publishedObjectIDs("facebook", fb), ","); got != "pg1_1,vid" {
+		t.Fatalf("unexpected facebook ids %s", got)
+	}
+	x := map[string]interface{}{"tweetIds": []interface{}{"t1", "t2"}}
+	if got := strings.Join(publishedObjectIDs("x", x), ","); got != "t2,t1" {
+		t.Fatalf("unexpected x ids %s", got)
+	}
+	stories := map[string]interface{}{"stories": []interface{}{map[string]interface{}{"publishedId": "s1"}, map[string]interface{}{"publishedId": "s2"}}}
+	if got := strings.Join(publishedObjectIDs("instagram", stories), ","); got != "s1,s2" {
+		t.Fatalf("unexpected instagram story ids %s", got)
+	}
+}
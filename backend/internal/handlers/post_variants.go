@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PortNumber53/simple-social-thing/backend/internal/models"
+)
+
+const (
+	postVariantMaxCaption = 63206 // Facebook's limit; the strictest networks are checked at publish time.
+	postVariantMaxTitle   = 100
+	postVariantMaxComment = 2200
+)
+
+// normalizePostVariants validates per-provider overrides. Keys must be known providers, links must be http(s)
+// and variant media must come from the post's media (checked only when postMedia is known).
+func normalizePostVariants(in map[string]models.PostVariant, postMedia []string) (map[string]models.PostVariant, error) {
+	out := map[string]models.PostVariant{}
+	var allowed map[string]bool
+	if postMedia != nil {
+		allowed = map[string]bool{}
+		for _, m := range postMedia {
+			allowed[m] = true
+		}
+	}
+	for key, v := range in {
+		norm := normalizePostProviders([]string{key})
+		if len(norm) != 1 {
+			return nil, fmt.Errorf("unknown variant provider %q", key)
+		}
+		provider := norm[0]
+		v.Caption = strings.TrimSpace(v.Caption)
+		v.Title = strings.TrimSpace(v.Title)
+		v.Link = strings.TrimSpace(v.Link)
+		v.FirstComment = strings.TrimSpace(v.FirstComment)
+		if len(v.Caption) > postVariantMaxCaption {
+			return nil, fmt.Errorf("%s variant caption is too long", provider)
+		}
+		if len([]rune(v.Title)) > postVariantMaxTitle {
+			return nil, fmt.Errorf("%s variant title is too long (max %d)", provider, postVariantMaxTitle)
+		}
+		if len([]rune(v.FirstComment)) > postVariantMaxComment {
+			return nil, fmt.Errorf("%s variant firstComment is too long (max %d)", provider, postVariantMaxComment)
+		}
+		if v.Link != "" {
+			u, err := url.Parse(v.Link)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, fmt.Errorf("%s variant link must be an http(s) URL", provider)
+			}
+		}
+		if v.Media != nil {
+			v.Media = normalizePostMedia(v.Media)
+			for _, m := range v.Media {
+				if allowed != nil && !allowed[m] {
+					return nil, fmt.Errorf("%s variant media must be a subset of the post's media", provider)
+				}
+			}
+			if len(v.Media) == 0 {
+				v.Media = nil
+			}
+		}
+		if v.Caption == "" && v.Title == "" && v.Link == "" && v.FirstComment == "" && len(v.Media) == 0 {
+			continue
+		}
+		out[provider] = v
+	}
+	return out, nil
+}
+
+// postVariantsFromJSON decodes a posts.variants column (nil when unset or unreadable).
+func postVariantsFromJSON(raw []byte) map[string]models.PostVariant {
+	if len(raw) == 0 {
+		return nil
+	}
+	var v map[string]models.PostVariant
+	if err := json.Unmarshal(raw, &v); err != nil || len(v) == 0 {
+		return nil
+	}
+	return v
+}
+
+// providerPublishInput is what one provider publishes after its variant is applied.
+type providerPublishInput struct {
+	Caption      string
+	Title        string
+	Link         string
+	FirstComment string
+	RelMedia     []string
+	MediaFiles   []uploadedMedia
+}
+
+// publishInputFor applies provider's variant to the shared caption/media. Without a media override the
+// shared slices are passed through unchanged (later providers see earlier in-place fixes such as transcodes).
+func publishInputFor(provider, caption string, variants map[string]models.PostVariant, relMedia []string, mediaFiles []uploadedMedia) providerPublishInput {
+	in := providerPublishInput{Caption: caption, RelMedia: relMedia, MediaFiles: mediaFiles}
+	v, ok := variants[provider]
+	if !ok {
+		return in
+	}
+	if v.Caption != "" {
+		in.Caption = v.Caption
+	}
+	in.Title = v.Title
+	in.Link = v.Link
+	in.FirstComment = v.FirstComment
+	if len(v.Media) > 0 {
+		idx := map[string]int{}
+		for i, rel := range relMedia {
+			idx[rel] = i
+		}
+		subRel := make([]string, 0, len(v.Media))
+		subFiles := make([]uploadedMedia, 0, len(v.Media))
+		for _, rel := range v.Media {
+			i, ok := idx[rel]
+			if !ok {
+				continue
+			}
+			subRel = append(subRel, rel)
+			if i < len(mediaFiles) {
+				subFiles = append(subFiles, mediaFiles[i])
+			}
+		}
+		// Media removed from the post since the variant was saved: fall back to the shared list.
+		if len(subRel) > 0 {
+			in.RelMedia = subRel
+			if len(mediaFiles) > 0 {
+				in.MediaFiles = subFiles
+			}
+		}
+	}
+	// Pinterest has a destination link field and Facebook attaches the link to text posts; elsewhere the
+	// link goes at the end of the caption.
+	attached := provider == "pinterest" || (provider == "facebook" && len(in.RelMedia) == 0 && len(in.MediaFiles) == 0)
+	if in.Link != "" && !attached && !strings.Contains(in.Caption, in.Link) {
+		in.Caption = strings.TrimSpace(in.Caption + "\n\n" + in.Link)
+	}
+	return in
+}
+
+// postFirstComments posts each provider's variant first comment under the content it just published and
+// records the outcome as details.firstComment. Providers that failed or were resumed from an earlier run
+// are skipped (a resumed provider already got its comment).
+func (h *Handler) postFirstComments(jobID, userID string, variants map[string]models.PostVariant, results map[string]publishProviderResult, dryRun bool) {
+	for provider, res := range results {
+		v, ok := variants[provider]
+		if !ok || v.FirstComment == "" || !res.OK || res.Resumed {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		out := h.postFirstComment(ctx, userID, provider, v.FirstComment, res.Details, dryRun)
+		cancel()
+		if res.Details == nil {
+			res.Details = map[string]interface{}{}
+		}
+		res.Details["firstComment"] = out
+		results[provider] = res
+		if errText, _ := out["error"].(string); errText != "" {
+			log.Printf("[PublishJob] first_comment_failed jobId=%s userId=%s provider=%s err=%s", jobID, userID, provider, truncate(errText, 300))
+		} else {
+			log.Printf("[PublishJob] first_comment_ok jobId=%s userId=%s provider=%s", jobID, userID, provider)
+		}
+	}
+}
+
+func (h *Handler) postFirstComment(ctx context.Context, userID, provider, text string, details map[string]interface{}, dryRun bool) map[string]interface{} {
+	if dryRun {
+		return map[string]interface{}{"ok": true, "dryRun": true}
+	}
+	fail := func(code string) map[string]interface{} { return map[string]interface{}{"ok": false, "error": code} }
+	switch provider {
+	case "facebook":
+		return h.postFacebookFirstComments(ctx, userID, text, details)
+	case "instagram":
+		mediaID, _ := details["publishedId"].(string)
+		if mediaID == "" {
+			return fail("missing_published_id")
+		}
+		var raw []byte
+		if err := h.db.QueryRowContext(ctx, `SELECT value FROM public.user_settings WHERE user_id=$1 AND key='instagram_oauth' AND value IS NOT NULL`, userID).Scan(&raw); err != nil {
+			return fail("not_connected")
+		}
+		var tok instagramOAuth
+		if err := json.Unmarshal(raw, &tok); err != nil || strings.TrimSpace(tok.AccessToken) == "" {
+			return fail("not_connected")
+		}
+		id, err := graphComment(ctx, mediaID, tok.AccessToken, text)
+		if err != nil {
+			return fail(err.Error())
+		}
+		return map[string]interface{}{"ok": true, "id": id}
+	case "x":
+		ids, _ := details["tweetIds"].([]string)
+		if len(ids) == 0 {
+			return fail("missing_published_id")
+		}
+		tok, err := h.loadXOAuth(ctx, userID)
+		if err != nil {
+			return fail(err.Error())
+		}
+		// Reply to the last tweet so the comment follows a multi-part thread.
+		body, _ := json.Marshal(map[string]interface{}{
+			"text":  text,
+			"reply": map[string]interface{}{"in_reply_to_tweet_id": ids[len(ids)-1]},
+		})
+		req, _ := http.NewRequestWithContext(ctx, "POST", xAPIBase+"/tweets", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(tok.AccessToken))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		res, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+		if err != nil {
+			return fail(err.Error())
+		}
+		b, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+		_ = res.Body.Close()
+		var out struct {
+			Data struct {
+				ID string `json:"id"`
+			} `json:"data"`
+		}
+		if res.StatusCode < 200 || res.StatusCode >= 300 || json.Unmarshal(b, &out) != nil || out.Data.ID == "" {
+			return map[string]interface{}{"ok": false, "error": "x_reply_failed", "status": res.StatusCode, "body": truncate(string(b), 600)}
+		}
+		return map[string]interface{}{"ok": true, "id": out.Data.ID}
+	default:
+		return fail("not_supported")
+	}
+}
+
+// postFacebookFirstComments comments on every page post created by the job, with that page's token.
+func (h *Handler) postFacebookFirstComments(ctx context.Context, userID, text string, details map[string]interface{}) map[string]interface{} {
+	var pages []struct {
+		PageID string `json:"pageId"`
+		PostID string `json:"postId"`
+	}
+	if b, err := json.Marshal(details["pages"]); err == nil {
+		_ = json.Unmarshal(b, &pages)
+	}
+	var raw []byte
+	if err := h.db.QueryRowContext(ctx, `SELECT value FROM public.user_settings WHERE user_id=$1 AND key='facebook_oauth' AND value IS NOT NULL`, userID).Scan(&raw); err != nil {
+		if err == sql.ErrNoRows {
+			return map[string]interface{}{"ok": false, "error": "not_connected"}
+		}
+		return map[string]interface{}{"ok": false, "error": err.Error()}
+	}
+	var tok fbOAuthPayload
+	_ = json.Unmarshal(raw, &tok)
+	tokens := map[string]string{}
+	for _, p := range tok.Pages {
+		tokens[p.ID] = p.AccessToken
+	}
+	if tok.PageID != "" && tokens[tok.PageID] == "" {
+		tokens[tok.PageID] = tok.AccessToken
+	}
+
+	ids := map[string]string{}
+	errs := map[string]string{}
+	for _, p := range pages {
+		if p.PostID == "" {
+			continue
+		}
+		pageToken := tokens[p.PageID]
+		if pageToken == "" {
+			errs[p.PageID] = "missing_page_token"
+			continue
+		}
+		id, err := graphComment(ctx, p.PostID, pageToken, text)
+		if err != nil {
+			errs[p.PageID] = err.Error()
+			continue
+		}
+		ids[p.PageID] = id
+	}
+	out := map[string]interface{}{"ok": len(errs) == 0 && len(ids) > 0, "ids": ids}
+	if len(errs) > 0 {
+		out["errors"] = errs
+		out["error"] = "facebook_comment_failed"
+	} else if len(ids) == 0 {
+		out["error"] = "missing_published_id"
+	}
+	return out
+}
+
+// graphComment adds a comment to a Facebook post or Instagram media object through the Graph API.
+func graphComment(ctx context.Context, objectID, accessToken, text string) (string, error) {
+	form := url.Values{}
+	form.Set("message", text)
+	form.Set("access_token", accessToken)
+	endpoint := fmt.Sprintf("https://graph.facebook.com/v24.0/%s/comments", url.PathEscape(objectID))
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	res, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return "", err
+	}
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	_ = res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return "", fmt.Errorf("%s", truncate(extractFacebookErrorMessage(b, "comment_failed"), 300))
+	}
+	var obj struct {
+		ID string `json:"id"`
+	}
+	_ = json.Unmarshal(b, &obj)
+	return obj.ID, nil
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PortNumber53/simple-social-thing/backend/internal/models"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+func TestNormalizePostVariants_Validates(t *testing.T) {
+	out, err := normalizePostVariants(map[string]models.PostVariant{
+		" Instagram ": {Caption: "  ig caption ", FirstComment: "#tags", Media: []string{"/media/u/a.png"}},
+		"facebook":    {},
+	}, []string{"/media/u/a.png", "/media/u/b.png"})
+	if err != nil {
+		t.Fatalf("normalizePostVariants: %v", err)
+	}
+	if len(out) != 1 || out["instagram"].Caption != "ig caption" || len(out["instagram"].Media) != 1 {
+		t.Fatalf("unexpected variants %#v", out)
+	}
+
+	for name, bad := range map[string]map[string]models.PostVariant{
+		"provider": {"myspace": {Caption: "x"}},
+		"link":     {"facebook": {Link: "javascript:alert(1)"}},
+		"title":    {"youtube": {Title: strings.Repeat("t", postVariantMaxTitle+1)}},
+		"media":    {"pinterest": {Media: []string{"/media/u/other.png"}}},
+	} {
+		if _, err := normalizePostVariants(bad, []string{"/media/u/a.png"}); err == nil {
+			t.Fatalf("expected %s error", name)
+		}
+	}
+}
+
+func TestPublishInputFor_AppliesVariant(t *testing.T) {
+	rel := []string{"/media/u/a.png", "/media/u/b.png"}
+	files := []uploadedMedia{{Filename: "a.png"}, {Filename: "b.png"}}
+	variants := map[string]models.PostVariant{
+		"facebook":  {Caption: "fb text", Link: "https://example.com/x"},
+		"pinterest": {Title: "Pin", Link: "https://example.com/p", Media: []string{"/media/u/b.png"}},
+	}
+
+	fb := publishInputFor("facebook", "shared", variants, rel, files)
+	if fb.Caption != "fb text\n\nhttps://example.com/x" || len(fb.RelMedia) != 2 {
+		t.Fatalf("unexpected facebook input %#v", fb)
+	}
+	pin := publishInputFor("pinterest", "shared", variants, rel, files)
+	if pin.Caption != "shared" || pin.Link != "https://example.com/p" || pin.Title != "Pin" {
+		t.Fatalf("unexpected pinterest input %#v", pin)
+	}
+	if len(pin.RelMedia) != 1 || pin.RelMedia[0] != "/media/u/b.png" || len(pin.MediaFiles) != 1 || pin.MediaFiles[0].Filename != "b.png" {
+		t.Fatalf("unexpected pinterest media %#v", pin)
+	}
+	x := publishInputFor("x", "shared", variants, rel, files)
+	if x.Caption != "shared" || len(x.MediaFiles) != 2 {
+		t.Fatalf("expected shared input for x, got %#v", x)
+	}
+}
+
+func TestCreatePostForUser_StoresVariants(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	now := time.Now().UTC()
+	stored := `{"instagram":{"caption":"ig","firstComment":"#go"}}`
+	mock.ExpectQuery(`INSERT INTO public\.posts`).
+		WithArgs(sqlmock.AnyArg(), "u1", sqlmock.AnyArg(), "draft", sqlmock.AnyArg(), sqlmock.AnyArg(), (*time.Time)(nil), (*time.Time)(nil), nil, stored, nil).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "teamId", "userId", "content", "status", "providers", "media",
+			"scheduledFor", "publishedAt",
+			"lastPublishJobId", "lastPublishStatus", "lastPublishError", "lastPublishAttemptAt",
+			"createdAt", "updatedAt", "recurrence", "variants", "options",
+		}).
+			AddRow("p1", "", "u1", "hi", "draft", pq.StringArray{"instagram"}, pq.StringArray{}, sql.NullTime{}, sql.NullTime{}, sql.NullString{}, sql.NullString{}, sql.NullString{}, sql.NullTime{}, now, now, nil, []byte(stored), nil))
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/posts/user/u1", bytes.NewBufferString(
+		`{"content":"hi","providers":["instagram"],"variants":{"instagram":{"caption":"ig","firstComment":"#go"}}}`))
+	req = mux.SetURLVars(req, map[string]string{"userId": "u1"})
+	h.CreatePostForUser(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"firstComment":"#go"`) {
+		t.Fatalf("expected variants in response, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/api/posts/user/u1", bytes.NewBufferString(
+		`{"content":"hi","providers":["instagram"],"variants":{"instagram":{"link":"ftp://example.com"}}}`))
+	req = mux.SetURLVars(req, map[string]string{"userId": "u1"})
+	h.CreatePostForUser(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for bad link got %d", rr.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestPostFirstComment_InstagramUsesPublishedMedia(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	mock.ExpectQuery(`key='instagram_oauth'`).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte(`{"accessToken":"tok","igBusinessId":"ig1"}`)))
+
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	var gotPath, gotMessage string
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		_ = r.ParseForm()
+		gotPath, gotMessage = r.URL.Path, r.PostForm.Get("message")
+		return httpJSON(200, `{"id":"c1"}`, nil), nil
+	}}
+
+	results := map[string]publishProviderResult{
+		"instagram": {OK: true, Details: map[string]interface{}{"publishedId": "m1"}},
+		"facebook":  {OK: false},
+	}
+	variants := map[string]models.PostVariant{
+		"instagram": {FirstComment: "#go #golang"},
+		"facebook":  {FirstComment: "skipped"},
+	}
+	h.postFirstComments("j1", "u1", variants, results, false)
+
+	fc, _ := results["instagram"].Details["firstComment"].(map[string]interface{})
+	if fc["ok"] != true || fc["id"] != "c1" || gotPath != "/v24.0/m1/comments" || gotMessage != "#go #golang" {
+		t.Fatalf("unexpected first comment %#v path=%s message=%q", fc, gotPath, gotMessage)
+	}
+	if results["facebook"].Details != nil {
+		t.Fatalf("failed providers should not be commented on")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
@@ -0,0 +1,580 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// postingQueueSettingKey is the user_settings key holding the user's weekly posting slots.
+	postingQueueSettingKey = "posting_queue"
+	// postingQueueHorizonWeeks bounds how far ahead a queued post may be placed.
+	postingQueueHorizonWeeks = 12
+	postingQueueMaxSlots     = 100
+	// postingQueueMaxSpacing caps per-provider spacing (one day).
+	postingQueueMaxSpacing = 24 * 60
+)
+
+type postingQueueSlot struct {
+	// Weekday is an RRULE weekday code (MO..SU).
+	Weekday string `json:"weekday"`
+	// Time is HH:MM in the queue's timezone.
+	Time string `json:"time"`
+	// Providers restricts the slot to posts that only target these providers (empty: any post).
+	Providers []string `json:"providers,omitempty"`
+}
+
+// postingQueueConfig is stored as the posting_queue user setting.
+type postingQueueConfig struct {
+	Timezone string             `json:"timezone,omitempty"`
+	Slots    []postingQueueSlot `json:"slots"`
+	// SpacingMinutes is the minimum gap between two publishes to the same provider, e.g. {"instagram": 60}.
+	// The scheduled-post sweep holds a due post back until its providers are clear.
+	SpacingMinutes map[string]int `json:"spacingMinutes,omitempty"`
+}
+
+type queueSlotTime struct {
+	At        time.Time
+	Providers []string
+}
+
+func normalizePostingQueue(cfg *postingQueueConfig) error {
+	cfg.Timezone = strings.TrimSpace(cfg.Timezone)
+	if cfg.Timezone == "" {
+		cfg.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(cfg.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone")
+	}
+	if len(cfg.Slots) > postingQueueMaxSlots {
+		return fmt.Errorf("too many slots (max %d)", postingQueueMaxSlots)
+	}
+	slots := make([]postingQueueSlot, 0, len(cfg.Slots))
+	seen := map[string]bool{}
+	for _, s := range cfg.Slots {
+		codes := normalizeWeekdays([]string{s.Weekday})
+		if len(codes) != 1 {
+			return fmt.Errorf("invalid slot weekday")
+		}
+		if _, ok := recurrenceWeekdays[codes[0]]; !ok {
+			return fmt.Errorf("invalid slot weekday")
+		}
+		tod, err := time.Parse("15:04", strings.TrimSpace(s.Time))
+		if err != nil {
+			return fmt.Errorf("invalid slot time (want HH:MM)")
+		}
+		ns := postingQueueSlot{Weekday: codes[0], Time: tod.Format("15:04")}
+		if len(s.Providers) > 0 {
+			ns.Providers = normalizePostProviders(s.Providers)
+			sort.Strings(ns.Providers)
+		}
+		key := ns.Weekday + " " + ns.Time + " " + strings.Join(ns.Providers, ",")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		slots = append(slots, ns)
+	}
+	sort.SliceStable(slots, func(i, j int) bool {
+		di := (int(recurrenceWeekdays[slots[i].Weekday]) + 6) % 7
+		dj := (int(recurrenceWeekdays[slots[j].Weekday]) + 6) % 7
+		if di != dj {
+			return di < dj
+		}
+		return slots[i].Time < slots[j].Time
+	})
+	cfg.Slots = slots
+
+	spacing := map[string]int{}
+	for p, m := range cfg.SpacingMinutes {
+		norm := normalizePostProviders([]string{p})
+		if len(norm) != 1 {
+			return fmt.Errorf("invalid spacing provider %q", p)
+		}
+		if m < 0 || m > postingQueueMaxSpacing {
+			return fmt.Errorf("invalid spacing for %s (0-%d minutes)", norm[0], postingQueueMaxSpacing)
+		}
+		if m > 0 {
+			spacing[norm[0]] = m
+		}
+	}
+	cfg.SpacingMinutes = spacing
+	if len(spacing) == 0 {
+		cfg.SpacingMinutes = nil
+	}
+	return nil
+}
+
+// slotTimes lists slot occurrences strictly after `after`, in time order, for the given number of weeks.
+func (cfg postingQueueConfig) slotTimes(after time.Time, weeks int) []queueSlotTime {
+	loc, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := after.In(loc)
+	out := make([]queueSlotTime, 0)
+	for d := 0; d <= weeks*7; d++ {
+		day := time.Date(local.Year(), local.Month(), local.Day()+d, 0, 0, 0, 0, loc)
+		for _, s := range cfg.Slots {
+			if recurrenceWeekdays[s.Weekday] != day.Weekday() {
+				continue
+			}
+			tod, err := time.Parse("15:04", s.Time)
+			if err != nil {
+				continue
+			}
+			at := resolveLocalTime(day.Year(), day.Month(), day.Day(), tod.Hour(), tod.Minute(), 0, loc)
+			if !at.After(after) {
+				continue
+			}
+			out = append(out, queueSlotTime{At: at.UTC(), Providers: s.Providers})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].At.Before(out[j].At) })
+	return out
+}
+
+// slotFits reports whether a post targeting providers may use a slot restricted to slotProviders.
+func slotFits(slotProviders, providers []string) bool {
+	if len(slotProviders) == 0 {
+		return true
+	}
+	allowed := map[string]bool{}
+	for _, p := range slotProviders {
+		allowed[p] = true
+	}
+	for _, p := range providers {
+		if !allowed[p] {
+			return false
+		}
+	}
+	return len(providers) > 0
+}
+
+// pickQueueSlot returns the first free slot the post fits in and marks it taken.
+func pickQueueSlot(slots []queueSlotTime, taken map[int64]bool, providers []string) (time.Time, bool) {
+	for _, s := range slots {
+		if taken[s.At.Unix()] || !slotFits(s.Providers, providers) {
+			continue
+		}
+		taken[s.At.Unix()] = true
+		return s.At, true
+	}
+	return time.Time{}, false
+}
+
+// providerSpacingClear is a SQL condition that holds when none of the post's providers had a publish job for
+// the user within that provider's posting_queue spacingMinutes (always true without spacing configured).
+func providerSpacingClear(userExpr, providersExpr string) string {
+	return fmt.Sprintf(`NOT EXISTS (
+		SELECT 1
+		  FROM public.user_settings qs
+		 CROSS JOIN LATERAL jsonb_each_text(
+		         CASE WHEN jsonb_typeof(qs.value->'spacingMinutes') = 'object' THEN qs.value->'spacingMinutes' ELSE '{}'::jsonb END
+		       ) sp(provider, minutes)
+		  JOIN public.publish_jobs pj
+		    ON pj.user_id = qs.user_id
+		   AND sp.provider = ANY(pj.providers)
+		   AND pj.created_at > NOW() - ((CASE WHEN sp.minutes ~ '^[0-9]{1,5}$' THEN sp.minutes::int ELSE 0 END) * INTERVAL '1 minute')
+		 WHERE qs.user_id = %s
+		   AND qs.key = 'posting_queue'
+		   AND sp.provider = ANY(%s)
+	)`, userExpr, providersExpr)
+}
+
+// loadPostingQueue returns the user's queue config (ok=false when none is configured).
+func (h *Handler) loadPostingQueue(ctx context.Context, userID string) (postingQueueConfig, bool, error) {
+	var cfg postingQueueConfig
+	var raw []byte
+	err := h.db.QueryRowContext(ctx, `SELECT value FROM public.user_settings WHERE user_id=$1 AND key=$2`, userID, postingQueueSettingKey).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return cfg, false, nil
+	}
+	if err != nil {
+		return cfg, false, err
+	}
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return cfg, false, nil
+	}
+	if err := normalizePostingQueue(&cfg); err != nil {
+		return cfg, false, nil
+	}
+	return cfg, true, nil
+}
+
+// takenQueueTimes returns the future scheduled times already used by the user's posts, optionally ignoring
+// queued posts (which get re-placed) and one post id.
+func (h *Handler) takenQueueTimes(ctx context.Context, userID, excludePostID string, includeQueued bool) (map[int64]bool, error) {
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT scheduled_for
+		  FROM public.posts
+		 WHERE user_id = $1
+		   AND status = 'scheduled'
+		   AND scheduled_for > NOW()
+		   AND id <> $2
+		   AND ($3 OR NOT in_queue)
+	`, userID, excludePostID, includeQueued)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	taken := map[int64]bool{}
+	for rows.Next() {
+		var t time.Time
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		taken[t.Unix()] = true
+	}
+	return taken, rows.Err()
+}
+
+// reshufflePostingQueue packs the user's queued posts (in their current order) into the earliest free slots,
+// closing gaps left by removed posts. Posts with an exact time picked by the user are never moved.
+func (h *Handler) reshufflePostingQueue(ctx context.Context, userID string) (int, error) {
+	if h == nil || h.db == nil || strings.TrimSpace(userID) == "" {
+		return 0, nil
+	}
+	cfg, ok, err := h.loadPostingQueue(ctx, userID)
+	if err != nil || !ok || len(cfg.Slots) == 0 {
+		return 0, err
+	}
+
+	type queued struct {
+		id        string
+		providers []string
+		at        time.Time
+	}
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT id, COALESCE(providers, ARRAY[]::text[]), scheduled_for
+		  FROM public.posts
+		 WHERE user_id = $1
+		   AND in_queue
+		   AND status = 'scheduled'
+		   AND last_publish_job_id IS NULL
+		   AND scheduled_for > NOW()
+		 ORDER BY scheduled_for ASC, created_at ASC
+	`, userID)
+	if err != nil {
+		return 0, err
+	}
+	posts := make([]queued, 0)
+	for rows.Next() {
+		var q queued
+		if err := rows.Scan(&q.id, pq.Array(&q.providers), &q.at); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		posts = append(posts, q)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(posts) == 0 {
+		return 0, nil
+	}
+
+	taken, err := h.takenQueueTimes(ctx, userID, "", false)
+	if err != nil {
+		return 0, err
+	}
+	slots := cfg.slotTimes(time.Now(), postingQueueHorizonWeeks)
+	moved := 0
+	for _, q := range posts {
+		at, ok := pickQueueSlot(slots, taken, q.providers)
+		if !ok || at.Equal(q.at) {
+			taken[q.at.Unix()] = true
+			continue
+		}
+		res, err := h.db.ExecContext(ctx, `
+			UPDATE public.posts
+			   SET scheduled_for = $3, updated_at = NOW()
+			 WHERE id = $1 AND user_id = $2 AND in_queue AND last_publish_job_id IS NULL
+		`, q.id, userID, at)
+		if err != nil {
+			return moved, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			moved++
+			h.emitEvent(userID, realtimeEvent{Type: "post.updated", PostID: q.id, Status: "rescheduled"})
+		}
+	}
+	if moved > 0 {
+		log.Printf("[PostingQueue] reshuffled userId=%s queued=%d moved=%d", userID, len(posts), moved)
+	}
+	return moved, nil
+}
+
+// GetPostingQueueForUser returns the user's posting slots and the upcoming slot times with the post in each.
+//
+// URL: GET /api/posting-queue/user/{userId}?limit=50
+func (h *Handler) GetPostingQueueForUser(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	userID := strings.TrimSpace(pathVar(r, "userId"))
+	if userID == "" {
+		writeError(w, http.StatusBadRequest, "userId is required")
+		return
+	}
+	limit := parseLimit(r, 50, 1, 500)
+	if limit < 0 {
+		writeError(w, http.StatusBadRequest, "invalid limit")
+		return
+	}
+	cfg, ok, err := h.loadPostingQueue(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !ok {
+		cfg = postingQueueConfig{Timezone: "UTC", Slots: []postingQueueSlot{}}
+	}
+
+	type upcomingSlot struct {
+		At        time.Time `json:"at"`
+		Providers []string  `json:"providers,omitempty"`
+		PostID    string    `json:"postId,omitempty"`
+		Queued    bool      `json:"queued,omitempty"`
+	}
+	upcoming := make([]upcomingSlot, 0)
+	slots := cfg.slotTimes(time.Now(), postingQueueHorizonWeeks)
+	if len(slots) > limit {
+		slots = slots[:limit]
+	}
+	if len(slots) > 0 {
+		rows, err := h.db.QueryContext(r.Context(), `
+			SELECT id, scheduled_for, in_queue
+			  FROM public.posts
+			 WHERE user_id = $1
+			   AND status = 'scheduled'
+			   AND scheduled_for > NOW()
+			   AND scheduled_for <= $2
+		`, userID, slots[len(slots)-1].At)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		defer rows.Close()
+		type occupant struct {
+			id     string
+			queued bool
+		}
+		byTime := map[int64]occupant{}
+		for rows.Next() {
+			var (
+				id     string
+				at     time.Time
+				queued bool
+			)
+			if err := rows.Scan(&id, &at, &queued); err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			byTime[at.Unix()] = occupant{id: id, queued: queued}
+		}
+		if err := rows.Err(); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for _, s := range slots {
+			u := upcomingSlot{At: s.At, Providers: s.Providers}
+			if o, ok := byTime[s.At.Unix()]; ok {
+				u.PostID = o.id
+				u.Queued = o.queued
+			}
+			upcoming = append(upcoming, u)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"config":   cfg,
+		"upcoming": upcoming,
+	})
+}
+
+// PutPostingQueueForUser replaces the user's posting slots/spacing and re-packs queued posts into them.
+//
+// URL: PUT /api/posting-queue/user/{userId}
+// Body: {"timezone":"Europe/Berlin","slots":[{"weekday":"MO","time":"09:00"}],"spacingMinutes":{"instagram":60}}
+func (h *Handler) PutPostingQueueForUser(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPut) {
+		return
+	}
+	userID := strings.TrimSpace(pathVar(r, "userId"))
+	if userID == "" {
+		writeError(w, http.StatusBadRequest, "userId is required")
+		return
+	}
+	var cfg postingQueueConfig
+	if err := decodeJSON(r, &cfg); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+	if strings.TrimSpace(cfg.Timezone) == "" {
+		// Slots are wall-clock times; default to the user's scheduling timezone.
+		if prefs, err := h.loadSchedulingPreferences(r.Context(), userID); err == nil {
+			cfg.Timezone = prefs.Timezone
+		}
+	}
+	if err := normalizePostingQueue(&cfg); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	raw, _ := json.Marshal(cfg)
+	if _, err := h.db.ExecContext(r.Context(), `
+		INSERT INTO public.user_settings (user_id, key, value, updated_at)
+		VALUES ($1, $2, $3::jsonb, NOW())
+		ON CONFLICT (user_id, key) DO UPDATE SET value = EXCLUDED.value, updated_at = NOW()
+	`, userID, postingQueueSettingKey, string(raw)); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	moved, err := h.reshufflePostingQueue(r.Context(), userID)
+	if err != nil {
+		log.Printf("[PostingQueue] reshuffle_failed userId=%s err=%v", userID, err)
+	}
+	log.Printf("[PostingQueue] saved userId=%s slots=%d spacing=%v moved=%d", userID, len(cfg.Slots), cfg.SpacingMinutes, moved)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"ok": true, "config": cfg, "moved": moved})
+}
+
+// AddPostToQueueForUser schedules a draft into the next free posting slot that fits its providers.
+//
+// URL: POST /api/posts/{postId}/queue/user/{userId}
+func (h *Handler) AddPostToQueueForUser(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	userID := strings.TrimSpace(pathVar(r, "userId"))
+	postID := strings.TrimSpace(pathVar(r, "postId"))
+	if userID == "" || postID == "" {
+		writeError(w, http.StatusBadRequest, "userId and postId are required")
+		return
+	}
+	ctx := r.Context()
+
+	var (
+		status     string
+		content    sql.NullString
+		providers  []string
+		media      []string
+		lastJob    sql.NullString
+		recurrence []byte
+	)
+	err := h.db.QueryRowContext(ctx, `
+		SELECT status, content, COALESCE(providers, ARRAY[]::text[]), COALESCE(media, ARRAY[]::text[]),
+		       last_publish_job_id, recurrence
+		  FROM public.posts
+		 WHERE id = $1 AND user_id = $2
+	`, postID, userID).Scan(&status, &content, pq.Array(&providers), pq.Array(&media), &lastJob, &recurrence)
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if status == "published" || lastJob.Valid {
+		writeError(w, http.StatusConflict, "already_published_or_processing")
+		return
+	}
+	if len(recurrence) > 0 {
+		writeError(w, http.StatusBadRequest, "recurring_post")
+		return
+	}
+	if reason := scheduledPublishProblem(content.String, providers, media); reason != "" {
+		writeError(w, http.StatusBadRequest, reason)
+		return
+	}
+
+	cfg, ok, err := h.loadPostingQueue(ctx, userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !ok || len(cfg.Slots) == 0 {
+		writeError(w, http.StatusBadRequest, "no_queue_slots")
+		return
+	}
+	taken, err := h.takenQueueTimes(ctx, userID, postID, true)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	at, ok := pickQueueSlot(cfg.slotTimes(time.Now(), postingQueueHorizonWeeks), taken, providers)
+	if !ok {
+		writeError(w, http.StatusConflict, "queue_full")
+		return
+	}
+
+	res, err := h.db.ExecContext(ctx, `
+		UPDATE public.posts
+		   SET status = 'scheduled',
+		       scheduled_for = $3,
+		       in_queue = TRUE,
+		       last_publish_status = NULL,
+		       last_publish_error = NULL,
+		       last_publish_attempt_at = NULL,
+		       updated_at = NOW()
+		 WHERE id = $1 AND user_id = $2 AND last_publish_job_id IS NULL AND published_at IS NULL
+	`, postID, userID, at)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		writeError(w, http.StatusConflict, "already_published_or_processing")
+		return
+	}
+	log.Printf("[PostingQueue] queued postId=%s userId=%s at=%s providers=%v", postID, userID, at.Format(time.RFC3339), providers)
+	h.emitEvent(userID, realtimeEvent{Type: "post.updated", PostID: postID, Status: "scheduled"})
+	writeJSON(w, http.StatusOK, map[string]interface{}{"ok": true, "postId": postID, "scheduledFor": at})
+}
+
+// RemovePostFromQueueForUser moves a queued post back to drafts and re-packs the rest of the queue.
+//
+// URL: DELETE /api/posts/{postId}/queue/user/{userId}
+func (h *Handler) RemovePostFromQueueForUser(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodDelete) {
+		return
+	}
+	userID := strings.TrimSpace(pathVar(r, "userId"))
+	postID := strings.TrimSpace(pathVar(r, "postId"))
+	if userID == "" || postID == "" {
+		writeError(w, http.StatusBadRequest, "userId and postId are required")
+		return
+	}
+	res, err := h.db.ExecContext(r.Context(), `
+		UPDATE public.posts
+		   SET status = 'draft',
+		       scheduled_for = NULL,
+		       in_queue = FALSE,
+		       updated_at = NOW()
+		 WHERE id = $1 AND user_id = $2 AND in_queue AND last_publish_job_id IS NULL
+	`, postID, userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		writeError(w, http.StatusNotFound, "not_queued")
+		return
+	}
+	moved, err := h.reshufflePostingQueue(r.Context(), userID)
+	if err != nil {
+		log.Printf("[PostingQueue] reshuffle_failed userId=%s err=%v", userID, err)
+	}
+	h.emitEvent(userID, realtimeEvent{Type: "post.updated", PostID: postID, Status: "draft"})
+	writeJSON(w, http.StatusOK, map[string]interface{}{"ok": true, "postId": postID, "moved": moved})
+}
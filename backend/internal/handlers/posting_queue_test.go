@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+)
+
+func everyDayQueue(t *testing.T, tod string) (postingQueueConfig, []byte) {
+	t.Helper()
+	cfg := postingQueueConfig{Timezone: "UTC"}
+	for _, d := range []string{"MO", "TU", "WE", "TH", "FR", "SA", "SU"} {
+		cfg.Slots = append(cfg.Slots, postingQueueSlot{Weekday: d, Time: tod})
+	}
+	if err := normalizePostingQueue(&cfg); err != nil {
+		t.Fatalf("normalizePostingQueue: %v", err)
+	}
+	raw, _ := json.Marshal(cfg)
+	return cfg, raw
+}
+
+func TestNormalizePostingQueue_SortsDedupesAndValidates(t *testing.T) {
+	cfg := postingQueueConfig{
+		Slots: []postingQueueSlot{
+			{Weekday: "friday", Time: "9:05"},
+			{Weekday: "MO", Time: "18:00", Providers: []string{" Instagram ", "facebook"}},
+			{Weekday: "mo", Time: "08:30"},
+			{Weekday: "FR", Time: "09:05"},
+		},
+		SpacingMinutes: map[string]int{"Instagram": 60, "facebook": 0},
+	}
+	if err := normalizePostingQueue(&cfg); err != nil {
+		t.Fatalf("normalizePostingQueue: %v", err)
+	}
+	if cfg.Timezone != "UTC" || len(cfg.Slots) != 3 {
+		t.Fatalf("unexpected config %#v", cfg)
+	}
+	if cfg.Slots[0].Time != "08:30" || cfg.Slots[1].Weekday != "MO" || cfg.Slots[2].Weekday != "FR" || cfg.Slots[2].Time != "09:05" {
+		t.Fatalf("unexpected slot order %#v", cfg.Slots)
+	}
+	if strings.Join(cfg.Slots[1].Providers, ",") != "facebook,instagram" {
+		t.Fatalf("unexpected slot providers %v", cfg.Slots[1].Providers)
+	}
+	if len(cfg.SpacingMinutes) != 1 || cfg.SpacingMinutes["instagram"] != 60 {
+		t.Fatalf("unexpected spacing %v", cfg.SpacingMinutes)
+	}
+
+	for _, bad := range []postingQueueConfig{
+		{Timezone: "Mars/Olympus"},
+		{Slots: []postingQueueSlot{{Weekday: "XX", Time: "09:00"}}},
+		{Slots: []postingQueueSlot{{Weekday: "MO", Time: "25:00"}}},
+		{SpacingMinutes: map[string]int{"instagram": -5}},
+	} {
+		if err := normalizePostingQueue(&bad); err == nil {
+			t.Fatalf("expected error for %#v", bad)
+		}
+	}
+}
+
+func TestPostingQueueSlotTimes_TimezoneAndProviderFit(t *testing.T) {
+	cfg := postingQueueConfig{
+		Timezone: "America/New_York",
+		Slots: []postingQueueSlot{
+			{Weekday: "MO", Time: "09:00", Providers: []string{"pinterest"}},
+			{Weekday: "MO", Time: "12:00"},
+		},
+	}
+	if err := normalizePostingQueue(&cfg); err != nil {
+		t.Fatalf("normalizePostingQueue: %v", err)
+	}
+	// Sunday 2026-03-08 (DST starts in New York that morning).
+	slots := cfg.slotTimes(time.Date(2026, 3, 8, 12, 0, 0, 0, time.UTC), 1)
+	if len(slots) != 2 || !slots[0].At.Equal(time.Date(2026, 3, 9, 13, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected slots %#v", slots)
+	}
+
+	taken := map[int64]bool{}
+	at, ok := pickQueueSlot(slots, taken, []string{"instagram"})
+	if !ok || !at.Equal(slots[1].At) {
+		t.Fatalf("expected instagram post to skip pinterest-only slot, got %v ok=%v", at, ok)
+	}
+	at, ok = pickQueueSlot(slots, taken, []string{"pinterest"})
+	if !ok || !at.Equal(slots[0].At) {
+		t.Fatalf("expected pinterest slot, got %v ok=%v", at, ok)
+	}
+	if _, ok := pickQueueSlot(slots, taken, []string{"pinterest"}); ok {
+		t.Fatalf("expected queue to be full")
+	}
+}
+
+func TestAddPostToQueueForUser_UsesNextFreeSlot(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	cfg, raw := everyDayQueue(t, "09:00")
+	slots := cfg.slotTimes(time.Now(), 1)
+
+	mock.ExpectQuery(`SELECT status, content, COALESCE\(providers`).
+		WithArgs("p1", "u1").
+		WillReturnRows(sqlmock.NewRows([]string{"status", "content", "providers", "media", "last_publish_job_id", "recurrence"}).
+			AddRow("draft", "hello", "{facebook}", "{}", nil, nil))
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings`).
+		WithArgs("u1", postingQueueSettingKey).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(raw))
+	mock.ExpectQuery(`SELECT scheduled_for\s+FROM public\.posts`).
+		WithArgs("u1", "p1", true).
+		WillReturnRows(sqlmock.NewRows([]string{"scheduled_for"}).AddRow(slots[0].At))
+	mock.ExpectExec(`UPDATE public\.posts\s+SET status = 'scheduled'`).
+		WithArgs("p1", "u1", slots[1].At).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/posts/p1/queue/user/u1", nil)
+	req = mux.SetURLVars(req, map[string]string{"userId": "u1", "postId": "p1"})
+	h.AddPostToQueueForUser(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), slots[1].At.Format(time.RFC3339)) {
+		t.Fatalf("expected second slot, got %d %s", rr.Code, rr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestAddPostToQueueForUser_RequiresSlots(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	mock.ExpectQuery(`SELECT status, content, COALESCE\(providers`).
+		WithArgs("p1", "u1").
+		WillReturnRows(sqlmock.NewRows([]string{"status", "content", "providers", "media", "last_publish_job_id", "recurrence"}).
+			AddRow("draft", "hello", "{facebook}", "{}", nil, nil))
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings`).
+		WithArgs("u1", postingQueueSettingKey).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}))
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/posts/p1/queue/user/u1", nil)
+	req = mux.SetURLVars(req, map[string]string{"userId": "u1", "postId": "p1"})
+	h.AddPostToQueueForUser(rr, req)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "no_queue_slots") {
+		t.Fatalf("expected no_queue_slots, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestReshufflePostingQueue_ClosesGaps(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	cfg, raw := everyDayQueue(t, "10:00")
+	slots := cfg.slotTimes(time.Now(), 1)
+
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings`).
+		WithArgs("u1", postingQueueSettingKey).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(raw))
+	mock.ExpectQuery(`SELECT id, COALESCE\(providers, ARRAY\[\]::text\[\]\), scheduled_for`).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "providers", "scheduled_for"}).
+			AddRow("p1", "{instagram}", slots[1].At).
+			AddRow("p2", "{instagram}", slots[3].At))
+	// A post pinned to an exact time keeps its slot.
+	mock.ExpectQuery(`SELECT scheduled_for\s+FROM public\.posts`).
+		WithArgs("u1", "", false).
+		WillReturnRows(sqlmock.NewRows([]string{"scheduled_for"}).AddRow(slots[0].At))
+	mock.ExpectExec(`UPDATE public\.posts\s+SET scheduled_for = \$3`).
+		WithArgs("p2", "u1", slots[2].At).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	moved, err := h.reshufflePostingQueue(t.Context(), "u1")
+	if err != nil || moved != 1 {
+		t.Fatalf("expected one move, got moved=%d err=%v", moved, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestProcessDueScheduledPostsOnce_HonorsProviderSpacing(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	mock.ExpectQuery(`(?s)FROM public\.posts\s+WHERE status = 'scheduled'.*jsonb_each_text.*qs\.user_id = posts\.user_id.*ANY\(posts\.providers\)`).
+		WithArgs(50).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "scheduled_for"}))
+	mock.ExpectQuery(`SELECT id, user_id, scheduled_for, recurrence`).
+		WithArgs(50).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "scheduled_for", "recurrence"}))
+	mock.ExpectQuery(`(?s)FROM public\.post_occurrences o.*jsonb_each_text.*ANY\(COALESCE\(o\.providers, p\.providers\)\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "post_id", "user_id", "occurrence_at"}))
+
+	_, _ = h.processDueScheduledPostsOnce(t.Context(), "http://localhost", 50, nil)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
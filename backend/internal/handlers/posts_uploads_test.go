@@ -32,9 +32,9 @@ func TestListPostsForUser_NoStatus(t *testing.T) {
 		"id", "teamId", "userId", "content", "status", "providers", "media",
 		"scheduledFor", "publishedAt",
 		"lastPublishJobId", "lastPublishStatus", "lastPublishError", "lastPublishAttemptAt",
-		"createdAt", "updatedAt",
+		"createdAt", "updatedAt", "recurrence", "variants", "options",
 	}).
-		AddRow("p1", "", "u1", sql.NullString{Valid: true, String: "hi"}, "draft", pq.StringArray{"instagram"}, pq.StringArray{}, sql.NullTime{}, sql.NullTime{}, sql.NullString{}, sql.NullString{}, sql.NullString{}, sql.NullTime{}, now, now)
+		AddRow("p1", "", "u1", sql.NullString{Valid: true, String: "hi"}, "draft", pq.StringArray{"instagram"}, pq.StringArray{}, sql.NullTime{}, sql.NullTime{}, sql.NullString{}, sql.NullString{}, sql.NullString{}, sql.NullTime{}, now, now, nil, nil, nil)
 
 	mock.ExpectQuery(`FROM public\.posts\s+WHERE user_id = \$1`).
 		WithArgs("u1", 200).
@@ -71,9 +71,9 @@ func TestListPostsForUser_WithStatus(t *testing.T) {
 		"id", "teamId", "userId", "content", "status", "providers", "media",
 		"scheduledFor", "publishedAt",
 		"lastPublishJobId", "lastPublishStatus", "lastPublishError", "lastPublishAttemptAt",
-		"createdAt", "updatedAt",
+		"createdAt", "updatedAt", "recurrence", "variants", "options",
 	}).
-		AddRow("p2", "", "u1", sql.NullString{Valid: false}, "scheduled", pq.StringArray{"facebook"}, pq.StringArray{}, sql.NullTime{}, sql.NullTime{}, sql.NullString{}, sql.NullString{}, sql.NullString{}, sql.NullTime{}, now, now)
+		AddRow("p2", "", "u1", sql.NullString{Valid: false}, "scheduled", pq.StringArray{"facebook"}, pq.StringArray{}, sql.NullTime{}, sql.NullTime{}, sql.NullString{}, sql.NullString{}, sql.NullString{}, sql.NullTime{}, now, now, nil, nil, nil)
 
 	mock.ExpectQuery(`FROM public\.posts\s+WHERE user_id = \$1 AND status = \$2`).
 		WithArgs("u1", "scheduled", 200).
@@ -154,14 +154,14 @@ func TestCreatePostForUser_Success(t *testing.T) {
 	now := time.Now().UTC()
 
 	mock.ExpectQuery(`INSERT INTO public\.posts`).
-		WithArgs(id, "u1", &content, status, sqlmock.AnyArg(), sqlmock.AnyArg(), (*time.Time)(nil), (*time.Time)(nil)).
+		WithArgs(id, "u1", &content, status, sqlmock.AnyArg(), sqlmock.AnyArg(), (*time.Time)(nil), (*time.Time)(nil), nil, nil, nil).
 		WillReturnRows(sqlmock.NewRows([]string{
 			"id", "teamId", "userId", "content", "status", "providers", "media",
 			"scheduledFor", "publishedAt",
 			"lastPublishJobId", "lastPublishStatus", "lastPublishError", "lastPublishAttemptAt",
-			"createdAt", "updatedAt",
+			"createdAt", "updatedAt", "recurrence", "variants", "options",
 		}).
-			AddRow(id, "", "u1", sql.NullString{Valid: true, String: content}, status, pq.StringArray{}, pq.StringArray{}, sql.NullTime{}, sql.NullTime{}, sql.NullString{}, sql.NullString{}, sql.NullString{}, sql.NullTime{}, now, now))
+			AddRow(id, "", "u1", sql.NullString{Valid: true, String: content}, status, pq.StringArray{}, pq.StringArray{}, sql.NullTime{}, sql.NullTime{}, sql.NullString{}, sql.NullString{}, sql.NullString{}, sql.NullTime{}, now, now, nil, nil, nil))
 
 	body, _ := json.Marshal(map[string]any{"id": id, "content": content, "status": status})
 	rr := httptest.NewRecorder()
@@ -213,14 +213,14 @@ func TestUpdatePostForUser_NotFoundAndSuccess(t *testing.T) {
 		now := time.Now().UTC()
 
 		mock.ExpectQuery(`UPDATE public\.posts`).
-			WithArgs("p1", "u1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WithArgs("p1", "u1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil, nil, nil).
 			WillReturnRows(sqlmock.NewRows([]string{
 				"id", "teamId", "userId", "content", "status", "providers", "media",
 				"scheduledFor", "publishedAt",
 				"lastPublishJobId", "lastPublishStatus", "lastPublishError", "lastPublishAttemptAt",
-				"createdAt", "updatedAt",
+				"createdAt", "updatedAt", "recurrence", "variants", "options",
 			}).
-				AddRow("p1", "", "u1", sql.NullString{Valid: true, String: newContent}, newStatus, pq.StringArray{"instagram"}, pq.StringArray{}, sql.NullTime{Valid: true, Time: when}, sql.NullTime{}, sql.NullString{}, sql.NullString{}, sql.NullString{}, sql.NullTime{}, now, now))
+				AddRow("p1", "", "u1", sql.NullString{Valid: true, String: newContent}, newStatus, pq.StringArray{"instagram"}, pq.StringArray{}, sql.NullTime{Valid: true, Time: when}, sql.NullTime{}, sql.NullString{}, sql.NullString{}, sql.NullString{}, sql.NullTime{}, now, now, nil, nil, nil))
 
 		body, _ := json.Marshal(map[string]any{"content": newContent, "status": newStatus, "scheduledFor": when})
 		rr := httptest.NewRecorder()
@@ -2,10 +2,14 @@ package handlers
 
 import (
 	"bytes"
+	"context"
+	"database/sql/driver"
 	"encoding/json"
+	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -76,6 +80,27 @@ func TestParsePublishPostRequest_Multipart_CommaSeparatedProviders(t *testing.T)
 	}
 }
 
+func TestParsePublishPostRequest_MultipartOptions(t *testing.T) {
+	parse := func(options string) (publishPostRequest, error) {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		_ = mw.WriteField("caption", "hello")
+		_ = mw.WriteField("options", options)
+		_ = mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/x", &buf)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		parsed, _, err := parsePublishPostRequest(req)
+		return parsed, err
+	}
+	parsed, err := parse(`{"facebook":{"unpublished":true}}`)
+	if err != nil || parsed.Options == nil || parsed.Options.Facebook == nil || !parsed.Options.Facebook.Unpublished {
+		t.Fatalf("unexpected parsed options %+v err=%v", parsed.Options, err)
+	}
+	if _, err := parse(`{`); err == nil {
+		t.Fatalf("expected invalid options to be rejected")
+	}
+}
+
 func TestParsePublishPostRequest_JSON_Invalid(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/x", bytes.NewBufferString("{"))
 	req.Header.Set("Content-Type", "application/json")
@@ -103,19 +128,7 @@ func TestEnqueuePublishJobForUser_Success_UnknownProvider(t *testing.T) {
 		WithArgs(sqlmock.AnyArg(), "u1", sqlmock.AnyArg(), "cap", sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	// Background job updates (unknown provider => no provider calls)
-	mock.ExpectExec(`UPDATE public\.publish_jobs.*status='running'`).
-		WithArgs(sqlmock.AnyArg()).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectExec(`UPDATE public\.posts.*last_publish_status='running'`).
-		WithArgs(sqlmock.AnyArg()).
-		WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectExec(`UPDATE public\.publish_jobs.*SET status=\$2`).
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectExec(`UPDATE public\.posts.*SET last_publish_status=\$2`).
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
-		WillReturnResult(sqlmock.NewResult(0, 0))
+	// The job is only enqueued here; the worker pool claims and runs it.
 
 	rr := httptest.NewRecorder()
 	body := `{"caption":"cap","providers":["unknown"],"dryRun":true}`
@@ -127,15 +140,104 @@ func TestEnqueuePublishJobForUser_Success_UnknownProvider(t *testing.T) {
 		t.Fatalf("expected 200 got %d body=%q", rr.Code, rr.Body.String())
 	}
 
-	// Give the goroutine time to run; it should complete quickly for "unknown" provider.
-	time.Sleep(25 * time.Millisecond)
-
 	var out map[string]any
 	_ = json.Unmarshal(rr.Body.Bytes(), &out)
 	if out["jobId"] == "" {
 		t.Fatalf("expected jobId in response got %#v", out)
 	}
+	select {
+	case <-h.publishWake:
+	default:
+		t.Fatalf("expected enqueue to wake a publish worker")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+// captureArg matches any value and keeps it, for asserting on JSON written to the database.
+type captureArg struct{ v *string }
+
+func (c captureArg) Match(v driver.Value) bool {
+	switch x := v.(type) {
+	case string:
+		*c.v = x
+	case []byte:
+		*c.v = string(x)
+	}
+	return true
+}
+
+func TestEnqueuePublishJobForUser_VariantsAndOptionsReachRunPublishJob(t *testing.T) {
+	tmp := t.TempDir()
+	cwd, _ := os.Getwd()
+	_ = os.Chdir(tmp)
+	defer func() { _ = os.Chdir(cwd) }()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
 
+	var reqJSON string
+	mock.ExpectExec(`INSERT INTO public\.publish_jobs`).
+		WithArgs(sqlmock.AnyArg(), "u1", sqlmock.AnyArg(), "cap", captureArg{&reqJSON}, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	body := `{"caption":"cap","providers":["facebook"],"facebookPageIds":["pg1"],
+		"variants":{"Facebook":{"caption":" FB caption "}},"options":{"facebook":{"unpublished":true}}}`
+	req := httptest.NewRequest(http.MethodPost, "/api/social-posts/publish-async/user/u1", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"userId": "u1"})
+	rr := httptest.NewRecorder()
+	h.EnqueuePublishJobForUser(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d body=%q", rr.Code, rr.Body.String())
+	}
+
+	// The worker rebuilds the request from request_json alone.
+	var job publishJobRequest
+	if err := json.Unmarshal([]byte(reqJSON), &job); err != nil {
+		t.Fatalf("request_json: %v (%s)", err, reqJSON)
+	}
+
+	mock.ExpectExec(`UPDATE public\.publish_jobs.*status='running'`).
+		WithArgs("job1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE public\.posts.*last_publish_status='running'`).
+		WithArgs("job1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	raw, _ := json.Marshal(fbOAuthPayload{Pages: []fbOAuthPageRow{{ID: "pg1", AccessToken: "ptok", Tasks: []string{"CREATE_CONTENT"}}}})
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings.*key='facebook_oauth'`).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(raw))
+	// Unpublished posts are not added to the library.
+	mock.ExpectExec(`UPDATE public\.publish_jobs.*SET status=\$2`).
+		WithArgs("job1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "w1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE public\.posts.*SET last_publish_status=\$2`).
+		WithArgs("job1", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	var form url.Values
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		if r.URL.Host == "graph.facebook.com" && strings.HasSuffix(r.URL.Path, "/feed") {
+			b, _ := io.ReadAll(r.Body)
+			form, _ = url.ParseQuery(string(b))
+			return httpJSON(200, `{"id":"pg1_1"}`, nil), nil
+		}
+		return httpJSON(404, `{}`, nil), nil
+	}}
+
+	h.runPublishJob(context.Background(), "job1", "w1", "u1", "cap", job.publishPostRequest, job.Media, job.PublicOrigin)
+	if form.Get("message") != "FB caption" || form.Get("published") != "false" {
+		t.Fatalf("expected the facebook variant and options to be applied, got %v", form)
+	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Fatalf("sql expectations: %v", err)
 	}
@@ -173,13 +275,13 @@ func TestRunPublishJob_TikTokDryRun(t *testing.T) {
 		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(raw))
 
 	mock.ExpectExec(`UPDATE public\.publish_jobs.*SET status=\$2`).
-		WithArgs("job1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs("job1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "w1").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectExec(`UPDATE public\.posts.*SET last_publish_status=\$2`).
 		WithArgs("job1", sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(0, 0))
 
-	h.runPublishJob("job1", "u1", "cap", publishPostRequest{Providers: []string{"tiktok"}, DryRun: true}, []string{"/media/uploads/u1/v.mp4"}, "https://app.test")
+	h.runPublishJob(context.Background(), "job1", "w1", "u1", "cap", publishPostRequest{Providers: []string{"tiktok"}, DryRun: true}, []string{"/media/uploads/u1/v.mp4"}, "https://app.test")
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Fatalf("sql expectations: %v", err)
@@ -218,7 +320,7 @@ func TestRunPublishJob_FacebookCaptionOnly_Success(t *testing.T) {
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	mock.ExpectExec(`UPDATE public\.publish_jobs.*SET status=\$2`).
-		WithArgs("job1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs("job1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "w1").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectExec(`UPDATE public\.posts.*SET last_publish_status=\$2`).
 		WithArgs("job1", sqlmock.AnyArg(), sqlmock.AnyArg()).
@@ -233,7 +335,7 @@ func TestRunPublishJob_FacebookCaptionOnly_Success(t *testing.T) {
 		return httpJSON(404, `{"error":"not_found"}`, nil), nil
 	}}
 
-	h.runPublishJob("job1", "u1", "cap", publishPostRequest{Providers: []string{"facebook"}, FacebookPageIDs: []string{"pg1"}}, nil, "https://app.test")
+	h.runPublishJob(context.Background(), "job1", "w1", "u1", "cap", publishPostRequest{Providers: []string{"facebook"}, FacebookPageIDs: []string{"pg1"}}, nil, "https://app.test")
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Fatalf("sql expectations: %v", err)
@@ -287,7 +389,7 @@ func TestRunPublishJob_MultiProviders_DryRun(t *testing.T) {
 		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(pinRaw))
 
 	mock.ExpectExec(`UPDATE public\.publish_jobs.*SET status=\$2`).
-		WithArgs("job1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs("job1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "w1").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectExec(`UPDATE public\.posts.*SET last_publish_status=\$2`).
 		WithArgs("job1", sqlmock.AnyArg(), sqlmock.AnyArg()).
@@ -295,7 +397,7 @@ func TestRunPublishJob_MultiProviders_DryRun(t *testing.T) {
 
 	req := publishPostRequest{Providers: []string{"instagram", "tiktok", "youtube", "pinterest"}, DryRun: true}
 	rel := []string{"/media/uploads/u1/img.jpg", "/media/uploads/u1/vid.mp4"}
-	h.runPublishJob("job1", "u1", "cap", req, rel, "https://app.test")
+	h.runPublishJob(context.Background(), "job1", "w1", "u1", "cap", req, rel, "https://app.test")
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Fatalf("sql expectations: %v", err)
@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Per-provider retry policy inside a single publish job run.
+// Vars (not consts) so tests can shrink the delays.
+var (
+	publishProviderMaxAttempts = 3
+	publishRetryBaseDelay      = 2 * time.Second
+	publishRetryMaxDelay       = 30 * time.Second
+)
+
+// retryablePublishErrors are provider error codes that describe a transient condition on the network side.
+var retryablePublishErrors = map[string]bool{
+	"instagram_container_not_ready": true,
+	"threads_container_not_ready":   true,
+	"x_media_not_ready":             true,
+}
+
+// isRetryablePublishError reports whether a provider failure is worth retrying automatically:
+// transport errors/timeouts, HTTP 429/5xx, and known "not ready yet" states (Instagram containers stuck IN_PROGRESS).
+// Config/validation errors (not_connected, missing media, 4xx) are never retried.
+func isRetryablePublishError(err error, details map[string]interface{}) bool {
+	if err == nil {
+		return false
+	}
+	if retryablePublishErrors[err.Error()] {
+		return true
+	}
+	var ne net.Error
+	if errors.As(err, &ne) {
+		return true
+	}
+	retryableStatus := func(code int) bool {
+		return code == http.StatusTooManyRequests || code >= 500
+	}
+	if details == nil {
+		return false
+	}
+	if code, ok := details["status"].(int); ok && retryableStatus(code) {
+		return true
+	}
+	// Facebook reports per-page results; retry only when every page failed with a transient status.
+	if pages, ok := details["pages"]; ok {
+		b, _ := json.Marshal(pages)
+		var rows []struct {
+			Posted     bool `json:"posted"`
+			StatusCode int  `json:"statusCode"`
+		}
+		if json.Unmarshal(b, &rows) == nil && len(rows) > 0 {
+			for _, r := range rows {
+				if r.Posted || !retryableStatus(r.StatusCode) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// publishRetryDelay returns the exponential backoff (with up to 20% jitter) before retry number `attempt` (1-based).
+func publishRetryDelay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := publishRetryBaseDelay
+	for i := 1; i < attempt && d < publishRetryMaxDelay; i++ {
+		d *= 2
+	}
+	if d > publishRetryMaxDelay {
+		d = publishRetryMaxDelay
+	}
+	if d > 0 {
+		d += time.Duration(rand.Int63n(int64(d)/5 + 1))
+	}
+	return d
+}
+
+// publishWithRetry calls a provider publish function, retrying retryable failures with exponential backoff.
+// Nothing is retried once the provider reports a partial post, to avoid double-posting.
+// Successful and partial results are checkpointed into result_json so a re-run of the job (retry endpoint or
+// lease expiry) never posts twice: finished providers are skipped and partial posts are resumed or left alone
+// (see canResumePartialPublish).
+// The provider is not called (or retried) once ctx is cancelled, i.e. after workerID lost the job's lease.
+func (h *Handler) publishWithRetry(ctx context.Context, jobID, workerID, provider string, call func() (int, error, map[string]interface{})) (int, error, map[string]interface{}, int) {
+	posted, err, details, attempts := callWithRetry(ctx, jobID, provider, call)
+	if err == nil {
+		h.checkpointPublishResult(jobID, workerID, provider, publishProviderResult{OK: true, Posted: posted, Details: details, Attempts: attempts})
+	} else if posted > 0 {
+		// Partial post: keep which targets went out so a re-run doesn't post to them again.
+		h.checkpointPublishResult(jobID, workerID, provider, publishProviderResult{OK: false, Posted: posted, Error: err.Error(), Details: details, Attempts: attempts})
+	}
+	return posted, err, details, attempts
+}
+
+// callWithRetry is publishWithRetry without the checkpoint, for providers that publish in several calls and
+// checkpoint their combined progress themselves.
+func callWithRetry(ctx context.Context, jobID, provider string, call func() (int, error, map[string]interface{})) (int, error, map[string]interface{}, int) {
+	var (
+		posted  int
+		err     error
+		details map[string]interface{}
+	)
+	attempts := 0
+	for {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			if attempts == 0 {
+				return 0, ctxErr, nil, 0
+			}
+			break
+		}
+		attempts++
+		posted, err, details = call()
+		if err == nil || posted > 0 || attempts >= publishProviderMaxAttempts || !isRetryablePublishError(err, details) {
+			break
+		}
+		delay := publishRetryDelay(attempts)
+		log.Printf("[PublishJob] provider_retry jobId=%s provider=%s attempt=%d delay=%s err=%s", jobID, provider, attempts, delay, truncate(err.Error(), 200))
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+	}
+	return posted, err, details, attempts
+}
+
+// checkpointPublishResult merges one provider result into publish_jobs.result_json.results while workerID
+// still holds the job.
+func (h *Handler) checkpointPublishResult(jobID, workerID, provider string, res publishProviderResult) {
+	if h == nil || h.db == nil {
+		return
+	}
+	b, err := json.Marshal(res)
+	if err != nil {
+		return
+	}
+	_, _ = h.db.Exec(`
+		UPDATE public.publish_jobs
+		   SET result_json = COALESCE(result_json, '{}'::jsonb)
+		       || jsonb_build_object('results', COALESCE(result_json->'results', '{}'::jsonb) || jsonb_build_object($2::text, $3::jsonb)),
+		       updated_at = NOW()
+		 WHERE id = $1 AND locked_by = $4
+	`, jobID, provider, string(b), workerID)
+}
+
+// loadPreviousPublishResults returns the provider results checkpointed by earlier runs of this job.
+func (h *Handler) loadPreviousPublishResults(jobID string) map[string]publishProviderResult {
+	out := map[string]publishProviderResult{}
+	if h == nil || h.db == nil {
+		return out
+	}
+	var raw []byte
+	if err := h.db.QueryRow(`
+		SELECT COALESCE(result_json->'results', '{}'::jsonb)
+		  FROM public.publish_jobs
+		 WHERE id=$1
+	`, jobID).Scan(&raw); err != nil || len(raw) == 0 {
+		return out
+	}
+	var prev map[string]publishProviderResult
+	if err := json.Unmarshal(raw, &prev); err != nil {
+		return out
+	}
+	for p, r := range prev {
+		if p != "media" {
+			out[p] = r
+		}
+	}
+	return out
+}
+
+// canResumePartialPublish reports whether a re-run can finish provider's partial post (prev) without posting
+// anything twice: Facebook skips the pages, Instagram the Stories and X the thread parts already published.
+func canResumePartialPublish(provider string, prev publishProviderResult) bool {
+	switch provider {
+	case "facebook":
+		return len(facebookPostedPages(prev)) > 0
+	case "instagram":
+		return len(instagramPostedStories(prev)) > 0
+	case "x":
+		return len(xPostedTweets(prev)) > 0
+	}
+	return false
+}
+
+// facebookPostedPages returns the pages an earlier partial run of the job already posted to. A re-run
+// publishes only to the other pages and reports these alongside its own.
+func facebookPostedPages(prev publishProviderResult) []fbPageResult {
+	if prev.OK || prev.Posted == 0 {
+		return nil
+	}
+	var rows []fbPageResult
+	if b, err := json.Marshal(prev.Details["pages"]); err == nil {
+		_ = json.Unmarshal(b, &rows)
+	}
+	posted := make([]fbPageResult, 0, len(rows))
+	for _, r := range rows {
+		if r.Posted {
+			posted = append(posted, r)
+		}
+	}
+	return posted
+}
+
+// xPostedTweets returns the tweets (in thread order) an earlier run of the job posted before the thread broke
+// off. A re-run continues the thread from the last of them instead of posting it again.
+func xPostedTweets(prev publishProviderResult) []string {
+	if prev.OK || prev.Posted == 0 {
+		return nil
+	}
+	var ids []string
+	if b, err := json.Marshal(prev.Details["tweetIds"]); err == nil {
+		_ = json.Unmarshal(b, &ids)
+	}
+	return ids
+}
+
+// instagramPostedStories returns, by media index, the Stories an earlier run of the job already published.
+// A re-run publishes only the other media and reports these alongside its own.
+func instagramPostedStories(prev publishProviderResult) map[int]map[string]interface{} {
+	if prev.OK || prev.Posted == 0 || prev.Details["mediaType"] != "STORIES" {
+		return nil
+	}
+	var rows []map[string]interface{}
+	if b, err := json.Marshal(prev.Details["stories"]); err == nil {
+		_ = json.Unmarshal(b, &rows)
+	}
+	out := map[int]map[string]interface{}{}
+	for _, r := range rows {
+		idx, ok := r["index"].(float64)
+		if id, _ := r["publishedId"].(string); ok && id != "" {
+			out[int(idx)] = r
+		}
+	}
+	return out
+}
+
+// RetryPublishJobForUser re-queues a failed publish job. Providers that already succeeded, and partial posts
+// that can't be resumed, are skipped when the worker picks it up again, so only the failed providers are
+// re-attempted.
+func (h *Handler) RetryPublishJobForUser(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	jobID := strings.TrimSpace(pathVar(r, "jobId"))
+	userID := strings.TrimSpace(pathVar(r, "userId"))
+	if jobID == "" || userID == "" {
+		writeError(w, http.StatusBadRequest, "jobId and userId are required")
+		return
+	}
+
+	var resJSON []byte
+	err := h.db.QueryRowContext(r.Context(), `
+		UPDATE public.publish_jobs
+		   SET status = 'queued',
+		       error = NULL,
+		       finished_at = NULL,
+		       attempts = 0,
+		       locked_by = NULL,
+		       locked_until = NULL,
+		       updated_at = NOW()
+		 WHERE id = $1
+		   AND user_id = $2
+		   AND status = 'failed'
+		RETURNING COALESCE(result_json->'results', '{}'::jsonb)
+	`, jobID, userID).Scan(&resJSON)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			var status string
+			if err2 := h.db.QueryRowContext(r.Context(), `SELECT status FROM public.publish_jobs WHERE id=$1 AND user_id=$2`, jobID, userID).Scan(&status); err2 != nil {
+				if err2 == sql.ErrNoRows {
+					writeError(w, http.StatusNotFound, "not_found")
+					return
+				}
+				writeError(w, http.StatusInternalServerError, err2.Error())
+				return
+			}
+			writeJSON(w, http.StatusConflict, map[string]interface{}{"ok": false, "error": "job_not_failed", "status": status})
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	skip := make([]string, 0)
+	var prev map[string]publishProviderResult
+	if json.Unmarshal(resJSON, &prev) == nil {
+		for p, rr := range prev {
+			if p != "media" && (rr.OK || (rr.Posted > 0 && !canResumePartialPublish(p, rr))) {
+				skip = append(skip, p)
+			}
+		}
+	}
+	sort.Strings(skip)
+
+	_, _ = h.db.ExecContext(r.Context(), `
+		UPDATE public.posts
+		   SET last_publish_status = 'queued',
+		       last_publish_error = NULL,
+		       updated_at = NOW()
+		 WHERE last_publish_job_id = $1
+	`, jobID)
+	h.setOccurrenceJobStatus(r.Context(), []string{jobID}, "queued", "")
+
+	log.Printf("[PublishJob] retry_requested jobId=%s userId=%s skipProviders=%v", jobID, userID, skip)
+	h.emitEvent(userID, realtimeEvent{
+		Type:   "publish_job",
+		JobID:  jobID,
+		Status: "queued",
+		At:     time.Now().UTC().Format(time.RFC3339),
+	})
+	h.notifyPublishWorkers()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"ok":            true,
+		"jobId":         jobID,
+		"status":        "queued",
+		"skipProviders": skip,
+	})
+}
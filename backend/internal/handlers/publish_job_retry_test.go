@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+)
+
+type fakeTimeoutErr struct{}
+
+func (fakeTimeoutErr) Error() string   { return "i/o timeout" }
+func (fakeTimeoutErr) Timeout() bool   { return true }
+func (fakeTimeoutErr) Temporary() bool { return true }
+
+func TestIsRetryablePublishError(t *testing.T) {
+	cases := []struct {
+		name    string
+		err     error
+		details map[string]interface{}
+		want    bool
+	}{
+		{"nil", nil, nil, false},
+		{"not_connected", fmt.Errorf("not_connected"), nil, false},
+		{"container_not_ready", fmt.Errorf("instagram_container_not_ready"), nil, true},
+		{"timeout", fmt.Errorf("wrapped: %w", fakeTimeoutErr{}), nil, true},
+		{"http_500", fmt.Errorf("tiktok_non_2xx"), map[string]interface{}{"status": 502}, true},
+		{"http_429", fmt.Errorf("youtube_init_non_2xx"), map[string]interface{}{"status": 429}, true},
+		{"http_400", fmt.Errorf("tiktok_non_2xx"), map[string]interface{}{"status": 400}, false},
+		{"fb_all_5xx", fmt.Errorf("no_posts_created"), map[string]interface{}{"pages": []map[string]interface{}{{"posted": false, "statusCode": 503}}}, true},
+		{"fb_mixed", fmt.Errorf("no_posts_created"), map[string]interface{}{"pages": []map[string]interface{}{{"posted": false, "statusCode": 503}, {"posted": false, "statusCode": 400}}}, false},
+	}
+	for _, tc := range cases {
+		if got := isRetryablePublishError(tc.err, tc.details); got != tc.want {
+			t.Fatalf("%s: expected %v got %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestPublishWithRetry_RetriesTransientThenCheckpoints(t *testing.T) {
+	oldBase, oldMax := publishRetryBaseDelay, publishRetryMaxDelay
+	publishRetryBaseDelay, publishRetryMaxDelay = time.Millisecond, time.Millisecond
+	defer func() { publishRetryBaseDelay, publishRetryMaxDelay = oldBase, oldMax }()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	mock.ExpectExec(`UPDATE public\.publish_jobs\s+SET result_json = COALESCE`).
+		WithArgs("job1", "tiktok", sqlmock.AnyArg(), "w1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	calls := 0
+	posted, err, _, attempts := h.publishWithRetry(context.Background(), "job1", "w1", "tiktok", func() (int, error, map[string]interface{}) {
+		calls++
+		if calls < 2 {
+			return 0, fmt.Errorf("tiktok_non_2xx"), map[string]interface{}{"status": 503}
+		}
+		return 1, nil, map[string]interface{}{"publishId": "p1"}
+	})
+	if err != nil || posted != 1 || attempts != 2 {
+		t.Fatalf("expected success on attempt 2 got posted=%d attempts=%d err=%v", posted, attempts, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestPublishWithRetry_DoesNotRetryPermanentErrors(t *testing.T) {
+	h := New(nil)
+	calls := 0
+	_, err, _, attempts := h.publishWithRetry(context.Background(), "job1", "w1", "youtube", func() (int, error, map[string]interface{}) {
+		calls++
+		return 0, errors.New("not_connected"), nil
+	})
+	if err == nil || attempts != 1 || calls != 1 {
+		t.Fatalf("expected single attempt got attempts=%d calls=%d err=%v", attempts, calls, err)
+	}
+}
+
+func TestRetryPublishJobForUser_RequeuesAndSkipsOKProviders(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	prev := `{"facebook":{"ok":true,"posted":1},"instagram":{"ok":false,"error":"instagram_container_not_ready"}}`
+	mock.ExpectQuery(`UPDATE public\.publish_jobs\s+SET status = 'queued'`).
+		WithArgs("job1", "u1").
+		WillReturnRows(sqlmock.NewRows([]string{"results"}).AddRow([]byte(prev)))
+	mock.ExpectExec(`UPDATE public\.posts\s+SET last_publish_status = 'queued'`).
+		WithArgs("job1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/social-posts/publish-jobs/job1/retry/user/u1", nil)
+	req = mux.SetURLVars(req, map[string]string{"jobId": "job1", "userId": "u1"})
+	h.RetryPublishJobForUser(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d body=%q", rr.Code, rr.Body.String())
+	}
+	var out struct {
+		Status        string   `json:"status"`
+		SkipProviders []string `json:"skipProviders"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &out)
+	if out.Status != "queued" || len(out.SkipProviders) != 1 || out.SkipProviders[0] != "facebook" {
+		t.Fatalf("unexpected response %s", rr.Body.String())
+	}
+	select {
+	case <-h.publishWake:
+	default:
+		t.Fatalf("expected retry to wake a publish worker")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestRetryPublishJobForUser_NotFailed_Conflict(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	mock.ExpectQuery(`UPDATE public\.publish_jobs\s+SET status = 'queued'`).
+		WithArgs("job1", "u1").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`SELECT status FROM public\.publish_jobs`).
+		WithArgs("job1", "u1").
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("running"))
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/social-posts/publish-jobs/job1/retry/user/u1", nil)
+	req = mux.SetURLVars(req, map[string]string{"jobId": "job1", "userId": "u1"})
+	h.RetryPublishJobForUser(rr, req)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 got %d body=%q", rr.Code, rr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestLoadPreviousPublishResults_SkipsMedia(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	mock.ExpectQuery(`SELECT COALESCE\(result_json->'results'`).
+		WithArgs("job1").
+		WillReturnRows(sqlmock.NewRows([]string{"results"}).AddRow([]byte(`{"facebook":{"ok":true,"posted":2},"tiktok":{"ok":false},"media":{"ok":true}}`)))
+
+	got := h.loadPreviousPublishResults("job1")
+	if len(got) != 2 || got["facebook"].Posted != 2 || got["tiktok"].OK {
+		t.Fatalf("unexpected results %#v", got)
+	}
+}
+
+func TestRunPublishJob_FacebookRerunPostsOnlyToFailedPages(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	payload := fbOAuthPayload{Pages: []fbOAuthPageRow{{ID: "pg1", AccessToken: "t1"}, {ID: "pg2", AccessToken: "t2"}}}
+	raw, _ := json.Marshal(payload)
+	stubFeed := func(failing string) *[]string {
+		var calls []string
+		http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+			calls = append(calls, r.URL.Path)
+			if strings.Contains(r.URL.Path, failing) {
+				return httpJSON(503, `{"error":{"message":"try later"}}`, nil), nil
+			}
+			return httpJSON(200, `{"id":"`+strings.Split(r.URL.Path, "/")[2]+`_1"}`, nil), nil
+		}}
+		return &calls
+	}
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+
+	// First run: pg2 fails, so the provider is a partial failure rather than a success.
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings.*key='facebook_oauth'`).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(raw))
+	mock.ExpectExec(`INSERT INTO public\.social_libraries`).WillReturnResult(sqlmock.NewResult(1, 1))
+	stubFeed("pg2")
+	posted, perr, details := h.publishFacebookPages(context.Background(), "u1", "cap", nil, nil, false)
+	if perr == nil || perr.Error() != "partial_failure" || posted != 1 {
+		t.Fatalf("expected partial_failure, got posted=%d err=%v details=%v", posted, perr, details)
+	}
+	prev, _ := json.Marshal(map[string]publishProviderResult{"facebook": {OK: false, Posted: 1, Error: "partial_failure", Details: details}})
+
+	// Re-run of the job: only pg2 is posted to, and the result covers both pages.
+	mock.ExpectExec(`UPDATE public\.publish_jobs.*status='running'`).WithArgs("job1").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE public\.posts.*last_publish_status='running'`).WithArgs("job1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT COALESCE\(result_json->'results'`).
+		WithArgs("job1").
+		WillReturnRows(sqlmock.NewRows([]string{"results"}).AddRow(prev))
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings.*key='facebook_oauth'`).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(raw))
+	mock.ExpectExec(`INSERT INTO public\.social_libraries`).WillReturnResult(sqlmock.NewResult(1, 1))
+	var final string
+	mock.ExpectExec(`UPDATE public\.publish_jobs.*SET status=\$2`).
+		WithArgs("job1", "completed", captureArg{&final}, sqlmock.AnyArg(), "w1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE public\.posts.*SET last_publish_status=\$2`).
+		WithArgs("job1", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	calls := stubFeed("nothing")
+
+	h.runPublishJob(context.Background(), "job1", "w1", "u1", "cap", publishPostRequest{Providers: []string{"facebook"}}, nil, "https://app.test")
+	if got := strings.Join(*calls, ","); got != "/v24.0/pg2/feed" {
+		t.Fatalf("expected only pg2 to be posted to, got %s", got)
+	}
+	var out struct {
+		Results map[string]publishProviderResult `json:"results"`
+	}
+	_ = json.Unmarshal([]byte(final), &out)
+	if fb := out.Results["facebook"]; !fb.OK || fb.Posted != 2 {
+		t.Fatalf("unexpected facebook result %s", final)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestRunPublishJob_PartialPostWithoutResumeNeedsManualAction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		t.Fatalf("unexpected provider call %s", r.URL)
+		return nil, nil
+	}}
+
+	prev := `{"pinterest":{"ok":false,"posted":1,"error":"pinterest_board_missing"}}`
+	mock.ExpectExec(`UPDATE public\.publish_jobs.*status='running'`).WithArgs("job1").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE public\.posts.*last_publish_status='running'`).WithArgs("job1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT COALESCE\(result_json->'results'`).
+		WithArgs("job1").
+		WillReturnRows(sqlmock.NewRows([]string{"results"}).AddRow([]byte(prev)))
+	var final string
+	mock.ExpectExec(`UPDATE public\.publish_jobs.*SET status=\$2`).
+		WithArgs("job1", "failed", captureArg{&final}, sqlmock.AnyArg(), "w1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE public\.posts.*SET last_publish_status=\$2`).
+		WithArgs("job1", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	h.runPublishJob(context.Background(), "job1", "w1", "u1", "cap", publishPostRequest{Providers: []string{"pinterest"}}, nil, "https://app.test")
+
+	var out struct {
+		Results map[string]publishProviderResult `json:"results"`
+	}
+	_ = json.Unmarshal([]byte(final), &out)
+	if pin := out.Results["pinterest"]; pin.OK || !pin.ManualAction || pin.Posted != 1 {
+		t.Fatalf("expected the partial pinterest post to be left for manual action, got %s", final)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
@@ -0,0 +1,394 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PortNumber53/simple-social-thing/backend/internal/workers"
+	"github.com/lib/pq"
+)
+
+// PublishJobWorkerOptions configures the DB-backed publish job worker pool.
+type PublishJobWorkerOptions struct {
+	// Workers is the number of concurrent jobs this instance will run (default: 4).
+	Workers int
+	// PollInterval is how often idle workers look for queued jobs (default: 5s).
+	// Local enqueues wake a worker immediately; polling picks up jobs enqueued by other instances.
+	PollInterval time.Duration
+	// Lease is how long a claimed job stays locked without a heartbeat before it is re-queued (default: 2m).
+	Lease time.Duration
+	// MaxAttempts caps how many times an expired job is re-queued before it is marked failed (default: 3).
+	MaxAttempts int
+	// Origin is the fallback public origin for jobs whose request snapshot does not carry one.
+	Origin string
+}
+
+// publishJobRequest is the request_json snapshot stored on publish_jobs rows.
+// Both the async publish endpoint and the scheduled-posts poller write it, so a worker
+// on any instance can rebuild the runPublishJob arguments from the row alone.
+type publishJobRequest struct {
+	publishPostRequest
+	Media        []string `json:"media"`
+	PublicOrigin string   `json:"publicOrigin"`
+}
+
+type claimedPublishJob struct {
+	ID       string
+	UserID   string
+	Caption  string
+	Request  publishJobRequest
+	Attempts int
+}
+
+// notifyPublishWorkers wakes an idle local worker so freshly enqueued jobs don't wait for the next poll.
+func (h *Handler) notifyPublishWorkers() {
+	if h == nil || h.publishWake == nil {
+		return
+	}
+	select {
+	case h.publishWake <- struct{}{}:
+	default:
+	}
+}
+
+func leaseSeconds(d time.Duration) int {
+	secs := int(d / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	return secs
+}
+
+// claimPublishJobOnce atomically moves the oldest queued job to running and leases it to workerID.
+// FOR UPDATE SKIP LOCKED lets several workers (and API instances) claim concurrently without contention.
+// Returns (nil, nil) when nothing is queued.
+func (h *Handler) claimPublishJobOnce(ctx context.Context, workerID string, lease time.Duration) (*claimedPublishJob, error) {
+	if h == nil || h.db == nil {
+		return nil, nil
+	}
+	var (
+		job     claimedPublishJob
+		reqJSON []byte
+	)
+	err := h.db.QueryRowContext(ctx, `
+		UPDATE public.publish_jobs
+		   SET status = 'running',
+		       attempts = attempts + 1,
+		       locked_by = $1,
+		       locked_until = NOW() + ($2 * INTERVAL '1 second'),
+		       heartbeat_at = NOW(),
+		       started_at = COALESCE(started_at, NOW()),
+		       updated_at = NOW()
+		 WHERE id = (
+		       SELECT id
+		         FROM public.publish_jobs
+		        WHERE status = 'queued'
+		        ORDER BY created_at ASC
+		        LIMIT 1
+		        FOR UPDATE SKIP LOCKED
+		 )
+		RETURNING id, user_id, COALESCE(caption, ''), COALESCE(request_json, '{}'::jsonb), attempts
+	`, workerID, leaseSeconds(lease)).Scan(&job.ID, &job.UserID, &job.Caption, &reqJSON, &job.Attempts)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(reqJSON) > 0 {
+		if err := json.Unmarshal(reqJSON, &job.Request); err != nil {
+			// Keep going with what we have; runPublishJob will report provider errors.
+			log.Printf("[PublishWorker] invalid request_json jobId=%s err=%v", job.ID, err)
+		}
+	}
+	return &job, nil
+}
+
+// heartbeatPublishJob extends the lease of a running job. It returns false if the job is no longer owned by workerID.
+func (h *Handler) heartbeatPublishJob(ctx context.Context, jobID, workerID string, lease time.Duration) (bool, error) {
+	res, err := h.db.ExecContext(ctx, `
+		UPDATE public.publish_jobs
+		   SET heartbeat_at = NOW(),
+		       locked_until = NOW() + ($3 * INTERVAL '1 second')
+		 WHERE id = $1
+		   AND locked_by = $2
+		   AND status = 'running'
+	`, jobID, workerID, leaseSeconds(lease))
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// requeueExpiredPublishJobsOnce returns running jobs whose lease expired (worker crashed or the process restarted)
+// to the queue. Jobs that already used maxAttempts are marked failed instead so a poison job can't loop forever.
+// Rows left in `running` before leases existed (locked_until IS NULL) are treated as expired once they go stale.
+func (h *Handler) requeueExpiredPublishJobsOnce(ctx context.Context, lease time.Duration, maxAttempts int) (requeued int, failed int, err error) {
+	if h == nil || h.db == nil {
+		return 0, 0, nil
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
+
+	type expiredJob struct {
+		id     string
+		userID string
+	}
+	collect := func(rows *sql.Rows) ([]expiredJob, error) {
+		defer rows.Close()
+		out := make([]expiredJob, 0)
+		for rows.Next() {
+			var j expiredJob
+			if err := rows.Scan(&j.id, &j.userID); err != nil {
+				return nil, err
+			}
+			out = append(out, j)
+		}
+		return out, rows.Err()
+	}
+
+	rows, err := h.db.QueryContext(ctx, `
+		UPDATE public.publish_jobs
+		   SET status = 'failed',
+		       error = 'lease_expired',
+		       locked_by = NULL,
+		       locked_until = NULL,
+		       finished_at = NOW(),
+		       updated_at = NOW()
+		 WHERE status = 'running'
+		   AND COALESCE(locked_until, updated_at + ($1 * INTERVAL '1 second')) < NOW()
+		   AND attempts >= $2
+		RETURNING id, user_id
+	`, leaseSeconds(lease), maxAttempts)
+	if err != nil {
+		return 0, 0, err
+	}
+	dead, err := collect(rows)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(dead) > 0 {
+		ids := make([]string, 0, len(dead))
+		for _, j := range dead {
+			ids = append(ids, j.id)
+		}
+		_, _ = h.db.ExecContext(ctx, `
+			UPDATE public.posts
+			   SET last_publish_status = 'failed',
+			       last_publish_error = 'lease_expired',
+			       updated_at = NOW()
+			 WHERE last_publish_job_id = ANY($1)
+		`, pq.Array(ids))
+		h.setOccurrenceJobStatus(ctx, ids, "failed", "lease_expired")
+		for _, j := range dead {
+			log.Printf("[PublishWorker] lease_expired_failed jobId=%s userId=%s", j.id, j.userID)
+			h.emitEvent(j.userID, realtimeEvent{Type: "publish_job", JobID: j.id, Status: "failed"})
+		}
+	}
+
+	rows, err = h.db.QueryContext(ctx, `
+		UPDATE public.publish_jobs
+		   SET status = 'queued',
+		       locked_by = NULL,
+		       locked_until = NULL,
+		       updated_at = NOW()
+		 WHERE status = 'running'
+		   AND COALESCE(locked_until, updated_at + ($1 * INTERVAL '1 second')) < NOW()
+		   AND attempts < $2
+		RETURNING id, user_id
+	`, leaseSeconds(lease), maxAttempts)
+	if err != nil {
+		return 0, len(dead), err
+	}
+	back, err := collect(rows)
+	if err != nil {
+		return 0, len(dead), err
+	}
+	if len(back) > 0 {
+		ids := make([]string, 0, len(back))
+		for _, j := range back {
+			ids = append(ids, j.id)
+		}
+		_, _ = h.db.ExecContext(ctx, `
+			UPDATE public.posts
+			   SET last_publish_status = 'queued',
+			       updated_at = NOW()
+			 WHERE last_publish_job_id = ANY($1)
+		`, pq.Array(ids))
+		h.setOccurrenceJobStatus(ctx, ids, "queued", "")
+		for _, j := range back {
+			log.Printf("[PublishWorker] lease_expired_requeued jobId=%s userId=%s", j.id, j.userID)
+			h.emitEvent(j.userID, realtimeEvent{Type: "publish_job", JobID: j.id, Status: "queued"})
+		}
+	}
+	return len(back), len(dead), nil
+}
+
+// executeClaimedPublishJob runs a claimed job while a heartbeat keeps its lease alive.
+func (h *Handler) executeClaimedPublishJob(ctx context.Context, workerID string, job *claimedPublishJob, opts PublishJobWorkerOptions) {
+	if job == nil {
+		return
+	}
+	origin := strings.TrimSpace(job.Request.PublicOrigin)
+	if origin == "" {
+		origin = opts.Origin
+	}
+	caption := job.Caption
+	if strings.TrimSpace(caption) == "" {
+		caption = job.Request.Caption
+	}
+
+	// jobCtx is cancelled when the lease is lost so the run stops posting; the re-queued job belongs to another worker.
+	jobCtx, cancelJob := context.WithCancel(context.Background())
+	defer cancelJob()
+	hbCtx, stopHeartbeat := context.WithCancel(context.Background())
+	hbDone := make(chan struct{})
+	go func() {
+		defer close(hbDone)
+		every := opts.Lease / 3
+		if every < time.Second {
+			every = time.Second
+		}
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-hbCtx.Done():
+				return
+			case <-ticker.C:
+				owned, err := h.heartbeatPublishJob(hbCtx, job.ID, workerID, opts.Lease)
+				if err != nil {
+					log.Printf("[PublishWorker] heartbeat_failed jobId=%s worker=%s err=%v", job.ID, workerID, err)
+					continue
+				}
+				if !owned {
+					log.Printf("[PublishWorker] lease_lost jobId=%s worker=%s", job.ID, workerID)
+					cancelJob()
+					return
+				}
+			}
+		}
+	}()
+
+	log.Printf("[PublishWorker] run jobId=%s userId=%s worker=%s attempt=%d", job.ID, job.UserID, workerID, job.Attempts)
+	// runPublishJob is deliberately detached from ctx: a shutdown stops new claims but lets in-flight
+	// provider calls finish. If the process dies anyway, the lease expires and another worker re-queues it.
+	h.runPublishJob(jobCtx, job.ID, workerID, job.UserID, caption, job.Request.publishPostRequest, job.Request.Media, origin)
+
+	stopHeartbeat()
+	<-hbDone
+	_, _ = h.db.ExecContext(context.Background(), `
+		UPDATE public.publish_jobs
+		   SET locked_by = NULL, locked_until = NULL
+		 WHERE id = $1 AND locked_by = $2
+	`, job.ID, workerID)
+}
+
+// StartPublishJobWorkers runs a pool of workers that claim queued publish_jobs rows and execute them.
+// Safe to run on every API instance: claims use FOR UPDATE SKIP LOCKED and leases are heartbeated,
+// so each job runs on exactly one worker and jobs orphaned by a crash are re-queued.
+func (h *Handler) StartPublishJobWorkers(ctx context.Context, opts PublishJobWorkerOptions) {
+	if h == nil || h.db == nil {
+		return
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 5 * time.Second
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = 2 * time.Minute
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	if strings.TrimSpace(opts.Origin) == "" {
+		opts.Origin = strings.TrimSpace(os.Getenv("FRONTEND_URL"))
+		if opts.Origin == "" {
+			opts.Origin = "http://localhost"
+		}
+	}
+
+	instanceID := workers.DefaultInstanceID()
+	log.Printf("[PublishWorker] started instance=%s workers=%d poll=%s lease=%s maxAttempts=%d",
+		instanceID, opts.Workers, opts.PollInterval, opts.Lease, opts.MaxAttempts)
+
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		wg.Add(1)
+		workerID := fmt.Sprintf("%s#%d", instanceID, i)
+		go func() {
+			defer wg.Done()
+			h.publishJobWorkerLoop(ctx, workerID, opts)
+		}()
+	}
+
+	// Reaper: re-queue jobs whose lease expired (on this or any other instance).
+	reap := func() {
+		reapCtx, cancel := context.WithTimeout(ctx, 20*time.Second)
+		defer cancel()
+		requeued, failed, err := h.requeueExpiredPublishJobsOnce(reapCtx, opts.Lease, opts.MaxAttempts)
+		if err != nil {
+			log.Printf("[PublishWorker] reap error err=%v", err)
+			return
+		}
+		if requeued > 0 {
+			h.notifyPublishWorkers()
+		}
+		if requeued > 0 || failed > 0 {
+			log.Printf("[PublishWorker] reaped requeued=%d failed=%d", requeued, failed)
+		}
+	}
+	reapEvery := opts.Lease / 2
+	if reapEvery < time.Second {
+		reapEvery = time.Second
+	}
+	ticker := time.NewTicker(reapEvery)
+	defer ticker.Stop()
+
+	reap()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			log.Printf("[PublishWorker] stopped instance=%s err=%v", instanceID, ctx.Err())
+			return
+		case <-ticker.C:
+			reap()
+		}
+	}
+}
+
+func (h *Handler) publishJobWorkerLoop(ctx context.Context, workerID string, opts PublishJobWorkerOptions) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		claimCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		job, err := h.claimPublishJobOnce(claimCtx, workerID, opts.Lease)
+		cancel()
+		if err != nil {
+			log.Printf("[PublishWorker] claim error worker=%s err=%v", workerID, err)
+		}
+		if job != nil {
+			h.executeClaimedPublishJob(ctx, workerID, job, opts)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.publishWake:
+		case <-time.After(opts.PollInterval):
+		}
+	}
+}
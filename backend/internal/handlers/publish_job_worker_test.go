@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestClaimPublishJobOnce_NoQueuedJobs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	mock.ExpectQuery(`UPDATE public\.publish_jobs[\s\S]*FOR UPDATE SKIP LOCKED`).
+		WithArgs("w1", 120).
+		WillReturnError(sql.ErrNoRows)
+
+	job, err := h.claimPublishJobOnce(context.Background(), "w1", 2*time.Minute)
+	if err != nil {
+		t.Fatalf("claimPublishJobOnce err=%v", err)
+	}
+	if job != nil {
+		t.Fatalf("expected no job got %#v", job)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestClaimPublishJobOnce_DecodesSnapshot(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	snapshot := `{"caption":"cap","providers":["facebook"],"facebookPageIds":["pg1"],"dryRun":true,"media":["/media/uploads/u1/a.jpg"],"publicOrigin":"https://app.test"}`
+	rows := sqlmock.NewRows([]string{"id", "user_id", "caption", "request_json", "attempts"}).
+		AddRow("job1", "u1", "cap", []byte(snapshot), 1)
+	mock.ExpectQuery(`UPDATE public\.publish_jobs[\s\S]*FOR UPDATE SKIP LOCKED`).
+		WithArgs("w1", 60).
+		WillReturnRows(rows)
+
+	job, err := h.claimPublishJobOnce(context.Background(), "w1", time.Minute)
+	if err != nil {
+		t.Fatalf("claimPublishJobOnce err=%v", err)
+	}
+	if job == nil || job.ID != "job1" || job.UserID != "u1" || job.Attempts != 1 {
+		t.Fatalf("unexpected job %#v", job)
+	}
+	if !job.Request.DryRun || len(job.Request.Providers) != 1 || job.Request.Providers[0] != "facebook" {
+		t.Fatalf("unexpected request %#v", job.Request)
+	}
+	if len(job.Request.FacebookPageIDs) != 1 || len(job.Request.Media) != 1 || job.Request.PublicOrigin != "https://app.test" {
+		t.Fatalf("unexpected request %#v", job.Request)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestRequeueExpiredPublishJobsOnce_RequeuesAndFails(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	mock.ExpectQuery(`UPDATE public\.publish_jobs\s+SET status = 'failed'`).
+		WithArgs(120, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow("dead1", "u1"))
+	mock.ExpectExec(`UPDATE public\.posts\s+SET last_publish_status = 'failed'`).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`UPDATE public\.publish_jobs\s+SET status = 'queued'`).
+		WithArgs(120, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow("job2", "u2").AddRow("job3", "u3"))
+	mock.ExpectExec(`UPDATE public\.posts\s+SET last_publish_status = 'queued'`).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	requeued, failed, err := h.requeueExpiredPublishJobsOnce(context.Background(), 2*time.Minute, 3)
+	if err != nil {
+		t.Fatalf("requeueExpiredPublishJobsOnce err=%v", err)
+	}
+	if requeued != 2 || failed != 1 {
+		t.Fatalf("expected requeued=2 failed=1 got requeued=%d failed=%d", requeued, failed)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestHeartbeatPublishJob_LeaseLost(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	mock.ExpectExec(`UPDATE public\.publish_jobs\s+SET heartbeat_at = NOW\(\)`).
+		WithArgs("job1", "w1", 120).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	owned, err := h.heartbeatPublishJob(context.Background(), "job1", "w1", 2*time.Minute)
+	if err != nil {
+		t.Fatalf("heartbeatPublishJob err=%v", err)
+	}
+	if owned {
+		t.Fatalf("expected lease lost")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestNotifyPublishWorkers_NonBlocking(t *testing.T) {
+	h := New(nil)
+	h.notifyPublishWorkers()
+	h.notifyPublishWorkers() // buffer full: must not block
+	select {
+	case <-h.publishWake:
+	default:
+		t.Fatalf("expected a pending wake-up")
+	}
+	var nilH *Handler
+	nilH.notifyPublishWorkers()
+}
+
+func TestRunPublishJob_LeaseLostStopsPublishing(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		t.Fatalf("unexpected provider call %s", r.URL)
+		return nil, nil
+	}}
+
+	mock.ExpectExec(`UPDATE public\.publish_jobs.*status='running'`).
+		WithArgs("job1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE public\.posts.*last_publish_status='running'`).
+		WithArgs("job1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	var final string
+	// The job was re-queued and claimed by another worker: the final write matches nothing.
+	mock.ExpectExec(`UPDATE public\.publish_jobs.*SET status=\$2.*WHERE id=\$1 AND locked_by=\$5`).
+		WithArgs("job1", "failed", captureArg{&final}, sqlmock.AnyArg(), "w1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	h.runPublishJob(ctx, "job1", "w1", "u1", "cap", publishPostRequest{Providers: []string{"tiktok"}}, nil, "https://app.test")
+
+	if !strings.Contains(final, `"error":"context canceled"`) {
+		t.Fatalf("expected the provider to be skipped, got %s", final)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
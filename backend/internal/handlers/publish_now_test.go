@@ -24,8 +24,8 @@ func TestPublishScheduledPostNowOnce_EnqueuesJob(t *testing.T) {
 	mock.ExpectQuery(`UPDATE public\.posts`).
 		WithArgs("p1", "u1", sqlmock.AnyArg()).
 		WillReturnRows(
-			sqlmock.NewRows([]string{"content", "providers", "media", "scheduledFor"}).
-				AddRow(sql.NullString{Valid: true, String: "hi"}, pq.StringArray{"instagram"}, pq.StringArray{"/media/u/shard/img.png"}, now),
+			sqlmock.NewRows([]string{"content", "providers", "media", "scheduledFor", "variants", "options"}).
+				AddRow(sql.NullString{Valid: true, String: "hi"}, pq.StringArray{"instagram"}, pq.StringArray{"/media/u/shard/img.png"}, now, nil, nil),
 		)
 
 	// Insert job row
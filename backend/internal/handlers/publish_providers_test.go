@@ -152,9 +152,15 @@ func TestPublishTikTokWithVideoURL_Success(t *testing.T) {
 	orig := http.DefaultTransport
 	defer func() { http.DefaultTransport = orig }()
 	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
-		if r.URL.Host == "open.tiktokapis.com" && strings.Contains(r.URL.Path, "/v2/post/publish/inbox/video/init/") {
+		if r.URL.Host == "open.tiktokapis.com" && strings.Contains(r.URL.Path, "/v2/post/publish/creator_info/query/") {
+			return httpJSON(200, `{"data":{"privacy_level_options":["PUBLIC_TO_EVERYONE","SELF_ONLY"]},"error":{"code":"ok"}}`, nil), nil
+		}
+		if r.URL.Host == "open.tiktokapis.com" && strings.Contains(r.URL.Path, "/v2/post/publish/video/init/") {
 			return httpJSON(200, `{"data":{"publish_id":"p1"}}`, nil), nil
 		}
+		if r.URL.Host == "open.tiktokapis.com" && strings.Contains(r.URL.Path, "/v2/post/publish/status/fetch/") {
+			return httpJSON(200, `{"data":{"status":"PUBLISH_COMPLETE"}}`, nil), nil
+		}
 		return httpJSON(404, `{"error":"not_found"}`, nil), nil
 	}}
 
@@ -185,7 +191,7 @@ func TestPublishYouTubeWithVideoBytes_Success(t *testing.T) {
 		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(raw))
 
 	mock.ExpectExec(`INSERT INTO public\.social_libraries`).
-		WithArgs(sqlmock.AnyArg(), "u1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), "u1", "video", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	orig := http.DefaultTransport
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -63,3 +64,86 @@ func TestPublishSocialPostForUser_FacebookAndInstagram_DryRun(t *testing.T) {
 		t.Fatalf("sql expectations: %v", err)
 	}
 }
+
+func TestPublishSocialPostForUser_Threads_DryRun(t *testing.T) {
+	tmp := t.TempDir()
+	cwd, _ := os.Getwd()
+	_ = os.Chdir(tmp)
+	defer func() { _ = os.Chdir(cwd) }()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	tok, _ := json.Marshal(threadsOAuth{AccessToken: "ttok", ThreadsUserID: "th1"})
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings.*key='threads_oauth'`).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(tok))
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	_ = mw.WriteField("caption", "cap")
+	_ = mw.WriteField("dryRun", "true")
+	_ = mw.WriteField("providers", `["threads"]`)
+	fw, _ := mw.CreateFormFile("media", "img.jpg")
+	_, _ = fw.Write([]byte{0xff, 0xd8, 0xff, 0xdb})
+	_ = mw.Close()
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/social-posts/publish/user/u1", &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req = mux.SetURLVars(req, map[string]string{"userId": "u1"})
+
+	h.PublishSocialPostForUser(rr, req)
+	var out struct {
+		OK      bool                             `json:"ok"`
+		Results map[string]publishProviderResult `json:"results"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &out)
+	th := out.Results["threads"]
+	urls, _ := th.Details["mediaUrls"].([]interface{})
+	if rr.Code != http.StatusOK || !out.OK || !th.OK || th.Details["mediaType"] != "IMAGE" || len(urls) != 1 {
+		t.Fatalf("unexpected response %d %s", rr.Code, rr.Body.String())
+	}
+	if u, _ := urls[0].(string); !strings.HasPrefix(u, "http://example.com/media/") {
+		t.Fatalf("expected a public media URL, got %v", urls[0])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestPublishSocialPostForUser_X_DryRun(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	tok, _ := json.Marshal(xOAuth{AccessToken: "xtok"})
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings.*key='x_oauth'`).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(tok))
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/social-posts/publish/user/u1", bytes.NewBufferString(`{"caption":"hi","providers":["x"],"dryRun":true}`))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"userId": "u1"})
+
+	h.PublishSocialPostForUser(rr, req)
+	var out struct {
+		OK      bool                             `json:"ok"`
+		Results map[string]publishProviderResult `json:"results"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &out)
+	if x, ok := out.Results["x"]; rr.Code != http.StatusOK || !out.OK || !ok || !x.OK || x.Details["dryRun"] != true {
+		t.Fatalf("unexpected response %d %s", rr.Code, rr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
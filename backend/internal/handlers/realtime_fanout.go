@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// realtimeNotifyChannel is the Postgres LISTEN/NOTIFY channel every API instance subscribes to.
+	realtimeNotifyChannel = "sst_realtime_events"
+	// realtimeNotifyMaxBytes keeps NOTIFY payloads under Postgres' hard 8000-byte limit.
+	realtimeNotifyMaxBytes = 7900
+	// realtimeNotifyQueue bounds events waiting to be NOTIFYed; beyond it events are only delivered locally.
+	realtimeNotifyQueue = 256
+)
+
+// realtimeListener is the subset of *pq.Listener used by the fan-out loop (swapped out in tests).
+type realtimeListener interface {
+	Listen(channel string) error
+	NotificationChannel() <-chan *pq.Notification
+	Ping() error
+	Close() error
+}
+
+// realtimeEnvelope is the NOTIFY payload: the serialized event plus who it is for and which instance sent it.
+type realtimeEnvelope struct {
+	Origin string          `json:"o"`
+	UserID string          `json:"u"`
+	Event  json.RawMessage `json:"e"`
+}
+
+// realtimeFanout relays realtime events between API instances. Events are always broadcast to local sockets
+// first; the fan-out NOTIFYs them so other instances can broadcast them to their own sockets, and ignores its
+// own notifications when they come back. If the DB channel is down, events still reach local connections.
+type realtimeFanout struct {
+	instanceID string
+	out        chan realtimeEnvelope
+	listening  atomic.Bool
+	dropped    atomic.Int64
+}
+
+// StartRealtimeFanout subscribes this instance to realtime events emitted by other instances via Postgres
+// LISTEN/NOTIFY and makes emitEvent publish to them. It must be called before events are emitted; the
+// background loops stop when ctx is done.
+func (h *Handler) StartRealtimeFanout(ctx context.Context, dsn string, instanceID string) {
+	if h == nil || h.db == nil || strings.TrimSpace(dsn) == "" {
+		return
+	}
+	f := newRealtimeFanout(instanceID)
+	l := pq.NewListener(dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		switch ev {
+		case pq.ListenerEventConnected, pq.ListenerEventReconnected:
+			f.listening.Store(true)
+			log.Printf("[RealtimeFanout] listening instance=%s channel=%s", f.instanceID, realtimeNotifyChannel)
+		case pq.ListenerEventDisconnected:
+			f.listening.Store(false)
+			log.Printf("[RealtimeFanout] disconnected instance=%s err=%v (local delivery only)", f.instanceID, err)
+		case pq.ListenerEventConnectionAttemptFailed:
+			log.Printf("[RealtimeFanout] reconnect_failed instance=%s err=%v", f.instanceID, err)
+		}
+	})
+	h.startRealtimeFanout(ctx, f, l)
+}
+
+func newRealtimeFanout(instanceID string) *realtimeFanout {
+	return &realtimeFanout{
+		instanceID: instanceID,
+		out:        make(chan realtimeEnvelope, realtimeNotifyQueue),
+	}
+}
+
+func (h *Handler) startRealtimeFanout(ctx context.Context, f *realtimeFanout, l realtimeListener) {
+	h.fanout = f
+	go h.realtimeListenLoop(ctx, f, l)
+	go h.realtimeNotifyLoop(ctx, f)
+}
+
+// state describes the fan-out for logs: off (single instance), listening, or down (local delivery only).
+func (f *realtimeFanout) state() string {
+	switch {
+	case f == nil:
+		return "off"
+	case f.listening.Load():
+		return "listening"
+	default:
+		return "down"
+	}
+}
+
+func (h *Handler) realtimeListenLoop(ctx context.Context, f *realtimeFanout, l realtimeListener) {
+	defer func() { _ = l.Close() }()
+	go func() {
+		<-ctx.Done()
+		// Unblocks Listen while it waits for a first connection.
+		_ = l.Close()
+	}()
+	// Listen blocks until the listener connects (retrying in the background).
+	if err := l.Listen(realtimeNotifyChannel); err != nil {
+		if ctx.Err() == nil {
+			log.Printf("[RealtimeFanout] listen_failed instance=%s err=%v", f.instanceID, err)
+		}
+		return
+	}
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n, ok := <-l.NotificationChannel():
+			if !ok {
+				return
+			}
+			if n == nil {
+				// Sent after a reconnect: anything NOTIFYed while disconnected was missed.
+				log.Printf("[RealtimeFanout] reconnected instance=%s (events during the outage were not relayed)", f.instanceID)
+				continue
+			}
+			h.deliverRealtimeNotification(f, n.Extra)
+		case <-ping.C:
+			go func() { _ = l.Ping() }()
+		}
+	}
+}
+
+// deliverRealtimeNotification broadcasts an event received from another instance to local sockets.
+func (h *Handler) deliverRealtimeNotification(f *realtimeFanout, payload string) {
+	var env realtimeEnvelope
+	if err := json.Unmarshal([]byte(payload), &env); err != nil {
+		log.Printf("[RealtimeFanout] bad_payload bytes=%d err=%v", len(payload), err)
+		return
+	}
+	if env.Origin == f.instanceID || strings.TrimSpace(env.UserID) == "" {
+		return
+	}
+	h.rt.broadcast(env.UserID, env.Event)
+}
+
+func (h *Handler) realtimeNotifyLoop(ctx context.Context, f *realtimeFanout) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case env := <-f.out:
+			b, err := json.Marshal(env)
+			if err != nil {
+				continue
+			}
+			nctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			_, err = h.db.ExecContext(nctx, `SELECT pg_notify($1, $2)`, realtimeNotifyChannel, string(b))
+			cancel()
+			if err != nil && ctx.Err() == nil {
+				log.Printf("[RealtimeFanout] notify_failed userId=%s err=%v (local delivery only)", env.UserID, err)
+			}
+		}
+	}
+}
+
+// publish queues an already-locally-broadcast event for the other instances. Oversized events drop their
+// result payload (clients refetch when they see truncated=true); events that still do not fit, or that arrive
+// while the queue is full, stay local.
+func (f *realtimeFanout) publish(userID string, ev realtimeEvent, b []byte) {
+	env := realtimeEnvelope{Origin: f.instanceID, UserID: userID, Event: b}
+	if realtimeEnvelopeSize(env) > realtimeNotifyMaxBytes {
+		ev.Result = nil
+		ev.Truncated = true
+		slim, err := json.Marshal(ev)
+		if err != nil {
+			return
+		}
+		env.Event = slim
+		if n := realtimeEnvelopeSize(env); n > realtimeNotifyMaxBytes {
+			log.Printf("[RealtimeFanout] too_large userId=%s type=%s bytes=%d (local delivery only)", userID, ev.Type, n)
+			return
+		}
+	}
+	select {
+	case f.out <- env:
+	default:
+		if n := f.dropped.Add(1); n == 1 || n%100 == 0 {
+			log.Printf("[RealtimeFanout] queue_full userId=%s type=%s dropped=%d (local delivery only)", userID, ev.Type, n)
+		}
+	}
+}
+
+func realtimeEnvelopeSize(env realtimeEnvelope) int {
+	b, err := json.Marshal(env)
+	if err != nil {
+		return realtimeNotifyMaxBytes + 1
+	}
+	return len(b)
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"golang.org/x/net/websocket"
+)
+
+type fakeRealtimeListener struct {
+	ch chan *pq.Notification
+}
+
+func (l *fakeRealtimeListener) Listen(string) error                          { return nil }
+func (l *fakeRealtimeListener) NotificationChannel() <-chan *pq.Notification { return l.ch }
+func (l *fakeRealtimeListener) Ping() error                                  { return nil }
+func (l *fakeRealtimeListener) Close() error                                 { return nil }
+
+type notifyPayloadArg struct {
+	mu  *sync.Mutex
+	got *string
+}
+
+func (a notifyPayloadArg) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	a.mu.Lock()
+	*a.got = s
+	a.mu.Unlock()
+	return true
+}
+
+func dialRealtimeWS(t *testing.T, h *Handler, userID string) *websocket.Conn {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(h.EventsWebSocket))
+	t.Cleanup(srv.Close)
+	c, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/?userId="+userID, "", "http://localhost/")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	deadline := time.Now().Add(2 * time.Second)
+	for h.rt.count(userID) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("ws connection never registered")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return c
+}
+
+// nextRealtimeEvent returns the next event that is not a hello/clock tick.
+func nextRealtimeEvent(t *testing.T, c *websocket.Conn) realtimeEvent {
+	t.Helper()
+	_ = c.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for {
+		var msg string
+		if err := websocket.Message.Receive(c, &msg); err != nil {
+			t.Fatalf("receive: %v", err)
+		}
+		var ev realtimeEvent
+		if err := json.Unmarshal([]byte(msg), &ev); err != nil {
+			t.Fatalf("bad event %q: %v", msg, err)
+		}
+		if ev.Type != "hello" && ev.Type != "clock" {
+			return ev
+		}
+	}
+}
+
+func TestRealtimeFanout_RelaysRemoteEventsAndIgnoresOwn(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l := &fakeRealtimeListener{ch: make(chan *pq.Notification, 4)}
+	h.startRealtimeFanout(ctx, newRealtimeFanout("a"), l)
+
+	c := dialRealtimeWS(t, h, "u1")
+	notify := func(origin, userID, typ string) {
+		raw, _ := json.Marshal(realtimeEnvelope{Origin: origin, UserID: userID, Event: json.RawMessage(`{"type":"` + typ + `","user_id":"` + userID + `","at":"x"}`)})
+		l.ch <- &pq.Notification{Channel: realtimeNotifyChannel, Extra: string(raw)}
+	}
+	notify("a", "u1", "own_echo")
+	l.ch <- nil // reconnect marker
+	notify("b", "u2", "other_user")
+	notify("b", "u1", "publish_job")
+
+	if ev := nextRealtimeEvent(t, c); ev.Type != "publish_job" {
+		t.Fatalf("expected relayed publish_job event, got %#v", ev)
+	}
+}
+
+func TestRealtimeFanout_EmitNotifiesAndTruncatesLargeResults(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	var (
+		mu    sync.Mutex
+		small string
+		large string
+	)
+	mock.ExpectExec(`SELECT pg_notify\(\$1, \$2\)`).
+		WithArgs(realtimeNotifyChannel, notifyPayloadArg{mu: &mu, got: &small}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`SELECT pg_notify\(\$1, \$2\)`).
+		WithArgs(realtimeNotifyChannel, notifyPayloadArg{mu: &mu, got: &large}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h.startRealtimeFanout(ctx, newRealtimeFanout("a"), &fakeRealtimeListener{ch: make(chan *pq.Notification)})
+
+	c := dialRealtimeWS(t, h, "u1")
+	h.emitEvent("u1", realtimeEvent{Type: "post.published", PostID: "p1", Result: json.RawMessage(`{"ok":true}`)})
+	big, _ := json.Marshal(map[string]string{"blob": strings.Repeat("x", 9000)})
+	h.emitEvent("u1", realtimeEvent{Type: "publish_job", JobID: "j1", Status: "completed", Result: big})
+
+	// Local sockets always get the full event.
+	if ev := nextRealtimeEvent(t, c); ev.Type != "post.published" {
+		t.Fatalf("unexpected local event %#v", ev)
+	}
+	if ev := nextRealtimeEvent(t, c); ev.JobID != "j1" || ev.Truncated || len(ev.Result) < 9000 {
+		t.Fatalf("expected untruncated local event, got type=%s truncated=%v result=%d", ev.Type, ev.Truncated, len(ev.Result))
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for mock.ExpectationsWereMet() != nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("sql expectations: %v", mock.ExpectationsWereMet())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	var env realtimeEnvelope
+	if err := json.Unmarshal([]byte(small), &env); err != nil || env.Origin != "a" || env.UserID != "u1" || !strings.Contains(string(env.Event), `"result":{"ok":true}`) {
+		t.Fatalf("unexpected small payload %q err=%v", small, err)
+	}
+	if len(large) > realtimeNotifyMaxBytes {
+		t.Fatalf("payload over limit: %d bytes", len(large))
+	}
+	var ev realtimeEvent
+	if err := json.Unmarshal([]byte(large), &env); err != nil {
+		t.Fatalf("bad large payload: %v", err)
+	}
+	if err := json.Unmarshal(env.Event, &ev); err != nil || !ev.Truncated || ev.Result != nil || ev.JobID != "j1" || ev.Status != "completed" {
+		t.Fatalf("expected truncated event without result, got %#v err=%v", ev, err)
+	}
+}
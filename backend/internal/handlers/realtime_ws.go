@@ -141,18 +141,21 @@ func (h *Handler) EventsPing(w http.ResponseWriter, r *http.Request) {
 type realtimeEvent struct {
 	Type string `json:"type"`
 
-	UserID string `json:"user_id"`
-	PostID string `json:"postId,omitempty"`
-	JobID  string `json:"jobId,omitempty"`
+	UserID   string `json:"user_id"`
+	PostID   string `json:"postId,omitempty"`
+	JobID    string `json:"jobId,omitempty"`
+	Provider string `json:"provider,omitempty"`
 
 	Status string   `json:"status,omitempty"`
 	IDs    []string `json:"ids,omitempty"`
 	Now    string   `json:"now,omitempty"`
 	At     string   `json:"at"`
 
-	// Result carries the full job result payload for terminal publish_job events,
-	// so the frontend can render results without an extra HTTP round-trip.
+	// Result carries the full job result payload for terminal publish_job events (and the run result for
+	// finished import.progress events), so the frontend can render results without an extra HTTP round-trip.
 	Result json.RawMessage `json:"result,omitempty"`
+	// Truncated is set when Result was dropped to fit a cross-instance notification; clients should refetch.
+	Truncated bool `json:"truncated,omitempty"`
 }
 
 // EventsWebSocket is an internal WS endpoint (meant to be proxied by the Worker) that streams realtime events.
@@ -256,7 +259,11 @@ func (h *Handler) emitEvent(userID string, ev realtimeEvent) {
 		log.Printf("[Realtime] marshal_failed userId=%s err=%v", userID, err)
 		return
 	}
-	log.Printf("[Realtime] emit userId=%s type=%s postId=%s jobId=%s status=%s subs=%d",
-		userID, ev.Type, ev.PostID, ev.JobID, ev.Status, h.rt.count(userID))
+	log.Printf("[Realtime] emit userId=%s type=%s postId=%s jobId=%s status=%s subs=%d fanout=%s",
+		userID, ev.Type, ev.PostID, ev.JobID, ev.Status, h.rt.count(userID), h.fanout.state())
+	// Local sockets get the event straight away; the fan-out relays it to sockets held by other instances.
 	h.rt.broadcast(userID, b)
+	if h.fanout != nil {
+		h.fanout.publish(userID, ev, b)
+	}
 }
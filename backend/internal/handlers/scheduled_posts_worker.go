@@ -18,6 +18,7 @@ type startPublishJobFunc func(jobID, userID, caption string, providers []string,
 // processDueScheduledPostsOnce claims due scheduled posts and enqueues a PublishJob per post.
 //
 // Claiming is done by setting Posts.lastPublishJobId so we don't enqueue duplicates across instances.
+// Recurring posts are handled afterwards by processDueRecurringPostsOnce (one job per occurrence).
 func (h *Handler) processDueScheduledPostsOnce(ctx context.Context, origin string, limit int, startJob startPublishJobFunc) (int, error) {
 	if h == nil || h.db == nil {
 		return 0, nil
@@ -47,6 +48,8 @@ func (h *Handler) processDueScheduledPostsOnce(ctx context.Context, origin strin
 		   AND scheduled_for IS NOT NULL
 		   AND scheduled_for <= NOW()
 		   AND last_publish_job_id IS NULL
+		   AND recurrence IS NULL
+		   AND `+providerSpacingClear("posts.user_id", "posts.providers")+`
 		 ORDER BY scheduled_for ASC, user_id, id
 		 LIMIT $1
 	`, limit)
@@ -66,12 +69,14 @@ func (h *Handler) processDueScheduledPostsOnce(ctx context.Context, origin strin
 	if err := rows.Err(); err != nil {
 		return 0, err
 	}
-	if len(cands) == 0 {
-		return 0, nil
-	}
 
 	enqueued := 0
+	prefs := map[string]*schedulingPreferences{}
 	for _, c := range cands {
+		if until, quiet := h.quietHoursDeferral(ctx, prefs, c.userID, time.Now()); quiet {
+			h.deferScheduledPost(ctx, c.id, c.userID, until)
+			continue
+		}
 		jobID := fmt.Sprintf("pub_%s", randHex(12))
 
 		log.Printf("[ScheduledPosts] candidate postId=%s userId=%s scheduledFor=%s",
@@ -92,6 +97,8 @@ func (h *Handler) processDueScheduledPostsOnce(ctx context.Context, origin strin
 			   AND scheduled_for IS NOT NULL
 			   AND scheduled_for <= NOW()
 			   AND last_publish_job_id IS NULL
+			   AND recurrence IS NULL
+			   AND `+providerSpacingClear("posts.user_id", "posts.providers")+`
 		`, c.id, c.userID, jobID)
 		if err != nil {
 			log.Printf("[ScheduledPosts] claim_failed postId=%s userId=%s err=%v", c.id, c.userID, err)
@@ -109,16 +116,19 @@ func (h *Handler) processDueScheduledPostsOnce(ctx context.Context, origin strin
 		var providers []string
 		var media []string
 		var scheduledFor time.Time
+		var variants, options []byte
 		if err := h.db.QueryRowContext(ctx, `
 			SELECT content,
 			       COALESCE(providers, ARRAY[]::text[]),
 			       COALESCE(media, ARRAY[]::text[]),
-			       scheduled_for
+			       scheduled_for,
+			       variants,
+			       options
 			  FROM public.posts
 			 WHERE id = $1
 			   AND user_id = $2
 			   AND last_publish_job_id = $3
-		`, c.id, c.userID, jobID).Scan(&content, pq.Array(&providers), pq.Array(&media), &scheduledFor); err != nil {
+		`, c.id, c.userID, jobID).Scan(&content, pq.Array(&providers), pq.Array(&media), &scheduledFor, &variants, &options); err != nil {
 			reason := "load_failed"
 			if strings.Contains(strings.ToLower(err.Error()), "out of memory") {
 				reason = "db_out_of_memory"
@@ -188,6 +198,12 @@ func (h *Handler) processDueScheduledPostsOnce(ctx context.Context, origin strin
 			"scheduledFor": scheduledFor.UTC().Format(time.RFC3339),
 			"publicOrigin": origin,
 		}
+		if v := postVariantsFromJSON(variants); v != nil {
+			reqSnapshot["variants"] = v
+		}
+		if o := postOptionsFromJSON(options); o != nil {
+			reqSnapshot["options"] = o
+		}
 		reqJSON, _ := json.Marshal(reqSnapshot)
 		now := time.Now()
 
@@ -225,6 +241,13 @@ func (h *Handler) processDueScheduledPostsOnce(ctx context.Context, origin strin
 		startJob(jobID, c.userID, caption, providers, media)
 	}
 
+	// A failing recurring sweep must not hold back one-shot posts (claims make the next sweep safe to retry).
+	if n, err := h.processDueRecurringPostsOnce(ctx, origin, limit, startJob); err != nil {
+		log.Printf("[ScheduledPosts] recurring_sweep_failed err=%v", err)
+	} else {
+		enqueued += n
+	}
+
 	return enqueued, nil
 }
 
@@ -286,7 +309,7 @@ func (h *Handler) StartScheduledPostsWorker(ctx context.Context, interval time.D
 			// Timebox each sweep attempt.
 			sweepCtx, cancel := context.WithTimeout(ctx, 20*time.Second)
 			n, err = h.processDueScheduledPostsOnce(sweepCtx, origin, limit, func(jobID, userID, caption string, providers []string, relMedia []string) {
-				go h.runPublishJob(jobID, userID, caption, publishPostRequest{Providers: providers}, relMedia, origin)
+				h.notifyPublishWorkers()
 			})
 			cancel()
 			if err == nil {
@@ -31,8 +31,8 @@ func TestProcessDueScheduledPostsOnce_EnqueuesJob(t *testing.T) {
 		WithArgs("p1", "u1", sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	details := sqlmock.NewRows([]string{"content", "providers", "media", "scheduledFor"}).
-		AddRow(sql.NullString{Valid: true, String: "hello"}, pq.StringArray{"facebook"}, pq.StringArray{}, when)
+	details := sqlmock.NewRows([]string{"content", "providers", "media", "scheduledFor", "variants", "options"}).
+		AddRow(sql.NullString{Valid: true, String: "hello"}, pq.StringArray{"facebook"}, pq.StringArray{}, when, nil, nil)
 	mock.ExpectQuery(`SELECT content,\s*COALESCE\(providers, ARRAY\[\]::text\[\]\),\s*COALESCE\(media, ARRAY\[\]::text\[\]\)`).
 		WithArgs("p1", "u1", sqlmock.AnyArg()).
 		WillReturnRows(details)
@@ -74,8 +74,8 @@ func TestProcessDueScheduledPostsOnce_EmptyContent_MarksFailed_NoJobInsert(t *te
 		WithArgs("p1", "u1", sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	details := sqlmock.NewRows([]string{"content", "providers", "media", "scheduledFor"}).
-		AddRow(sql.NullString{Valid: true, String: "   "}, pq.StringArray{"facebook"}, pq.StringArray{}, when)
+	details := sqlmock.NewRows([]string{"content", "providers", "media", "scheduledFor", "variants", "options"}).
+		AddRow(sql.NullString{Valid: true, String: "   "}, pq.StringArray{"facebook"}, pq.StringArray{}, when, nil, nil)
 	mock.ExpectQuery(`SELECT content,\s*COALESCE\(providers, ARRAY\[\]::text\[\]\),\s*COALESCE\(media, ARRAY\[\]::text\[\]\)`).
 		WithArgs("p1", "u1", sqlmock.AnyArg()).
 		WillReturnRows(details)
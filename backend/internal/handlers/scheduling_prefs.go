@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// schedulingSettingKey is the user_settings key holding the user's timezone and quiet hours.
+const schedulingSettingKey = "scheduling"
+
+// quietHoursWindow is a daily window (in the user's timezone) during which scheduled posts are held back.
+// End before Start means the window crosses midnight; Weekdays (RRULE codes) refer to the day it starts.
+type quietHoursWindow struct {
+	Start    string   `json:"start"`
+	End      string   `json:"end"`
+	Weekdays []string `json:"weekdays,omitempty"`
+}
+
+type schedulingPreferences struct {
+	// Timezone is the IANA zone local-time schedules are interpreted in.
+	Timezone   string             `json:"timezone"`
+	QuietHours []quietHoursWindow `json:"quietHours"`
+}
+
+func normalizeSchedulingPreferences(p *schedulingPreferences) error {
+	p.Timezone = strings.TrimSpace(p.Timezone)
+	if p.Timezone == "" {
+		p.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(p.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone")
+	}
+	if len(p.QuietHours) > 14 {
+		return fmt.Errorf("too many quiet hours windows (max 14)")
+	}
+	windows := make([]quietHoursWindow, 0, len(p.QuietHours))
+	for _, q := range p.QuietHours {
+		start, err1 := time.Parse("15:04", strings.TrimSpace(q.Start))
+		end, err2 := time.Parse("15:04", strings.TrimSpace(q.End))
+		if err1 != nil || err2 != nil {
+			return fmt.Errorf("invalid quiet hours (want HH:MM)")
+		}
+		if start.Equal(end) {
+			return fmt.Errorf("quiet hours start and end must differ")
+		}
+		nq := quietHoursWindow{Start: start.Format("15:04"), End: end.Format("15:04")}
+		for _, d := range normalizeWeekdays(q.Weekdays) {
+			if _, ok := recurrenceWeekdays[d]; !ok {
+				return fmt.Errorf("invalid quiet hours weekday")
+			}
+			nq.Weekdays = append(nq.Weekdays, d)
+		}
+		windows = append(windows, nq)
+	}
+	p.QuietHours = windows
+	return nil
+}
+
+func (p schedulingPreferences) location() *time.Location {
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// quietUntil reports whether t falls in a quiet hours window and, if so, when publishing is allowed again
+// (following back-to-back windows).
+func (p schedulingPreferences) quietUntil(t time.Time) (time.Time, bool) {
+	loc := p.location()
+	until, quiet := t, false
+	for i := 0; i < 8; i++ {
+		end, ok := p.quietWindowEnd(until, loc)
+		if !ok {
+			break
+		}
+		until, quiet = end, true
+	}
+	return until, quiet
+}
+
+func (p schedulingPreferences) quietWindowEnd(t time.Time, loc *time.Location) (time.Time, bool) {
+	local := t.In(loc)
+	for _, q := range p.QuietHours {
+		start, err1 := time.Parse("15:04", q.Start)
+		end, err2 := time.Parse("15:04", q.End)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		crosses := !end.After(start)
+		// A window that crosses midnight may have started yesterday.
+		for _, back := range []int{0, -1} {
+			day := time.Date(local.Year(), local.Month(), local.Day()+back, 0, 0, 0, 0, time.UTC)
+			if len(q.Weekdays) > 0 && !containsWeekday(q.Weekdays, day.Weekday()) {
+				continue
+			}
+			ws := resolveLocalTime(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, loc)
+			endDay := day
+			if crosses {
+				endDay = day.AddDate(0, 0, 1)
+			}
+			we := resolveLocalTime(endDay.Year(), endDay.Month(), endDay.Day(), end.Hour(), end.Minute(), 0, loc)
+			if !t.Before(ws) && t.Before(we) {
+				return we, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+func containsWeekday(codes []string, wd time.Weekday) bool {
+	for _, c := range codes {
+		if d, ok := recurrenceWeekdays[c]; ok && d == wd {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveLocalTime turns a wall-clock time in loc into an instant without leaving the DST edge cases to
+// time.Date: a time skipped by a spring-forward gap moves forward by the gap (02:30 -> 03:30), and a time
+// repeated by a fall-back overlap resolves to its first occurrence.
+func resolveLocalTime(year int, month time.Month, day, hour, min, sec int, loc *time.Location) time.Time {
+	naive := time.Date(year, month, day, hour, min, sec, 0, time.UTC)
+	want := naive.Format("2006-01-02T15:04:05")
+	_, before := naive.Add(-24 * time.Hour).In(loc).Zone()
+	_, after := naive.Add(24 * time.Hour).In(loc).Zone()
+	var best time.Time
+	for _, off := range []int{before, after} {
+		cand := naive.Add(-time.Duration(off) * time.Second)
+		if cand.In(loc).Format("2006-01-02T15:04:05") != want {
+			continue
+		}
+		if best.IsZero() || cand.Before(best) {
+			best = cand
+		}
+	}
+	if best.IsZero() {
+		// Skipped wall time: read it with the offset in effect before the transition.
+		best = naive.Add(-time.Duration(before) * time.Second)
+	}
+	return best.In(loc)
+}
+
+// parseLocalScheduleTime parses a wall-clock time without offset ("2026-03-09T09:00") in loc.
+func parseLocalScheduleTime(s string, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return resolveLocalTime(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), loc).UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid scheduledForLocal (want YYYY-MM-DDTHH:MM without offset)")
+}
+
+// loadSchedulingPreferences returns the user's scheduling preferences (UTC without quiet hours if unset).
+func (h *Handler) loadSchedulingPreferences(ctx context.Context, userID string) (schedulingPreferences, error) {
+	p := schedulingPreferences{Timezone: "UTC", QuietHours: []quietHoursWindow{}}
+	var raw []byte
+	err := h.db.QueryRowContext(ctx, `SELECT value FROM public.user_settings WHERE user_id=$1 AND key=$2`, userID, schedulingSettingKey).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return p, nil
+	}
+	if err != nil {
+		return p, err
+	}
+	var stored schedulingPreferences
+	if err := json.Unmarshal(raw, &stored); err != nil || normalizeSchedulingPreferences(&stored) != nil {
+		return p, nil
+	}
+	return stored, nil
+}
+
+// resolvePostSchedule applies the user's timezone to a create/update request: scheduledForLocal becomes
+// scheduledFor, and a new recurrence without its own timezone repeats in the user's timezone (so 09:00 stays
+// 09:00 across DST). The preferences are only loaded when needed.
+func (h *Handler) resolvePostSchedule(ctx context.Context, userID string, req *createOrUpdatePostRequest) error {
+	needsLocal := req.ScheduledForLocal != nil && strings.TrimSpace(*req.ScheduledForLocal) != ""
+	needsRecurrenceTZ := req.Recurrence != nil && strings.TrimSpace(req.Recurrence.Timezone) == "" &&
+		!strings.EqualFold(strings.TrimSpace(req.Recurrence.Freq), "none")
+	if !needsLocal && !needsRecurrenceTZ {
+		return nil
+	}
+	tz := ""
+	if req.Timezone != nil {
+		tz = strings.TrimSpace(*req.Timezone)
+	}
+	if tz == "" {
+		prefs, err := h.loadSchedulingPreferences(ctx, userID)
+		if err != nil {
+			return err
+		}
+		tz = prefs.Timezone
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return scheduleInputError("invalid timezone")
+	}
+	if needsLocal {
+		t, err := parseLocalScheduleTime(*req.ScheduledForLocal, loc)
+		if err != nil {
+			return scheduleInputError(err.Error())
+		}
+		req.ScheduledFor = &t
+	}
+	if needsRecurrenceTZ {
+		req.Recurrence.Timezone = tz
+	}
+	return nil
+}
+
+// scheduleInputError is a resolvePostSchedule error caused by the request (as opposed to the database).
+type scheduleInputError string
+
+func (e scheduleInputError) Error() string { return string(e) }
+
+// quietHoursDeferral returns when a due post of userID may go out if now is inside the user's quiet hours.
+// Preferences are cached per sweep in prefs; failing to load them never blocks publishing.
+func (h *Handler) quietHoursDeferral(ctx context.Context, prefs map[string]*schedulingPreferences, userID string, now time.Time) (time.Time, bool) {
+	p, ok := prefs[userID]
+	if !ok {
+		loaded, err := h.loadSchedulingPreferences(ctx, userID)
+		if err != nil {
+			log.Printf("[QuietHours] load_failed userId=%s err=%v (not deferring)", userID, err)
+		}
+		p = &loaded
+		prefs[userID] = p
+	}
+	if len(p.QuietHours) == 0 {
+		return time.Time{}, false
+	}
+	return p.quietUntil(now)
+}
+
+// deferScheduledPost moves a due one-shot post to the end of the user's quiet hours.
+func (h *Handler) deferScheduledPost(ctx context.Context, postID, userID string, until time.Time) {
+	res, err := h.db.ExecContext(ctx, `
+		UPDATE public.posts
+		   SET scheduled_for = $3, updated_at = NOW()
+		 WHERE id = $1
+		   AND user_id = $2
+		   AND status = 'scheduled'
+		   AND published_at IS NULL
+		   AND last_publish_job_id IS NULL
+	`, postID, userID, until)
+	if err != nil {
+		log.Printf("[ScheduledPosts] defer_failed postId=%s userId=%s err=%v", postID, userID, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return
+	}
+	log.Printf("[ScheduledPosts] deferred postId=%s userId=%s until=%s reason=quiet_hours", postID, userID, until.UTC().Format(time.RFC3339))
+	h.emitEvent(userID, realtimeEvent{Type: "post.updated", PostID: postID, Status: "deferred"})
+}
+
+// GetSchedulingPreferencesForUser returns the user's timezone and quiet hours.
+//
+// URL: GET /api/scheduling-preferences/user/{userId}
+func (h *Handler) GetSchedulingPreferencesForUser(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	userID := strings.TrimSpace(pathVar(r, "userId"))
+	if userID == "" {
+		writeError(w, http.StatusBadRequest, "userId is required")
+		return
+	}
+	prefs, err := h.loadSchedulingPreferences(r.Context(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, prefs)
+}
+
+// PutSchedulingPreferencesForUser replaces the user's timezone and quiet hours.
+//
+// URL: PUT /api/scheduling-preferences/user/{userId}
+// Body: {"timezone":"Europe/Berlin","quietHours":[{"start":"22:00","end":"07:00"},{"start":"00:00","end":"12:00","weekdays":["SA","SU"]}]}
+func (h *Handler) PutSchedulingPreferencesForUser(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPut) {
+		return
+	}
+	userID := strings.TrimSpace(pathVar(r, "userId"))
+	if userID == "" {
+		writeError(w, http.StatusBadRequest, "userId is required")
+		return
+	}
+	var prefs schedulingPreferences
+	if err := decodeJSON(r, &prefs); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json body")
+		return
+	}
+	if err := normalizeSchedulingPreferences(&prefs); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	raw, _ := json.Marshal(prefs)
+	if _, err := h.db.ExecContext(r.Context(), `
+		INSERT INTO public.user_settings (user_id, key, value, updated_at)
+		VALUES ($1, $2, $3::jsonb, NOW())
+		ON CONFLICT (user_id, key) DO UPDATE SET value = EXCLUDED.value, updated_at = NOW()
+	`, userID, schedulingSettingKey, string(raw)); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	log.Printf("[SchedulingPrefs] saved userId=%s timezone=%s quietWindows=%d", userID, prefs.Timezone, len(prefs.QuietHours))
+	writeJSON(w, http.StatusOK, prefs)
+}
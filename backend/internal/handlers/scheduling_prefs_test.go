@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+func TestResolveLocalTime_DSTGapAndOverlap(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("tzdata unavailable: %v", err)
+	}
+	// 02:30 does not exist on 2026-03-08; it moves forward by the gap.
+	if got := resolveLocalTime(2026, time.March, 8, 2, 30, 0, ny).UTC(); !got.Equal(time.Date(2026, 3, 8, 7, 30, 0, 0, time.UTC)) {
+		t.Fatalf("gap: got %s", got)
+	}
+	// 01:30 happens twice on 2026-11-01; the first (EDT) one wins.
+	if got := resolveLocalTime(2026, time.November, 1, 1, 30, 0, ny).UTC(); !got.Equal(time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC)) {
+		t.Fatalf("overlap: got %s", got)
+	}
+	// Ordinary days keep their wall clock on both sides of the change.
+	if got := resolveLocalTime(2026, time.March, 9, 9, 0, 0, ny).UTC(); !got.Equal(time.Date(2026, 3, 9, 13, 0, 0, 0, time.UTC)) {
+		t.Fatalf("after change: got %s", got)
+	}
+}
+
+func TestQuietUntil_CrossesMidnightAndWeekdays(t *testing.T) {
+	p := schedulingPreferences{
+		Timezone: "Europe/Berlin",
+		QuietHours: []quietHoursWindow{
+			{Start: "22:00", End: "07:00"},
+			{Start: "07:00", End: "10:00", Weekdays: []string{"saturday"}},
+		},
+	}
+	if err := normalizeSchedulingPreferences(&p); err != nil {
+		t.Fatalf("normalizeSchedulingPreferences: %v", err)
+	}
+	loc := p.location()
+
+	// Friday 23:30 -> Saturday 07:00, then the Saturday morning window -> 10:00.
+	until, quiet := p.quietUntil(time.Date(2030, 1, 4, 23, 30, 0, 0, loc))
+	if !quiet || !until.Equal(time.Date(2030, 1, 5, 10, 0, 0, 0, loc)) {
+		t.Fatalf("expected Saturday 10:00, got %s quiet=%v", until, quiet)
+	}
+	// Thursday 03:00 is inside the window that started Wednesday night.
+	until, quiet = p.quietUntil(time.Date(2030, 1, 3, 3, 0, 0, 0, loc))
+	if !quiet || !until.Equal(time.Date(2030, 1, 3, 7, 0, 0, 0, loc)) {
+		t.Fatalf("expected Thursday 07:00, got %s quiet=%v", until, quiet)
+	}
+	if _, quiet := p.quietUntil(time.Date(2030, 1, 3, 12, 0, 0, 0, loc)); quiet {
+		t.Fatalf("noon should not be quiet")
+	}
+
+	bad := schedulingPreferences{QuietHours: []quietHoursWindow{{Start: "09:00", End: "09:00"}}}
+	if err := normalizeSchedulingPreferences(&bad); err == nil {
+		t.Fatalf("expected error for empty window")
+	}
+}
+
+func TestCreatePostForUser_ScheduledForLocalUsesUserTimezone(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	prefs, _ := json.Marshal(schedulingPreferences{Timezone: "Europe/Berlin"})
+	want := time.Date(2030, 7, 1, 7, 0, 0, 0, time.UTC) // 09:00 CEST
+	now := time.Now().UTC()
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings`).
+		WithArgs("u1", schedulingSettingKey).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(prefs))
+	mock.ExpectQuery(`INSERT INTO public\.posts`).
+		WithArgs(sqlmock.AnyArg(), "u1", sqlmock.AnyArg(), "scheduled", sqlmock.AnyArg(), sqlmock.AnyArg(), &want, (*time.Time)(nil), sqlmock.AnyArg(), nil, nil).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "teamId", "userId", "content", "status", "providers", "media",
+			"scheduledFor", "publishedAt",
+			"lastPublishJobId", "lastPublishStatus", "lastPublishError", "lastPublishAttemptAt",
+			"createdAt", "updatedAt", "recurrence", "variants", "options",
+		}).
+			AddRow("p1", "", "u1", "hi", "scheduled", pq.StringArray{"facebook"}, pq.StringArray{}, want, sql.NullTime{}, sql.NullString{}, sql.NullString{}, sql.NullString{}, sql.NullTime{}, now, now, nil, nil, nil))
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/posts/user/u1", bytes.NewBufferString(
+		`{"content":"hi","status":"scheduled","providers":["facebook"],"scheduledForLocal":"2030-07-01T09:00"}`))
+	req = mux.SetURLVars(req, map[string]string{"userId": "u1"})
+	h.CreatePostForUser(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/api/posts/user/u1", bytes.NewBufferString(
+		`{"content":"hi","status":"scheduled","providers":["facebook"],"scheduledForLocal":"2030-07-01T09:00","timezone":"Nowhere/City"}`))
+	req = mux.SetURLVars(req, map[string]string{"userId": "u1"})
+	h.CreatePostForUser(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for bad timezone got %d", rr.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestProcessDueScheduledPostsOnce_DefersDuringQuietHours(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	now := time.Now().UTC()
+	prefs, _ := json.Marshal(schedulingPreferences{
+		Timezone:   "UTC",
+		QuietHours: []quietHoursWindow{{Start: now.Add(-time.Hour).Format("15:04"), End: now.Add(time.Hour).Format("15:04")}},
+	})
+	mock.ExpectQuery(`FROM public\.posts\s+WHERE status = 'scheduled'`).
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "scheduled_for"}).AddRow("p1", "u1", now.Add(-time.Minute)))
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings`).
+		WithArgs("u1", schedulingSettingKey).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(prefs))
+	mock.ExpectExec(`UPDATE public\.posts\s+SET scheduled_for = \$3, updated_at = NOW\(\)`).
+		WithArgs("p1", "u1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT id, user_id, scheduled_for, recurrence`).
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "scheduled_for", "recurrence"}))
+	mock.ExpectQuery(`FROM public\.post_occurrences o`).
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "post_id", "user_id", "occurrence_at"}))
+
+	n, err := h.processDueScheduledPostsOnce(t.Context(), "http://localhost", 10, nil)
+	if err != nil || n != 0 {
+		t.Fatalf("expected nothing enqueued, got n=%d err=%v", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
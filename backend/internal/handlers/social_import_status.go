@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PortNumber53/simple-social-thing/backend/internal/socialimport"
+)
+
+type socialImportRunView struct {
+	ID            string    `json:"id"`
+	Provider      string    `json:"provider"`
+	Trigger       string    `json:"trigger"`
+	Status        string    `json:"status"`
+	Fetched       int       `json:"fetched"`
+	Upserted      int       `json:"upserted"`
+	SkippedReason string    `json:"skippedReason,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	StartedAt     time.Time `json:"startedAt"`
+	FinishedAt    time.Time `json:"finishedAt"`
+	DurationMs    int64     `json:"durationMs"`
+}
+
+type socialImportProviderStatus struct {
+	Provider            string               `json:"provider"`
+	LastRunAt           *time.Time           `json:"lastRunAt"`
+	LastSuccessAt       *time.Time           `json:"lastSuccessAt"`
+	LastError           string               `json:"lastError,omitempty"`
+	NextRunAt           *time.Time           `json:"nextRunAt"`
+	ConsecutiveFailures int                  `json:"consecutiveFailures"`
+	LastRun             *socialImportRunView `json:"lastRun"`
+}
+
+const socialImportRunColumns = `id, provider, trigger, status, fetched, upserted, COALESCE(skipped_reason,''), COALESCE(error,''), started_at, finished_at, duration_ms`
+
+func scanSocialImportRun(rows *sql.Rows) (socialImportRunView, error) {
+	var v socialImportRunView
+	err := rows.Scan(&v.ID, &v.Provider, &v.Trigger, &v.Status, &v.Fetched, &v.Upserted, &v.SkippedReason, &v.Error, &v.StartedAt, &v.FinishedAt, &v.DurationMs)
+	return v, err
+}
+
+// EmitImportProgress forwards social import progress to the user's realtime stream as `import.progress` events.
+// It is used as socialimport.Runner.OnProgress for both manual syncs and the background provider workers.
+func (h *Handler) EmitImportProgress(ev socialimport.ProgressEvent) {
+	out := realtimeEvent{
+		Type:     "import.progress",
+		Provider: ev.Provider,
+		Status:   ev.Status,
+	}
+	if ev.Result != nil {
+		payload := map[string]interface{}{
+			"trigger":    ev.Trigger,
+			"fetched":    ev.Result.Fetched,
+			"upserted":   ev.Result.Upserted,
+			"durationMs": ev.Result.DurationMs,
+		}
+		if ev.Result.Reason != "" {
+			payload["skippedReason"] = ev.Result.Reason
+		}
+		if ev.Result.Error != "" {
+			payload["error"] = truncate(ev.Result.Error, 400)
+		}
+		if ev.Result.NextRunAt != nil {
+			payload["nextRunAt"] = ev.Result.NextRunAt.UTC().Format(time.RFC3339)
+		}
+		b, _ := json.Marshal(payload)
+		out.Result = b
+	}
+	h.emitEvent(ev.UserID, out)
+}
+
+// GetSocialLibrarySyncStatusForUser reports, per network, when the library last synced, the last error and
+// when the background importer will try again, plus the most recent import runs.
+//
+// Query: ?provider=x narrows the run list; ?limit=N (default 20, max 200).
+func (h *Handler) GetSocialLibrarySyncStatusForUser(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	userID := pathVar(r, "userId")
+	if userID == "" {
+		writeError(w, http.StatusBadRequest, "userId is required")
+		return
+	}
+	limit := parseLimit(r, 20, 1, 200)
+	if limit <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid limit")
+		return
+	}
+	providerFilter := strings.TrimSpace(strings.ToLower(r.URL.Query().Get("provider")))
+
+	providers := map[string]*socialImportProviderStatus{}
+	statusFor := func(p string) *socialImportProviderStatus {
+		if st, ok := providers[p]; ok {
+			return st
+		}
+		st := &socialImportProviderStatus{Provider: p}
+		providers[p] = st
+		return st
+	}
+
+	stateRows, err := h.db.QueryContext(r.Context(), `
+		SELECT provider, last_run_at, last_success_at, COALESCE(last_error,''), next_run_at, consecutive_failures
+		  FROM public.social_import_states
+		 WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer stateRows.Close()
+	for stateRows.Next() {
+		var (
+			provider                        string
+			lastRun, lastSuccess, nextRunAt sql.NullTime
+			lastError                       string
+			failures                        int
+		)
+		if err := stateRows.Scan(&provider, &lastRun, &lastSuccess, &lastError, &nextRunAt, &failures); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		st := statusFor(provider)
+		st.LastRunAt = nullTimePtr(lastRun)
+		st.LastSuccessAt = nullTimePtr(lastSuccess)
+		st.LastError = lastError
+		st.NextRunAt = nullTimePtr(nextRunAt)
+		st.ConsecutiveFailures = failures
+	}
+
+	// Latest run per provider (covers providers that were only ever skipped and so have no state row).
+	lastRows, err := h.db.QueryContext(r.Context(), `
+		SELECT DISTINCT ON (provider) `+socialImportRunColumns+`
+		  FROM public.social_import_runs
+		 WHERE user_id = $1
+		 ORDER BY provider, started_at DESC
+	`, userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer lastRows.Close()
+	for lastRows.Next() {
+		v, err := scanSocialImportRun(lastRows)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		vv := v
+		statusFor(v.Provider).LastRun = &vv
+	}
+
+	runRows, err := h.db.QueryContext(r.Context(), `
+		SELECT `+socialImportRunColumns+`
+		  FROM public.social_import_runs
+		 WHERE user_id = $1
+		   AND ($2 = '' OR provider = $2)
+		 ORDER BY started_at DESC
+		 LIMIT $3
+	`, userID, providerFilter, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer runRows.Close()
+	runs := make([]socialImportRunView, 0, limit)
+	for runRows.Next() {
+		v, err := scanSocialImportRun(runRows)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		runs = append(runs, v)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"ok":        true,
+		"userId":    userID,
+		"providers": providers,
+		"runs":      runs,
+	})
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+)
+
+func TestGetSocialLibrarySyncStatusForUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	next := now.Add(30 * time.Minute)
+	runCols := []string{"id", "provider", "trigger", "status", "fetched", "upserted", "skipped_reason", "error", "started_at", "finished_at", "duration_ms"}
+
+	mock.ExpectQuery(`FROM public\.social_import_states`).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"provider", "last_run_at", "last_success_at", "last_error", "next_run_at", "consecutive_failures"}).
+			AddRow("x", now, now.Add(-time.Hour), "x_rate_limited", next, 2).
+			AddRow("youtube", now, now, "", nil, 0))
+	mock.ExpectQuery(`SELECT DISTINCT ON \(provider\)[\s\S]*FROM public\.social_import_runs`).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows(runCols).
+			AddRow("r2", "x", "worker", "failed", 0, 0, "", "x_rate_limited", now, now, int64(120)).
+			AddRow("r3", "tiktok", "manual", "skipped", 0, 0, "daily_quota_exceeded", "", now, now, int64(1)))
+	mock.ExpectQuery(`FROM public\.social_import_runs[\s\S]*LIMIT \$3`).
+		WithArgs("u1", "x", 5).
+		WillReturnRows(sqlmock.NewRows(runCols).
+			AddRow("r2", "x", "worker", "failed", 0, 0, "", "x_rate_limited", now, now, int64(120)).
+			AddRow("r1", "x", "manual", "completed", 4, 4, "", "", now.Add(-time.Hour), now.Add(-time.Hour), int64(900)))
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/social-libraries/sync-status/user/u1?provider=x&limit=5", nil)
+	req = mux.SetURLVars(req, map[string]string{"userId": "u1"})
+	h.GetSocialLibrarySyncStatusForUser(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d body=%q", rr.Code, rr.Body.String())
+	}
+
+	var out struct {
+		Providers map[string]socialImportProviderStatus `json:"providers"`
+		Runs      []socialImportRunView                 `json:"runs"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	x := out.Providers["x"]
+	if x.LastError != "x_rate_limited" || x.ConsecutiveFailures != 2 || x.NextRunAt == nil || !x.NextRunAt.Equal(next) || x.LastRun == nil || x.LastRun.ID != "r2" {
+		t.Fatalf("unexpected x status %#v", x)
+	}
+	if yt := out.Providers["youtube"]; yt.NextRunAt != nil || yt.LastRun != nil || yt.LastSuccessAt == nil {
+		t.Fatalf("unexpected youtube status %#v", yt)
+	}
+	if tt := out.Providers["tiktok"]; tt.LastRun == nil || tt.LastRun.SkippedReason != "daily_quota_exceeded" || tt.LastRunAt != nil {
+		t.Fatalf("unexpected tiktok status %#v", tt)
+	}
+	if len(out.Runs) != 2 || out.Runs[1].Fetched != 4 {
+		t.Fatalf("unexpected runs %#v", out.Runs)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestGetSocialLibrarySyncStatusForUser_BadRequests(t *testing.T) {
+	h := New(nil)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/social-libraries/sync-status/user/u1", nil)
+	req = mux.SetURLVars(req, map[string]string{"userId": "u1"})
+	h.GetSocialLibrarySyncStatusForUser(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405 got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/social-libraries/sync-status/user/u1?limit=abc", nil)
+	req = mux.SetURLVars(req, map[string]string{"userId": "u1"})
+	h.GetSocialLibrarySyncStatusForUser(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 got %d", rr.Code)
+	}
+}
@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// threadsGraphBase is the Threads Graph API root (tokens come from the Threads login flow, not Facebook Login).
+var threadsGraphBase = "https://graph.threads.net/v1.0"
+
+const (
+	threadsMaxTextChars     = 500
+	threadsMaxCarouselItems = 20
+)
+
+type threadsOAuth struct {
+	AccessToken   string `json:"accessToken"`
+	TokenType     string `json:"tokenType"`
+	ThreadsUserID string `json:"threadsUserId"`
+	ExpiresAt     string `json:"expiresAt"`
+	Scope         string `json:"scope"`
+}
+
+// threadsMedia is one public media URL attached to a Threads post.
+type threadsMedia struct {
+	URL   string
+	Video bool
+}
+
+// threadsMediaFromRelPaths maps stored /media/... paths to public URLs, keeping only images and videos.
+func threadsMediaFromRelPaths(relMedia []string, mediaFiles []uploadedMedia, origin string) []threadsMedia {
+	out := make([]threadsMedia, 0, len(relMedia))
+	for i, rel := range relMedia {
+		ct := ""
+		fn := filepath.Base(rel)
+		if i < len(mediaFiles) {
+			ct = strings.ToLower(strings.TrimSpace(mediaFiles[i].ContentType))
+			if mediaFiles[i].Filename != "" {
+				fn = mediaFiles[i].Filename
+			}
+		}
+		if semi := strings.Index(ct, ";"); semi >= 0 {
+			ct = strings.TrimSpace(ct[:semi])
+		}
+		ext := strings.ToLower(filepath.Ext(fn))
+		if ext == "" {
+			ext = strings.ToLower(filepath.Ext(rel))
+		}
+		u := strings.TrimRight(origin, "/") + rel
+		switch {
+		case strings.HasPrefix(ct, "video/") || ext == ".mp4" || ext == ".mov" || ext == ".m4v" || ext == ".webm":
+			out = append(out, threadsMedia{URL: u, Video: true})
+		case strings.HasPrefix(ct, "image/") || ext == ".jpg" || ext == ".jpeg" || ext == ".png" || ext == ".webp" || ext == ".gif":
+			out = append(out, threadsMedia{URL: u})
+		}
+	}
+	return out
+}
+
+// publishThreads publishes a text, image, video or carousel post via the Threads container/publish flow:
+// create container(s) on /{threads-user-id}/threads, wait for FINISHED, then /{threads-user-id}/threads_publish.
+func (h *Handler) publishThreads(ctx context.Context, userID, caption string, media []threadsMedia, dryRun bool) (int, error, map[string]interface{}) {
+	details := map[string]interface{}{"mediaCount": len(media)}
+	if strings.TrimSpace(caption) == "" && len(media) == 0 {
+		return 0, fmt.Errorf("threads_requires_text_or_media"), details
+	}
+	if utf8.RuneCountInString(caption) > threadsMaxTextChars {
+		details["maxChars"] = threadsMaxTextChars
+		return 0, fmt.Errorf("threads_text_too_long"), details
+	}
+	if len(media) > threadsMaxCarouselItems {
+		details["maxItems"] = threadsMaxCarouselItems
+		return 0, fmt.Errorf("threads_too_many_media"), details
+	}
+
+	var raw []byte
+	if err := h.db.QueryRowContext(ctx, `SELECT value FROM public.user_settings WHERE user_id=$1 AND key='threads_oauth' AND value IS NOT NULL`, userID).Scan(&raw); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("not_connected"), details
+		}
+		return 0, err, details
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return 0, fmt.Errorf("not_connected"), details
+	}
+	var tok threadsOAuth
+	if err := json.Unmarshal(raw, &tok); err != nil {
+		return 0, fmt.Errorf("invalid_oauth_payload"), map[string]interface{}{"raw": truncate(string(raw), 800)}
+	}
+	if strings.TrimSpace(tok.AccessToken) == "" || strings.TrimSpace(tok.ThreadsUserID) == "" {
+		return 0, fmt.Errorf("not_connected"), details
+	}
+
+	mediaType := "TEXT"
+	switch {
+	case len(media) > 1:
+		mediaType = "CAROUSEL"
+	case len(media) == 1 && media[0].Video:
+		mediaType = "VIDEO"
+	case len(media) == 1:
+		mediaType = "IMAGE"
+	}
+	details["mediaType"] = mediaType
+
+	if dryRun {
+		urls := make([]string, 0, len(media))
+		for _, m := range media {
+			urls = append(urls, m.URL)
+		}
+		return 0, nil, map[string]interface{}{"dryRun": true, "mediaType": mediaType, "mediaCount": len(media), "mediaUrls": urls}
+	}
+
+	client := &http.Client{Timeout: 60 * time.Second}
+	accessToken := tok.AccessToken
+	threadsUserID := tok.ThreadsUserID
+
+	postForm := func(endpoint string, form url.Values) (map[string]interface{}, int, []byte, error) {
+		form.Set("access_token", accessToken)
+		req, _ := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		res, err := client.Do(req)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		b, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+		_ = res.Body.Close()
+		var obj map[string]interface{}
+		_ = json.Unmarshal(b, &obj)
+		return obj, res.StatusCode, b, nil
+	}
+
+	waitForContainer := func(containerID string) (string, error) {
+		type statusResp struct {
+			ID           string `json:"id"`
+			Status       string `json:"status"`
+			ErrorMessage string `json:"error_message"`
+		}
+		var last string
+		for i := 0; i < 30; i++ { // ~60s worst case (videos can take a while to process)
+			if i > 0 {
+				time.Sleep(2 * time.Second)
+			}
+			endpoint := fmt.Sprintf("%s/%s?fields=status,error_message&access_token=%s",
+				threadsGraphBase, url.PathEscape(containerID), url.QueryEscape(accessToken))
+			req, _ := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+			req.Header.Set("Accept", "application/json")
+			res, err := client.Do(req)
+			if err != nil {
+				last = "request_error"
+				log.Printf("[ThreadsPublish] container status poll %d: request_error: %v", i, err)
+				continue
+			}
+			b, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+			_ = res.Body.Close()
+			if res.StatusCode < 200 || res.StatusCode >= 300 {
+				last = fmt.Sprintf("http_%d", res.StatusCode)
+				log.Printf("[ThreadsPublish] container status poll %d: http_%d body=%s", i, res.StatusCode, truncate(string(b), 500))
+				continue
+			}
+			var sr statusResp
+			if err := json.Unmarshal(b, &sr); err != nil {
+				last = "bad_json"
+				continue
+			}
+			last = strings.ToUpper(strings.TrimSpace(sr.Status))
+			if last == "FINISHED" || last == "PUBLISHED" {
+				return last, nil
+			}
+			if last == "ERROR" || last == "EXPIRED" {
+				if sr.ErrorMessage != "" {
+					last = last + ":" + sr.ErrorMessage
+				}
+				return last, fmt.Errorf("threads_container_failed")
+			}
+		}
+		return last, fmt.Errorf("threads_container_not_ready")
+	}
+
+	createEndpoint := fmt.Sprintf("%s/%s/threads", threadsGraphBase, url.PathEscape(threadsUserID))
+	createContainer := func(form url.Values) (string, error, map[string]interface{}) {
+		obj, status, b, err := postForm(createEndpoint, form)
+		if err != nil {
+			return "", err, details
+		}
+		if status < 200 || status >= 300 {
+			msg := extractFacebookErrorMessage(b, string(b))
+			return "", fmt.Errorf("threads_container_failed"), map[string]interface{}{"status": status, "error": msg, "body": truncate(string(b), 1200)}
+		}
+		id, _ := obj["id"].(string)
+		if id == "" {
+			return "", fmt.Errorf("threads_missing_container_id"), map[string]interface{}{"body": truncate(string(b), 1200)}
+		}
+		return id, nil, nil
+	}
+	mediaForm := func(m threadsMedia) url.Values {
+		form := url.Values{}
+		if m.Video {
+			form.Set("media_type", "VIDEO")
+			form.Set("video_url", m.URL)
+		} else {
+			form.Set("media_type", "IMAGE")
+			form.Set("image_url", m.URL)
+		}
+		return form
+	}
+
+	creationID := ""
+	switch mediaType {
+	case "CAROUSEL":
+		children := make([]string, 0, len(media))
+		for _, m := range media {
+			form := mediaForm(m)
+			form.Set("is_carousel_item", "true")
+			id, err, d := createContainer(form)
+			if err != nil {
+				return 0, err, d
+			}
+			if st, err := waitForContainer(id); err != nil {
+				return 0, err, map[string]interface{}{"containerId": id, "status": st}
+			}
+			children = append(children, id)
+		}
+		details["childContainerIds"] = children
+		form := url.Values{}
+		form.Set("media_type", "CAROUSEL")
+		form.Set("children", strings.Join(children, ","))
+		form.Set("text", caption)
+		id, err, d := createContainer(form)
+		if err != nil {
+			return 0, err, d
+		}
+		creationID = id
+	case "IMAGE", "VIDEO":
+		form := mediaForm(media[0])
+		form.Set("text", caption)
+		id, err, d := createContainer(form)
+		if err != nil {
+			return 0, err, d
+		}
+		creationID = id
+	default:
+		form := url.Values{}
+		form.Set("media_type", "TEXT")
+		form.Set("text", caption)
+		id, err, d := createContainer(form)
+		if err != nil {
+			return 0, err, d
+		}
+		creationID = id
+	}
+	details["containerId"] = creationID
+
+	if st, err := waitForContainer(creationID); err != nil {
+		return 0, err, map[string]interface{}{"containerId": creationID, "status": st}
+	}
+
+	// Publish
+	form := url.Values{}
+	form.Set("creation_id", creationID)
+	pub, status, b, err := postForm(fmt.Sprintf("%s/%s/threads_publish", threadsGraphBase, url.PathEscape(threadsUserID)), form)
+	if err != nil {
+		return 0, err, details
+	}
+	if status < 200 || status >= 300 {
+		msg := extractFacebookErrorMessage(b, string(b))
+		return 0, fmt.Errorf("threads_publish_failed"), map[string]interface{}{"status": status, "error": msg, "body": truncate(string(b), 1200)}
+	}
+	mediaID, _ := pub["id"].(string)
+	details["publishedId"] = mediaID
+
+	// Best-effort permalink lookup (the publish response only carries the id).
+	permalink := ""
+	if mediaID != "" {
+		endpoint := fmt.Sprintf("%s/%s?fields=permalink&access_token=%s", threadsGraphBase, url.PathEscape(mediaID), url.QueryEscape(accessToken))
+		req, _ := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+		req.Header.Set("Accept", "application/json")
+		if res, err := client.Do(req); err == nil {
+			pb, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+			_ = res.Body.Close()
+			var obj map[string]interface{}
+			if res.StatusCode >= 200 && res.StatusCode < 300 && json.Unmarshal(pb, &obj) == nil {
+				permalink, _ = obj["permalink"].(string)
+			}
+		}
+		if permalink != "" {
+			details["permalink"] = permalink
+		}
+	}
+
+	// Store created item in SocialLibraries
+	if mediaID != "" {
+		rawPayload := strings.ReplaceAll(string(b), "\x00", "")
+		if !utf8.ValidString(rawPayload) {
+			rawPayload = strings.ToValidUTF8(rawPayload, "�")
+		}
+		rowID := fmt.Sprintf("threads:%s:%s", userID, mediaID)
+		_, _ = h.db.ExecContext(ctx, `
+			INSERT INTO public.social_libraries
+			  (id, user_id, network, content_type, title, permalink_url, media_url, thumbnail_url, posted_at, views, likes, raw_payload, external_id, created_at, updated_at)
+			VALUES
+			  ($1, $2, 'threads', 'post', NULLIF($3,''), NULLIF($4,''), NULL, NULL, NOW(), NULL, NULL, $5::jsonb, $6, NOW(), NOW())
+			ON CONFLICT (user_id, network, external_id)
+			DO UPDATE SET
+			  title = EXCLUDED.title,
+			  permalink_url = COALESCE(EXCLUDED.permalink_url, public.social_libraries.permalink_url),
+			  raw_payload = EXCLUDED.raw_payload,
+			  updated_at = NOW()
+		`, rowID, userID, caption, permalink, rawPayload, mediaID)
+	}
+
+	log.Printf("[ThreadsPublish] ok userId=%s mediaId=%s mediaType=%s", userID, mediaID, mediaType)
+	return 1, nil, details
+}
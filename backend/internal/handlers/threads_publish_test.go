@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func expectThreadsOAuth(mock sqlmock.Sqlmock) {
+	raw, _ := json.Marshal(threadsOAuth{AccessToken: "ttok", ThreadsUserID: "th1"})
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings.*key='threads_oauth'`).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(raw))
+}
+
+func TestPublishThreads_Carousel_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	expectThreadsOAuth(mock)
+	mock.ExpectExec(`INSERT INTO public\.social_libraries`).
+		WithArgs("threads:u1:th_media_1", "u1", "hello", "https://www.threads.net/@me/post/1", sqlmock.AnyArg(), "th_media_1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+
+	var (
+		mu       sync.Mutex
+		children []url.Values
+		parent   url.Values
+	)
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		if r.URL.Host != "graph.threads.net" {
+			return httpJSON(500, `{"error":"unexpected_host"}`, nil), nil
+		}
+		p := r.URL.Path
+		if r.Method == "POST" && p == "/v1.0/th1/threads" {
+			b, _ := io.ReadAll(r.Body)
+			form, _ := url.ParseQuery(string(b))
+			mu.Lock()
+			defer mu.Unlock()
+			if form.Get("media_type") == "CAROUSEL" {
+				parent = form
+				return httpJSON(200, `{"id":"pc1"}`, nil), nil
+			}
+			children = append(children, form)
+			if form.Get("media_type") == "VIDEO" {
+				return httpJSON(200, `{"id":"c2"}`, nil), nil
+			}
+			return httpJSON(200, `{"id":"c1"}`, nil), nil
+		}
+		if r.Method == "GET" && (p == "/v1.0/c1" || p == "/v1.0/c2" || p == "/v1.0/pc1") {
+			return httpJSON(200, `{"status":"FINISHED"}`, nil), nil
+		}
+		if r.Method == "POST" && p == "/v1.0/th1/threads_publish" {
+			b, _ := io.ReadAll(r.Body)
+			if !strings.Contains(string(b), "creation_id=pc1") {
+				return httpJSON(400, `{"error":{"message":"bad creation id"}}`, nil), nil
+			}
+			return httpJSON(200, `{"id":"th_media_1"}`, nil), nil
+		}
+		if r.Method == "GET" && p == "/v1.0/th_media_1" {
+			return httpJSON(200, `{"id":"th_media_1","permalink":"https://www.threads.net/@me/post/1"}`, nil), nil
+		}
+		return httpJSON(404, `{"error":"not_found"}`, nil), nil
+	}}
+
+	media := []threadsMedia{{URL: "https://app.test/media/a.png"}, {URL: "https://app.test/media/b.mp4", Video: true}}
+	posted, perr, details := h.publishThreads(context.Background(), "u1", "hello", media, false)
+	if perr != nil {
+		t.Fatalf("publish err: %v details=%#v", perr, details)
+	}
+	if posted != 1 || details["publishedId"] != "th_media_1" || details["mediaType"] != "CAROUSEL" {
+		t.Fatalf("unexpected result posted=%d details=%#v", posted, details)
+	}
+	if len(children) != 2 || children[0].Get("is_carousel_item") != "true" || children[1].Get("video_url") != "https://app.test/media/b.mp4" {
+		t.Fatalf("unexpected child containers %#v", children)
+	}
+	if parent.Get("children") != "c1,c2" || parent.Get("text") != "hello" {
+		t.Fatalf("unexpected carousel container %#v", parent)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestPublishThreads_TextOnly_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	expectThreadsOAuth(mock)
+	mock.ExpectExec(`INSERT INTO public\.social_libraries`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/v1.0/th1/threads":
+			b, _ := io.ReadAll(r.Body)
+			form, _ := url.ParseQuery(string(b))
+			if form.Get("media_type") != "TEXT" || form.Get("text") != "just text" {
+				return httpJSON(400, `{"error":{"message":"bad container"}}`, nil), nil
+			}
+			return httpJSON(200, `{"id":"t1"}`, nil), nil
+		case r.Method == "GET" && r.URL.Path == "/v1.0/t1":
+			return httpJSON(200, `{"status":"FINISHED"}`, nil), nil
+		case r.Method == "POST" && r.URL.Path == "/v1.0/th1/threads_publish":
+			return httpJSON(200, `{"id":"m1"}`, nil), nil
+		}
+		return httpJSON(404, `{"error":"not_found"}`, nil), nil
+	}}
+
+	posted, perr, details := h.publishThreads(context.Background(), "u1", "just text", nil, false)
+	if perr != nil || posted != 1 || details["mediaType"] != "TEXT" {
+		t.Fatalf("unexpected posted=%d err=%v details=%#v", posted, perr, details)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestPublishThreads_ContainerError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	expectThreadsOAuth(mock)
+
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		if r.Method == "POST" && r.URL.Path == "/v1.0/th1/threads" {
+			return httpJSON(200, `{"id":"v1"}`, nil), nil
+		}
+		if r.Method == "GET" && r.URL.Path == "/v1.0/v1" {
+			return httpJSON(200, `{"status":"ERROR","error_message":"unsupported codec"}`, nil), nil
+		}
+		return httpJSON(404, `{"error":"not_found"}`, nil), nil
+	}}
+
+	_, perr, details := h.publishThreads(context.Background(), "u1", "vid", []threadsMedia{{URL: "https://app.test/media/v.mov", Video: true}}, false)
+	if perr == nil || perr.Error() != "threads_container_failed" {
+		t.Fatalf("expected threads_container_failed got %v", perr)
+	}
+	if st, _ := details["status"].(string); !strings.Contains(st, "unsupported codec") {
+		t.Fatalf("expected error message in details got %#v", details)
+	}
+}
+
+func TestPublishThreads_DryRun_NotConnected_Validation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	// Validation happens before any DB access.
+	if _, perr, _ := h.publishThreads(context.Background(), "u1", strings.Repeat("x", threadsMaxTextChars+1), nil, true); perr == nil || perr.Error() != "threads_text_too_long" {
+		t.Fatalf("expected threads_text_too_long got %v", perr)
+	}
+
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings.*key='threads_oauth'`).
+		WithArgs("u1").
+		WillReturnError(sql.ErrNoRows)
+	if _, perr, _ := h.publishThreads(context.Background(), "u1", "hi", nil, true); perr == nil || perr.Error() != "not_connected" {
+		t.Fatalf("expected not_connected got %v", perr)
+	}
+
+	expectThreadsOAuth(mock)
+	posted, perr, details := h.publishThreads(context.Background(), "u1", "hi", []threadsMedia{{URL: "https://app.test/media/a.jpg"}}, true)
+	if perr != nil || posted != 0 || details["dryRun"] != true || details["mediaType"] != "IMAGE" {
+		t.Fatalf("unexpected dry run posted=%d err=%v details=%#v", posted, perr, details)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestThreadsMediaFromRelPaths(t *testing.T) {
+	got := threadsMediaFromRelPaths([]string{"/media/uploads/u1/a.jpg", "/media/uploads/u1/b.mp4", "/media/uploads/u1/c.txt"}, nil, "https://app.test/")
+	if len(got) != 2 || got[0].URL != "https://app.test/media/uploads/u1/a.jpg" || got[0].Video || !got[1].Video {
+		t.Fatalf("unexpected media %#v", got)
+	}
+}
@@ -0,0 +1,372 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/PortNumber53/simple-social-thing/backend/internal/models"
+)
+
+const (
+	tiktokAPIBase          = "https://open.tiktokapis.com"
+	tiktokMaxVideoTitle    = 2200
+	tiktokMaxPhotoTitle    = 90
+	tiktokMaxPhotoDesc     = 4000
+	tiktokDefaultPrivacy   = "PUBLIC_TO_EVERYONE"
+	tiktokStatusAttempts   = 40
+	tiktokStatusPublished  = "PUBLISH_COMPLETE"
+	tiktokStatusSentToUser = "SEND_TO_USER_INBOX"
+	tiktokStatusFailed     = "FAILED"
+)
+
+// tiktokStatusPollInterval is a var so tests don't wait between status checks.
+var tiktokStatusPollInterval = 3 * time.Second
+
+// tiktokMedia is what one TikTok post publishes: a video, or photos for a photo carousel (public URLs).
+type tiktokMedia struct {
+	VideoURL  string
+	PhotoURLs []string
+}
+
+// tiktokCreatorInfo is the subset of creator_info/query used to validate a direct post.
+type tiktokCreatorInfo struct {
+	Username        string   `json:"creator_username"`
+	PrivacyOptions  []string `json:"privacy_level_options"`
+	CommentDisabled bool     `json:"comment_disabled"`
+	DuetDisabled    bool     `json:"duet_disabled"`
+	StitchDisabled  bool     `json:"stitch_disabled"`
+	MaxVideoSec     int      `json:"max_video_post_duration_sec"`
+}
+
+// tiktokOptionsFor returns the post's TikTok options (zero value when unset).
+func tiktokOptionsFor(opts *models.PostOptions) models.TikTokPostOptions {
+	if opts == nil || opts.TikTok == nil {
+		return models.TikTokPostOptions{}
+	}
+	return *opts.TikTok
+}
+
+// tiktokAPI POSTs a JSON body to the Content Posting API and decodes data. TikTok reports failures in
+// error.code (anything but "ok") as well as with HTTP status codes.
+func tiktokAPI(ctx context.Context, client *http.Client, accessToken, path string, payload interface{}, data interface{}) (int, []byte, error) {
+	body, _ := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(ctx, "POST", tiktokAPIBase+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("Accept", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	_ = res.Body.Close()
+	var env struct {
+		Data  json.RawMessage `json:"data"`
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	_ = json.Unmarshal(b, &env)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, b, fmt.Errorf("tiktok_non_2xx")
+	}
+	if env.Error.Code != "" && env.Error.Code != "ok" {
+		return res.StatusCode, b, fmt.Errorf("tiktok_%s", env.Error.Code)
+	}
+	if data != nil && len(env.Data) > 0 {
+		_ = json.Unmarshal(env.Data, data)
+	}
+	return res.StatusCode, b, nil
+}
+
+// tiktokResolvePrivacy picks the privacy level for a direct post from what the creator allows.
+func tiktokResolvePrivacy(requested string, allowed []string) (string, error) {
+	has := func(p string) bool {
+		for _, a := range allowed {
+			if a == p {
+				return true
+			}
+		}
+		return false
+	}
+	if requested != "" {
+		if !has(requested) {
+			return "", fmt.Errorf("tiktok_privacy_not_allowed")
+		}
+		return requested, nil
+	}
+	if has(tiktokDefaultPrivacy) || len(allowed) == 0 {
+		return tiktokDefaultPrivacy, nil
+	}
+	return allowed[0], nil
+}
+
+// tiktokPostPayload builds the init request for a video or photo post and returns it with the API path.
+func tiktokPostPayload(caption string, media tiktokMedia, opts models.TikTokPostOptions, privacy string, creator tiktokCreatorInfo) (string, map[string]interface{}) {
+	draft := opts.Mode == "draft"
+	if media.VideoURL != "" {
+		source := map[string]interface{}{"source": "PULL_FROM_URL", "video_url": media.VideoURL}
+		if draft {
+			return "/v2/post/publish/inbox/video/init/", map[string]interface{}{"source_info": source}
+		}
+		return "/v2/post/publish/video/init/", map[string]interface{}{
+			"post_info": map[string]interface{}{
+				"title":                    truncate(caption, tiktokMaxVideoTitle),
+				"privacy_level":            privacy,
+				"disable_comment":          opts.DisableComment || creator.CommentDisabled,
+				"disable_duet":             opts.DisableDuet || creator.DuetDisabled,
+				"disable_stitch":           opts.DisableStitch || creator.StitchDisabled,
+				"video_cover_timestamp_ms": opts.CoverTimestampMs,
+				"brand_content_toggle":     opts.BrandContent,
+				"brand_organic_toggle":     opts.BrandOrganic,
+			},
+			"source_info": source,
+		}
+	}
+
+	// Photo posts: the first caption line is the title, the whole caption the description.
+	title := strings.TrimSpace(strings.SplitN(caption, "\n", 2)[0])
+	postInfo := map[string]interface{}{
+		"title":       truncate(title, tiktokMaxPhotoTitle),
+		"description": truncate(caption, tiktokMaxPhotoDesc),
+	}
+	mode := "MEDIA_UPLOAD"
+	if !draft {
+		mode = "DIRECT_POST"
+		postInfo["privacy_level"] = privacy
+		postInfo["disable_comment"] = opts.DisableComment || creator.CommentDisabled
+		postInfo["auto_add_music"] = opts.AutoAddMusic
+		postInfo["brand_content_toggle"] = opts.BrandContent
+		postInfo["brand_organic_toggle"] = opts.BrandOrganic
+	}
+	cover := opts.PhotoCoverIndex
+	if cover >= len(media.PhotoURLs) {
+		cover = 0
+	}
+	return "/v2/post/publish/content/init/", map[string]interface{}{
+		"post_info": postInfo,
+		"source_info": map[string]interface{}{
+			"source":            "PULL_FROM_URL",
+			"photo_cover_index": cover,
+			"photo_images":      media.PhotoURLs,
+		},
+		"post_mode":  mode,
+		"media_type": "PHOTO",
+	}
+}
+
+// tiktokWaitForPublish polls status/fetch until the post is live (or in the inbox, for drafts), failed, or
+// attempts run out. It returns the last status, the fail reason and the public post ids.
+func tiktokWaitForPublish(ctx context.Context, client *http.Client, accessToken, publishID string, attempts int) (string, string, []string, int) {
+	status, reason := "", ""
+	var postIDs []string
+	checks := 0
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return status, reason, postIDs, checks
+			case <-time.After(tiktokStatusPollInterval):
+			}
+		}
+		checks++
+		var data struct {
+			Status     string        `json:"status"`
+			FailReason string        `json:"fail_reason"`
+			PostIDs    []json.Number `json:"publicaly_available_post_id"`
+		}
+		if _, _, err := tiktokAPI(ctx, client, accessToken, "/v2/post/publish/status/fetch/", map[string]string{"publish_id": publishID}, &data); err != nil {
+			continue
+		}
+		status, reason = data.Status, data.FailReason
+		postIDs = postIDs[:0]
+		for _, id := range data.PostIDs {
+			postIDs = append(postIDs, id.String())
+		}
+		switch status {
+		case tiktokStatusPublished, tiktokStatusSentToUser, tiktokStatusFailed:
+			return status, reason, postIDs, checks
+		}
+	}
+	return status, reason, postIDs, checks
+}
+
+// publishTikTok posts a video or photo carousel directly, or uploads it to the creator's inbox as a draft,
+// then polls the publish status. A post still processing when polling gives up counts as posted.
+func (h *Handler) publishTikTok(ctx context.Context, userID, caption string, media tiktokMedia, opts models.TikTokPostOptions, dryRun bool) (int, error, map[string]interface{}) {
+	details := map[string]interface{}{}
+	if media.VideoURL != "" {
+		details["videoUrl"] = media.VideoURL
+	} else {
+		details["photoUrls"] = media.PhotoURLs
+	}
+	draft := opts.Mode == "draft"
+	if draft {
+		details["mode"] = "draft"
+	}
+	if strings.TrimSpace(media.VideoURL) == "" && len(media.PhotoURLs) == 0 {
+		return 0, fmt.Errorf("tiktok_requires_video"), details
+	}
+
+	// Load token from UserSettings
+	var raw []byte
+	if err := h.db.QueryRowContext(ctx, `SELECT value FROM public.user_settings WHERE user_id=$1 AND key='tiktok_oauth' AND value IS NOT NULL`, userID).Scan(&raw); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("not_connected"), details
+		}
+		return 0, err, details
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return 0, fmt.Errorf("not_connected"), details
+	}
+	var tok tiktokOAuth
+	if err := json.Unmarshal(raw, &tok); err != nil {
+		return 0, fmt.Errorf("invalid_oauth_payload"), map[string]interface{}{"raw": truncate(string(raw), 800)}
+	}
+	if strings.TrimSpace(tok.AccessToken) == "" || strings.TrimSpace(tok.OpenID) == "" {
+		return 0, fmt.Errorf("not_connected"), details
+	}
+
+	// Guard: direct posts need video.upload + video.publish; inbox drafts only video.upload.
+	// TikTok can return scopes as space-delimited; we normalize spaces/commas and then check exact tokens.
+	scopeSet := map[string]bool{}
+	for _, s := range strings.FieldsFunc(tok.Scope, func(r rune) bool {
+		return r == ' ' || r == ',' || r == '\n' || r == '\r' || r == '\t'
+	}) {
+		s = strings.TrimSpace(s)
+		if s != "" {
+			scopeSet[s] = true
+		}
+	}
+	required := []string{"video.upload", "video.publish"}
+	if draft {
+		required = []string{"video.upload"}
+	}
+	for _, s := range required {
+		if !scopeSet[s] {
+			return 0, fmt.Errorf("missing_scope"), map[string]interface{}{"scope": tok.Scope, "required": required}
+		}
+	}
+
+	if dryRun {
+		details["dryRun"] = true
+		return 0, nil, details
+	}
+
+	client := &http.Client{Timeout: 60 * time.Second}
+	privacy := ""
+	var creator tiktokCreatorInfo
+	if !draft {
+		status, b, err := tiktokAPI(ctx, client, tok.AccessToken, "/v2/post/publish/creator_info/query/", map[string]interface{}{}, &creator)
+		if err != nil {
+			return 0, err, map[string]interface{}{"status": status, "body": truncate(string(b), 2000), "step": "creator_info"}
+		}
+		privacy, err = tiktokResolvePrivacy(opts.Privacy, creator.PrivacyOptions)
+		if err != nil {
+			return 0, err, map[string]interface{}{"requested": opts.Privacy, "allowed": creator.PrivacyOptions}
+		}
+		details["privacyLevel"] = privacy
+		details["creator"] = creator.Username
+	}
+
+	path, payload := tiktokPostPayload(caption, media, opts, privacy, creator)
+	var init struct {
+		PublishID string `json:"publish_id"`
+	}
+	status, b, err := tiktokAPI(ctx, client, tok.AccessToken, path, payload, &init)
+	if err != nil {
+		return 0, err, map[string]interface{}{"status": status, "body": truncate(string(b), 2000)}
+	}
+	details["publishId"] = init.PublishID
+	details["response"] = json.RawMessage(b)
+	if init.PublishID == "" {
+		log.Printf("[TTPost] ok userId=%s publishId=<none>", userID)
+		return 1, nil, details
+	}
+
+	st, reason, postIDs, checks := tiktokWaitForPublish(ctx, client, tok.AccessToken, init.PublishID, tiktokStatusAttempts)
+	details["publishStatus"] = st
+	details["statusChecks"] = checks
+	if len(postIDs) > 0 {
+		details["postIds"] = postIDs
+	}
+	if st == tiktokStatusFailed {
+		details["failReason"] = reason
+		log.Printf("[TTPost] failed userId=%s publishId=%s reason=%s", userID, init.PublishID, reason)
+		return 0, fmt.Errorf("tiktok_publish_failed"), details
+	}
+	if st != tiktokStatusPublished && st != tiktokStatusSentToUser {
+		details["statusPending"] = true
+	}
+	log.Printf("[TTPost] ok userId=%s publishId=%s status=%s draft=%v photos=%d", userID, init.PublishID, st, draft, len(media.PhotoURLs))
+	return 1, nil, details
+}
+
+// tiktokPhotoReady reports whether TikTok accepts the image as is (photo posts take JPEG and WebP only).
+func tiktokPhotoReady(m uploadedMedia) bool {
+	ct := strings.ToLower(m.ContentType)
+	ext := strings.ToLower(filepath.Ext(m.Filename))
+	return strings.HasPrefix(ct, "image/jpeg") || strings.HasPrefix(ct, "image/webp") || ext == ".jpg" || ext == ".jpeg" || ext == ".webp"
+}
+
+// convertImageToJPEG re-encodes an image (PNG, GIF) as JPEG for networks that only take JPEG.
+func convertImageToJPEG(b []byte) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, img, &jpeg.Options{Quality: 92}); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// tiktokPhotoRels picks up to tiktokMaxPhotos images for a photo post, converting ones TikTok can't take
+// (PNG, GIF) to JPEG and saving them next to the originals.
+func tiktokPhotoRels(jobID, userID, postID string, relMedia []string, mediaFiles []uploadedMedia) ([]string, int) {
+	out := []string{}
+	converted := 0
+	for i, rel := range relMedia {
+		if len(out) >= tiktokMaxPhotos || i >= len(mediaFiles) {
+			break
+		}
+		m := mediaFiles[i]
+		if !strings.HasPrefix(strings.ToLower(m.ContentType), "image/") {
+			continue
+		}
+		if tiktokPhotoReady(m) {
+			out = append(out, rel)
+			continue
+		}
+		b, err := convertImageToJPEG(m.Bytes)
+		if err != nil {
+			log.Printf("[PublishJob] tiktok image conversion failed jobId=%s userId=%s postId=%s rel=%s err=%v", jobID, userID, postID, rel, err)
+			continue
+		}
+		base := strings.TrimSuffix(m.Filename, filepath.Ext(m.Filename))
+		saved, _, err := saveUploadedMedia(userID, "", []uploadedMedia{{Filename: base + ".jpg", ContentType: "image/jpeg", Bytes: b}})
+		if err != nil || len(saved) == 0 {
+			log.Printf("[PublishJob] tiktok converted image save failed jobId=%s userId=%s postId=%s rel=%s err=%v", jobID, userID, postID, rel, err)
+			continue
+		}
+		out = append(out, saved[0])
+		converted++
+	}
+	return out, converted
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PortNumber53/simple-social-thing/backend/internal/models"
+)
+
+// stubTikTok answers the Content Posting API, recording init payloads by path.
+func stubTikTok(t *testing.T, privacy string, statuses []string, inits map[string]map[string]interface{}) func() {
+	t.Helper()
+	orig, origInterval := http.DefaultTransport, tiktokStatusPollInterval
+	tiktokStatusPollInterval = 0
+	polls := 0
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/creator_info/query/"):
+			return httpJSON(200, `{"data":{"creator_username":"me","privacy_level_options":`+privacy+`,"duet_disabled":true},"error":{"code":"ok"}}`, nil), nil
+		case strings.HasSuffix(r.URL.Path, "/status/fetch/"):
+			st := statuses[len(statuses)-1]
+			if polls < len(statuses) {
+				st = statuses[polls]
+			}
+			polls++
+			return httpJSON(200, `{"data":{"status":"`+st+`","fail_reason":"picture_size_check_failed","publicaly_available_post_id":[7311]},"error":{"code":"ok"}}`, nil), nil
+		case strings.HasSuffix(r.URL.Path, "/init/"):
+			var body map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			inits[r.URL.Path] = body
+			return httpJSON(200, `{"data":{"publish_id":"pub1"},"error":{"code":"ok"}}`, nil), nil
+		}
+		return httpJSON(404, `{"error":{"code":"not_found"}}`, nil), nil
+	}}
+	return func() { http.DefaultTransport, tiktokStatusPollInterval = orig, origInterval }
+}
+
+func expectTikTokToken(mock sqlmock.Sqlmock, scope string) {
+	raw, _ := json.Marshal(tiktokOAuth{AccessToken: "tok", OpenID: "oid", Scope: scope})
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings.*key='tiktok_oauth'`).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(raw))
+}
+
+func TestPublishTikTok_DirectVideoUsesCreatorInfoAndPollsStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+	expectTikTokToken(mock, "video.upload,video.publish")
+
+	inits := map[string]map[string]interface{}{}
+	defer stubTikTok(t, `["FOLLOWER_OF_CREATOR","SELF_ONLY"]`, []string{"PROCESSING_DOWNLOAD", "PUBLISH_COMPLETE"}, inits)()
+
+	opts := models.TikTokPostOptions{DisableComment: true, CoverTimestampMs: 1500, BrandOrganic: true}
+	posted, perr, details := h.publishTikTok(context.Background(), "u1", "caption", tiktokMedia{VideoURL: "https://x/v.mp4"}, opts, false)
+	if perr != nil || posted != 1 {
+		t.Fatalf("expected success, got posted=%d err=%v details=%v", posted, perr, details)
+	}
+	info, _ := inits["/v2/post/publish/video/init/"]["post_info"].(map[string]interface{})
+	// Public isn't allowed for this creator, so the first allowed level is used; duet follows the creator's setting.
+	if info["privacy_level"] != "FOLLOWER_OF_CREATOR" || info["disable_comment"] != true || info["disable_duet"] != true || info["disable_stitch"] != false ||
+		info["video_cover_timestamp_ms"] != float64(1500) || info["brand_organic_toggle"] != true {
+		t.Fatalf("unexpected post_info %v", info)
+	}
+	if details["publishStatus"] != "PUBLISH_COMPLETE" || details["statusChecks"] != 2 || details["publishId"] != "pub1" {
+		t.Fatalf("unexpected details %v", details)
+	}
+	if ids, _ := details["postIds"].([]string); len(ids) != 1 || ids[0] != "7311" {
+		t.Fatalf("unexpected post ids %v", details["postIds"])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestPublishTikTok_RejectsPrivacyTheCreatorDisallows(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+	expectTikTokToken(mock, "video.upload video.publish")
+
+	inits := map[string]map[string]interface{}{}
+	defer stubTikTok(t, `["SELF_ONLY"]`, []string{"PUBLISH_COMPLETE"}, inits)()
+
+	_, perr, _ := h.publishTikTok(context.Background(), "u1", "caption", tiktokMedia{VideoURL: "https://x/v.mp4"}, models.TikTokPostOptions{Privacy: "PUBLIC_TO_EVERYONE"}, false)
+	if perr == nil || perr.Error() != "tiktok_privacy_not_allowed" || len(inits) != 0 {
+		t.Fatalf("expected tiktok_privacy_not_allowed before init, got err=%v inits=%v", perr, inits)
+	}
+}
+
+func TestPublishTikTok_DraftPhotosGoToInboxAndFailuresAreReported(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+	// Drafts only need video.upload, and skip the creator-info query.
+	expectTikTokToken(mock, "video.upload")
+	expectTikTokToken(mock, "video.upload")
+
+	inits := map[string]map[string]interface{}{}
+	defer stubTikTok(t, `[]`, []string{"SEND_TO_USER_INBOX"}, inits)()
+
+	draft := models.TikTokPostOptions{Mode: "draft", PhotoCoverIndex: 1}
+	photos := tiktokMedia{PhotoURLs: []string{"https://x/a.jpg", "https://x/b.jpg"}}
+	posted, perr, details := h.publishTikTok(context.Background(), "u1", "Title line\nmore", photos, draft, false)
+	if perr != nil || posted != 1 || details["publishStatus"] != "SEND_TO_USER_INBOX" {
+		t.Fatalf("expected draft to reach the inbox, got posted=%d err=%v details=%v", posted, perr, details)
+	}
+	body := inits["/v2/post/publish/content/init/"]
+	info, _ := body["post_info"].(map[string]interface{})
+	source, _ := body["source_info"].(map[string]interface{})
+	if body["post_mode"] != "MEDIA_UPLOAD" || body["media_type"] != "PHOTO" || info["title"] != "Title line" || info["privacy_level"] != nil ||
+		source["photo_cover_index"] != float64(1) || len(source["photo_images"].([]interface{})) != 2 {
+		t.Fatalf("unexpected photo init %v", body)
+	}
+
+	_, perr, _ = h.publishTikTok(context.Background(), "u1", "caption", tiktokMedia{VideoURL: "https://x/v.mp4"}, draft, false)
+	if perr != nil || inits["/v2/post/publish/inbox/video/init/"] == nil || inits["/v2/post/publish/inbox/video/init/"]["post_info"] != nil {
+		t.Fatalf("expected inbox video init without post_info, got err=%v inits=%v", perr, inits)
+	}
+
+	// A FAILED status is a failed publish (and is not retried).
+	expectTikTokToken(mock, "video.upload")
+	defer stubTikTok(t, `[]`, []string{"FAILED"}, inits)()
+	posted, perr, details = h.publishTikTok(context.Background(), "u1", "caption", photos, draft, false)
+	if perr == nil || perr.Error() != "tiktok_publish_failed" || posted != 0 || details["failReason"] != "picture_size_check_failed" || details["status"] != nil {
+		t.Fatalf("expected tiktok_publish_failed, got posted=%d err=%v details=%v", posted, perr, details)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestNormalizeTikTokOptions(t *testing.T) {
+	bad := []models.TikTokPostOptions{
+		{Privacy: "friends"},
+		{Mode: "later"},
+		{Privacy: "SELF_ONLY", BrandContent: true},
+		{CoverTimestampMs: -1},
+		{PhotoCoverIndex: tiktokMaxPhotos},
+	}
+	for _, o := range bad {
+		o := o
+		if _, err := normalizePostOptions(&models.PostOptions{TikTok: &o}, nil); err == nil {
+			t.Fatalf("expected %+v to be rejected", o)
+		}
+	}
+	got, err := normalizePostOptions(&models.PostOptions{TikTok: &models.TikTokPostOptions{Privacy: "self_only", Mode: "direct"}}, nil)
+	if err != nil || got.TikTok == nil || got.TikTok.Privacy != "SELF_ONLY" || got.TikTok.Mode != "" {
+		t.Fatalf("unexpected normalization %+v err=%v", got, err)
+	}
+}
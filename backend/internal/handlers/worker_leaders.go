@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/PortNumber53/simple-social-thing/backend/internal/workers"
+)
+
+// SetWorkerLeader sets the leader elector whose lock status is reported by GetWorkerLeaders.
+func (h *Handler) SetWorkerLeader(l *workers.Leader) {
+	h.leader = l
+}
+
+// GetWorkerLeaders reports, for each singleton background worker, whether this instance leads it and which
+// instance currently holds its lock. Lock holders include hostnames and PIDs, so it is admin only.
+//
+// URL: GET /api/workers/leaders/admin/user/{userId}
+func (h *Handler) GetWorkerLeaders(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	adminUserID := strings.TrimSpace(pathVar(r, "userId"))
+	if adminUserID == "" {
+		writeError(w, http.StatusBadRequest, "userId is required")
+		return
+	}
+	if !h.isAdminUser(adminUserID) {
+		writeError(w, http.StatusForbidden, "admin access required")
+		return
+	}
+	instanceID := ""
+	if h.leader != nil {
+		instanceID = h.leader.InstanceID
+	}
+	locks, err := h.leader.Status(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"ok":         true,
+		"instanceId": instanceID,
+		"workers":    locks,
+	})
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+)
+
+func workerLeadersRequest(h *Handler, userID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/workers/leaders/admin/user/"+userID, nil)
+	req = mux.SetURLVars(req, map[string]string{"userId": userID})
+	rr := httptest.NewRecorder()
+	h.GetWorkerLeaders(rr, req)
+	return rr
+}
+
+func expectAdminCheck(mock sqlmock.Sqlmock, userID string, admin bool) {
+	mock.ExpectQuery(`FROM public\.users\s+WHERE id = \$1`).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"ok"}).AddRow(admin))
+}
+
+func TestGetWorkerLeaders_NoLeaderConfigured(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	expectAdminCheck(mock, "admin1", true)
+	rr := workerLeadersRequest(h, "admin1")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d", rr.Code)
+	}
+	var out struct {
+		OK      bool              `json:"ok"`
+		Workers []json.RawMessage `json:"workers"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil || !out.OK || len(out.Workers) != 0 {
+		t.Fatalf("unexpected body %q", rr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestGetWorkerLeaders_NonAdminForbidden(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	expectAdminCheck(mock, "u1", false)
+	if rr := workerLeadersRequest(h, "u1"); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 got %d body=%q", rr.Code, rr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
@@ -0,0 +1,503 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// xAPIBase is the X (Twitter) v2 API root.
+var xAPIBase = "https://api.x.com/2"
+
+const (
+	xMaxTweetWeight   = 280
+	xMaxThreadLength  = 25
+	xMaxImagesPerPost = 4
+	xUploadChunkBytes = 4 << 20 // 4MB per APPEND segment
+)
+
+type xOAuth struct {
+	AccessToken  string `json:"accessToken"`
+	TokenType    string `json:"tokenType"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresAt    string `json:"expiresAt"`
+	Scope        string `json:"scope"`
+	XUserID      string `json:"xUserId"`
+	Username     string `json:"username"`
+}
+
+func (h *Handler) loadXOAuth(ctx context.Context, userID string) (xOAuth, error) {
+	var tok xOAuth
+	var raw []byte
+	if err := h.db.QueryRowContext(ctx, `SELECT value FROM public.user_settings WHERE user_id=$1 AND key='x_oauth' AND value IS NOT NULL`, userID).Scan(&raw); err != nil {
+		if err == sql.ErrNoRows {
+			return tok, fmt.Errorf("not_connected")
+		}
+		return tok, err
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return tok, fmt.Errorf("not_connected")
+	}
+	if err := json.Unmarshal(raw, &tok); err != nil {
+		return tok, fmt.Errorf("invalid_oauth_payload")
+	}
+	if strings.TrimSpace(tok.AccessToken) == "" {
+		return tok, fmt.Errorf("not_connected")
+	}
+	return tok, nil
+}
+
+// xTextWeight approximates X's weighted character count: most Latin/Cyrillic/punctuation counts 1,
+// everything else (CJK, emoji) counts 2. URLs are counted at face value, which only over-estimates
+// once they are longer than the 23-char t.co length.
+func xTextWeight(s string) int {
+	w := 0
+	for _, r := range s {
+		switch {
+		case r <= 0x10FF, r >= 0x2000 && r <= 0x200D, r >= 0x2010 && r <= 0x201F, r >= 0x2032 && r <= 0x2037:
+			w++
+		default:
+			w += 2
+		}
+	}
+	return w
+}
+
+// splitXThread splits a caption into tweet-sized parts on word boundaries, numbering them " i/n" when
+// more than one part is needed.
+func splitXThread(caption string) []string {
+	caption = strings.TrimSpace(caption)
+	if xTextWeight(caption) <= xMaxTweetWeight {
+		return []string{caption}
+	}
+	const suffixReserve = 8 // " 99/99" plus slack
+	limit := xMaxTweetWeight - suffixReserve
+
+	parts := make([]string, 0, 4)
+	var cur strings.Builder
+	curW := 0
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" {
+			parts = append(parts, s)
+		}
+		cur.Reset()
+		curW = 0
+	}
+	for _, word := range strings.FieldsFunc(caption, unicode.IsSpace) {
+		ww := xTextWeight(word)
+		// Hard-split words that can't fit on their own (long URLs, unbroken CJK text).
+		for ww > limit {
+			if curW > 0 {
+				flush()
+			}
+			cut, cutW := 0, 0
+			for i, r := range word {
+				rw := xTextWeight(string(r))
+				if cutW+rw > limit {
+					cut = i
+					break
+				}
+				cutW += rw
+			}
+			parts = append(parts, word[:cut])
+			word = word[cut:]
+			ww = xTextWeight(word)
+		}
+		sep := 0
+		if curW > 0 {
+			sep = 1
+		}
+		if curW+sep+ww > limit {
+			flush()
+			sep = 0
+		}
+		if sep == 1 {
+			cur.WriteByte(' ')
+		}
+		cur.WriteString(word)
+		curW += sep + ww
+	}
+	flush()
+	if len(parts) > 1 {
+		for i := range parts {
+			parts[i] = fmt.Sprintf("%s %d/%d", parts[i], i+1, len(parts))
+		}
+	}
+	return parts
+}
+
+func xMediaKind(m uploadedMedia) (category string, isVideo bool, ok bool) {
+	ct := strings.ToLower(strings.TrimSpace(m.ContentType))
+	if semi := strings.Index(ct, ";"); semi >= 0 {
+		ct = strings.TrimSpace(ct[:semi])
+	}
+	fn := strings.ToLower(m.Filename)
+	switch {
+	case ct == "image/gif" || strings.HasSuffix(fn, ".gif"):
+		return "tweet_gif", false, true
+	case strings.HasPrefix(ct, "image/") || strings.HasSuffix(fn, ".jpg") || strings.HasSuffix(fn, ".jpeg") || strings.HasSuffix(fn, ".png") || strings.HasSuffix(fn, ".webp"):
+		return "tweet_image", false, true
+	case strings.HasPrefix(ct, "video/") || strings.HasSuffix(fn, ".mp4") || strings.HasSuffix(fn, ".mov"):
+		return "tweet_video", true, true
+	}
+	return "", false, false
+}
+
+// publishX posts to X: media is uploaded with the chunked upload flow (INIT/APPEND/FINALIZE/STATUS),
+// attached to the first tweet, and captions over the character limit continue as a reply thread.
+// A post carries either one video or up to four images; extra media is skipped and reported in details.
+func (h *Handler) publishX(ctx context.Context, userID, caption string, media []uploadedMedia, dryRun bool) (int, error, map[string]interface{}) {
+	return h.resumeXThread(ctx, userID, caption, media, nil, dryRun)
+}
+
+// resumeXThread is publishX for a thread whose first tweets (postedIDs, in order) an earlier run already
+// posted: no media is uploaded and the remaining parts reply to the last posted tweet.
+func (h *Handler) resumeXThread(ctx context.Context, userID, caption string, media []uploadedMedia, postedIDs []string, dryRun bool) (int, error, map[string]interface{}) {
+	details := map[string]interface{}{}
+	if len(postedIDs) > 0 {
+		details["resumedParts"] = len(postedIDs)
+	}
+
+	// Pick what X accepts: one video, or up to four images/GIFs.
+	var picked []uploadedMedia
+	skipped := 0
+	for _, m := range media {
+		if _, isVideo, ok := xMediaKind(m); ok && isVideo {
+			picked = []uploadedMedia{m}
+			break
+		}
+	}
+	if len(picked) == 0 {
+		for _, m := range media {
+			if _, _, ok := xMediaKind(m); ok && len(picked) < xMaxImagesPerPost {
+				picked = append(picked, m)
+			}
+		}
+	}
+	skipped = len(media) - len(picked)
+	if skipped > 0 {
+		details["skippedMedia"] = skipped
+	}
+
+	parts := splitXThread(caption)
+	if len(parts) == 1 && parts[0] == "" && len(picked) == 0 {
+		return 0, fmt.Errorf("x_requires_text_or_media"), details
+	}
+	if len(parts) > xMaxThreadLength {
+		details["parts"] = len(parts)
+		details["maxParts"] = xMaxThreadLength
+		return 0, fmt.Errorf("x_caption_too_long"), details
+	}
+	details["parts"] = len(parts)
+	details["mediaCount"] = len(picked)
+
+	tok, err := h.loadXOAuth(ctx, userID)
+	if err != nil {
+		return 0, err, details
+	}
+	if strings.TrimSpace(tok.ExpiresAt) != "" {
+		if t, err := time.Parse(time.RFC3339, tok.ExpiresAt); err == nil && time.Now().After(t.Add(-30*time.Second)) {
+			return 0, fmt.Errorf("token_expired_reconnect"), map[string]interface{}{"expiresAt": tok.ExpiresAt}
+		}
+	}
+
+	if dryRun {
+		details["dryRun"] = true
+		details["thread"] = parts
+		return 0, nil, details
+	}
+
+	client := &http.Client{Timeout: 120 * time.Second}
+	do := func(req *http.Request) (int, []byte, error) {
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(tok.AccessToken))
+		req.Header.Set("Accept", "application/json")
+		res, err := client.Do(req)
+		if err != nil {
+			return 0, nil, err
+		}
+		defer res.Body.Close()
+		b, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+		return res.StatusCode, b, nil
+	}
+	postJSON := func(endpoint string, payload interface{}) (int, []byte, error) {
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		return do(req)
+	}
+
+	mediaIDs := make([]string, 0, len(picked))
+	if len(postedIDs) > 0 {
+		// The media went out with the root tweet.
+		picked = nil
+	}
+	for _, m := range picked {
+		category, _, _ := xMediaKind(m)
+		id, err, d := xUploadMedia(ctx, do, postJSON, m, category)
+		if err != nil {
+			if d == nil {
+				d = map[string]interface{}{}
+			}
+			d["filename"] = m.Filename
+			return 0, err, d
+		}
+		mediaIDs = append(mediaIDs, id)
+	}
+	if len(mediaIDs) > 0 {
+		details["mediaIds"] = mediaIDs
+	}
+
+	tweetIDs := make([]string, 0, len(parts))
+	tweetIDs = append(tweetIDs, postedIDs...)
+	var rootBody []byte
+	for i, text := range parts {
+		if i < len(tweetIDs) {
+			continue
+		}
+		payload := map[string]interface{}{"text": text}
+		if i == 0 && len(mediaIDs) > 0 {
+			payload["media"] = map[string]interface{}{"media_ids": mediaIDs}
+		}
+		if i > 0 {
+			payload["reply"] = map[string]interface{}{"in_reply_to_tweet_id": tweetIDs[i-1]}
+		}
+		status, b, err := postJSON(xAPIBase+"/tweets", payload)
+		if err == nil && (status < 200 || status >= 300) {
+			err = fmt.Errorf("x_create_post_non_2xx")
+		}
+		var out struct {
+			Data struct {
+				ID string `json:"id"`
+			} `json:"data"`
+		}
+		if err == nil {
+			_ = json.Unmarshal(b, &out)
+			if out.Data.ID == "" {
+				err = fmt.Errorf("x_missing_post_id")
+			}
+		}
+		if err != nil {
+			d := map[string]interface{}{"status": status, "body": truncate(string(b), 1200), "tweetIds": tweetIDs}
+			if i == 0 {
+				return 0, err, d
+			}
+			// The root tweet is live; report the partial thread without retrying (that would double-post).
+			d["failedPart"] = i + 1
+			log.Printf("[XPublish] thread_incomplete userId=%s rootId=%s part=%d/%d err=%v", userID, tweetIDs[0], i+1, len(parts), err)
+			return 1, fmt.Errorf("x_thread_incomplete"), d
+		}
+		tweetIDs = append(tweetIDs, out.Data.ID)
+		if i == 0 {
+			rootBody = b
+		}
+	}
+	details["tweetIds"] = tweetIDs
+	details["publishedId"] = tweetIDs[0]
+	permalink := "https://x.com/i/web/status/" + tweetIDs[0]
+	if u := strings.TrimSpace(tok.Username); u != "" {
+		permalink = fmt.Sprintf("https://x.com/%s/status/%s", url.PathEscape(u), tweetIDs[0])
+	}
+	details["permalink"] = permalink
+
+	// Store created item in SocialLibraries (root tweet; replies are kept in raw_payload).
+	rawObj := map[string]interface{}{"tweetIds": tweetIDs}
+	if len(rootBody) > 0 {
+		rawObj["response"] = json.RawMessage(rootBody)
+	}
+	rawPayload, _ := json.Marshal(rawObj)
+	rawStr := strings.ReplaceAll(string(rawPayload), "\x00", "")
+	if !utf8.ValidString(rawStr) {
+		rawStr = strings.ToValidUTF8(rawStr, "�")
+	}
+	rowID := fmt.Sprintf("x:%s:%s", userID, tweetIDs[0])
+	_, _ = h.db.ExecContext(ctx, `
+		INSERT INTO public.social_libraries
+		  (id, user_id, network, content_type, title, permalink_url, media_url, thumbnail_url, posted_at, views, likes, raw_payload, external_id, created_at, updated_at)
+		VALUES
+		  ($1, $2, 'x', 'post', NULLIF($3,''), $4, NULL, NULL, NOW(), NULL, NULL, $5::jsonb, $6, NOW(), NOW())
+		ON CONFLICT (user_id, network, external_id)
+		DO UPDATE SET
+		  title = EXCLUDED.title,
+		  permalink_url = EXCLUDED.permalink_url,
+		  raw_payload = EXCLUDED.raw_payload,
+		  updated_at = NOW()
+	`, rowID, userID, truncate(caption, 160), permalink, rawStr, tweetIDs[0])
+
+	log.Printf("[XPublish] ok userId=%s tweetId=%s parts=%d media=%d", userID, tweetIDs[0], len(tweetIDs), len(mediaIDs))
+	return 1, nil, details
+}
+
+// xUploadMedia uploads one file with the v2 chunked media upload endpoints and waits for processing.
+func xUploadMedia(
+	ctx context.Context,
+	do func(*http.Request) (int, []byte, error),
+	postJSON func(string, interface{}) (int, []byte, error),
+	m uploadedMedia,
+	category string,
+) (string, error, map[string]interface{}) {
+	ct := strings.TrimSpace(m.ContentType)
+	if semi := strings.Index(ct, ";"); semi >= 0 {
+		ct = strings.TrimSpace(ct[:semi])
+	}
+	if ct == "" || ct == "application/octet-stream" {
+		ct = http.DetectContentType(m.Bytes)
+	}
+
+	// INIT
+	status, b, err := postJSON(xAPIBase+"/media/upload/initialize", map[string]interface{}{
+		"media_type":     ct,
+		"total_bytes":    len(m.Bytes),
+		"media_category": category,
+	})
+	if err != nil {
+		return "", err, nil
+	}
+	if status < 200 || status >= 300 {
+		return "", fmt.Errorf("x_media_init_non_2xx"), map[string]interface{}{"status": status, "body": truncate(string(b), 1200)}
+	}
+	var initResp struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	_ = json.Unmarshal(b, &initResp)
+	mediaID := initResp.Data.ID
+	if mediaID == "" {
+		return "", fmt.Errorf("x_media_missing_id"), map[string]interface{}{"body": truncate(string(b), 1200)}
+	}
+
+	// APPEND
+	for seg, off := 0, 0; off < len(m.Bytes); seg, off = seg+1, off+xUploadChunkBytes {
+		end := off + xUploadChunkBytes
+		if end > len(m.Bytes) {
+			end = len(m.Bytes)
+		}
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		_ = mw.WriteField("segment_index", fmt.Sprintf("%d", seg))
+		fw, _ := mw.CreateFormFile("media", m.Filename)
+		_, _ = fw.Write(m.Bytes[off:end])
+		_ = mw.Close()
+		req, _ := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/media/upload/%s/append", xAPIBase, url.PathEscape(mediaID)), &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		status, b, err := do(req)
+		if err != nil {
+			return "", err, nil
+		}
+		if status < 200 || status >= 300 {
+			return "", fmt.Errorf("x_media_append_non_2xx"), map[string]interface{}{"status": status, "segment": seg, "body": truncate(string(b), 1200)}
+		}
+	}
+
+	// FINALIZE
+	req, _ := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/media/upload/%s/finalize", xAPIBase, url.PathEscape(mediaID)), nil)
+	status, b, err = do(req)
+	if err != nil {
+		return "", err, nil
+	}
+	if status < 200 || status >= 300 {
+		return "", fmt.Errorf("x_media_finalize_non_2xx"), map[string]interface{}{"status": status, "body": truncate(string(b), 1200)}
+	}
+
+	type processingInfo struct {
+		State          string `json:"state"`
+		CheckAfterSecs int    `json:"check_after_secs"`
+		Error          *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	var fin struct {
+		Data struct {
+			ProcessingInfo *processingInfo `json:"processing_info"`
+		} `json:"data"`
+	}
+	_ = json.Unmarshal(b, &fin)
+	info := fin.Data.ProcessingInfo
+
+	// STATUS (videos/GIFs are processed asynchronously)
+	for i := 0; info != nil && i < 60; i++ {
+		switch strings.ToLower(info.State) {
+		case "succeeded", "":
+			return mediaID, nil, nil
+		case "failed":
+			msg := ""
+			if info.Error != nil {
+				msg = info.Error.Message
+			}
+			return "", fmt.Errorf("x_media_processing_failed"), map[string]interface{}{"mediaId": mediaID, "error": msg}
+		}
+		wait := time.Duration(info.CheckAfterSecs) * time.Second
+		if wait <= 0 {
+			wait = 2 * time.Second
+		}
+		if wait > 10*time.Second {
+			wait = 10 * time.Second
+		}
+		time.Sleep(wait)
+
+		q := url.Values{}
+		q.Set("command", "STATUS")
+		q.Set("media_id", mediaID)
+		req, _ := http.NewRequestWithContext(ctx, "GET", xAPIBase+"/media/upload?"+q.Encode(), nil)
+		status, b, err := do(req)
+		if err != nil {
+			continue
+		}
+		if status < 200 || status >= 300 {
+			continue
+		}
+		var st struct {
+			Data struct {
+				ProcessingInfo *processingInfo `json:"processing_info"`
+			} `json:"data"`
+		}
+		if json.Unmarshal(b, &st) == nil {
+			info = st.Data.ProcessingInfo
+		}
+	}
+	if info != nil {
+		return "", fmt.Errorf("x_media_not_ready"), map[string]interface{}{"mediaId": mediaID, "state": info.State}
+	}
+	return mediaID, nil, nil
+}
+
+func (h *Handler) deleteXPost(ctx context.Context, userID string, tweetID string) error {
+	tok, err := h.loadXOAuth(ctx, userID)
+	if err != nil {
+		if err.Error() == "not_connected" {
+			return fmt.Errorf("x_not_connected")
+		}
+		if err.Error() == "invalid_oauth_payload" {
+			return fmt.Errorf("x_invalid_oauth_payload")
+		}
+		return err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	endpoint := fmt.Sprintf("%s/tweets/%s", xAPIBase, url.PathEscape(strings.TrimSpace(tweetID)))
+	req, _ := http.NewRequestWithContext(ctx, http.MethodDelete, endpoint, nil)
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(tok.AccessToken))
+	req.Header.Set("Accept", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		log.Printf("[ExternalDelete] ok userId=%s network=x externalId=%s", userID, truncate(tweetID, 64))
+		return nil
+	}
+	return fmt.Errorf("x_delete_non_2xx status=%d body=%s", res.StatusCode, truncate(string(b), 300))
+}
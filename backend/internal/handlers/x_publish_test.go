@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func expectXOAuth(mock sqlmock.Sqlmock) {
+	raw, _ := json.Marshal(xOAuth{AccessToken: "xtok", XUserID: "42", Username: "me"})
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings.*key='x_oauth'`).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(raw))
+}
+
+func TestPublishX_MediaAndThread_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	expectXOAuth(mock)
+	mock.ExpectExec(`INSERT INTO public\.social_libraries`).
+		WithArgs("x:u1:t1", "u1", sqlmock.AnyArg(), "https://x.com/me/status/t1", sqlmock.AnyArg(), "t1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+
+	var (
+		mu      sync.Mutex
+		appends int
+		tweets  []map[string]interface{}
+	)
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		if r.URL.Host != "api.x.com" || r.Header.Get("Authorization") != "Bearer xtok" {
+			return httpJSON(401, `{"error":"unauthorized"}`, nil), nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/2/media/upload/initialize":
+			b, _ := io.ReadAll(r.Body)
+			if !strings.Contains(string(b), `"media_category":"tweet_image"`) {
+				return httpJSON(400, `{"error":"bad category"}`, nil), nil
+			}
+			return httpJSON(200, `{"data":{"id":"m1"}}`, nil), nil
+		case r.Method == "POST" && r.URL.Path == "/2/media/upload/m1/append":
+			if err := r.ParseMultipartForm(1 << 20); err != nil || r.FormValue("segment_index") != "0" {
+				return httpJSON(400, `{"error":"bad append"}`, nil), nil
+			}
+			appends++
+			return httpJSON(200, `{}`, nil), nil
+		case r.Method == "POST" && r.URL.Path == "/2/media/upload/m1/finalize":
+			return httpJSON(200, `{"data":{"id":"m1"}}`, nil), nil
+		case r.Method == "POST" && r.URL.Path == "/2/tweets":
+			b, _ := io.ReadAll(r.Body)
+			var body map[string]interface{}
+			_ = json.Unmarshal(b, &body)
+			tweets = append(tweets, body)
+			if len(tweets) == 1 {
+				return httpJSON(201, `{"data":{"id":"t1","text":"..."}}`, nil), nil
+			}
+			return httpJSON(201, `{"data":{"id":"t2","text":"..."}}`, nil), nil
+		}
+		return httpJSON(404, `{"error":"not_found"}`, nil), nil
+	}}
+
+	caption := strings.TrimSpace(strings.Repeat("word ", 80))
+	media := []uploadedMedia{{Filename: "a.png", ContentType: "image/png", Bytes: []byte("png-bytes")}}
+	posted, perr, details := h.publishX(context.Background(), "u1", caption, media, false)
+	if perr != nil {
+		t.Fatalf("publish err: %v details=%#v", perr, details)
+	}
+	ids, _ := details["tweetIds"].([]string)
+	if posted != 1 || details["publishedId"] != "t1" || len(ids) != 2 || ids[1] != "t2" {
+		t.Fatalf("unexpected result posted=%d details=%#v", posted, details)
+	}
+	if appends != 1 || len(tweets) != 2 {
+		t.Fatalf("expected 1 append and 2 tweets, got appends=%d tweets=%d", appends, len(tweets))
+	}
+	if _, ok := tweets[0]["media"]; !ok {
+		t.Fatalf("expected media on root tweet %#v", tweets[0])
+	}
+	reply, _ := tweets[1]["reply"].(map[string]interface{})
+	if reply["in_reply_to_tweet_id"] != "t1" {
+		t.Fatalf("expected reply to t1 %#v", tweets[1])
+	}
+	if _, ok := tweets[1]["media"]; ok {
+		t.Fatalf("media should only be attached to the root tweet %#v", tweets[1])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestPublishX_ThreadIncomplete_NotRetried(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	expectXOAuth(mock)
+
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	calls := 0
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		if r.Method == "POST" && r.URL.Path == "/2/tweets" {
+			calls++
+			if calls == 1 {
+				return httpJSON(201, `{"data":{"id":"t1"}}`, nil), nil
+			}
+			return httpJSON(503, `{"title":"Service Unavailable"}`, nil), nil
+		}
+		return httpJSON(404, `{"error":"not_found"}`, nil), nil
+	}}
+
+	caption := strings.TrimSpace(strings.Repeat("word ", 80))
+	posted, perr, details := h.publishX(context.Background(), "u1", caption, nil, false)
+	if perr == nil || perr.Error() != "x_thread_incomplete" || posted != 1 {
+		t.Fatalf("expected x_thread_incomplete with posted=1 got posted=%d err=%v", posted, perr)
+	}
+	if details["failedPart"] != 2 {
+		t.Fatalf("unexpected details %#v", details)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestPublishX_DryRun_NotConnected_Validation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	// Validation happens before any DB access.
+	if _, perr, _ := h.publishX(context.Background(), "u1", "  ", nil, true); perr == nil || perr.Error() != "x_requires_text_or_media" {
+		t.Fatalf("expected x_requires_text_or_media got %v", perr)
+	}
+	if _, perr, _ := h.publishX(context.Background(), "u1", strings.Repeat("word ", 2000), nil, true); perr == nil || perr.Error() != "x_caption_too_long" {
+		t.Fatalf("expected x_caption_too_long got %v", perr)
+	}
+
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings.*key='x_oauth'`).
+		WithArgs("u1").
+		WillReturnError(sql.ErrNoRows)
+	if _, perr, _ := h.publishX(context.Background(), "u1", "hi", nil, true); perr == nil || perr.Error() != "not_connected" {
+		t.Fatalf("expected not_connected got %v", perr)
+	}
+
+	expectXOAuth(mock)
+	media := []uploadedMedia{
+		{Filename: "a.jpg", ContentType: "image/jpeg"},
+		{Filename: "b.mp4", ContentType: "video/mp4"},
+		{Filename: "c.jpg", ContentType: "image/jpeg"},
+	}
+	posted, perr, details := h.publishX(context.Background(), "u1", "hi", media, true)
+	if perr != nil || posted != 0 || details["dryRun"] != true || details["mediaCount"] != 1 || details["skippedMedia"] != 2 {
+		t.Fatalf("unexpected dry run posted=%d err=%v details=%#v", posted, perr, details)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestSplitXThread(t *testing.T) {
+	if got := splitXThread("short one"); len(got) != 1 || got[0] != "short one" {
+		t.Fatalf("unexpected single part %#v", got)
+	}
+	parts := splitXThread(strings.Repeat("abcdefghi ", 60) + strings.Repeat("z", 400))
+	if len(parts) < 3 {
+		t.Fatalf("expected several parts got %d", len(parts))
+	}
+	for i, p := range parts {
+		if xTextWeight(p) > xMaxTweetWeight {
+			t.Fatalf("part %d too long (%d): %q", i, xTextWeight(p), p)
+		}
+	}
+	if !strings.HasSuffix(parts[0], fmt.Sprintf(" 1/%d", len(parts))) {
+		t.Fatalf("expected numbering suffix got %q", parts[0])
+	}
+	if xTextWeight("日本") != 4 || xTextWeight("ab") != 2 {
+		t.Fatalf("unexpected weights")
+	}
+}
+
+func TestDeleteXPost(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		if r.Method == http.MethodDelete && r.URL.Path == "/2/tweets/t1" {
+			return httpJSON(200, `{"data":{"deleted":true}}`, nil), nil
+		}
+		return httpJSON(403, `{"title":"Forbidden"}`, nil), nil
+	}}
+
+	expectXOAuth(mock)
+	if err := h.deleteXPost(context.Background(), "u1", "t1"); err != nil {
+		t.Fatalf("delete err: %v", err)
+	}
+	expectXOAuth(mock)
+	if err := h.deleteXPost(context.Background(), "u1", "t2"); err == nil || !strings.HasPrefix(err.Error(), "x_delete_non_2xx") {
+		t.Fatalf("expected x_delete_non_2xx got %v", err)
+	}
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings.*key='x_oauth'`).
+		WithArgs("u1").
+		WillReturnError(sql.ErrNoRows)
+	if err := h.deleteXPost(context.Background(), "u1", "t1"); err == nil || err.Error() != "x_not_connected" {
+		t.Fatalf("expected x_not_connected got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestRunPublishJob_XRerunContinuesBrokenThread(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	prev := `{"x":{"ok":false,"posted":1,"error":"x_thread_incomplete","details":{"failedPart":2,"tweetIds":["t1"]}}}`
+	mock.ExpectExec(`UPDATE public\.publish_jobs.*status='running'`).WithArgs("job1").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE public\.posts.*last_publish_status='running'`).WithArgs("job1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT COALESCE\(result_json->'results'`).
+		WithArgs("job1").
+		WillReturnRows(sqlmock.NewRows([]string{"results"}).AddRow([]byte(prev)))
+	expectXOAuth(mock)
+	mock.ExpectExec(`INSERT INTO public\.social_libraries`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE public\.publish_jobs\s+SET result_json = COALESCE`).
+		WithArgs("job1", "x", sqlmock.AnyArg(), "w1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	var final string
+	mock.ExpectExec(`UPDATE public\.publish_jobs.*SET status=\$2`).
+		WithArgs("job1", "completed", captureArg{&final}, sqlmock.AnyArg(), "w1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE public\.posts.*SET last_publish_status=\$2`).
+		WithArgs("job1", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	var posts []map[string]interface{}
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		if r.Method == "POST" && r.URL.Path == "/2/tweets" {
+			var body map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			posts = append(posts, body)
+			return httpJSON(201, `{"data":{"id":"t2"}}`, nil), nil
+		}
+		t.Fatalf("unexpected request %s %s", r.Method, r.URL)
+		return nil, nil
+	}}
+
+	caption := strings.TrimSpace(strings.Repeat("word ", 80))
+	h.runPublishJob(context.Background(), "job1", "w1", "u1", caption, publishPostRequest{Providers: []string{"x"}}, nil, "https://app.test")
+
+	if len(posts) != 1 || fmt.Sprint(posts[0]["reply"]) != "map[in_reply_to_tweet_id:t1]" {
+		t.Fatalf("expected only the second part, replying to t1, got %v", posts)
+	}
+	var out struct {
+		Results map[string]publishProviderResult `json:"results"`
+	}
+	_ = json.Unmarshal([]byte(final), &out)
+	if x := out.Results["x"]; !x.OK || fmt.Sprint(x.Details["tweetIds"]) != "[t1 t2]" {
+		t.Fatalf("unexpected x result %s", final)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
@@ -0,0 +1,498 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/PortNumber53/simple-social-thing/backend/internal/models"
+)
+
+const (
+	youtubeDefaultCategory  = "22" // People & Blogs
+	youtubeShortsMaxSec     = 180
+	youtubeMaxThumbnail     = 2 << 20
+	youtubeUploadMaxRetries = 5
+)
+
+var (
+	// Chunks must be multiples of 256 KiB; vars so tests can use small uploads and no waiting.
+	youtubeUploadChunkSize  int64 = 8 << 20
+	youtubeUploadRetryDelay       = 2 * time.Second
+)
+
+// youtubeVideoOptions carries per-post video metadata; empty values keep the defaults (title and description
+// from the caption, public, People & Blogs).
+type youtubeVideoOptions struct {
+	Title       string
+	Description string
+	Tags        []string
+	Privacy     string
+	PublishAt   *time.Time
+	MadeForKids bool
+	CategoryID  string
+	// Thumbnail is a /media/ rel path.
+	Thumbnail  string
+	PlaylistID string
+}
+
+// youtubeOptionsFor merges the post's YouTube options over the variant title.
+func youtubeOptionsFor(variantTitle string, opts *models.PostOptions) youtubeVideoOptions {
+	out := youtubeVideoOptions{Title: variantTitle}
+	if opts == nil || opts.YouTube == nil {
+		return out
+	}
+	yt := opts.YouTube
+	if yt.Title != "" {
+		out.Title = yt.Title
+	}
+	out.Description = yt.Description
+	out.Tags = yt.Tags
+	out.Privacy = yt.Privacy
+	out.PublishAt = yt.PublishAt
+	out.MadeForKids = yt.MadeForKids
+	out.CategoryID = yt.CategoryID
+	out.Thumbnail = yt.Thumbnail
+	out.PlaylistID = yt.PlaylistID
+	return out
+}
+
+// youtubeVideoSource is a video read in chunks during the resumable upload, from memory or from disk.
+type youtubeVideoSource struct {
+	Filename    string
+	ContentType string
+	Size        int64
+	Reader      io.ReaderAt
+	// Path is set for files on disk so they can be probed without loading them.
+	Path string
+}
+
+func youtubeSourceFromMedia(m uploadedMedia) youtubeVideoSource {
+	return youtubeVideoSource{Filename: m.Filename, ContentType: m.ContentType, Size: int64(len(m.Bytes)), Reader: bytes.NewReader(m.Bytes)}
+}
+
+// openYouTubeSource opens an uploaded video (rel path) for streaming; the caller closes the file.
+func openYouTubeSource(rel string) (youtubeVideoSource, *os.File, error) {
+	path, err := safeMediaJoin(strings.TrimPrefix(strings.TrimSpace(rel), "/media/"))
+	if err != nil {
+		return youtubeVideoSource{}, nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return youtubeVideoSource{}, nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return youtubeVideoSource{}, nil, err
+	}
+	ct := mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+	if ct == "" {
+		ct = "video/mp4"
+	}
+	return youtubeVideoSource{Filename: filepath.Base(path), ContentType: ct, Size: st.Size(), Reader: f, Path: path}, f, nil
+}
+
+// probe analyzes the video for Shorts detection; it needs ffprobe and reports zero values without it.
+// Files on disk are probed in place; other sources are streamed to a temp file rather than copied in memory.
+func (s youtubeVideoSource) probe() preflightMedia {
+	out := preflightMedia{Kind: "video"}
+	if _, err := exec.LookPath("ffprobe"); err != nil {
+		return out
+	}
+	if s.Path != "" {
+		return probePreflightVideo(s.Path, s.Filename, out)
+	}
+	if s.Reader == nil || s.Size <= 0 {
+		return out
+	}
+	tmp, err := os.CreateTemp("", "youtube_probe_*"+filepath.Ext(s.Filename))
+	if err != nil {
+		return out
+	}
+	defer os.Remove(tmp.Name())
+	_, werr := io.Copy(tmp, io.NewSectionReader(s.Reader, 0, s.Size))
+	_ = tmp.Close()
+	if werr != nil {
+		return out
+	}
+	return probePreflightVideo(tmp.Name(), s.Filename, out)
+}
+
+// isYouTubeShort reports whether YouTube will treat the video as a Short: vertical or square, up to 3 minutes.
+func isYouTubeShort(info preflightMedia) bool {
+	return info.Width > 0 && info.Height >= info.Width && info.DurationSec > 0 && info.DurationSec <= youtubeShortsMaxSec
+}
+
+// youtubeVideoMetadata builds the snippet/status resource for videos.insert and returns the title used.
+func youtubeVideoMetadata(caption string, opts youtubeVideoOptions, short bool, now time.Time) (string, map[string]interface{}) {
+	title := strings.TrimSpace(opts.Title)
+	if title == "" {
+		title = strings.TrimSpace(caption)
+	}
+	if title == "" {
+		title = "New video"
+	}
+	if len(title) > 95 {
+		title = truncate(title, 95)
+	}
+	description := opts.Description
+	if description == "" {
+		description = caption
+	}
+	if short && !strings.Contains(strings.ToLower(title+" "+description), "#shorts") {
+		description = strings.TrimSpace(description + "\n\n#Shorts")
+	}
+	category := opts.CategoryID
+	if category == "" {
+		category = youtubeDefaultCategory
+	}
+	snippet := map[string]interface{}{
+		"title":       title,
+		"description": description,
+		"categoryId":  category,
+	}
+	if len(opts.Tags) > 0 {
+		snippet["tags"] = opts.Tags
+	}
+	privacy := opts.Privacy
+	status := map[string]interface{}{"selfDeclaredMadeForKids": opts.MadeForKids}
+	// A publishAt already in the past (e.g. a late retry) just publishes now.
+	if opts.PublishAt != nil && opts.PublishAt.After(now) {
+		privacy = "private"
+		status["publishAt"] = opts.PublishAt.UTC().Format(time.RFC3339)
+	} else if privacy == "" || (opts.PublishAt != nil && privacy == "private") {
+		privacy = "public"
+	}
+	status["privacyStatus"] = privacy
+	return title, map[string]interface{}{"snippet": snippet, "status": status}
+}
+
+// youtubeUploadRange parses the Range header of a 308 response ("bytes=0-1234") into the next offset.
+func youtubeUploadRange(h string) int64 {
+	h = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(h), "bytes="))
+	if dash := strings.LastIndex(h, "-"); dash >= 0 {
+		if end, err := strconv.ParseInt(h[dash+1:], 10, 64); err == nil {
+			return end + 1
+		}
+	}
+	return 0
+}
+
+// youtubeResumableUpload sends the video to a resumable session in chunks. Transient failures (network
+// errors, 5xx, 429) ask YouTube how much it received and resume from there, with exponential backoff.
+func youtubeResumableUpload(ctx context.Context, client *http.Client, uploadURL, accessToken string, src youtubeVideoSource) (int, []byte, error) {
+	contentType := src.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	offset, retries := int64(0), 0
+	for {
+		var status int
+		var body []byte
+		var reqErr error
+		if offset < src.Size {
+			end := offset + youtubeUploadChunkSize
+			if end > src.Size {
+				end = src.Size
+			}
+			req, err := http.NewRequestWithContext(ctx, "PUT", uploadURL, io.NewSectionReader(src.Reader, offset, end-offset))
+			if err != nil {
+				return 0, nil, err
+			}
+			req.ContentLength = end - offset
+			req.Header.Set("Authorization", "Bearer "+accessToken)
+			req.Header.Set("Content-Type", contentType)
+			req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, end-1, src.Size))
+			res, err := client.Do(req)
+			if err != nil {
+				reqErr = err
+			} else {
+				body, _ = io.ReadAll(io.LimitReader(res.Body, 4<<20))
+				_ = res.Body.Close()
+				status = res.StatusCode
+				switch {
+				case status == http.StatusOK || status == http.StatusCreated:
+					return status, body, nil
+				case status == http.StatusPermanentRedirect:
+					if next := youtubeUploadRange(res.Header.Get("Range")); next > offset {
+						offset, retries = next, 0
+						continue
+					}
+				case status < 500 && status != http.StatusTooManyRequests:
+					return status, body, fmt.Errorf("youtube_upload_non_2xx")
+				}
+			}
+		}
+
+		retries++
+		if retries > youtubeUploadMaxRetries {
+			if reqErr != nil {
+				return status, body, reqErr
+			}
+			return status, body, fmt.Errorf("youtube_upload_non_2xx")
+		}
+		log.Printf("[YTPublish] upload_retry offset=%d/%d attempt=%d status=%d err=%v", offset, src.Size, retries, status, reqErr)
+		select {
+		case <-ctx.Done():
+			return status, body, ctx.Err()
+		case <-time.After(youtubeUploadRetryDelay * time.Duration(1<<(retries-1))):
+		}
+
+		// Ask where to resume; a finished upload answers with the video resource.
+		req, err := http.NewRequestWithContext(ctx, "PUT", uploadURL, nil)
+		if err != nil {
+			return 0, nil, err
+		}
+		req.ContentLength = 0
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", src.Size))
+		res, err := client.Do(req)
+		if err != nil {
+			continue
+		}
+		qBody, _ := io.ReadAll(io.LimitReader(res.Body, 4<<20))
+		_ = res.Body.Close()
+		switch {
+		case res.StatusCode == http.StatusOK || res.StatusCode == http.StatusCreated:
+			return res.StatusCode, qBody, nil
+		case res.StatusCode == http.StatusPermanentRedirect:
+			offset = youtubeUploadRange(res.Header.Get("Range"))
+		case res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone:
+			// The session expired; the whole upload has to start over.
+			return res.StatusCode, qBody, fmt.Errorf("youtube_upload_session_expired")
+		}
+	}
+}
+
+// youtubeHasScope reports whether the granted scopes include one of the given youtube scopes.
+func youtubeHasScope(granted string, scopes ...string) bool {
+	for _, g := range strings.Fields(strings.ReplaceAll(granted, ",", " ")) {
+		for _, s := range scopes {
+			if g == "https://www.googleapis.com/auth/"+s {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// youtubeSetThumbnail uploads a custom thumbnail (needs a verified channel).
+func youtubeSetThumbnail(ctx context.Context, client *http.Client, accessToken, videoID, rel string) error {
+	files, err := loadUploadedMediaFromRelPaths([]string{rel})
+	if err != nil || len(files) == 0 {
+		return fmt.Errorf("youtube_thumbnail_unavailable")
+	}
+	thumb := files[0]
+	if _, isVideo, ok := xMediaKind(thumb); !ok || isVideo {
+		return fmt.Errorf("youtube_thumbnail_not_an_image")
+	}
+	if len(thumb.Bytes) > youtubeMaxThumbnail {
+		return fmt.Errorf("youtube_thumbnail_too_large")
+	}
+	endpoint := "https://www.googleapis.com/upload/youtube/v3/thumbnails/set?uploadType=media&videoId=" + url.QueryEscape(videoID)
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(thumb.Bytes))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", thumb.ContentType)
+	return youtubeDo(client, req)
+}
+
+// youtubeAddToPlaylist appends the video to one of the channel's playlists.
+func youtubeAddToPlaylist(ctx context.Context, client *http.Client, accessToken, videoID, playlistID string) error {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"snippet": map[string]interface{}{
+			"playlistId": playlistID,
+			"resourceId": map[string]interface{}{"kind": "youtube#video", "videoId": videoID},
+		},
+	})
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://www.googleapis.com/youtube/v3/playlistItems?part=snippet", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	return youtubeDo(client, req)
+}
+
+// youtubeDo runs a Data API call and turns a non-2xx answer into an error carrying YouTube's message.
+func youtubeDo(client *http.Client, req *http.Request) error {
+	req.Header.Set("Accept", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	_ = res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		var e struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(b, &e) == nil && e.Error.Message != "" {
+			return fmt.Errorf("%s", truncate(e.Error.Message, 400))
+		}
+		return fmt.Errorf("http_%d", res.StatusCode)
+	}
+	return nil
+}
+
+func (h *Handler) publishYouTubeVideo(ctx context.Context, userID, caption string, video uploadedMedia, opts youtubeVideoOptions, dryRun bool) (int, error, map[string]interface{}) {
+	return h.publishYouTubeSource(ctx, userID, caption, youtubeSourceFromMedia(video), opts, dryRun)
+}
+
+// publishYouTubeSource uploads a video with a resumable session, then sets the custom thumbnail and playlist.
+// Thumbnail and playlist failures are reported in details without failing the publish.
+func (h *Handler) publishYouTubeSource(ctx context.Context, userID, caption string, video youtubeVideoSource, opts youtubeVideoOptions, dryRun bool) (int, error, map[string]interface{}) {
+	details := map[string]interface{}{
+		"contentType": video.ContentType,
+		"size":        video.Size,
+	}
+	if video.Size == 0 {
+		return 0, fmt.Errorf("youtube_requires_video"), details
+	}
+
+	// Load token
+	var raw []byte
+	if err := h.db.QueryRowContext(ctx, `SELECT value FROM public.user_settings WHERE user_id=$1 AND key='youtube_oauth' AND value IS NOT NULL`, userID).Scan(&raw); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("not_connected"), details
+		}
+		return 0, err, details
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return 0, fmt.Errorf("not_connected"), details
+	}
+	var tok youtubeOAuth
+	if err := json.Unmarshal(raw, &tok); err != nil {
+		return 0, fmt.Errorf("invalid_oauth_payload"), map[string]interface{}{"raw": truncate(string(raw), 800)}
+	}
+	if strings.TrimSpace(tok.AccessToken) == "" {
+		return 0, fmt.Errorf("not_connected"), details
+	}
+
+	// Guard: publishing requires youtube.upload
+	if !strings.Contains(tok.Scope, "youtube.upload") {
+		return 0, fmt.Errorf("missing_scope"), map[string]interface{}{"scope": tok.Scope, "required": []string{"https://www.googleapis.com/auth/youtube.upload"}}
+	}
+
+	// Best-effort: detect expiration (we don't refresh tokens server-side yet).
+	if strings.TrimSpace(tok.ExpiresAt) != "" {
+		if t, err := time.Parse(time.RFC3339, tok.ExpiresAt); err == nil {
+			if time.Now().After(t.Add(-30 * time.Second)) {
+				return 0, fmt.Errorf("token_expired_reconnect"), map[string]interface{}{"expiresAt": tok.ExpiresAt}
+			}
+		}
+	}
+
+	if dryRun {
+		return 0, nil, map[string]interface{}{"dryRun": true}
+	}
+
+	short := isYouTubeShort(video.probe())
+	details["short"] = short
+	title, meta := youtubeVideoMetadata(caption, opts, short, time.Now())
+	details["privacyStatus"] = meta["status"].(map[string]interface{})["privacyStatus"]
+	metaBytes, _ := json.Marshal(meta)
+
+	client := &http.Client{Timeout: 120 * time.Second}
+	initURL := "https://www.googleapis.com/upload/youtube/v3/videos?uploadType=resumable&part=snippet,status"
+	initReq, err := http.NewRequestWithContext(ctx, "POST", initURL, bytes.NewReader(metaBytes))
+	if err != nil {
+		return 0, err, details
+	}
+	initReq.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+	initReq.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	initReq.Header.Set("Accept", "application/json")
+	initReq.Header.Set("X-Upload-Content-Length", strconv.FormatInt(video.Size, 10))
+	if video.ContentType != "" {
+		initReq.Header.Set("X-Upload-Content-Type", video.ContentType)
+	}
+
+	initRes, err := client.Do(initReq)
+	if err != nil {
+		return 0, err, details
+	}
+	initBody, _ := io.ReadAll(io.LimitReader(initRes.Body, 1<<20))
+	_ = initRes.Body.Close()
+	if initRes.StatusCode < 200 || initRes.StatusCode >= 300 {
+		return 0, fmt.Errorf("youtube_init_non_2xx"), map[string]interface{}{"status": initRes.StatusCode, "body": truncate(string(initBody), 2000)}
+	}
+	uploadURL := initRes.Header.Get("Location")
+	if uploadURL == "" {
+		return 0, fmt.Errorf("youtube_missing_upload_url"), map[string]interface{}{"headers": initRes.Header}
+	}
+
+	putStatus, putBody, err := youtubeResumableUpload(ctx, client, uploadURL, tok.AccessToken, video)
+	if err != nil {
+		return 0, err, map[string]interface{}{"status": putStatus, "body": truncate(string(putBody), 3000)}
+	}
+
+	var published map[string]interface{}
+	_ = json.Unmarshal(putBody, &published)
+	videoID, _ := published["id"].(string)
+	details["videoId"] = videoID
+	details["response"] = json.RawMessage(putBody)
+
+	if videoID != "" && opts.Thumbnail != "" {
+		if err := youtubeSetThumbnail(ctx, client, tok.AccessToken, videoID, opts.Thumbnail); err != nil {
+			details["thumbnailError"] = err.Error()
+			log.Printf("[YTPublish] thumbnail_failed userId=%s videoId=%s err=%v", userID, videoID, err)
+		}
+	}
+	if videoID != "" && opts.PlaylistID != "" {
+		if !youtubeHasScope(tok.Scope, "youtube", "youtube.force-ssl", "youtubepartner") {
+			details["playlistError"] = "missing_scope"
+		} else if err := youtubeAddToPlaylist(ctx, client, tok.AccessToken, videoID, opts.PlaylistID); err != nil {
+			details["playlistError"] = err.Error()
+			log.Printf("[YTPublish] playlist_failed userId=%s videoId=%s playlistId=%s err=%v", userID, videoID, opts.PlaylistID, err)
+		}
+	}
+
+	if videoID != "" {
+		contentType := "video"
+		permalink := fmt.Sprintf("https://www.youtube.com/watch?v=%s", videoID)
+		if short {
+			contentType = "short"
+			permalink = fmt.Sprintf("https://www.youtube.com/shorts/%s", videoID)
+		}
+		rawPayload := strings.ReplaceAll(string(putBody), "\x00", "")
+		if !utf8.ValidString(rawPayload) {
+			rawPayload = strings.ToValidUTF8(rawPayload, "�")
+		}
+		rowID := fmt.Sprintf("youtube:%s:%s", userID, videoID)
+		_, _ = h.db.ExecContext(ctx, `
+			INSERT INTO public.social_libraries
+			  (id, user_id, network, content_type, title, permalink_url, media_url, thumbnail_url, posted_at, views, likes, raw_payload, external_id, created_at, updated_at)
+			VALUES
+			  ($1, $2, 'youtube', $3, NULLIF($4,''), $5, $5, NULL, NOW(), NULL, NULL, $6::jsonb, $7, NOW(), NOW())
+			ON CONFLICT (user_id, network, external_id)
+			DO UPDATE SET
+			  title = EXCLUDED.title,
+			  permalink_url = EXCLUDED.permalink_url,
+			  media_url = EXCLUDED.media_url,
+			  raw_payload = EXCLUDED.raw_payload,
+			  updated_at = NOW()
+		`, rowID, userID, contentType, title, permalink, rawPayload, videoID)
+	}
+
+	log.Printf("[YTPublish] ok userId=%s videoId=%s short=%v", userID, videoID, short)
+	return 1, nil, details
+}
@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PortNumber53/simple-social-thing/backend/internal/models"
+	"github.com/gorilla/mux"
+)
+
+func TestYouTubeResumableUpload_ChunksAndResumes(t *testing.T) {
+	origChunk, origDelay := youtubeUploadChunkSize, youtubeUploadRetryDelay
+	defer func() { youtubeUploadChunkSize, youtubeUploadRetryDelay = origChunk, origDelay }()
+	youtubeUploadChunkSize, youtubeUploadRetryDelay = 4, 0
+
+	var ranges []string
+	var received bytes.Buffer
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		cr := r.Header.Get("Content-Range")
+		ranges = append(ranges, cr)
+		var b []byte
+		if r.Body != nil {
+			b, _ = io.ReadAll(r.Body)
+		}
+		switch cr {
+		case "bytes 0-3/10":
+			received.Write(b)
+			return httpJSON(308, ``, map[string]string{"Range": "bytes=0-3"}), nil
+		case "bytes 4-7/10":
+			// YouTube kept only two of these bytes before failing.
+			received.Write(b[:2])
+			return httpJSON(503, `{}`, nil), nil
+		case "bytes */10":
+			return httpJSON(308, ``, map[string]string{"Range": "bytes=0-5"}), nil
+		case "bytes 6-9/10":
+			received.Write(b)
+			return httpJSON(200, `{"id":"v1"}`, nil), nil
+		}
+		return httpJSON(400, `{}`, nil), nil
+	}}
+
+	status, body, err := youtubeResumableUpload(context.Background(), &http.Client{}, "https://upload.youtube.com/s1", "tok",
+		youtubeSourceFromMedia(uploadedMedia{Filename: "v.mp4", ContentType: "video/mp4", Bytes: []byte("0123456789")}))
+	if err != nil || status != 200 || !strings.Contains(string(body), "v1") {
+		t.Fatalf("expected upload to finish, got status=%d body=%s err=%v", status, body, err)
+	}
+	if got := strings.Join(ranges, ","); got != "bytes 0-3/10,bytes 4-7/10,bytes */10,bytes 6-9/10" {
+		t.Fatalf("unexpected requests %s", got)
+	}
+	if received.String() != "0123456789" {
+		t.Fatalf("unexpected bytes %q", received.String())
+	}
+}
+
+func TestYouTubeVideoMetadata(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := now.Add(48 * time.Hour)
+	title, meta := youtubeVideoMetadata("caption", youtubeVideoOptions{Title: "My clip", Tags: []string{"a"}, PublishAt: &at, MadeForKids: true}, true, now)
+	snippet, status := meta["snippet"].(map[string]interface{}), meta["status"].(map[string]interface{})
+	if title != "My clip" || snippet["description"] != "caption\n\n#Shorts" || snippet["categoryId"] != "22" {
+		t.Fatalf("unexpected snippet %v", snippet)
+	}
+	if status["privacyStatus"] != "private" || status["publishAt"] != "2030-01-03T00:00:00Z" || status["selfDeclaredMadeForKids"] != true {
+		t.Fatalf("unexpected status %v", status)
+	}
+
+	// A publishAt that has already passed publishes right away.
+	past := now.Add(-time.Hour)
+	_, meta = youtubeVideoMetadata("caption", youtubeVideoOptions{Privacy: "private", PublishAt: &past}, false, now)
+	if status := meta["status"].(map[string]interface{}); status["privacyStatus"] != "public" || status["publishAt"] != nil {
+		t.Fatalf("unexpected status for past publishAt %v", status)
+	}
+
+	if !isYouTubeShort(preflightMedia{Width: 1080, Height: 1920, DurationSec: 59}) || isYouTubeShort(preflightMedia{Width: 1920, Height: 1080, DurationSec: 59}) ||
+		isYouTubeShort(preflightMedia{Width: 1080, Height: 1920, DurationSec: 600}) {
+		t.Fatalf("unexpected Shorts detection")
+	}
+
+	bad := []models.YouTubePostOptions{
+		{Privacy: "public", PublishAt: &at},
+		{Privacy: "friends"},
+		{Title: "<b>hi</b>"},
+		{CategoryID: "music"},
+		{Thumbnail: "https://x/t.jpg"},
+	}
+	for _, o := range bad {
+		o := o
+		if _, err := normalizePostOptions(&models.PostOptions{YouTube: &o}, nil); err == nil {
+			t.Fatalf("expected %+v to be rejected", o)
+		}
+	}
+	if got, err := normalizePostOptions(&models.PostOptions{YouTube: &models.YouTubePostOptions{Tags: []string{" #go ", "Go", ""}}}, nil); err != nil || len(got.YouTube.Tags) != 1 || got.YouTube.Tags[0] != "go" {
+		t.Fatalf("expected tags to be cleaned up, got %+v err=%v", got, err)
+	}
+}
+
+func TestPublishYouTubeSource_SendsOptionsAndAddsToPlaylist(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	raw, _ := json.Marshal(youtubeOAuth{AccessToken: "tok", Scope: "https://www.googleapis.com/auth/youtube.upload https://www.googleapis.com/auth/youtube.force-ssl"})
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings.*key='youtube_oauth'`).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(raw))
+	mock.ExpectExec(`INSERT INTO public\.social_libraries`).
+		WithArgs("youtube:u1:vid1", "u1", "video", "Title", "https://www.youtube.com/watch?v=vid1", sqlmock.AnyArg(), "vid1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	var meta map[string]map[string]interface{}
+	var playlist string
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/upload/youtube/v3/videos"):
+			_ = json.NewDecoder(r.Body).Decode(&meta)
+			return httpJSON(200, `{}`, map[string]string{"Location": "https://upload.youtube.com/resumable/1"}), nil
+		case r.URL.Host == "upload.youtube.com":
+			return httpJSON(200, `{"id":"vid1"}`, nil), nil
+		case strings.HasSuffix(r.URL.Path, "/youtube/v3/playlistItems"):
+			b, _ := io.ReadAll(r.Body)
+			playlist = string(b)
+			return httpJSON(200, `{"id":"pi1"}`, nil), nil
+		}
+		return httpJSON(404, `{"error":{"message":"not found"}}`, nil), nil
+	}}
+
+	opts := youtubeOptionsFor("Variant title", &models.PostOptions{YouTube: &models.YouTubePostOptions{
+		Title: "Title", Description: "Desc", Privacy: "unlisted", CategoryID: "10", PlaylistID: "PL123",
+	}})
+	posted, perr, details := h.publishYouTubeVideo(context.Background(), "u1", "caption", uploadedMedia{Filename: "v.mp4", ContentType: "video/mp4", Bytes: []byte("1234")}, opts, false)
+	if perr != nil || posted != 1 || details["playlistError"] != nil {
+		t.Fatalf("expected success, got posted=%d err=%v details=%v", posted, perr, details)
+	}
+	if meta["snippet"]["title"] != "Title" || meta["snippet"]["description"] != "Desc" || meta["snippet"]["categoryId"] != "10" || meta["status"]["privacyStatus"] != "unlisted" {
+		t.Fatalf("unexpected metadata %v", meta)
+	}
+	if !strings.Contains(playlist, `"playlistId":"PL123"`) || !strings.Contains(playlist, `"videoId":"vid1"`) {
+		t.Fatalf("unexpected playlist request %s", playlist)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestEnqueuePublishJobForUser_CarriesYouTubeOptions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	enqueue := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/social-posts/publish-async/user/u1", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req = mux.SetURLVars(req, map[string]string{"userId": "u1"})
+		rr := httptest.NewRecorder()
+		h.EnqueuePublishJobForUser(rr, req)
+		return rr
+	}
+
+	var reqJSON string
+	mock.ExpectExec(`INSERT INTO public\.publish_jobs`).
+		WithArgs(sqlmock.AnyArg(), "u1", sqlmock.AnyArg(), "cap", captureArg{&reqJSON}, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	rr := enqueue(`{"caption":"cap","providers":["youtube"],"options":{"youtube":{"title":"My video","privacy":"unlisted","categoryId":"10","tags":[" music "]}}}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d body=%q", rr.Code, rr.Body.String())
+	}
+	var job publishJobRequest
+	if err := json.Unmarshal([]byte(reqJSON), &job); err != nil {
+		t.Fatalf("request_json: %v (%s)", err, reqJSON)
+	}
+	got := youtubeOptionsFor("", job.Options)
+	if got.Title != "My video" || got.Privacy != "unlisted" || got.CategoryID != "10" || len(got.Tags) != 1 || got.Tags[0] != "music" {
+		t.Fatalf("unexpected youtube options after the round trip %+v", got)
+	}
+
+	// Invalid options are rejected before anything is queued.
+	if rr := enqueue(`{"caption":"cap","providers":["youtube"],"options":{"youtube":{"privacy":"friends"}}}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 got %d body=%q", rr.Code, rr.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestRunPublishJob_YouTubeOnlyStreamsFromDisk(t *testing.T) {
+	tmp := t.TempDir()
+	cwd, _ := os.Getwd()
+	_ = os.Chdir(tmp)
+	defer func() { _ = os.Chdir(cwd) }()
+	dir := filepath.Join("media", "uploads", "u1")
+	_ = os.MkdirAll(dir, 0o755)
+	video := []byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom")
+	_ = os.WriteFile(filepath.Join(dir, "v.mp4"), video, 0o644)
+
+	// Only the names and content types are read up front.
+	files, err := statUploadedMediaFromRelPaths([]string{"/media/uploads/u1/v.mp4"})
+	if err != nil || len(files) != 1 || files[0].Bytes != nil || files[0].ContentType != "video/mp4" {
+		t.Fatalf("unexpected stat result %+v err=%v", files, err)
+	}
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	h := New(db)
+
+	mock.ExpectExec(`UPDATE public\.publish_jobs.*status='running'`).WithArgs("job1").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE public\.posts.*last_publish_status='running'`).WithArgs("job1").WillReturnResult(sqlmock.NewResult(0, 0))
+	raw, _ := json.Marshal(youtubeOAuth{AccessToken: "tok", Scope: "https://www.googleapis.com/auth/youtube.upload"})
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings.*key='youtube_oauth'`).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(raw))
+	mock.ExpectExec(`INSERT INTO public\.social_libraries`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE public\.publish_jobs\s+SET result_json = COALESCE`).
+		WithArgs("job1", "youtube", sqlmock.AnyArg(), "w1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE public\.publish_jobs.*SET status=\$2`).
+		WithArgs("job1", "completed", sqlmock.AnyArg(), sqlmock.AnyArg(), "w1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE public\.posts.*SET last_publish_status=\$2`).
+		WithArgs("job1", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	var uploaded []byte
+	orig := http.DefaultTransport
+	defer func() { http.DefaultTransport = orig }()
+	http.DefaultTransport = stubTransport{fn: func(r *http.Request) (*http.Response, error) {
+		if r.URL.Host == "upload.youtube.com" {
+			uploaded, _ = io.ReadAll(r.Body)
+			return httpJSON(200, `{"id":"vid1"}`, nil), nil
+		}
+		return httpJSON(200, `{}`, map[string]string{"Location": "https://upload.youtube.com/resumable/1"}), nil
+	}}
+
+	h.runPublishJob(context.Background(), "job1", "w1", "u1", "cap", publishPostRequest{Providers: []string{"youtube"}}, []string{"/media/uploads/u1/v.mp4"}, "https://app.test")
+
+	if !bytes.Equal(uploaded, video) {
+		t.Fatalf("expected the video to be streamed from disk, got %q", uploaded)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
@@ -71,58 +71,70 @@ type insightMetric struct {
 // SyncUser imports the latest Instagram media for a single user and upserts rows into SocialLibraries.
 // It looks up the user's stored token in public.user_settings key='instagram_oauth'.
 func SyncUser(ctx context.Context, db *sql.DB, userID string, logger *log.Logger) (fetched int, upserted int, err error) {
-	return syncUserWithClient(ctx, db, userID, logger, &http.Client{Timeout: 15 * time.Second})
+	fetched, upserted, _, err = SyncUserSince(ctx, db, userID, time.Time{}, logger)
+	return fetched, upserted, err
+}
+
+// SyncUserSince is SyncUser limited to media posted at or after since (all media when zero).
+// It also returns the newest media timestamp seen, to pass as since on the next call.
+func SyncUserSince(ctx context.Context, db *sql.DB, userID string, since time.Time, logger *log.Logger) (fetched int, upserted int, newest time.Time, err error) {
+	return syncUserWithClient(ctx, db, userID, since, logger, &http.Client{Timeout: 15 * time.Second})
 }
 
-func syncUserWithClient(ctx context.Context, db *sql.DB, userID string, logger *log.Logger, client *http.Client) (fetched int, upserted int, err error) {
+func syncUserWithClient(ctx context.Context, db *sql.DB, userID string, since time.Time, logger *log.Logger, client *http.Client) (fetched int, upserted int, newest time.Time, err error) {
 	if db == nil {
-		return 0, 0, fmt.Errorf("db is nil")
+		return 0, 0, newest, fmt.Errorf("db is nil")
 	}
 	l := logger
 	if l == nil {
 		l = log.Default()
 	}
 	if userID == "" {
-		return 0, 0, fmt.Errorf("userID is required")
+		return 0, 0, newest, fmt.Errorf("userID is required")
 	}
 
 	var raw []byte
 	q := `SELECT value FROM public.user_settings WHERE user_id = $1 AND key = 'instagram_oauth' AND value IS NOT NULL`
 	if err := db.QueryRowContext(ctx, q, userID).Scan(&raw); err != nil {
 		if err == sql.ErrNoRows {
-			return 0, 0, nil
+			return 0, 0, newest, nil
 		}
-		return 0, 0, err
+		return 0, 0, newest, err
 	}
 	if len(raw) == 0 || string(raw) == "null" {
-		return 0, 0, nil
+		return 0, 0, newest, nil
 	}
 	var tok oauthRecord
 	if err := json.Unmarshal(raw, &tok); err != nil {
 		l.Printf("[IGSync] invalid oauth json userId=%s err=%v raw=%s", userID, err, truncate(string(raw), 600))
-		return 0, 0, nil
+		return 0, 0, newest, nil
 	}
 	if tok.AccessToken == "" || tok.IGBusinessID == "" {
 		l.Printf("[IGSync] missing token fields userId=%s accessToken=%t igBusinessId=%t", userID, tok.AccessToken != "", tok.IGBusinessID != "")
-		return 0, 0, nil
+		return 0, 0, newest, nil
 	}
 
 	if client == nil {
 		client = &http.Client{Timeout: 15 * time.Second}
 	}
 	imp := &Importer{DB: db, Client: client}
-	media, _, err := imp.fetchRecentMedia(ctx, tok.IGBusinessID, tok.AccessToken)
+	media, rawPayload, err := imp.fetchMediaSince(ctx, tok.IGBusinessID, tok.AccessToken, since)
 	if err != nil {
-		return 0, 0, err
+		return 0, 0, newest, err
 	}
 	fetched = len(media)
+	for _, m := range media {
+		if t := parseIGTimestamp(m.Timestamp); t != nil && t.After(newest) {
+			newest = *t
+		}
+	}
 	if fetched == 0 {
-		return fetched, 0, nil
+		return fetched, 0, newest, nil
 	}
 
 	// Reuse the existing upsert logic; it logs upsert errors and counts successes.
-	upserted, err = imp.importForUser(ctx, userID, tok, l)
-	return fetched, upserted, err
+	upserted, err = imp.importMedia(ctx, userID, tok, media, rawPayload, l)
+	return fetched, upserted, newest, err
 }
 
 // Start runs forever until ctx is cancelled.
@@ -241,6 +253,11 @@ func (i *Importer) importForUser(ctx context.Context, userID string, tok oauthRe
 	if err != nil {
 		return 0, err
 	}
+	return i.importMedia(ctx, userID, tok, media, rawPayload, l)
+}
+
+// importMedia fetches insights for fetched media and upserts it into SocialLibraries.
+func (i *Importer) importMedia(ctx context.Context, userID string, tok oauthRecord, media []mediaItem, rawPayload []byte, l *log.Logger) (int, error) {
 	if len(media) == 0 {
 		l.Printf("[IGImporter] no media userId=%s", userID)
 		return 0, nil
@@ -325,6 +342,11 @@ func (i *Importer) importForUser(ctx context.Context, userID string, tok oauthRe
 }
 
 func (i *Importer) fetchRecentMedia(ctx context.Context, igBusinessID string, accessToken string) ([]mediaItem, []byte, error) {
+	return i.fetchMediaSince(ctx, igBusinessID, accessToken, time.Time{})
+}
+
+// fetchMediaSince pages through the account's media posted at or after since (all of it when zero).
+func (i *Importer) fetchMediaSince(ctx context.Context, igBusinessID string, accessToken string, since time.Time) ([]mediaItem, []byte, error) {
 	allMedia := make([]mediaItem, 0)
 	var lastBody []byte
 	after := ""
@@ -340,6 +362,9 @@ func (i *Importer) fetchRecentMedia(ctx context.Context, igBusinessID string, ac
 		u := fmt.Sprintf("https://graph.facebook.com/v24.0/%s/media?fields=id,caption,media_type,permalink,timestamp,like_count,media_url,thumbnail_url&limit=100",
 			igBusinessID,
 		)
+		if !since.IsZero() {
+			u += fmt.Sprintf("&since=%d", since.Unix())
+		}
 		if after != "" {
 			u += "&after=" + after
 		}
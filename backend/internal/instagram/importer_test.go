@@ -113,7 +113,7 @@ func TestSyncUserWithClient_Success_Upserts(t *testing.T) {
 		WithArgs(sqlmock.AnyArg(), "u1", sqlmock.AnyArg(), sqlmock.AnyArg(), "p1", "u1", "u1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "m1").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	fetched, upserted, err := syncUserWithClient(context.Background(), db, "u1", log.New(io.Discard, "", 0), client)
+	fetched, upserted, _, err := syncUserWithClient(context.Background(), db, "u1", time.Time{}, log.New(io.Discard, "", 0), client)
 	if err != nil {
 		t.Fatalf("syncUserWithClient: %v", err)
 	}
@@ -438,3 +438,47 @@ func TestHelpers(t *testing.T) {
 		t.Fatalf("expected timestamp for +00:00 offset")
 	}
 }
+
+func TestSyncUserWithClient_Since_FiltersAndReturnsNewest(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT value FROM public\.user_settings`).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte(`{"accessToken":"t","igBusinessId":"ig"}`)))
+	mock.ExpectExec(`INSERT INTO public\.social_libraries`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	var gotSince string
+	client := &http.Client{
+		Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			body := `{"data":[]}`
+			if strings.HasSuffix(r.URL.Path, "/media") {
+				gotSince = r.URL.Query().Get("since")
+				body = `{"data":[{"id":"m2","media_type":"IMAGE","timestamp":"2024-01-03T00:00:00+0000"}]}`
+			}
+			return &http.Response{StatusCode: 200, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(body))}, nil
+		}),
+	}
+
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fetched, upserted, newest, err := syncUserWithClient(context.Background(), db, "u1", since, log.New(io.Discard, "", 0), client)
+	if err != nil {
+		t.Fatalf("syncUserWithClient: %v", err)
+	}
+	if fetched != 1 || upserted != 1 {
+		t.Fatalf("expected 1/1, got %d/%d", fetched, upserted)
+	}
+	if gotSince != "1704067200" {
+		t.Fatalf("expected since=1704067200, got %q", gotSince)
+	}
+	if !newest.Equal(time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected newest: %v", newest)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
@@ -56,4 +56,153 @@ type Post struct {
 	LastPublishAttemptAt *time.Time `json:"lastPublishAttemptAt,omitempty"`
 	CreatedAt            time.Time  `json:"createdAt"`
 	UpdatedAt            time.Time  `json:"updatedAt"`
+	// Recurrence repeats a scheduled post; ScheduledFor is then the next occurrence.
+	Recurrence *PostRecurrence `json:"recurrence,omitempty"`
+	// Variants overrides the shared content per provider (keyed by provider, e.g. "instagram").
+	Variants map[string]PostVariant `json:"variants,omitempty"`
+	// Options holds settings that only exist on one network (tags, locations, ...).
+	Options *PostOptions `json:"options,omitempty"`
+}
+
+// PostVariant customizes a post for one provider; empty fields fall back to the post's shared values.
+type PostVariant struct {
+	Caption string `json:"caption,omitempty"`
+	// Title is used where the network has one (YouTube, Pinterest).
+	Title string `json:"title,omitempty"`
+	// Link is the pin's destination on Pinterest and is appended to the caption elsewhere.
+	Link string `json:"link,omitempty"`
+	// Media is a subset of the post's media (in the order given) to use for this provider.
+	Media []string `json:"media,omitempty"`
+	// FirstComment is posted as a comment (or reply) right after publishing, where supported.
+	FirstComment string `json:"firstComment,omitempty"`
+}
+
+// PostOptions groups network-specific publish settings; a nil section uses the network's defaults.
+type PostOptions struct {
+	Instagram *InstagramPostOptions `json:"instagram,omitempty"`
+	Facebook  *FacebookPostOptions  `json:"facebook,omitempty"`
+	YouTube   *YouTubePostOptions   `json:"youtube,omitempty"`
+	TikTok    *TikTokPostOptions    `json:"tiktok,omitempty"`
+	Pinterest *PinterestPostOptions `json:"pinterest,omitempty"`
+}
+
+// InstagramPostOptions are Instagram-only publish fields. The first comment is set with
+// variants.instagram.firstComment like on the other networks.
+type InstagramPostOptions struct {
+	// UserTags tags accounts on images (at X/Y, 0..1 from the top-left) and videos (position ignored).
+	UserTags []InstagramUserTag `json:"userTags,omitempty"`
+	// LocationID is a Facebook Page id with a location, as returned by the Pages search API.
+	LocationID string `json:"locationId,omitempty"`
+	// Collaborators are usernames invited as collaborators (up to 3).
+	Collaborators []string `json:"collaborators,omitempty"`
+	// AltText is keyed by media rel path; only images support it.
+	AltText map[string]string `json:"altText,omitempty"`
+	// Mode is "feed" (default: image, carousel or Reel) or "story", which publishes each media item as a
+	// Story. Stories take no caption, tags, location, collaborators or alt text.
+	Mode string `json:"mode,omitempty"`
+	// StoryLetterbox pads story media that isn't 9:16 onto a 1080x1920 canvas instead of rejecting it.
+	StoryLetterbox bool `json:"storyLetterbox,omitempty"`
+}
+
+// FacebookPostOptions are Facebook Page publish fields. The link itself is variants.facebook.link; it is
+// attached as a link preview when the post has no media.
+type FacebookPostOptions struct {
+	// LinkPreview overrides the scraped preview; Meta only honors it for domains the Page has verified.
+	LinkPreview *FacebookLinkPreview `json:"linkPreview,omitempty"`
+	// NativeSchedule hands a scheduled post to Meta (scheduled_publish_time) when it is saved, so it goes
+	// out even if this server is down. The time must be 10 minutes to 30 days ahead.
+	NativeSchedule bool `json:"nativeSchedule,omitempty"`
+	// Unpublished creates the Page post without showing it on the Page (e.g. for ads).
+	Unpublished bool `json:"unpublished,omitempty"`
+}
+
+// FacebookLinkPreview replaces the title, description and image Facebook scrapes from the link.
+type FacebookLinkPreview struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+}
+
+// YouTubePostOptions are YouTube video fields; empty fields fall back to the caption (title and description),
+// public visibility and the People & Blogs category.
+type YouTubePostOptions struct {
+	// Title overrides variants.youtube.title and the caption-derived title (max 100 characters).
+	Title       string   `json:"title,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	// Privacy is public, unlisted or private.
+	Privacy string `json:"privacy,omitempty"`
+	// PublishAt uploads the video as private and lets YouTube make it public at that time.
+	PublishAt   *time.Time `json:"publishAt,omitempty"`
+	MadeForKids bool       `json:"madeForKids,omitempty"`
+	// CategoryID is a YouTube video category id ("22" is People & Blogs).
+	CategoryID string `json:"categoryId,omitempty"`
+	// Thumbnail is the rel path (/media/...) of an uploaded image used as the custom thumbnail.
+	Thumbnail string `json:"thumbnail,omitempty"`
+	// PlaylistID adds the video to one of the channel's playlists after upload.
+	PlaylistID string `json:"playlistId,omitempty"`
+}
+
+// TikTokPostOptions are TikTok Content Posting API fields. A post with a video is published as that video;
+// a post with only images goes out as a photo carousel.
+type TikTokPostOptions struct {
+	// Privacy is PUBLIC_TO_EVERYONE, MUTUAL_FOLLOW_FRIENDS, FOLLOWER_OF_CREATOR or SELF_ONLY and must be one
+	// the creator currently allows. Empty uses public, or the creator's first option when public isn't allowed.
+	Privacy        string `json:"privacy,omitempty"`
+	DisableComment bool   `json:"disableComment,omitempty"`
+	DisableDuet    bool   `json:"disableDuet,omitempty"`
+	DisableStitch  bool   `json:"disableStitch,omitempty"`
+	// BrandContent discloses a paid partnership; BrandOrganic promotes the creator's own business.
+	BrandContent bool `json:"brandContent,omitempty"`
+	BrandOrganic bool `json:"brandOrganic,omitempty"`
+	// CoverTimestampMs picks the video frame used as the cover.
+	CoverTimestampMs int `json:"coverTimestampMs,omitempty"`
+	// PhotoCoverIndex picks the cover image of a photo post.
+	PhotoCoverIndex int  `json:"photoCoverIndex,omitempty"`
+	AutoAddMusic    bool `json:"autoAddMusic,omitempty"`
+	// Mode is "direct" (default) or "draft", which uploads to the creator's TikTok inbox to finish in the app.
+	// Drafts ignore privacy, interaction and disclosure settings.
+	Mode string `json:"mode,omitempty"`
+}
+
+// PinterestPostOptions choose where a pin is saved and fill its rich pin fields. Several images become a
+// carousel pin; a video becomes a video pin (with the post's first image as cover, if any).
+type PinterestPostOptions struct {
+	// BoardID is one of the user's boards (see GET /api/pinterest/boards/user/{userId}); empty uses the
+	// first board, creating one when the user has none.
+	BoardID string `json:"boardId,omitempty"`
+	// SectionID is a section of BoardID.
+	SectionID string `json:"sectionId,omitempty"`
+	// Title and Link override variants.pinterest.title/link.
+	Title   string `json:"title,omitempty"`
+	Link    string `json:"link,omitempty"`
+	AltText string `json:"altText,omitempty"`
+}
+
+// InstagramUserTag tags one account on one media item.
+type InstagramUserTag struct {
+	Username string  `json:"username"`
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	// Media is the rel path of the tagged item; empty tags the first item.
+	Media string `json:"media,omitempty"`
+}
+
+// PostRecurrence is an RRULE-style repeat rule for a scheduled post. Occurrences keep the wall-clock time of
+// Start in Timezone (so they stay at 09:00 local across DST changes).
+type PostRecurrence struct {
+	// Freq is daily, weekly or monthly.
+	Freq string `json:"freq"`
+	// Interval repeats every N days/weeks/months (default: 1).
+	Interval int `json:"interval,omitempty"`
+	// ByWeekday lists RRULE weekday codes (MO..SU) for weekly rules (default: Start's weekday).
+	ByWeekday []string `json:"byWeekday,omitempty"`
+	// ByMonthDay lists days of the month for monthly rules (default: Start's day); months without the day are skipped.
+	ByMonthDay []int `json:"byMonthDay,omitempty"`
+	// Until and Count end the series (both optional); Count includes skipped occurrences, like RRULE.
+	Until    *time.Time `json:"until,omitempty"`
+	Count    int        `json:"count,omitempty"`
+	Timezone string     `json:"timezone,omitempty"`
+	// Start anchors the series; set by the server from the post's scheduledFor.
+	Start *time.Time `json:"start,omitempty"`
 }
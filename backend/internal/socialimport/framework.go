@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 
 	"golang.org/x/time/rate"
@@ -20,10 +21,33 @@ type Provider interface {
 	SyncUser(ctx context.Context, db *sql.DB, userID string, client *http.Client, limiter *rate.Limiter, logger *log.Logger) (fetched int, upserted int, err error)
 }
 
+// IncrementalProvider is a Provider that resumes from a per-user cursor instead of re-fetching from the top.
+// The Runner loads the cursor from social_import_states, passes it in, and saves the returned one;
+// returning a nil cursor keeps the stored value (e.g. when a run was cut short and must be repeated).
+type IncrementalProvider interface {
+	Provider
+	SyncUserIncremental(ctx context.Context, db *sql.DB, userID string, cursor map[string]string, client *http.Client, limiter *rate.Limiter, logger *log.Logger) (fetched int, upserted int, next map[string]string, err error)
+}
+
 type Runner struct {
 	DB     *sql.DB
 	Client *http.Client
 	Logger *log.Logger
+	// Failed syncs are not retried before next_run_at: BackoffBase after the first failure,
+	// doubling per consecutive failure up to BackoffMax.
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// OnProgress, when set, is called as each provider sync starts and finishes (e.g. to push realtime events).
+	OnProgress func(ProgressEvent)
+
+	// Worker sweep tuning (StartProviderWorker): users synced in parallel per provider, the time budget for
+	// one user's sync, and the maximum random delay added before each sweep (0 disables jitter).
+	Concurrency int
+	UserBudget  time.Duration
+	Jitter      time.Duration
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
 }
 
 type RateLimitConfig struct {
@@ -76,12 +100,40 @@ func (r *Runner) EnsureDefaults() {
 	if r.Logger == nil {
 		r.Logger = log.Default()
 	}
+	if r.BackoffBase <= 0 {
+		r.BackoffBase = 15 * time.Minute
+	}
+	if r.BackoffMax <= 0 {
+		r.BackoffMax = 24 * time.Hour
+	}
+	if r.Concurrency <= 0 {
+		r.Concurrency = 2
+	}
+	if r.UserBudget <= 0 {
+		r.UserBudget = 5 * time.Minute
+	}
+}
+
+// RateLimitFor returns the effective limits for a provider (defaults plus env overrides).
+// Providers that page through several API calls per sync use DailyRequestsMax with ConsumeRequests.
+func RateLimitFor(provider string) RateLimitConfig {
+	return rateLimitFromEnv(provider, DefaultRateLimits()[provider])
 }
 
+// limiterForProvider returns the Runner's limiter for a provider, shared by all concurrent syncs so the
+// configured rate applies per provider rather than per user.
 func (r *Runner) limiterForProvider(provider string) (*rate.Limiter, RateLimitConfig) {
-	cfg := DefaultRateLimits()[provider]
-	cfg = rateLimitFromEnv(provider, cfg)
-	lim := rate.NewLimiter(rate.Limit(cfg.RequestsPerSecond), cfg.Burst)
+	cfg := RateLimitFor(provider)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.limiters == nil {
+		r.limiters = map[string]*rate.Limiter{}
+	}
+	lim, ok := r.limiters[provider]
+	if !ok {
+		lim = rate.NewLimiter(rate.Limit(cfg.RequestsPerSecond), cfg.Burst)
+		r.limiters[provider] = lim
+	}
 	return lim, cfg
 }
 
@@ -82,3 +82,29 @@ func TestConsumeRequests_OK_Exceeded_Error(t *testing.T) {
 		t.Fatalf("sql expectations: %v", err)
 	}
 }
+
+func TestLoadAndSaveCursor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`FROM public\.social_import_states`).
+		WithArgs("u1", "x").
+		WillReturnRows(sqlmock.NewRows([]string{"cursor"}).AddRow([]byte(`{"sinceId":"123","n":5}`)))
+	cur, err := LoadCursor(context.Background(), db, "u1", "x")
+	if err != nil || cur["sinceId"] != "123" || len(cur) != 1 {
+		t.Fatalf("unexpected cursor %#v err=%v", cur, err)
+	}
+
+	mock.ExpectExec(`INSERT INTO public\.social_import_states`).
+		WithArgs("x:u1", "u1", "x", `{"sinceId":"456"}`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	if err := SaveCursor(context.Background(), db, "u1", "x", map[string]string{"sinceId": "456"}); err != nil {
+		t.Fatalf("SaveCursor: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
@@ -17,6 +17,10 @@ import (
 	"golang.org/x/time/rate"
 )
 
+// FacebookProvider imports posts from the user's connected Facebook Pages.
+//
+// Sync is incremental per page: the newest post time seen on a page is stored as its `since:<pageId>`
+// cursor and only posts from then on are requested next time.
 type FacebookProvider struct{}
 
 func (p FacebookProvider) Name() string { return "facebook" }
@@ -39,7 +43,13 @@ type facebookOAuth struct {
 }
 
 type fbPostsResp struct {
-	Data []fbPost `json:"data"`
+	Data   []fbPost `json:"data"`
+	Paging struct {
+		Cursors struct {
+			After string `json:"after"`
+		} `json:"cursors"`
+		Next string `json:"next"`
+	} `json:"paging"`
 }
 
 type fbPost struct {
@@ -73,9 +83,14 @@ func sanitizeText(s string) string {
 	return s
 }
 
+// SyncUser loads and saves the cursor itself; the Runner goes through SyncUserIncremental.
 func (p FacebookProvider) SyncUser(ctx context.Context, db *sql.DB, userID string, client *http.Client, limiter *rate.Limiter, logger *log.Logger) (int, int, error) {
+	return socialimport.SyncIncremental(ctx, db, p, userID, client, limiter, logger)
+}
+
+func (p FacebookProvider) SyncUserIncremental(ctx context.Context, db *sql.DB, userID string, cursor map[string]string, client *http.Client, limiter *rate.Limiter, logger *log.Logger) (int, int, map[string]string, error) {
 	if db == nil {
-		return 0, 0, fmt.Errorf("db is nil")
+		return 0, 0, nil, fmt.Errorf("db is nil")
 	}
 	l := logger
 	if l == nil {
@@ -89,17 +104,17 @@ func (p FacebookProvider) SyncUser(ctx context.Context, db *sql.DB, userID strin
 	var raw []byte
 	if err := db.QueryRowContext(ctx, `SELECT value FROM public.user_settings WHERE user_id=$1 AND key='facebook_oauth' AND value IS NOT NULL`, userID).Scan(&raw); err != nil {
 		if err == sql.ErrNoRows {
-			return 0, 0, nil
+			return 0, 0, nil, nil
 		}
-		return 0, 0, err
+		return 0, 0, nil, err
 	}
 	if len(raw) == 0 || string(raw) == "null" {
-		return 0, 0, nil
+		return 0, 0, nil, nil
 	}
 	var tok facebookOAuth
 	if err := json.Unmarshal(raw, &tok); err != nil {
 		l.Printf("[FBImport] invalid oauth json userId=%s err=%v raw=%s", userID, err, truncate(string(raw), 600))
-		return 0, 0, nil
+		return 0, 0, nil, nil
 	}
 
 	// Determine pages to import from.
@@ -117,112 +132,144 @@ func (p FacebookProvider) SyncUser(ctx context.Context, db *sql.DB, userID strin
 	}
 	if len(pages) == 0 {
 		l.Printf("[FBImport] skip userId=%s reason=missing_page_token", userID)
-		return 0, 0, nil
+		return 0, 0, nil, nil
 	}
 
 	totalFetched := 0
 	totalUpserted := 0
+	var next map[string]string
 
 	for _, page := range pages {
-		if limiter != nil {
-			if err := limiter.Wait(ctx); err != nil {
-				return totalFetched, totalUpserted, err
+		key := "since:" + page.ID
+		since := cursorTime(cursor, key)
+		newest := since
+		fetched := 0
+		upserted := 0
+		complete := false
+		after := ""
+		for pg := 0; pg < importMaxPagesPerSync; pg++ {
+			if pg > 0 {
+				if err := consumeExtraRequest(ctx, db, p.Name()); err != nil {
+					return totalFetched, totalUpserted, next, err
+				}
+			}
+			if limiter != nil {
+				if err := limiter.Wait(ctx); err != nil {
+					return totalFetched, totalUpserted, next, err
+				}
 			}
-		}
-
-		// Prefer the published_posts edge; some page setups return empty results on /posts.
-		endpoint := fmt.Sprintf("https://graph.facebook.com/v24.0/%s/published_posts", url.PathEscape(page.ID))
-		q := url.Values{}
-		q.Set("fields", "id,message,created_time,permalink_url,attachments{type,url,media}")
-		q.Set("limit", "25")
-		q.Set("access_token", page.AccessToken)
-		reqURL := endpoint + "?" + q.Encode()
-
-		req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
-		if err != nil {
-			return totalFetched, totalUpserted, err
-		}
-		req.Header.Set("Accept", "application/json")
 
-		res, err := client.Do(req)
-		if err != nil {
-			return totalFetched, totalUpserted, err
-		}
-		body, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
-		_ = res.Body.Close()
-		if res.StatusCode < 200 || res.StatusCode >= 300 {
-			return totalFetched, totalUpserted, fmt.Errorf("facebook_non_2xx pageId=%s status=%d body=%s", page.ID, res.StatusCode, truncate(string(body), 600))
-		}
+			// Prefer the published_posts edge; some page setups return empty results on /posts.
+			endpoint := fmt.Sprintf("https://graph.facebook.com/v24.0/%s/published_posts", url.PathEscape(page.ID))
+			q := url.Values{}
+			q.Set("fields", "id,message,created_time,permalink_url,attachments{type,url,media}")
+			q.Set("limit", "25")
+			if !since.IsZero() {
+				q.Set("since", fmt.Sprintf("%d", since.Unix()))
+			}
+			if after != "" {
+				q.Set("after", after)
+			}
+			q.Set("access_token", page.AccessToken)
+			reqURL := endpoint + "?" + q.Encode()
 
-		var parsed fbPostsResp
-		if err := json.Unmarshal(body, &parsed); err != nil {
-			return totalFetched, totalUpserted, err
-		}
+			req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+			if err != nil {
+				return totalFetched, totalUpserted, next, err
+			}
+			req.Header.Set("Accept", "application/json")
 
-		fetched := len(parsed.Data)
-		upserted := 0
-		totalFetched += fetched
+			res, err := client.Do(req)
+			if err != nil {
+				return totalFetched, totalUpserted, next, err
+			}
+			body, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+			_ = res.Body.Close()
+			if res.StatusCode < 200 || res.StatusCode >= 300 {
+				return totalFetched, totalUpserted, next, fmt.Errorf("facebook_non_2xx pageId=%s status=%d body=%s", page.ID, res.StatusCode, truncate(string(body), 600))
+			}
 
-		for _, post := range parsed.Data {
-			if post.ID == "" {
-				continue
+			var parsed fbPostsResp
+			if err := json.Unmarshal(body, &parsed); err != nil {
+				return totalFetched, totalUpserted, next, err
 			}
-			title := sanitizeText(normalizeTitle(post.Message))
-			permalink := sanitizeText(strings.TrimSpace(post.Permalink))
-			var thumb string
-			var mediaURL string
-			if len(post.Attachments.Data) > 0 {
-				a := post.Attachments.Data[0]
-				mediaURL = sanitizeText(strings.TrimSpace(a.URL))
-				thumb = sanitizeText(strings.TrimSpace(a.Media.Image.Src))
-				if thumb == "" {
-					thumb = mediaURL
+
+			fetched += len(parsed.Data)
+			totalFetched += len(parsed.Data)
+
+			for _, post := range parsed.Data {
+				if post.ID == "" {
+					continue
 				}
-			}
+				title := sanitizeText(normalizeTitle(post.Message))
+				permalink := sanitizeText(strings.TrimSpace(post.Permalink))
+				var thumb string
+				var mediaURL string
+				if len(post.Attachments.Data) > 0 {
+					a := post.Attachments.Data[0]
+					mediaURL = sanitizeText(strings.TrimSpace(a.URL))
+					thumb = sanitizeText(strings.TrimSpace(a.Media.Image.Src))
+					if thumb == "" {
+						thumb = mediaURL
+					}
+				}
+
+				postedAt := parseGraphTime(post.CreatedTime)
+				newest = laterTime(newest, postedAt)
 
-			var postedAt *time.Time
-			if post.CreatedTime != "" {
-				if t, err := time.Parse(time.RFC3339, post.CreatedTime); err == nil {
-					tt := t.UTC()
-					postedAt = &tt
+				rawItem, _ := json.Marshal(post)
+				rawStr := sanitizeText(string(rawItem))
+				rowID := fmt.Sprintf("facebook:%s:%s", userID, post.ID)
+				_, err := db.ExecContext(ctx, `
+					INSERT INTO public.social_libraries
+					  (id, user_id, network, content_type, title, permalink_url, media_url, thumbnail_url, posted_at, views, likes, raw_payload, external_id, created_at, updated_at)
+					VALUES
+					  ($1, $2, 'facebook', 'post', NULLIF($3,''), NULLIF($4,''), NULLIF($5,''), NULLIF($6,''), $7, NULL, NULL, $8::jsonb, $9, NOW(), NOW())
+					ON CONFLICT (user_id, network, external_id)
+					DO UPDATE SET
+					  title = EXCLUDED.title,
+					  permalink_url = EXCLUDED.permalink_url,
+					  media_url = EXCLUDED.media_url,
+					  thumbnail_url = EXCLUDED.thumbnail_url,
+					  posted_at = EXCLUDED.posted_at,
+					  raw_payload = EXCLUDED.raw_payload,
+					  updated_at = NOW()
+				`, rowID, userID, title, permalink, mediaURL, thumb, postedAt, rawStr, post.ID)
+				if err != nil {
+					l.Printf("[FBImport] upsert failed userId=%s pageId=%s postId=%s err=%v", userID, page.ID, post.ID, err)
+					continue
 				}
+				upserted++
+				totalUpserted++
 			}
 
-			rawItem, _ := json.Marshal(post)
-			rawStr := sanitizeText(string(rawItem))
-			rowID := fmt.Sprintf("facebook:%s:%s", userID, post.ID)
-			_, err := db.ExecContext(ctx, `
-				INSERT INTO public.social_libraries
-				  (id, user_id, network, content_type, title, permalink_url, media_url, thumbnail_url, posted_at, views, likes, raw_payload, external_id, created_at, updated_at)
-				VALUES
-				  ($1, $2, 'facebook', 'post', NULLIF($3,''), NULLIF($4,''), NULLIF($5,''), NULLIF($6,''), $7, NULL, NULL, $8::jsonb, $9, NOW(), NOW())
-				ON CONFLICT (user_id, network, external_id)
-				DO UPDATE SET
-				  title = EXCLUDED.title,
-				  permalink_url = EXCLUDED.permalink_url,
-				  media_url = EXCLUDED.media_url,
-				  thumbnail_url = EXCLUDED.thumbnail_url,
-				  posted_at = EXCLUDED.posted_at,
-				  raw_payload = EXCLUDED.raw_payload,
-				  updated_at = NOW()
-			`, rowID, userID, title, permalink, mediaURL, thumb, postedAt, rawStr, post.ID)
-			if err != nil {
-				l.Printf("[FBImport] upsert failed userId=%s pageId=%s postId=%s err=%v", userID, page.ID, post.ID, err)
-				continue
+			after = parsed.Paging.Cursors.After
+			if parsed.Paging.Next == "" || after == "" {
+				complete = true
+				break
+			}
+		}
+
+		// Same rule as X: the page's cursor advances once every new post was read (or on its first sync).
+		if newest.After(since) && (complete || since.IsZero()) {
+			if next == nil {
+				next = make(map[string]string, len(cursor)+len(pages))
+				for k, v := range cursor {
+					next[k] = v
+				}
 			}
-			upserted++
-			totalUpserted++
+			next[key] = newest.Format(time.RFC3339)
 		}
 
 		pageName := ""
 		if page.Name != nil {
 			pageName = *page.Name
 		}
-		l.Printf("[FBImport] page userId=%s pageId=%s pageName=%s fetched=%d upserted=%d", userID, page.ID, pageName, fetched, upserted)
+		l.Printf("[FBImport] page userId=%s pageId=%s pageName=%s fetched=%d upserted=%d since=%s complete=%v", userID, page.ID, pageName, fetched, upserted, cursor[key], complete)
 	}
 
 	l.Printf("[FBImport] done userId=%s pages=%d fetched=%d upserted=%d", userID, len(pages), totalFetched, totalUpserted)
-	return totalFetched, totalUpserted, nil
+	return totalFetched, totalUpserted, next, nil
 }
 
-var _ socialimport.Provider = FacebookProvider{}
+var _ socialimport.IncrementalProvider = FacebookProvider{}
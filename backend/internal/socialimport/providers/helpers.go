@@ -1,10 +1,19 @@
 package providers
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"strings"
+	"time"
+
+	"github.com/PortNumber53/simple-social-thing/backend/internal/socialimport"
 )
 
+// importMaxPagesPerSync caps the pages a provider reads in one incremental sync. Like X, a first sync
+// only imports this many pages of history; later syncs stop at the stored cursor long before it.
+const importMaxPagesPerSync = 5
+
 func truncate(s string, n int) string {
 	if n <= 0 || len(s) <= n {
 		return s
@@ -47,3 +56,52 @@ func toInt64(v any) *int64 {
 		return nil
 	}
 }
+
+// parseGraphTime parses a Graph API timestamp. Facebook and Threads send offsets without a colon
+// (2024-01-02T03:04:05+0000), which time.RFC3339 rejects.
+func parseGraphTime(s string) *time.Time {
+	if s == "" {
+		return nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05-0700"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			tt := t.UTC()
+			return &tt
+		}
+	}
+	return nil
+}
+
+// cursorTime returns the timestamp stored under key in an import cursor, or the zero time when unset.
+func cursorTime(cursor map[string]string, key string) time.Time {
+	t, err := time.Parse(time.RFC3339, cursor[key])
+	if err != nil {
+		return time.Time{}
+	}
+	return t.UTC()
+}
+
+// laterTime returns the later of cur and t (t may be nil).
+func laterTime(cur time.Time, t *time.Time) time.Time {
+	if t != nil && t.After(cur) {
+		return *t
+	}
+	return cur
+}
+
+// consumeExtraRequest counts one more API call of a sync against provider's daily quota. The runner
+// already counted the first call, so providers only call this for the pages after it.
+func consumeExtraRequest(ctx context.Context, db *sql.DB, provider string) error {
+	dailyMax := socialimport.RateLimitFor(provider).DailyRequestsMax
+	if dailyMax <= 0 {
+		return nil
+	}
+	ok, used, err := socialimport.ConsumeRequests(ctx, db, provider, 1, dailyMax)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%s_daily_quota_exceeded used=%d max=%d", provider, used, dailyMax)
+	}
+	return nil
+}
@@ -70,3 +70,15 @@ func TestSanitizeText_RemovesNulAndFixesUTF8(t *testing.T) {
 		t.Fatalf("expected valid utf8, got %q", out)
 	}
 }
+
+func TestParseGraphTime(t *testing.T) {
+	for _, s := range []string{"2024-01-02T03:04:05+0000", "2024-01-02T03:04:05Z", "2024-01-02T05:04:05+02:00"} {
+		got := parseGraphTime(s)
+		if got == nil || got.Format("2006-01-02T15:04:05Z07:00") != "2024-01-02T03:04:05Z" {
+			t.Fatalf("parseGraphTime(%q) = %v", s, got)
+		}
+	}
+	if parseGraphTime("") != nil || parseGraphTime("bad") != nil {
+		t.Fatalf("expected nil for empty/invalid")
+	}
+}
@@ -5,24 +5,39 @@ import (
 	"database/sql"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/PortNumber53/simple-social-thing/backend/internal/instagram"
 	"github.com/PortNumber53/simple-social-thing/backend/internal/socialimport"
 	"golang.org/x/time/rate"
 )
 
+// InstagramProvider imports the user's Instagram media.
+//
+// Sync is incremental: the newest media time seen is stored as the `since` cursor and only media from
+// then on is requested next time.
 type InstagramProvider struct{}
 
 func (p InstagramProvider) Name() string { return "instagram" }
 
-func (p InstagramProvider) SyncUser(ctx context.Context, db *sql.DB, userID string, _ *http.Client, limiter *rate.Limiter, logger *log.Logger) (int, int, error) {
+// SyncUser loads and saves the cursor itself; the Runner goes through SyncUserIncremental.
+func (p InstagramProvider) SyncUser(ctx context.Context, db *sql.DB, userID string, client *http.Client, limiter *rate.Limiter, logger *log.Logger) (int, int, error) {
+	return socialimport.SyncIncremental(ctx, db, p, userID, client, limiter, logger)
+}
+
+func (p InstagramProvider) SyncUserIncremental(ctx context.Context, db *sql.DB, userID string, cursor map[string]string, _ *http.Client, limiter *rate.Limiter, logger *log.Logger) (int, int, map[string]string, error) {
 	// Limit one “unit” for the Graph call batch; fine-grained accounting happens in provider implementations later.
 	if limiter != nil {
 		if err := limiter.Wait(ctx); err != nil {
-			return 0, 0, err
+			return 0, 0, nil, err
 		}
 	}
-	return instagram.SyncUser(ctx, db, userID, logger)
+	since := cursorTime(cursor, "since")
+	fetched, upserted, newest, err := instagram.SyncUserSince(ctx, db, userID, since, logger)
+	if err != nil || !newest.After(since) {
+		return fetched, upserted, nil, err
+	}
+	return fetched, upserted, map[string]string{"since": newest.UTC().Format(time.RFC3339)}, nil
 }
 
-var _ socialimport.Provider = InstagramProvider{}
+var _ socialimport.IncrementalProvider = InstagramProvider{}
@@ -8,12 +8,17 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/PortNumber53/simple-social-thing/backend/internal/socialimport"
 	"golang.org/x/time/rate"
 )
 
+// PinterestProvider imports the user's pins.
+//
+// Sync is incremental: the newest pin time seen is stored as the `since` cursor. Pins are listed newest
+// first, so later syncs stop paging at the first pin that isn't newer than it.
 type PinterestProvider struct{}
 
 func (p PinterestProvider) Name() string { return "pinterest" }
@@ -25,9 +30,14 @@ type pinterestOAuth struct {
 	Scope       string `json:"scope"`
 }
 
+// SyncUser loads and saves the cursor itself; the Runner goes through SyncUserIncremental.
 func (p PinterestProvider) SyncUser(ctx context.Context, db *sql.DB, userID string, client *http.Client, limiter *rate.Limiter, logger *log.Logger) (int, int, error) {
+	return socialimport.SyncIncremental(ctx, db, p, userID, client, limiter, logger)
+}
+
+func (p PinterestProvider) SyncUserIncremental(ctx context.Context, db *sql.DB, userID string, cursor map[string]string, client *http.Client, limiter *rate.Limiter, logger *log.Logger) (int, int, map[string]string, error) {
 	if db == nil {
-		return 0, 0, fmt.Errorf("db is nil")
+		return 0, 0, nil, fmt.Errorf("db is nil")
 	}
 	l := logger
 	if l == nil {
@@ -40,129 +50,162 @@ func (p PinterestProvider) SyncUser(ctx context.Context, db *sql.DB, userID stri
 	var raw []byte
 	if err := db.QueryRowContext(ctx, `SELECT value FROM public.user_settings WHERE user_id=$1 AND key='pinterest_oauth' AND value IS NOT NULL`, userID).Scan(&raw); err != nil {
 		if err == sql.ErrNoRows {
-			return 0, 0, nil
+			return 0, 0, nil, nil
 		}
-		return 0, 0, err
+		return 0, 0, nil, err
 	}
 	if len(raw) == 0 || string(raw) == "null" {
-		return 0, 0, nil
+		return 0, 0, nil, nil
 	}
 	var tok pinterestOAuth
 	if err := json.Unmarshal(raw, &tok); err != nil {
 		l.Printf("[PinterestImport] invalid oauth json userId=%s err=%v raw=%s", userID, err, truncate(string(raw), 600))
-		return 0, 0, nil
+		return 0, 0, nil, nil
 	}
 	if tok.AccessToken == "" {
-		return 0, 0, nil
+		return 0, 0, nil, nil
 	}
 
-	if limiter != nil {
-		if err := limiter.Wait(ctx); err != nil {
-			return 0, 0, err
+	since := cursorTime(cursor, "since")
+	newest := since
+	fetched := 0
+	upserted := 0
+	complete := false
+	bookmark := ""
+	for page := 0; page < importMaxPagesPerSync && !complete; page++ {
+		if page > 0 {
+			if err := consumeExtraRequest(ctx, db, p.Name()); err != nil {
+				return fetched, upserted, nil, err
+			}
+		}
+		if limiter != nil {
+			if err := limiter.Wait(ctx); err != nil {
+				return fetched, upserted, nil, err
+			}
 		}
-	}
-
-	// List pins (v5). Endpoint choice: /v5/pins with page_size.
-	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.pinterest.com/v5/pins?page_size=25", nil)
-	if err != nil {
-		return 0, 0, err
-	}
-	req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
-	req.Header.Set("Accept", "application/json")
 
-	res, err := client.Do(req)
-	if err != nil {
-		return 0, 0, err
-	}
-	defer res.Body.Close()
-	body, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
-	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		return 0, 0, fmt.Errorf("pinterest_non_2xx status=%d body=%s", res.StatusCode, truncate(string(body), 600))
-	}
+		// List pins (v5). Endpoint choice: /v5/pins with page_size.
+		q := url.Values{}
+		q.Set("page_size", "25")
+		if bookmark != "" {
+			q.Set("bookmark", bookmark)
+		}
+		req, err := http.NewRequestWithContext(ctx, "GET", "https://api.pinterest.com/v5/pins?"+q.Encode(), nil)
+		if err != nil {
+			return fetched, upserted, nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+		req.Header.Set("Accept", "application/json")
 
-	var payload map[string]any
-	if err := json.Unmarshal(body, &payload); err != nil {
-		return 0, 0, err
-	}
-	itemsAny, _ := payload["items"].([]any)
-	fetched := len(itemsAny)
-	upserted := 0
+		res, err := client.Do(req)
+		if err != nil {
+			return fetched, upserted, nil, err
+		}
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+		_ = res.Body.Close()
+		if res.StatusCode < 200 || res.StatusCode >= 300 {
+			return fetched, upserted, nil, fmt.Errorf("pinterest_non_2xx status=%d body=%s", res.StatusCode, truncate(string(body), 600))
+		}
 
-	for _, itAny := range itemsAny {
-		it, _ := itAny.(map[string]any)
-		id, _ := it["id"].(string)
-		if id == "" {
-			continue
+		var payload map[string]any
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return fetched, upserted, nil, err
 		}
-		title, _ := it["title"].(string)
-		desc, _ := it["description"].(string)
-		link, _ := it["link"].(string)
-		createdAt, _ := it["created_at"].(string)
-
-		// images can be nested in media/images. Best-effort parse to find an image URL
-		var thumb string
-		if media, ok := it["media"].(map[string]any); ok {
-			if images, ok := media["images"].(map[string]any); ok {
-				// pick 400x300 if exists, else any
-				if x, ok := images["400x300"].(map[string]any); ok {
-					if u, ok := x["url"].(string); ok {
-						thumb = u
-					}
+		itemsAny, _ := payload["items"].([]any)
+		fetched += len(itemsAny)
+		bookmark, _ = payload["bookmark"].(string)
+		if bookmark == "" {
+			complete = true
+		}
+
+		for _, itAny := range itemsAny {
+			it, _ := itAny.(map[string]any)
+			id, _ := it["id"].(string)
+			if id == "" {
+				continue
+			}
+			title, _ := it["title"].(string)
+			desc, _ := it["description"].(string)
+			link, _ := it["link"].(string)
+			createdAt, _ := it["created_at"].(string)
+
+			var postedAt *time.Time
+			if createdAt != "" {
+				if t, err := time.Parse(time.RFC3339, createdAt); err == nil {
+					tt := t.UTC()
+					postedAt = &tt
 				}
-				if thumb == "" {
-					for _, v := range images {
-						if m, ok := v.(map[string]any); ok {
-							if u, ok := m["url"].(string); ok && u != "" {
-								thumb = u
-								break
+			}
+			// Everything from here on was imported by an earlier sync.
+			if postedAt != nil && !since.IsZero() && !postedAt.After(since) {
+				complete = true
+				break
+			}
+			newest = laterTime(newest, postedAt)
+
+			// images can be nested in media/images. Best-effort parse to find an image URL
+			var thumb string
+			if media, ok := it["media"].(map[string]any); ok {
+				if images, ok := media["images"].(map[string]any); ok {
+					// pick 400x300 if exists, else any
+					if x, ok := images["400x300"].(map[string]any); ok {
+						if u, ok := x["url"].(string); ok {
+							thumb = u
+						}
+					}
+					if thumb == "" {
+						for _, v := range images {
+							if m, ok := v.(map[string]any); ok {
+								if u, ok := m["url"].(string); ok && u != "" {
+									thumb = u
+									break
+								}
 							}
 						}
 					}
 				}
 			}
-		}
 
-		var postedAt *time.Time
-		if createdAt != "" {
-			if t, err := time.Parse(time.RFC3339, createdAt); err == nil {
-				tt := t.UTC()
-				postedAt = &tt
+			// Pinterest public URL isn't always in payload; use link as permalink fallback.
+			permalink := link
+			title2 := title
+			if title2 == "" {
+				title2 = desc
 			}
-		}
 
-		// Pinterest public URL isn't always in payload; use link as permalink fallback.
-		permalink := link
-		title2 := title
-		if title2 == "" {
-			title2 = desc
+			rawItem, _ := json.Marshal(it)
+			rowID := fmt.Sprintf("pinterest:%s:%s", userID, id)
+			_, err := db.ExecContext(ctx, `
+				INSERT INTO public.social_libraries
+				  (id, user_id, network, content_type, title, permalink_url, media_url, thumbnail_url, posted_at, views, likes, raw_payload, external_id, created_at, updated_at)
+				VALUES
+				  ($1, $2, 'pinterest', 'pin', NULLIF($3,''), NULLIF($4,''), NULLIF($5,''), NULLIF($6,''), $7, NULL, NULL, $8::jsonb, $9, NOW(), NOW())
+				ON CONFLICT (user_id, network, external_id)
+				DO UPDATE SET
+				  title = EXCLUDED.title,
+				  permalink_url = EXCLUDED.permalink_url,
+				  media_url = EXCLUDED.media_url,
+				  thumbnail_url = EXCLUDED.thumbnail_url,
+				  posted_at = EXCLUDED.posted_at,
+				  raw_payload = EXCLUDED.raw_payload,
+				  updated_at = NOW()
+			`, rowID, userID, normalizeTitle(title2), permalink, permalink, thumb, postedAt, string(rawItem), id)
+			if err != nil {
+				l.Printf("[PinterestImport] upsert failed userId=%s pinId=%s err=%v", userID, id, err)
+				continue
+			}
+			upserted++
 		}
+	}
 
-		rawItem, _ := json.Marshal(it)
-		rowID := fmt.Sprintf("pinterest:%s:%s", userID, id)
-		_, err := db.ExecContext(ctx, `
-			INSERT INTO public.social_libraries
-			  (id, user_id, network, content_type, title, permalink_url, media_url, thumbnail_url, posted_at, views, likes, raw_payload, external_id, created_at, updated_at)
-			VALUES
-			  ($1, $2, 'pinterest', 'pin', NULLIF($3,''), NULLIF($4,''), NULLIF($5,''), NULLIF($6,''), $7, NULL, NULL, $8::jsonb, $9, NOW(), NOW())
-			ON CONFLICT (user_id, network, external_id)
-			DO UPDATE SET
-			  title = EXCLUDED.title,
-			  permalink_url = EXCLUDED.permalink_url,
-			  media_url = EXCLUDED.media_url,
-			  thumbnail_url = EXCLUDED.thumbnail_url,
-			  posted_at = EXCLUDED.posted_at,
-			  raw_payload = EXCLUDED.raw_payload,
-			  updated_at = NOW()
-		`, rowID, userID, normalizeTitle(title2), permalink, permalink, thumb, postedAt, string(rawItem), id)
-		if err != nil {
-			l.Printf("[PinterestImport] upsert failed userId=%s pinId=%s err=%v", userID, id, err)
-			continue
-		}
-		upserted++
+	// Same rule as X: the cursor advances once every new pin was read (or on the first sync).
+	var next map[string]string
+	if newest.After(since) && (complete || since.IsZero()) {
+		next = map[string]string{"since": newest.Format(time.RFC3339)}
 	}
 
-	l.Printf("[PinterestImport] done userId=%s fetched=%d upserted=%d", userID, fetched, upserted)
-	return fetched, upserted, nil
+	l.Printf("[PinterestImport] done userId=%s fetched=%d upserted=%d since=%s complete=%v", userID, fetched, upserted, cursor["since"], complete)
+	return fetched, upserted, next, nil
 }
 
-var _ socialimport.Provider = PinterestProvider{}
+var _ socialimport.IncrementalProvider = PinterestProvider{}
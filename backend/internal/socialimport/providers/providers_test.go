@@ -62,14 +62,14 @@ func TestHelpers(t *testing.T) {
 	}
 }
 
-func TestStubXProvider(t *testing.T) {
+func TestXProvider_NilDB(t *testing.T) {
 	p := XProvider{}
 	if p.Name() != "x" {
 		t.Fatalf("expected x")
 	}
-	f, u, err := p.SyncUser(context.Background(), nil, "u1", nil, nil, log.Default())
-	if err != nil || f != 0 || u != 0 {
-		t.Fatalf("unexpected: f=%d u=%d err=%v", f, u, err)
+	_, _, err := p.SyncUser(context.Background(), nil, "u1", nil, nil, log.Default())
+	if err == nil {
+		t.Fatalf("expected error for nil db")
 	}
 }
 
@@ -148,6 +148,7 @@ func TestFacebookProvider_SyncUser_SkipsAndSuccess(t *testing.T) {
 	{
 		db, mock, _ := sqlmock.New()
 		defer func() { _ = db.Close() }()
+		expectCursor(mock, "facebook", "")
 		mock.ExpectQuery(`SELECT value FROM public\.user_settings.*facebook_oauth`).
 			WithArgs("u1").
 			WillReturnError(sql.ErrNoRows)
@@ -162,6 +163,7 @@ func TestFacebookProvider_SyncUser_SkipsAndSuccess(t *testing.T) {
 	{
 		db, mock, _ := sqlmock.New()
 		defer func() { _ = db.Close() }()
+		expectCursor(mock, "facebook", "")
 		mock.ExpectQuery(`SELECT value FROM public\.user_settings.*facebook_oauth`).
 			WithArgs("u1").
 			WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte("{")))
@@ -179,6 +181,7 @@ func TestFacebookProvider_SyncUser_SkipsAndSuccess(t *testing.T) {
 
 		tok := facebookOAuth{Pages: []facebookPage{{ID: "pg1", Name: ptr("Page"), AccessToken: "ptok"}}}
 		raw, _ := json.Marshal(tok)
+		expectCursor(mock, "facebook", "")
 		mock.ExpectQuery(`SELECT value FROM public\.user_settings.*facebook_oauth`).
 			WithArgs("u1").
 			WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(raw))
@@ -186,6 +189,7 @@ func TestFacebookProvider_SyncUser_SkipsAndSuccess(t *testing.T) {
 		mock.ExpectExec(`INSERT INTO public\.social_libraries`).
 			WithArgs(sqlmock.AnyArg(), "u1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 			WillReturnResult(sqlmock.NewResult(1, 1))
+		expectSaveCursor(mock, "facebook", `{"since:pg1":"2025-01-01T00:00:00Z"}`)
 
 		client := &http.Client{Transport: stubTransport{fn: func(r *http.Request) (*http.Response, error) {
 			if r.URL.Host != "graph.facebook.com" {
@@ -208,6 +212,7 @@ func TestTikTokProvider_SkipsAndSuccess(t *testing.T) {
 		db, mock, _ := sqlmock.New()
 		defer func() { _ = db.Close() }()
 		raw, _ := json.Marshal(tiktokOAuth{AccessToken: "t", OpenID: "o", Scope: "user.info.basic"})
+		expectCursor(mock, "tiktok", "")
 		mock.ExpectQuery(`SELECT value FROM public\.user_settings.*tiktok_oauth`).
 			WithArgs("u1").
 			WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(raw))
@@ -223,11 +228,13 @@ func TestTikTokProvider_SkipsAndSuccess(t *testing.T) {
 		db, mock, _ := sqlmock.New()
 		defer func() { _ = db.Close() }()
 		raw, _ := json.Marshal(tiktokOAuth{AccessToken: "t", OpenID: "o", Scope: "video.list"})
+		expectCursor(mock, "tiktok", "")
 		mock.ExpectQuery(`SELECT value FROM public\.user_settings.*tiktok_oauth`).
 			WithArgs("u1").
 			WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(raw))
 		mock.ExpectExec(`INSERT INTO public\.social_libraries`).
 			WillReturnResult(sqlmock.NewResult(1, 1))
+		expectSaveCursor(mock, "tiktok", `{"since":"2025-01-01T00:00:00Z"}`)
 
 		client := &http.Client{Transport: stubTransport{fn: func(r *http.Request) (*http.Response, error) {
 			if r.URL.Host != "open.tiktokapis.com" {
@@ -248,6 +255,7 @@ func TestTikTokProvider_SkipsAndSuccess(t *testing.T) {
 		db, mock, _ := sqlmock.New()
 		defer func() { _ = db.Close() }()
 		raw, _ := json.Marshal(tiktokOAuth{AccessToken: "t", OpenID: "o", Scope: "video.list"})
+		expectCursor(mock, "tiktok", "")
 		mock.ExpectQuery(`SELECT value FROM public\.user_settings.*tiktok_oauth`).
 			WithArgs("u1").
 			WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(raw))
@@ -267,12 +275,14 @@ func TestYouTubeProvider_Success(t *testing.T) {
 	defer func() { _ = db.Close() }()
 
 	raw, _ := json.Marshal(youtubeOAuth{AccessToken: "yt"})
+	expectCursor(mock, "youtube", "")
 	mock.ExpectQuery(`SELECT value FROM public\.user_settings.*youtube_oauth`).
 		WithArgs("u1").
 		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(raw))
 
 	mock.ExpectExec(`INSERT INTO public\.social_libraries`).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectSaveCursor(mock, "youtube", `{"since":"2025-01-01T00:00:00Z"}`)
 
 	client := &http.Client{Transport: stubTransport{fn: func(r *http.Request) (*http.Response, error) {
 		switch {
@@ -298,6 +308,7 @@ func TestYouTubeProvider_Non2xxChannels(t *testing.T) {
 	db, mock, _ := sqlmock.New()
 	defer func() { _ = db.Close() }()
 	raw, _ := json.Marshal(youtubeOAuth{AccessToken: "yt"})
+	expectCursor(mock, "youtube", "")
 	mock.ExpectQuery(`SELECT value FROM public\.user_settings.*youtube_oauth`).
 		WithArgs("u1").
 		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(raw))
@@ -317,12 +328,14 @@ func TestPinterestProvider_Success(t *testing.T) {
 	defer func() { _ = db.Close() }()
 
 	raw, _ := json.Marshal(pinterestOAuth{AccessToken: "pt"})
+	expectCursor(mock, "pinterest", "")
 	mock.ExpectQuery(`SELECT value FROM public\.user_settings.*pinterest_oauth`).
 		WithArgs("u1").
 		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(raw))
 
 	mock.ExpectExec(`INSERT INTO public\.social_libraries`).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectSaveCursor(mock, "pinterest", `{"since":"2025-01-01T00:00:00Z"}`)
 
 	client := &http.Client{Transport: stubTransport{fn: func(r *http.Request) (*http.Response, error) {
 		if r.URL.Host != "api.pinterest.com" {
@@ -342,6 +355,7 @@ func TestPinterestProvider_Non2xx(t *testing.T) {
 	db, mock, _ := sqlmock.New()
 	defer func() { _ = db.Close() }()
 	raw, _ := json.Marshal(pinterestOAuth{AccessToken: "pt"})
+	expectCursor(mock, "pinterest", "")
 	mock.ExpectQuery(`SELECT value FROM public\.user_settings.*pinterest_oauth`).
 		WithArgs("u1").
 		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(raw))
@@ -361,12 +375,14 @@ func TestThreadsProvider_Success(t *testing.T) {
 	defer func() { _ = db.Close() }()
 
 	raw, _ := json.Marshal(threadsOAuth{AccessToken: "tt", ThreadsUserID: "tu1"})
+	expectCursor(mock, "threads", "")
 	mock.ExpectQuery(`SELECT value FROM public\.user_settings.*threads_oauth`).
 		WithArgs("u1").
 		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(raw))
 
 	mock.ExpectExec(`INSERT INTO public\.social_libraries`).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectSaveCursor(mock, "threads", `{"since":"2025-01-01T00:00:00Z"}`)
 
 	client := &http.Client{Transport: stubTransport{fn: func(r *http.Request) (*http.Response, error) {
 		if r.URL.Host != "graph.facebook.com" {
@@ -384,11 +400,30 @@ func TestThreadsProvider_Success(t *testing.T) {
 
 func ptr(s string) *string { return &s }
 
+// expectCursor expects a sync for u1 to load its stored provider cursor (none when cursorJSON is empty).
+func expectCursor(mock sqlmock.Sqlmock, provider, cursorJSON string) {
+	rows := sqlmock.NewRows([]string{"cursor"})
+	if cursorJSON != "" {
+		rows.AddRow([]byte(cursorJSON))
+	}
+	mock.ExpectQuery(`FROM public\.social_import_states`).
+		WithArgs("u1", provider).
+		WillReturnRows(rows)
+}
+
+// expectSaveCursor expects a sync for u1 to store cursorJSON as its new provider cursor.
+func expectSaveCursor(mock sqlmock.Sqlmock, provider, cursorJSON string) {
+	mock.ExpectExec(`INSERT INTO public\.social_import_states`).
+		WithArgs(provider+":u1", "u1", provider, cursorJSON).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
 func TestInstagramProvider_SyncUser_PassesThroughNoRows(t *testing.T) {
 	// This covers the "limiter waits then instagram.SyncUser returns nil" path.
 	db, mock, _ := sqlmock.New()
 	defer func() { _ = db.Close() }()
-	// Underlying instagram.SyncUser queries instagram_oauth; return no rows.
+	// Underlying instagram.SyncUserSince queries instagram_oauth; return no rows.
+	expectCursor(mock, "instagram", "")
 	mock.ExpectQuery(`SELECT value FROM public\.user_settings.*instagram_oauth`).
 		WithArgs("u1").
 		WillReturnError(sql.ErrNoRows)
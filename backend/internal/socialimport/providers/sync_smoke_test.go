@@ -24,6 +24,7 @@ func TestPinterestProvider_SyncUser_EmptyItems(t *testing.T) {
 	}
 	defer db.Close()
 
+	expectCursor(mock, "pinterest", "")
 	mock.ExpectQuery(regexp.QuoteMeta(`SELECT value FROM public.user_settings WHERE user_id=$1 AND key='pinterest_oauth' AND value IS NOT NULL`)).
 		WithArgs("u1").
 		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte(`{"accessToken":"t"}`)))
@@ -55,6 +56,7 @@ func TestPinterestProvider_SyncUser_OneItem_Upserts(t *testing.T) {
 	}
 	defer db.Close()
 
+	expectCursor(mock, "pinterest", "")
 	mock.ExpectQuery(regexp.QuoteMeta(`SELECT value FROM public.user_settings WHERE user_id=$1 AND key='pinterest_oauth' AND value IS NOT NULL`)).
 		WithArgs("u1").
 		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte(`{"accessToken":"t"}`)))
@@ -71,6 +73,7 @@ func TestPinterestProvider_SyncUser_OneItem_Upserts(t *testing.T) {
 	mock.ExpectExec(execRe).
 		WithArgs("pinterest:u1:p1", "u1", "t", "https://example", "https://example", "thumb", sqlmock.AnyArg(), sqlmock.AnyArg(), "p1").
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectSaveCursor(mock, "pinterest", `{"since":"2024-01-02T03:04:05Z"}`)
 
 	fetched, upserted, err := (PinterestProvider{}).SyncUser(context.Background(), db, "u1", client, nil, log.New(io.Discard, "", 0))
 	if err != nil {
@@ -91,6 +94,7 @@ func TestPinterestProvider_SyncUser_Non2xx(t *testing.T) {
 	}
 	defer db.Close()
 
+	expectCursor(mock, "pinterest", "")
 	mock.ExpectQuery(regexp.QuoteMeta(`SELECT value FROM public.user_settings WHERE user_id=$1 AND key='pinterest_oauth' AND value IS NOT NULL`)).
 		WithArgs("u1").
 		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte(`{"accessToken":"t"}`)))
@@ -120,6 +124,7 @@ func TestPinterestProvider_SyncUser_FallbackThumb_TitleFallback_AndSkipBlankID(t
 	}
 	defer db.Close()
 
+	expectCursor(mock, "pinterest", "")
 	mock.ExpectQuery(regexp.QuoteMeta(`SELECT value FROM public.user_settings WHERE user_id=$1 AND key='pinterest_oauth' AND value IS NOT NULL`)).
 		WithArgs("u1").
 		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte(`{"accessToken":"t"}`)))
@@ -160,6 +165,7 @@ func TestThreadsProvider_SyncUser_EmptyData(t *testing.T) {
 	}
 	defer db.Close()
 
+	expectCursor(mock, "threads", "")
 	mock.ExpectQuery(regexp.QuoteMeta(`SELECT value FROM public.user_settings WHERE user_id=$1 AND key='threads_oauth' AND value IS NOT NULL`)).
 		WithArgs("u1").
 		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte(`{"accessToken":"t","threadsUserId":"th"}`)))
@@ -191,6 +197,7 @@ func TestThreadsProvider_SyncUser_OneItem_Upserts(t *testing.T) {
 	}
 	defer db.Close()
 
+	expectCursor(mock, "threads", "")
 	mock.ExpectQuery(regexp.QuoteMeta(`SELECT value FROM public.user_settings WHERE user_id=$1 AND key='threads_oauth' AND value IS NOT NULL`)).
 		WithArgs("u1").
 		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte(`{"accessToken":"t","threadsUserId":"th"}`)))
@@ -207,6 +214,7 @@ func TestThreadsProvider_SyncUser_OneItem_Upserts(t *testing.T) {
 	mock.ExpectExec(execRe).
 		WithArgs("threads:u1:t1", "u1", "hello", "pl", "mu", "tu", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "t1").
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectSaveCursor(mock, "threads", `{"since":"2024-01-02T03:04:05Z"}`)
 
 	fetched, upserted, err := (ThreadsProvider{}).SyncUser(context.Background(), db, "u1", client, nil, log.New(io.Discard, "", 0))
 	if err != nil {
@@ -227,6 +235,7 @@ func TestThreadsProvider_SyncUser_Non2xx(t *testing.T) {
 	}
 	defer db.Close()
 
+	expectCursor(mock, "threads", "")
 	mock.ExpectQuery(regexp.QuoteMeta(`SELECT value FROM public.user_settings WHERE user_id=$1 AND key='threads_oauth' AND value IS NOT NULL`)).
 		WithArgs("u1").
 		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte(`{"accessToken":"t","threadsUserId":"th"}`)))
@@ -256,6 +265,7 @@ func TestTikTokProvider_SyncUser_EmptyVideos(t *testing.T) {
 	}
 	defer db.Close()
 
+	expectCursor(mock, "tiktok", "")
 	mock.ExpectQuery(regexp.QuoteMeta(`SELECT value FROM public.user_settings WHERE user_id=$1 AND key='tiktok_oauth' AND value IS NOT NULL`)).
 		WithArgs("u1").
 		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte(`{"accessToken":"t","openId":"o","scope":"video.list"}`)))
@@ -287,6 +297,7 @@ func TestTikTokProvider_SyncUser_OneVideo_Upserts(t *testing.T) {
 	}
 	defer db.Close()
 
+	expectCursor(mock, "tiktok", "")
 	mock.ExpectQuery(regexp.QuoteMeta(`SELECT value FROM public.user_settings WHERE user_id=$1 AND key='tiktok_oauth' AND value IS NOT NULL`)).
 		WithArgs("u1").
 		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte(`{"accessToken":"t","openId":"o","scope":"video.list"}`)))
@@ -303,6 +314,7 @@ func TestTikTokProvider_SyncUser_OneVideo_Upserts(t *testing.T) {
 	mock.ExpectExec(execRe).
 		WithArgs("tiktok:u1:v1", "u1", "ti", "su", "su", "cu", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "v1").
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectSaveCursor(mock, "tiktok", `{"since":"2023-11-14T22:13:20Z"}`)
 
 	fetched, upserted, err := (TikTokProvider{}).SyncUser(context.Background(), db, "u1", client, nil, log.New(io.Discard, "", 0))
 	if err != nil {
@@ -324,6 +336,7 @@ func TestTikTokProvider_SyncUser_MissingScope(t *testing.T) {
 	}
 	defer db.Close()
 
+	expectCursor(mock, "tiktok", "")
 	mock.ExpectQuery(regexp.QuoteMeta(`SELECT value FROM public.user_settings WHERE user_id=$1 AND key='tiktok_oauth' AND value IS NOT NULL`)).
 		WithArgs("u1").
 		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte(`{"accessToken":"t","openId":"o","scope":"user.info.basic"}`)))
@@ -347,6 +360,7 @@ func TestYouTubeProvider_SyncUser_EmptyChannels(t *testing.T) {
 	}
 	defer db.Close()
 
+	expectCursor(mock, "youtube", "")
 	mock.ExpectQuery(regexp.QuoteMeta(`SELECT value FROM public.user_settings WHERE user_id=$1 AND key='youtube_oauth' AND value IS NOT NULL`)).
 		WithArgs("u1").
 		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte(`{"accessToken":"t"}`)))
@@ -379,6 +393,7 @@ func TestYouTubeProvider_SyncUser_NoUploadsPlaylistID(t *testing.T) {
 	}
 	defer db.Close()
 
+	expectCursor(mock, "youtube", "")
 	mock.ExpectQuery(regexp.QuoteMeta(`SELECT value FROM public.user_settings WHERE user_id=$1 AND key='youtube_oauth' AND value IS NOT NULL`)).
 		WithArgs("u1").
 		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte(`{"accessToken":"t"}`)))
@@ -411,6 +426,7 @@ func TestYouTubeProvider_SyncUser_EmptyPlaylistItems(t *testing.T) {
 	}
 	defer db.Close()
 
+	expectCursor(mock, "youtube", "")
 	mock.ExpectQuery(regexp.QuoteMeta(`SELECT value FROM public.user_settings WHERE user_id=$1 AND key='youtube_oauth' AND value IS NOT NULL`)).
 		WithArgs("u1").
 		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte(`{"accessToken":"t"}`)))
@@ -461,3 +477,172 @@ func TestInstagramProvider_SyncUser_LimiterWaitError(t *testing.T) {
 		t.Fatalf("expected error")
 	}
 }
+
+func TestXProvider_SyncUser_PagesAndSavesCursor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`FROM public\.social_import_states`).
+		WithArgs("u1", "x").
+		WillReturnRows(sqlmock.NewRows([]string{"cursor"}))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT value FROM public.user_settings WHERE user_id=$1 AND key='x_oauth' AND value IS NOT NULL`)).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte(`{"accessToken":"t"}`)))
+	mock.ExpectExec(`INSERT INTO public\.social_libraries`).
+		WithArgs("x:u1:t2", "u1", "video", "second", "https://x.com/me/status/t2", "", "https://pbs/p.jpg", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "t2").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO public\.social_libraries`).
+		WithArgs("x:u1:t1", "u1", "post", "first", "https://x.com/me/status/t1", "", "", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "t1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO public\.social_import_states`).
+		WithArgs("x:u1", "u1", "x", `{"sinceId":"t2"}`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Header.Get("Authorization") != "Bearer t" {
+			return &http.Response{StatusCode: 401, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+		}
+		body := `{}`
+		switch {
+		case r.URL.Path == "/2/users/me":
+			body = `{"data":{"id":"42","username":"me"}}`
+		case r.URL.Path == "/2/users/42/tweets" && r.URL.Query().Get("pagination_token") == "":
+			body = `{"data":[{"id":"t2","text":"second","created_at":"2024-01-02T03:04:05Z","public_metrics":{"like_count":3,"impression_count":100},"attachments":{"media_keys":["m1"]}}],
+				"includes":{"media":[{"media_key":"m1","type":"video","preview_image_url":"https://pbs/p.jpg"}]},
+				"meta":{"newest_id":"t2","result_count":1,"next_token":"pg2"}}`
+		case r.URL.Path == "/2/users/42/tweets" && r.URL.Query().Get("pagination_token") == "pg2":
+			body = `{"data":[{"id":"t1","text":"first","created_at":"2024-01-01T03:04:05Z","public_metrics":{"like_count":1}}],"meta":{"newest_id":"t1","result_count":1}}`
+		}
+		return &http.Response{StatusCode: 200, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+
+	fetched, upserted, err := (XProvider{}).SyncUser(context.Background(), db, "u1", client, rate.NewLimiter(rate.Inf, 1), log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("SyncUser: %v", err)
+	}
+	if fetched != 2 || upserted != 2 {
+		t.Fatalf("expected 2/2, got %d/%d", fetched, upserted)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestXProvider_SyncUser_RateLimited_KeepsCursor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`FROM public\.social_import_states`).
+		WithArgs("u1", "x").
+		WillReturnRows(sqlmock.NewRows([]string{"cursor"}).AddRow([]byte(`{"sinceId":"t0"}`)))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT value FROM public.user_settings WHERE user_id=$1 AND key='x_oauth' AND value IS NOT NULL`)).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte(`{"accessToken":"t","xUserId":"42"}`)))
+
+	var gotSince string
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		gotSince = r.URL.Query().Get("since_id")
+		h := make(http.Header)
+		h.Set("x-rate-limit-reset", "1700000000")
+		return &http.Response{StatusCode: 429, Header: h, Body: io.NopCloser(strings.NewReader(`{"title":"Too Many Requests"}`))}, nil
+	})}
+
+	_, _, err = (XProvider{}).SyncUser(context.Background(), db, "u1", client, nil, log.New(io.Discard, "", 0))
+	if err == nil || !strings.Contains(err.Error(), "x_rate_limited") {
+		t.Fatalf("expected x_rate_limited, got %v", err)
+	}
+	if gotSince != "t0" {
+		t.Fatalf("expected since_id=t0, got %q", gotSince)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestFacebookProvider_SyncUser_SinceCursorAndPaging(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer db.Close()
+
+	expectCursor(mock, "facebook", `{"since:pg1":"2024-01-01T00:00:00Z","since:gone":"2023-01-01T00:00:00Z"}`)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT value FROM public.user_settings WHERE user_id=$1 AND key='facebook_oauth' AND value IS NOT NULL`)).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte(`{"pages":[{"id":"pg1","access_token":"pt"}]}`)))
+	mock.ExpectExec(`INSERT INTO public\.social_libraries`).
+		WithArgs("facebook:u1:p2", "u1", "second", "", "", "", sqlmock.AnyArg(), sqlmock.AnyArg(), "p2").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO public\.social_libraries`).
+		WithArgs("facebook:u1:p1", "u1", "first", "", "", "", sqlmock.AnyArg(), sqlmock.AnyArg(), "p1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectSaveCursor(mock, "facebook", `{"since:gone":"2023-01-01T00:00:00Z","since:pg1":"2024-01-03T00:00:00Z"}`)
+
+	var sinces []string
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		sinces = append(sinces, r.URL.Query().Get("since"))
+		body := `{"data":[{"id":"p2","message":"second","created_time":"2024-01-03T00:00:00+0000"}],"paging":{"cursors":{"after":"a1"},"next":"https://graph.facebook.com/next"}}`
+		if r.URL.Query().Get("after") == "a1" {
+			body = `{"data":[{"id":"p1","message":"first","created_time":"2024-01-02T00:00:00Z"}],"paging":{"cursors":{"after":"a2"}}}`
+		}
+		return &http.Response{StatusCode: 200, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+
+	fetched, upserted, err := (FacebookProvider{}).SyncUser(context.Background(), db, "u1", client, nil, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("SyncUser: %v", err)
+	}
+	if fetched != 2 || upserted != 2 {
+		t.Fatalf("expected 2/2, got %d/%d", fetched, upserted)
+	}
+	if len(sinces) != 2 || sinces[0] != "1704067200" || sinces[1] != "1704067200" {
+		t.Fatalf("expected since=1704067200 on both pages, got %v", sinces)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestTikTokProvider_SyncUser_StopsAtCursor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer db.Close()
+
+	expectCursor(mock, "tiktok", `{"since":"2023-11-14T22:13:20Z"}`)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT value FROM public.user_settings WHERE user_id=$1 AND key='tiktok_oauth' AND value IS NOT NULL`)).
+		WithArgs("u1").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte(`{"accessToken":"t","openId":"o","scope":"video.list"}`)))
+	mock.ExpectExec(`INSERT INTO public\.social_libraries`).
+		WithArgs("tiktok:u1:v2", "u1", "new", "", "", "", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "v2").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectSaveCursor(mock, "tiktok", `{"since":"2023-11-14T22:30:00Z"}`)
+
+	calls := 0
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: 200, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(
+			`{"data":{"videos":[{"id":"v2","title":"new","create_time":1700001000},{"id":"v1","title":"old","create_time":1700000000}],"cursor":1699999999000,"has_more":true}}`))}, nil
+	})}
+
+	fetched, upserted, err := (TikTokProvider{}).SyncUser(context.Background(), db, "u1", client, nil, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("SyncUser: %v", err)
+	}
+	if upserted != 1 || fetched != 2 {
+		t.Fatalf("expected fetched=2 upserted=1, got %d/%d", fetched, upserted)
+	}
+	if calls != 1 {
+		t.Fatalf("expected paging to stop at the cursor after 1 call, got %d", calls)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
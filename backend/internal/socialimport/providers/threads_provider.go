@@ -19,6 +19,9 @@ import (
 //
 // NOTE: Threads API details can vary by app configuration/approval. This implementation is best-effort
 // and will log provider errors to help us adjust fields/endpoints as needed.
+//
+// Sync is incremental: the newest post time seen is stored as the `since` cursor and only posts from
+// then on are requested next time.
 type ThreadsProvider struct{}
 
 func (p ThreadsProvider) Name() string { return "threads" }
@@ -31,9 +34,14 @@ type threadsOAuth struct {
 	Scope         string `json:"scope"`
 }
 
+// SyncUser loads and saves the cursor itself; the Runner goes through SyncUserIncremental.
 func (p ThreadsProvider) SyncUser(ctx context.Context, db *sql.DB, userID string, client *http.Client, limiter *rate.Limiter, logger *log.Logger) (int, int, error) {
+	return socialimport.SyncIncremental(ctx, db, p, userID, client, limiter, logger)
+}
+
+func (p ThreadsProvider) SyncUserIncremental(ctx context.Context, db *sql.DB, userID string, cursor map[string]string, client *http.Client, limiter *rate.Limiter, logger *log.Logger) (int, int, map[string]string, error) {
 	if db == nil {
-		return 0, 0, fmt.Errorf("db is nil")
+		return 0, 0, nil, fmt.Errorf("db is nil")
 	}
 	l := logger
 	if l == nil {
@@ -46,108 +54,135 @@ func (p ThreadsProvider) SyncUser(ctx context.Context, db *sql.DB, userID string
 	var raw []byte
 	if err := db.QueryRowContext(ctx, `SELECT value FROM public.user_settings WHERE user_id=$1 AND key='threads_oauth' AND value IS NOT NULL`, userID).Scan(&raw); err != nil {
 		if err == sql.ErrNoRows {
-			return 0, 0, nil
+			return 0, 0, nil, nil
 		}
-		return 0, 0, err
+		return 0, 0, nil, err
 	}
 	if len(raw) == 0 || string(raw) == "null" {
-		return 0, 0, nil
+		return 0, 0, nil, nil
 	}
 	var tok threadsOAuth
 	if err := json.Unmarshal(raw, &tok); err != nil {
 		l.Printf("[ThreadsImport] invalid oauth json userId=%s err=%v raw=%s", userID, err, truncate(string(raw), 600))
-		return 0, 0, nil
+		return 0, 0, nil, nil
 	}
 	if tok.AccessToken == "" || tok.ThreadsUserID == "" {
-		return 0, 0, nil
+		return 0, 0, nil, nil
 	}
 
-	if limiter != nil {
-		if err := limiter.Wait(ctx); err != nil {
-			return 0, 0, err
+	since := cursorTime(cursor, "since")
+	newest := since
+	fetched := 0
+	upserted := 0
+	complete := false
+	after := ""
+	for page := 0; page < importMaxPagesPerSync; page++ {
+		if page > 0 {
+			if err := consumeExtraRequest(ctx, db, p.Name()); err != nil {
+				return fetched, upserted, nil, err
+			}
+		}
+		if limiter != nil {
+			if err := limiter.Wait(ctx); err != nil {
+				return fetched, upserted, nil, err
+			}
 		}
-	}
 
-	// Best-effort Graph call. Fields may vary; adjust later based on API responses.
-	base := fmt.Sprintf("https://graph.facebook.com/v24.0/%s/threads", url.PathEscape(tok.ThreadsUserID))
-	q := url.Values{}
-	q.Set("fields", "id,text,permalink,timestamp,media_type,media_url,thumbnail_url,like_count,reply_count,repost_count")
-	q.Set("limit", "25")
-	q.Set("access_token", tok.AccessToken)
-	reqURL := base + "?" + q.Encode()
-
-	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
-	if err != nil {
-		return 0, 0, err
-	}
-	req.Header.Set("Accept", "application/json")
+		// Best-effort Graph call. Fields may vary; adjust later based on API responses.
+		base := fmt.Sprintf("https://graph.facebook.com/v24.0/%s/threads", url.PathEscape(tok.ThreadsUserID))
+		q := url.Values{}
+		q.Set("fields", "id,text,permalink,timestamp,media_type,media_url,thumbnail_url,like_count,reply_count,repost_count")
+		q.Set("limit", "25")
+		if !since.IsZero() {
+			q.Set("since", fmt.Sprintf("%d", since.Unix()))
+		}
+		if after != "" {
+			q.Set("after", after)
+		}
+		q.Set("access_token", tok.AccessToken)
+		reqURL := base + "?" + q.Encode()
 
-	res, err := client.Do(req)
-	if err != nil {
-		return 0, 0, err
-	}
-	defer res.Body.Close()
-	body, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
-	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		return 0, 0, fmt.Errorf("threads_non_2xx status=%d body=%s", res.StatusCode, truncate(string(body), 600))
-	}
+		req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+		if err != nil {
+			return fetched, upserted, nil, err
+		}
+		req.Header.Set("Accept", "application/json")
 
-	var payload map[string]any
-	if err := json.Unmarshal(body, &payload); err != nil {
-		return 0, 0, err
-	}
-	dataAny, _ := payload["data"].([]any)
-	fetched := len(dataAny)
-	upserted := 0
+		res, err := client.Do(req)
+		if err != nil {
+			return fetched, upserted, nil, err
+		}
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+		_ = res.Body.Close()
+		if res.StatusCode < 200 || res.StatusCode >= 300 {
+			return fetched, upserted, nil, fmt.Errorf("threads_non_2xx status=%d body=%s", res.StatusCode, truncate(string(body), 600))
+		}
 
-	for _, itAny := range dataAny {
-		it, _ := itAny.(map[string]any)
-		id, _ := it["id"].(string)
-		if id == "" {
-			continue
+		var payload map[string]any
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return fetched, upserted, nil, err
 		}
-		text, _ := it["text"].(string)
-		permalink, _ := it["permalink"].(string)
-		ts, _ := it["timestamp"].(string)
-		mediaURL, _ := it["media_url"].(string)
-		thumb, _ := it["thumbnail_url"].(string)
-		likes := toInt64(it["like_count"])
-
-		var postedAt *time.Time
-		if ts != "" {
-			if t, err := time.Parse(time.RFC3339, ts); err == nil {
-				tt := t.UTC()
-				postedAt = &tt
+		dataAny, _ := payload["data"].([]any)
+		fetched += len(dataAny)
+
+		for _, itAny := range dataAny {
+			it, _ := itAny.(map[string]any)
+			id, _ := it["id"].(string)
+			if id == "" {
+				continue
+			}
+			text, _ := it["text"].(string)
+			permalink, _ := it["permalink"].(string)
+			ts, _ := it["timestamp"].(string)
+			mediaURL, _ := it["media_url"].(string)
+			thumb, _ := it["thumbnail_url"].(string)
+			likes := toInt64(it["like_count"])
+
+			postedAt := parseGraphTime(ts)
+			newest = laterTime(newest, postedAt)
+
+			rawItem, _ := json.Marshal(it)
+			rowID := fmt.Sprintf("threads:%s:%s", userID, id)
+			_, err := db.ExecContext(ctx, `
+				INSERT INTO public.social_libraries
+				  (id, user_id, network, content_type, title, permalink_url, media_url, thumbnail_url, posted_at, views, likes, raw_payload, external_id, created_at, updated_at)
+				VALUES
+				  ($1, $2, 'threads', 'post', NULLIF($3,''), NULLIF($4,''), NULLIF($5,''), NULLIF($6,''), $7, NULL, $8, $9::jsonb, $10, NOW(), NOW())
+				ON CONFLICT (user_id, network, external_id)
+				DO UPDATE SET
+				  title = EXCLUDED.title,
+				  permalink_url = EXCLUDED.permalink_url,
+				  media_url = EXCLUDED.media_url,
+				  thumbnail_url = EXCLUDED.thumbnail_url,
+				  posted_at = EXCLUDED.posted_at,
+				  likes = EXCLUDED.likes,
+				  raw_payload = EXCLUDED.raw_payload,
+				  updated_at = NOW()
+			`, rowID, userID, normalizeTitle(text), permalink, mediaURL, thumb, postedAt, likes, string(rawItem), id)
+			if err != nil {
+				l.Printf("[ThreadsImport] upsert failed userId=%s threadId=%s err=%v", userID, id, err)
+				continue
 			}
+			upserted++
 		}
 
-		rawItem, _ := json.Marshal(it)
-		rowID := fmt.Sprintf("threads:%s:%s", userID, id)
-		_, err := db.ExecContext(ctx, `
-			INSERT INTO public.social_libraries
-			  (id, user_id, network, content_type, title, permalink_url, media_url, thumbnail_url, posted_at, views, likes, raw_payload, external_id, created_at, updated_at)
-			VALUES
-			  ($1, $2, 'threads', 'post', NULLIF($3,''), NULLIF($4,''), NULLIF($5,''), NULLIF($6,''), $7, NULL, $8, $9::jsonb, $10, NOW(), NOW())
-			ON CONFLICT (user_id, network, external_id)
-			DO UPDATE SET
-			  title = EXCLUDED.title,
-			  permalink_url = EXCLUDED.permalink_url,
-			  media_url = EXCLUDED.media_url,
-			  thumbnail_url = EXCLUDED.thumbnail_url,
-			  posted_at = EXCLUDED.posted_at,
-			  likes = EXCLUDED.likes,
-			  raw_payload = EXCLUDED.raw_payload,
-			  updated_at = NOW()
-		`, rowID, userID, normalizeTitle(text), permalink, mediaURL, thumb, postedAt, likes, string(rawItem), id)
-		if err != nil {
-			l.Printf("[ThreadsImport] upsert failed userId=%s threadId=%s err=%v", userID, id, err)
-			continue
+		paging, _ := payload["paging"].(map[string]any)
+		cursors, _ := paging["cursors"].(map[string]any)
+		after, _ = cursors["after"].(string)
+		if nextURL, _ := paging["next"].(string); nextURL == "" || after == "" {
+			complete = true
+			break
 		}
-		upserted++
 	}
 
-	l.Printf("[ThreadsImport] done userId=%s fetched=%d upserted=%d", userID, fetched, upserted)
-	return fetched, upserted, nil
+	// Same rule as X: the cursor advances once every new post was read (or on the first sync).
+	var next map[string]string
+	if newest.After(since) && (complete || since.IsZero()) {
+		next = map[string]string{"since": newest.Format(time.RFC3339)}
+	}
+
+	l.Printf("[ThreadsImport] done userId=%s fetched=%d upserted=%d since=%s complete=%v", userID, fetched, upserted, cursor["since"], complete)
+	return fetched, upserted, next, nil
 }
 
-var _ socialimport.Provider = ThreadsProvider{}
+var _ socialimport.IncrementalProvider = ThreadsProvider{}
@@ -15,6 +15,10 @@ import (
 	"golang.org/x/time/rate"
 )
 
+// TikTokProvider imports the user's TikTok videos.
+//
+// Sync is incremental: the newest video time seen is stored as the `since` cursor. Videos are listed
+// newest first, so later syncs stop paging at the first video that isn't newer than it.
 type TikTokProvider struct{}
 
 func (p TikTokProvider) Name() string { return "tiktok" }
@@ -27,9 +31,14 @@ type tiktokOAuth struct {
 	ExpiresAt   string `json:"expiresAt"`
 }
 
+// SyncUser loads and saves the cursor itself; the Runner goes through SyncUserIncremental.
 func (p TikTokProvider) SyncUser(ctx context.Context, db *sql.DB, userID string, client *http.Client, limiter *rate.Limiter, logger *log.Logger) (int, int, error) {
+	return socialimport.SyncIncremental(ctx, db, p, userID, client, limiter, logger)
+}
+
+func (p TikTokProvider) SyncUserIncremental(ctx context.Context, db *sql.DB, userID string, cursor map[string]string, client *http.Client, limiter *rate.Limiter, logger *log.Logger) (int, int, map[string]string, error) {
 	if db == nil {
-		return 0, 0, fmt.Errorf("db is nil")
+		return 0, 0, nil, fmt.Errorf("db is nil")
 	}
 	l := logger
 	if l == nil {
@@ -43,20 +52,20 @@ func (p TikTokProvider) SyncUser(ctx context.Context, db *sql.DB, userID string,
 	var raw []byte
 	if err := db.QueryRowContext(ctx, `SELECT value FROM public.user_settings WHERE user_id=$1 AND key='tiktok_oauth' AND value IS NOT NULL`, userID).Scan(&raw); err != nil {
 		if err == sql.ErrNoRows {
-			return 0, 0, nil
+			return 0, 0, nil, nil
 		}
-		return 0, 0, err
+		return 0, 0, nil, err
 	}
 	if len(raw) == 0 || string(raw) == "null" {
-		return 0, 0, nil
+		return 0, 0, nil, nil
 	}
 	var tok tiktokOAuth
 	if err := json.Unmarshal(raw, &tok); err != nil {
 		l.Printf("[TTImport] invalid oauth json userId=%s err=%v raw=%s", userID, err, truncate(string(raw), 600))
-		return 0, 0, nil
+		return 0, 0, nil, nil
 	}
 	if tok.AccessToken == "" || tok.OpenID == "" {
-		return 0, 0, nil
+		return 0, 0, nil, nil
 	}
 
 	// Guard: importing video list requires the `video.list` scope.
@@ -64,90 +73,124 @@ func (p TikTokProvider) SyncUser(ctx context.Context, db *sql.DB, userID string,
 	scopeNorm := strings.ReplaceAll(tok.Scope, " ", ",")
 	if !strings.Contains(scopeNorm, "video.list") {
 		l.Printf("[TTImport] skip userId=%s reason=missing_scope scope=%s", userID, tok.Scope)
-		return 0, 0, nil
+		return 0, 0, nil, nil
 	}
 
-	if limiter != nil {
-		if err := limiter.Wait(ctx); err != nil {
-			return 0, 0, err
+	since := cursorTime(cursor, "since")
+	newest := since
+	fetched := 0
+	upserted := 0
+	complete := false
+	var pageCursor int64
+	for page := 0; page < importMaxPagesPerSync && !complete; page++ {
+		if page > 0 {
+			if err := consumeExtraRequest(ctx, db, p.Name()); err != nil {
+				return fetched, upserted, nil, err
+			}
+		}
+		if limiter != nil {
+			if err := limiter.Wait(ctx); err != nil {
+				return fetched, upserted, nil, err
+			}
 		}
-	}
-
-	// TikTok API: list user videos
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://open.tiktokapis.com/v2/video/list/", strings.NewReader(`{"max_count":25}`))
-	if err != nil {
-		return 0, 0, err
-	}
-	req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
 
-	res, err := client.Do(req)
-	if err != nil {
-		return 0, 0, err
-	}
-	defer res.Body.Close()
-	body, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
-	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		return 0, 0, fmt.Errorf("tiktok_non_2xx status=%d body=%s", res.StatusCode, truncate(string(body), 600))
-	}
+		// TikTok API: list user videos
+		reqBody := `{"max_count":25}`
+		if pageCursor > 0 {
+			reqBody = fmt.Sprintf(`{"max_count":25,"cursor":%d}`, pageCursor)
+		}
+		req, err := http.NewRequestWithContext(ctx, "POST", "https://open.tiktokapis.com/v2/video/list/", strings.NewReader(reqBody))
+		if err != nil {
+			return fetched, upserted, nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
 
-	// Shape: { data: { videos: [ { id, title, create_time, cover_image_url, share_url, view_count, like_count } ] } }
-	var payload map[string]any
-	if err := json.Unmarshal(body, &payload); err != nil {
-		return 0, 0, err
-	}
-	data, _ := payload["data"].(map[string]any)
-	videosAny, _ := data["videos"].([]any)
-	fetched := len(videosAny)
-	upserted := 0
+		res, err := client.Do(req)
+		if err != nil {
+			return fetched, upserted, nil, err
+		}
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+		_ = res.Body.Close()
+		if res.StatusCode < 200 || res.StatusCode >= 300 {
+			return fetched, upserted, nil, fmt.Errorf("tiktok_non_2xx status=%d body=%s", res.StatusCode, truncate(string(body), 600))
+		}
 
-	for _, vAny := range videosAny {
-		v, _ := vAny.(map[string]any)
-		id, _ := v["id"].(string)
-		if id == "" {
-			continue
+		// Shape: { data: { videos: [ { id, title, create_time, cover_image_url, share_url, view_count, like_count } ], cursor, has_more } }
+		var payload map[string]any
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return fetched, upserted, nil, err
 		}
-		title, _ := v["title"].(string)
-		shareURL, _ := v["share_url"].(string)
-		thumb, _ := v["cover_image_url"].(string)
-		views := toInt64(v["view_count"])
-		likes := toInt64(v["like_count"])
-
-		var postedAt *time.Time
-		if ct := toInt64(v["create_time"]); ct != nil && *ct > 0 {
-			t := time.Unix(*ct, 0).UTC()
-			postedAt = &t
+		data, _ := payload["data"].(map[string]any)
+		videosAny, _ := data["videos"].([]any)
+		fetched += len(videosAny)
+		hasMore, _ := data["has_more"].(bool)
+		if c := toInt64(data["cursor"]); hasMore && c != nil && *c > 0 {
+			pageCursor = *c
+		} else {
+			complete = true
 		}
 
-		rawItem, _ := json.Marshal(v)
-		rowID := fmt.Sprintf("tiktok:%s:%s", userID, id)
-		_, err := db.ExecContext(ctx, `
-			INSERT INTO public.social_libraries
-			  (id, user_id, network, content_type, title, permalink_url, media_url, thumbnail_url, posted_at, views, likes, raw_payload, external_id, created_at, updated_at)
-			VALUES
-			  ($1, $2, 'tiktok', 'video', NULLIF($3,''), NULLIF($4,''), NULLIF($5,''), NULLIF($6,''), $7, $8, $9, $10::jsonb, $11, NOW(), NOW())
-			ON CONFLICT (user_id, network, external_id)
-			DO UPDATE SET
-			  title = EXCLUDED.title,
-			  permalink_url = EXCLUDED.permalink_url,
-			  media_url = EXCLUDED.media_url,
-			  thumbnail_url = EXCLUDED.thumbnail_url,
-			  posted_at = EXCLUDED.posted_at,
-			  views = EXCLUDED.views,
-			  likes = EXCLUDED.likes,
-			  raw_payload = EXCLUDED.raw_payload,
-			  updated_at = NOW()
-		`, rowID, userID, title, shareURL, shareURL, thumb, postedAt, views, likes, string(rawItem), id)
-		if err != nil {
-			l.Printf("[TTImport] upsert failed userId=%s videoId=%s err=%v", userID, id, err)
-			continue
+		for _, vAny := range videosAny {
+			v, _ := vAny.(map[string]any)
+			id, _ := v["id"].(string)
+			if id == "" {
+				continue
+			}
+			title, _ := v["title"].(string)
+			shareURL, _ := v["share_url"].(string)
+			thumb, _ := v["cover_image_url"].(string)
+			views := toInt64(v["view_count"])
+			likes := toInt64(v["like_count"])
+
+			var postedAt *time.Time
+			if ct := toInt64(v["create_time"]); ct != nil && *ct > 0 {
+				t := time.Unix(*ct, 0).UTC()
+				postedAt = &t
+			}
+			// Everything from here on was imported by an earlier sync.
+			if postedAt != nil && !since.IsZero() && !postedAt.After(since) {
+				complete = true
+				break
+			}
+			newest = laterTime(newest, postedAt)
+
+			rawItem, _ := json.Marshal(v)
+			rowID := fmt.Sprintf("tiktok:%s:%s", userID, id)
+			_, err := db.ExecContext(ctx, `
+				INSERT INTO public.social_libraries
+				  (id, user_id, network, content_type, title, permalink_url, media_url, thumbnail_url, posted_at, views, likes, raw_payload, external_id, created_at, updated_at)
+				VALUES
+				  ($1, $2, 'tiktok', 'video', NULLIF($3,''), NULLIF($4,''), NULLIF($5,''), NULLIF($6,''), $7, $8, $9, $10::jsonb, $11, NOW(), NOW())
+				ON CONFLICT (user_id, network, external_id)
+				DO UPDATE SET
+				  title = EXCLUDED.title,
+				  permalink_url = EXCLUDED.permalink_url,
+				  media_url = EXCLUDED.media_url,
+				  thumbnail_url = EXCLUDED.thumbnail_url,
+				  posted_at = EXCLUDED.posted_at,
+				  views = EXCLUDED.views,
+				  likes = EXCLUDED.likes,
+				  raw_payload = EXCLUDED.raw_payload,
+				  updated_at = NOW()
+			`, rowID, userID, title, shareURL, shareURL, thumb, postedAt, views, likes, string(rawItem), id)
+			if err != nil {
+				l.Printf("[TTImport] upsert failed userId=%s videoId=%s err=%v", userID, id, err)
+				continue
+			}
+			upserted++
 		}
-		upserted++
 	}
 
-	l.Printf("[TTImport] done userId=%s fetched=%d upserted=%d", userID, fetched, upserted)
-	return fetched, upserted, nil
+	// Same rule as X: the cursor advances once every new video was read (or on the first sync).
+	var next map[string]string
+	if newest.After(since) && (complete || since.IsZero()) {
+		next = map[string]string{"since": newest.Format(time.RFC3339)}
+	}
+
+	l.Printf("[TTImport] done userId=%s fetched=%d upserted=%d since=%s complete=%v", userID, fetched, upserted, cursor["since"], complete)
+	return fetched, upserted, next, nil
 }
 
-var _ socialimport.Provider = TikTokProvider{}
+var _ socialimport.IncrementalProvider = TikTokProvider{}
@@ -0,0 +1,300 @@
+package providers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PortNumber53/simple-social-thing/backend/internal/socialimport"
+	"golang.org/x/time/rate"
+)
+
+// XProvider imports the user's own posts from X (Twitter) via the v2 API.
+//
+// Sync is incremental: the newest post id seen is returned as the `sinceId` cursor and only newer
+// posts are requested on the next run. The cursor only advances after every page was read, so a run
+// cut short by rate limits is simply repeated (upserts are idempotent).
+type XProvider struct{}
+
+func (p XProvider) Name() string { return "x" }
+
+// xAPIBase is a var so tests can point it elsewhere if needed.
+var xAPIBase = "https://api.x.com/2"
+
+const (
+	xPageSize        = 100
+	xMaxPagesPerSync = 5
+)
+
+type xOAuth struct {
+	AccessToken  string `json:"accessToken"`
+	TokenType    string `json:"tokenType"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresAt    string `json:"expiresAt"`
+	Scope        string `json:"scope"`
+	XUserID      string `json:"xUserId"`
+	Username     string `json:"username"`
+}
+
+type xTimelinePage struct {
+	Data []struct {
+		ID            string         `json:"id"`
+		Text          string         `json:"text"`
+		CreatedAt     string         `json:"created_at"`
+		PublicMetrics map[string]any `json:"public_metrics"`
+		Attachments   struct {
+			MediaKeys []string `json:"media_keys"`
+		} `json:"attachments"`
+	} `json:"data"`
+	Includes struct {
+		Media []struct {
+			MediaKey        string `json:"media_key"`
+			Type            string `json:"type"`
+			URL             string `json:"url"`
+			PreviewImageURL string `json:"preview_image_url"`
+		} `json:"media"`
+	} `json:"includes"`
+	Meta struct {
+		NewestID    string `json:"newest_id"`
+		ResultCount int    `json:"result_count"`
+		NextToken   string `json:"next_token"`
+	} `json:"meta"`
+}
+
+// SyncUser loads and saves the cursor itself; the Runner goes through SyncUserIncremental.
+func (p XProvider) SyncUser(ctx context.Context, db *sql.DB, userID string, client *http.Client, limiter *rate.Limiter, logger *log.Logger) (int, int, error) {
+	return socialimport.SyncIncremental(ctx, db, p, userID, client, limiter, logger)
+}
+
+func (p XProvider) SyncUserIncremental(ctx context.Context, db *sql.DB, userID string, cursor map[string]string, client *http.Client, limiter *rate.Limiter, logger *log.Logger) (int, int, map[string]string, error) {
+	if db == nil {
+		return 0, 0, nil, fmt.Errorf("db is nil")
+	}
+	l := logger
+	if l == nil {
+		l = log.Default()
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 20 * time.Second}
+	}
+
+	var raw []byte
+	if err := db.QueryRowContext(ctx, `SELECT value FROM public.user_settings WHERE user_id=$1 AND key='x_oauth' AND value IS NOT NULL`, userID).Scan(&raw); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, 0, nil, nil
+		}
+		return 0, 0, nil, err
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return 0, 0, nil, nil
+	}
+	var tok xOAuth
+	if err := json.Unmarshal(raw, &tok); err != nil {
+		l.Printf("[XImport] invalid oauth json userId=%s err=%v raw=%s", userID, err, truncate(string(raw), 600))
+		return 0, 0, nil, nil
+	}
+	if tok.AccessToken == "" {
+		return 0, 0, nil, nil
+	}
+
+	dailyMax := socialimport.RateLimitFor(p.Name()).DailyRequestsMax
+	requests := 0
+	// get performs one API call, honoring the limiter and (after the first call, which the runner already
+	// counted) the daily request quota.
+	get := func(endpoint string) (*http.Response, []byte, error) {
+		if requests > 0 && dailyMax > 0 {
+			ok, used, err := socialimport.ConsumeRequests(ctx, db, p.Name(), 1, dailyMax)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !ok {
+				return nil, nil, fmt.Errorf("x_daily_quota_exceeded used=%d max=%d", used, dailyMax)
+			}
+		}
+		requests++
+		if limiter != nil {
+			if err := limiter.Wait(ctx); err != nil {
+				return nil, nil, err
+			}
+		}
+		req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+		req.Header.Set("Accept", "application/json")
+		res, err := client.Do(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 2<<20))
+		if res.StatusCode == http.StatusTooManyRequests {
+			return res, body, fmt.Errorf("x_rate_limited reset=%s", res.Header.Get("x-rate-limit-reset"))
+		}
+		if res.StatusCode < 200 || res.StatusCode >= 300 {
+			return res, body, fmt.Errorf("x_non_2xx status=%d body=%s", res.StatusCode, truncate(string(body), 600))
+		}
+		return res, body, nil
+	}
+
+	// Resolve the X user id once if the OAuth payload doesn't carry it.
+	xUserID := strings.TrimSpace(tok.XUserID)
+	username := strings.TrimSpace(tok.Username)
+	if xUserID == "" {
+		_, body, err := get(xAPIBase + "/users/me")
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		var me struct {
+			Data struct {
+				ID       string `json:"id"`
+				Username string `json:"username"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &me); err != nil {
+			return 0, 0, nil, err
+		}
+		xUserID = me.Data.ID
+		if username == "" {
+			username = me.Data.Username
+		}
+		if xUserID == "" {
+			return 0, 0, nil, fmt.Errorf("x_missing_user_id")
+		}
+	}
+
+	sinceID := cursor["sinceId"]
+
+	fetched := 0
+	upserted := 0
+	newestID := ""
+	complete := false
+	nextToken := ""
+	for page := 0; page < xMaxPagesPerSync; page++ {
+		q := url.Values{}
+		q.Set("max_results", fmt.Sprintf("%d", xPageSize))
+		q.Set("tweet.fields", "created_at,public_metrics,attachments")
+		q.Set("expansions", "attachments.media_keys")
+		q.Set("media.fields", "type,url,preview_image_url")
+		q.Set("exclude", "retweets,replies")
+		if sinceID != "" {
+			q.Set("since_id", sinceID)
+		}
+		if nextToken != "" {
+			q.Set("pagination_token", nextToken)
+		}
+		res, body, err := get(fmt.Sprintf("%s/users/%s/tweets?%s", xAPIBase, url.PathEscape(xUserID), q.Encode()))
+		if err != nil {
+			l.Printf("[XImport] page failed userId=%s page=%d fetched=%d err=%v", userID, page, fetched, err)
+			return fetched, upserted, nil, err
+		}
+
+		var tl xTimelinePage
+		if err := json.Unmarshal(body, &tl); err != nil {
+			return fetched, upserted, nil, err
+		}
+		if page == 0 {
+			newestID = tl.Meta.NewestID
+		}
+
+		media := map[string]int{}
+		for i, m := range tl.Includes.Media {
+			media[m.MediaKey] = i
+		}
+		fetched += len(tl.Data)
+		for _, it := range tl.Data {
+			if it.ID == "" {
+				continue
+			}
+			contentType := "post"
+			mediaURL := ""
+			thumb := ""
+			for _, key := range it.Attachments.MediaKeys {
+				idx, ok := media[key]
+				if !ok {
+					continue
+				}
+				m := tl.Includes.Media[idx]
+				if m.Type == "video" || m.Type == "animated_gif" {
+					contentType = "video"
+				}
+				mediaURL = m.URL
+				thumb = m.PreviewImageURL
+				if thumb == "" {
+					thumb = m.URL
+				}
+				break
+			}
+			permalink := "https://x.com/i/web/status/" + it.ID
+			if username != "" {
+				permalink = fmt.Sprintf("https://x.com/%s/status/%s", username, it.ID)
+			}
+			views := toInt64(it.PublicMetrics["impression_count"])
+			likes := toInt64(it.PublicMetrics["like_count"])
+
+			var postedAt *time.Time
+			if it.CreatedAt != "" {
+				if t, err := time.Parse(time.RFC3339, it.CreatedAt); err == nil {
+					tt := t.UTC()
+					postedAt = &tt
+				}
+			}
+
+			rawItem, _ := json.Marshal(it)
+			rowID := fmt.Sprintf("x:%s:%s", userID, it.ID)
+			_, err := db.ExecContext(ctx, `
+				INSERT INTO public.social_libraries
+				  (id, user_id, network, content_type, title, permalink_url, media_url, thumbnail_url, posted_at, views, likes, raw_payload, external_id, created_at, updated_at)
+				VALUES
+				  ($1, $2, 'x', $3, NULLIF($4,''), NULLIF($5,''), NULLIF($6,''), NULLIF($7,''), $8, $9, $10, $11::jsonb, $12, NOW(), NOW())
+				ON CONFLICT (user_id, network, external_id)
+				DO UPDATE SET
+				  title = EXCLUDED.title,
+				  permalink_url = EXCLUDED.permalink_url,
+				  media_url = EXCLUDED.media_url,
+				  thumbnail_url = EXCLUDED.thumbnail_url,
+				  posted_at = EXCLUDED.posted_at,
+				  views = EXCLUDED.views,
+				  likes = EXCLUDED.likes,
+				  raw_payload = EXCLUDED.raw_payload,
+				  updated_at = NOW()
+			`, rowID, userID, contentType, normalizeTitle(it.Text), permalink, mediaURL, thumb, postedAt, views, likes, string(rawItem), it.ID)
+			if err != nil {
+				l.Printf("[XImport] upsert failed userId=%s postId=%s err=%v", userID, it.ID, err)
+				continue
+			}
+			upserted++
+		}
+
+		nextToken = tl.Meta.NextToken
+		if nextToken == "" {
+			complete = true
+			break
+		}
+		// Out of calls for this 15-minute window: stop now rather than hit a 429 on the next page.
+		if res != nil && res.Header.Get("x-rate-limit-remaining") == "0" {
+			l.Printf("[XImport] rate limit window exhausted userId=%s page=%d reset=%s", userID, page, res.Header.Get("x-rate-limit-reset"))
+			break
+		}
+	}
+
+	// First sync (no sinceId) is capped at xMaxPagesPerSync pages; newer posts are what matters from then on,
+	// so the cursor advances once the first page window is read even if older history remains.
+	var next map[string]string
+	if newestID != "" && (complete || sinceID == "") {
+		next = map[string]string{"sinceId": newestID}
+	}
+
+	l.Printf("[XImport] done userId=%s fetched=%d upserted=%d sinceId=%s newestId=%s complete=%v", userID, fetched, upserted, sinceID, newestID, complete)
+	return fetched, upserted, next, nil
+}
+
+var _ socialimport.IncrementalProvider = XProvider{}
@@ -15,6 +15,10 @@ import (
 	"golang.org/x/time/rate"
 )
 
+// YouTubeProvider imports the videos in the user's channel uploads playlist.
+//
+// Sync is incremental: the newest video publish time seen is stored as the `since` cursor. Uploads are
+// listed newest first, so later syncs stop paging at the first video that isn't newer than it.
 type YouTubeProvider struct{}
 
 func (p YouTubeProvider) Name() string { return "youtube" }
@@ -27,9 +31,14 @@ type youtubeOAuth struct {
 	Scope        string `json:"scope"`
 }
 
+// SyncUser loads and saves the cursor itself; the Runner goes through SyncUserIncremental.
 func (p YouTubeProvider) SyncUser(ctx context.Context, db *sql.DB, userID string, client *http.Client, limiter *rate.Limiter, logger *log.Logger) (int, int, error) {
+	return socialimport.SyncIncremental(ctx, db, p, userID, client, limiter, logger)
+}
+
+func (p YouTubeProvider) SyncUserIncremental(ctx context.Context, db *sql.DB, userID string, cursor map[string]string, client *http.Client, limiter *rate.Limiter, logger *log.Logger) (int, int, map[string]string, error) {
 	if db == nil {
-		return 0, 0, fmt.Errorf("db is nil")
+		return 0, 0, nil, fmt.Errorf("db is nil")
 	}
 	l := logger
 	if l == nil {
@@ -43,20 +52,20 @@ func (p YouTubeProvider) SyncUser(ctx context.Context, db *sql.DB, userID string
 	var raw []byte
 	if err := db.QueryRowContext(ctx, `SELECT value FROM public.user_settings WHERE user_id=$1 AND key='youtube_oauth' AND value IS NOT NULL`, userID).Scan(&raw); err != nil {
 		if err == sql.ErrNoRows {
-			return 0, 0, nil
+			return 0, 0, nil, nil
 		}
-		return 0, 0, err
+		return 0, 0, nil, err
 	}
 	if len(raw) == 0 || string(raw) == "null" {
-		return 0, 0, nil
+		return 0, 0, nil, nil
 	}
 	var tok youtubeOAuth
 	if err := json.Unmarshal(raw, &tok); err != nil {
 		l.Printf("[YTImport] invalid oauth json userId=%s err=%v raw=%s", userID, err, truncate(string(raw), 600))
-		return 0, 0, nil
+		return 0, 0, nil, nil
 	}
 	if tok.AccessToken == "" {
-		return 0, 0, nil
+		return 0, 0, nil, nil
 	}
 
 	wait := func() error {
@@ -68,7 +77,7 @@ func (p YouTubeProvider) SyncUser(ctx context.Context, db *sql.DB, userID string
 
 	// 1) Get channel (mine=true)
 	if err := wait(); err != nil {
-		return 0, 0, err
+		return 0, 0, nil, err
 	}
 	chURL := "https://www.googleapis.com/youtube/v3/channels"
 	chQ := url.Values{}
@@ -79,21 +88,21 @@ func (p YouTubeProvider) SyncUser(ctx context.Context, db *sql.DB, userID string
 	chReq.Header.Set("Accept", "application/json")
 	chRes, err := client.Do(chReq)
 	if err != nil {
-		return 0, 0, err
+		return 0, 0, nil, err
 	}
 	chBody, _ := io.ReadAll(io.LimitReader(chRes.Body, 1<<20))
 	_ = chRes.Body.Close()
 	if chRes.StatusCode < 200 || chRes.StatusCode >= 300 {
-		return 0, 0, fmt.Errorf("youtube_channels_non_2xx status=%d body=%s", chRes.StatusCode, truncate(string(chBody), 600))
+		return 0, 0, nil, fmt.Errorf("youtube_channels_non_2xx status=%d body=%s", chRes.StatusCode, truncate(string(chBody), 600))
 	}
 
 	var chParsed map[string]any
 	if err := json.Unmarshal(chBody, &chParsed); err != nil {
-		return 0, 0, err
+		return 0, 0, nil, err
 	}
 	itemsAny, _ := chParsed["items"].([]any)
 	if len(itemsAny) == 0 {
-		return 0, 0, nil
+		return 0, 0, nil, nil
 	}
 	first, _ := itemsAny[0].(map[string]any)
 	channelID, _ := first["id"].(string)
@@ -104,147 +113,178 @@ func (p YouTubeProvider) SyncUser(ctx context.Context, db *sql.DB, userID string
 	uploadsID, _ := related["uploads"].(string)
 
 	if uploadsID == "" {
-		return 0, 0, nil
+		return 0, 0, nil, nil
 	}
 
-	// 2) List playlist items (uploads)
-	if err := wait(); err != nil {
-		return 0, 0, err
-	}
-	plURL := "https://www.googleapis.com/youtube/v3/playlistItems"
-	plQ := url.Values{}
-	plQ.Set("part", "contentDetails,snippet")
-	plQ.Set("playlistId", uploadsID)
-	plQ.Set("maxResults", "25")
-	plReq, _ := http.NewRequestWithContext(ctx, "GET", plURL+"?"+plQ.Encode(), nil)
-	plReq.Header.Set("Authorization", "Bearer "+tok.AccessToken)
-	plReq.Header.Set("Accept", "application/json")
-	plRes, err := client.Do(plReq)
-	if err != nil {
-		return 0, 0, err
-	}
-	plBody, _ := io.ReadAll(io.LimitReader(plRes.Body, 1<<20))
-	_ = plRes.Body.Close()
-	if plRes.StatusCode < 200 || plRes.StatusCode >= 300 {
-		return 0, 0, fmt.Errorf("youtube_playlistitems_non_2xx status=%d body=%s", plRes.StatusCode, truncate(string(plBody), 600))
-	}
-	var plParsed map[string]any
-	if err := json.Unmarshal(plBody, &plParsed); err != nil {
-		return 0, 0, err
-	}
-	plItemsAny, _ := plParsed["items"].([]any)
-	if len(plItemsAny) == 0 {
-		return 0, 0, nil
-	}
-
-	videoIDs := make([]string, 0, len(plItemsAny))
-	for _, itAny := range plItemsAny {
-		it, _ := itAny.(map[string]any)
-		cd, _ := it["contentDetails"].(map[string]any)
-		vid, _ := cd["videoId"].(string)
-		if vid != "" {
-			videoIDs = append(videoIDs, vid)
+	since := cursorTime(cursor, "since")
+	newest := since
+	fetched := 0
+	upserted := 0
+	complete := false
+	pageToken := ""
+	// Charge the API calls after the channel lookup, which the runner already counted.
+	extra := func() error {
+		if err := consumeExtraRequest(ctx, db, p.Name()); err != nil {
+			return err
 		}
+		return wait()
 	}
-	if len(videoIDs) == 0 {
-		return 0, 0, nil
-	}
-
-	// 3) Fetch video details (statistics)
-	if err := wait(); err != nil {
-		return 0, 0, err
-	}
-	vidURL := "https://www.googleapis.com/youtube/v3/videos"
-	vidQ := url.Values{}
-	vidQ.Set("part", "snippet,statistics")
-	vidQ.Set("id", joinComma(videoIDs))
-	vidReq, _ := http.NewRequestWithContext(ctx, "GET", vidURL+"?"+vidQ.Encode(), nil)
-	vidReq.Header.Set("Authorization", "Bearer "+tok.AccessToken)
-	vidReq.Header.Set("Accept", "application/json")
-	vidRes, err := client.Do(vidReq)
-	if err != nil {
-		return 0, 0, err
-	}
-	vidBody, _ := io.ReadAll(io.LimitReader(vidRes.Body, 1<<20))
-	_ = vidRes.Body.Close()
-	if vidRes.StatusCode < 200 || vidRes.StatusCode >= 300 {
-		return 0, 0, fmt.Errorf("youtube_videos_non_2xx status=%d body=%s", vidRes.StatusCode, truncate(string(vidBody), 600))
-	}
-	var vidParsed map[string]any
-	if err := json.Unmarshal(vidBody, &vidParsed); err != nil {
-		return 0, 0, err
-	}
-	vidItemsAny, _ := vidParsed["items"].([]any)
-
-	fetched := len(vidItemsAny)
-	upserted := 0
+	for page := 0; page < importMaxPagesPerSync && !complete; page++ {
+		// 2) List playlist items (uploads)
+		if err := extra(); err != nil {
+			return fetched, upserted, nil, err
+		}
+		plURL := "https://www.googleapis.com/youtube/v3/playlistItems"
+		plQ := url.Values{}
+		plQ.Set("part", "contentDetails,snippet")
+		plQ.Set("playlistId", uploadsID)
+		plQ.Set("maxResults", "25")
+		if pageToken != "" {
+			plQ.Set("pageToken", pageToken)
+		}
+		plReq, _ := http.NewRequestWithContext(ctx, "GET", plURL+"?"+plQ.Encode(), nil)
+		plReq.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+		plReq.Header.Set("Accept", "application/json")
+		plRes, err := client.Do(plReq)
+		if err != nil {
+			return fetched, upserted, nil, err
+		}
+		plBody, _ := io.ReadAll(io.LimitReader(plRes.Body, 1<<20))
+		_ = plRes.Body.Close()
+		if plRes.StatusCode < 200 || plRes.StatusCode >= 300 {
+			return fetched, upserted, nil, fmt.Errorf("youtube_playlistitems_non_2xx status=%d body=%s", plRes.StatusCode, truncate(string(plBody), 600))
+		}
+		var plParsed map[string]any
+		if err := json.Unmarshal(plBody, &plParsed); err != nil {
+			return fetched, upserted, nil, err
+		}
+		plItemsAny, _ := plParsed["items"].([]any)
+		pageToken, _ = plParsed["nextPageToken"].(string)
+		if pageToken == "" {
+			complete = true
+		}
 
-	for _, vAny := range vidItemsAny {
-		v, _ := vAny.(map[string]any)
-		vid, _ := v["id"].(string)
-		if vid == "" {
+		videoIDs := make([]string, 0, len(plItemsAny))
+		for _, itAny := range plItemsAny {
+			it, _ := itAny.(map[string]any)
+			cd, _ := it["contentDetails"].(map[string]any)
+			// Everything from here on was imported by an earlier sync.
+			if ts, _ := cd["videoPublishedAt"].(string); ts != "" && !since.IsZero() {
+				if t, err := time.Parse(time.RFC3339, ts); err == nil && !t.After(since) {
+					complete = true
+					break
+				}
+			}
+			vid, _ := cd["videoId"].(string)
+			if vid != "" {
+				videoIDs = append(videoIDs, vid)
+			}
+		}
+		if len(videoIDs) == 0 {
 			continue
 		}
-		sn, _ := v["snippet"].(map[string]any)
-		title, _ := sn["title"].(string)
-		publishedAt, _ := sn["publishedAt"].(string)
-		thumbnails, _ := sn["thumbnails"].(map[string]any)
-		var thumb string
-		if thumbnails != nil {
-			if hi, ok := thumbnails["high"].(map[string]any); ok {
-				if u, ok := hi["url"].(string); ok {
-					thumb = u
-				}
+
+		// 3) Fetch video details (statistics)
+		if err := extra(); err != nil {
+			return fetched, upserted, nil, err
+		}
+		vidURL := "https://www.googleapis.com/youtube/v3/videos"
+		vidQ := url.Values{}
+		vidQ.Set("part", "snippet,statistics")
+		vidQ.Set("id", joinComma(videoIDs))
+		vidReq, _ := http.NewRequestWithContext(ctx, "GET", vidURL+"?"+vidQ.Encode(), nil)
+		vidReq.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+		vidReq.Header.Set("Accept", "application/json")
+		vidRes, err := client.Do(vidReq)
+		if err != nil {
+			return fetched, upserted, nil, err
+		}
+		vidBody, _ := io.ReadAll(io.LimitReader(vidRes.Body, 1<<20))
+		_ = vidRes.Body.Close()
+		if vidRes.StatusCode < 200 || vidRes.StatusCode >= 300 {
+			return fetched, upserted, nil, fmt.Errorf("youtube_videos_non_2xx status=%d body=%s", vidRes.StatusCode, truncate(string(vidBody), 600))
+		}
+		var vidParsed map[string]any
+		if err := json.Unmarshal(vidBody, &vidParsed); err != nil {
+			return fetched, upserted, nil, err
+		}
+		vidItemsAny, _ := vidParsed["items"].([]any)
+		fetched += len(vidItemsAny)
+
+		for _, vAny := range vidItemsAny {
+			v, _ := vAny.(map[string]any)
+			vid, _ := v["id"].(string)
+			if vid == "" {
+				continue
 			}
-			if thumb == "" {
-				if def, ok := thumbnails["default"].(map[string]any); ok {
-					if u, ok := def["url"].(string); ok {
+			sn, _ := v["snippet"].(map[string]any)
+			title, _ := sn["title"].(string)
+			publishedAt, _ := sn["publishedAt"].(string)
+			thumbnails, _ := sn["thumbnails"].(map[string]any)
+			var thumb string
+			if thumbnails != nil {
+				if hi, ok := thumbnails["high"].(map[string]any); ok {
+					if u, ok := hi["url"].(string); ok {
 						thumb = u
 					}
 				}
+				if thumb == "" {
+					if def, ok := thumbnails["default"].(map[string]any); ok {
+						if u, ok := def["url"].(string); ok {
+							thumb = u
+						}
+					}
+				}
 			}
-		}
-		stats, _ := v["statistics"].(map[string]any)
-		viewCount := toInt64(stats["viewCount"])
-		likeCount := toInt64(stats["likeCount"])
+			stats, _ := v["statistics"].(map[string]any)
+			viewCount := toInt64(stats["viewCount"])
+			likeCount := toInt64(stats["likeCount"])
+
+			var postedAt *time.Time
+			if publishedAt != "" {
+				if t, err := time.Parse(time.RFC3339, publishedAt); err == nil {
+					tt := t.UTC()
+					postedAt = &tt
+				}
+			}
+			newest = laterTime(newest, postedAt)
 
-		var postedAt *time.Time
-		if publishedAt != "" {
-			if t, err := time.Parse(time.RFC3339, publishedAt); err == nil {
-				tt := t.UTC()
-				postedAt = &tt
+			permalink := fmt.Sprintf("https://www.youtube.com/watch?v=%s", vid)
+			rawItem, _ := json.Marshal(v)
+			rowID := fmt.Sprintf("youtube:%s:%s", userID, vid)
+			_, err := db.ExecContext(ctx, `
+				INSERT INTO public.social_libraries
+				  (id, user_id, network, content_type, title, permalink_url, media_url, thumbnail_url, posted_at, views, likes, raw_payload, external_id, created_at, updated_at)
+				VALUES
+				  ($1, $2, 'youtube', 'video', NULLIF($3,''), $4, $5, NULLIF($6,''), $7, $8, $9, $10::jsonb, $11, NOW(), NOW())
+				ON CONFLICT (user_id, network, external_id)
+				DO UPDATE SET
+				  title = EXCLUDED.title,
+				  permalink_url = EXCLUDED.permalink_url,
+				  media_url = EXCLUDED.media_url,
+				  thumbnail_url = EXCLUDED.thumbnail_url,
+				  posted_at = EXCLUDED.posted_at,
+				  views = EXCLUDED.views,
+				  likes = EXCLUDED.likes,
+				  raw_payload = EXCLUDED.raw_payload,
+				  updated_at = NOW()
+			`, rowID, userID, normalizeTitle(title), permalink, permalink, thumb, postedAt, viewCount, likeCount, string(rawItem), vid)
+			if err != nil {
+				l.Printf("[YTImport] upsert failed userId=%s videoId=%s err=%v", userID, vid, err)
+				continue
 			}
+			upserted++
 		}
+	}
 
-		permalink := fmt.Sprintf("https://www.youtube.com/watch?v=%s", vid)
-		rawItem, _ := json.Marshal(v)
-		rowID := fmt.Sprintf("youtube:%s:%s", userID, vid)
-		_, err := db.ExecContext(ctx, `
-			INSERT INTO public.social_libraries
-			  (id, user_id, network, content_type, title, permalink_url, media_url, thumbnail_url, posted_at, views, likes, raw_payload, external_id, created_at, updated_at)
-			VALUES
-			  ($1, $2, 'youtube', 'video', NULLIF($3,''), $4, $5, NULLIF($6,''), $7, $8, $9, $10::jsonb, $11, NOW(), NOW())
-			ON CONFLICT (user_id, network, external_id)
-			DO UPDATE SET
-			  title = EXCLUDED.title,
-			  permalink_url = EXCLUDED.permalink_url,
-			  media_url = EXCLUDED.media_url,
-			  thumbnail_url = EXCLUDED.thumbnail_url,
-			  posted_at = EXCLUDED.posted_at,
-			  views = EXCLUDED.views,
-			  likes = EXCLUDED.likes,
-			  raw_payload = EXCLUDED.raw_payload,
-			  updated_at = NOW()
-		`, rowID, userID, normalizeTitle(title), permalink, permalink, thumb, postedAt, viewCount, likeCount, string(rawItem), vid)
-		if err != nil {
-			l.Printf("[YTImport] upsert failed userId=%s videoId=%s err=%v", userID, vid, err)
-			continue
-		}
-		upserted++
+	// Same rule as X: the cursor advances once every new video was read (or on the first sync).
+	var next map[string]string
+	if newest.After(since) && (complete || since.IsZero()) {
+		next = map[string]string{"since": newest.Format(time.RFC3339)}
 	}
 
-	l.Printf("[YTImport] done userId=%s channelId=%s channelTitle=%s fetched=%d upserted=%d", userID, channelID, channelTitle, fetched, upserted)
-	return fetched, upserted, nil
+	l.Printf("[YTImport] done userId=%s channelId=%s channelTitle=%s fetched=%d upserted=%d since=%s complete=%v", userID, channelID, channelTitle, fetched, upserted, cursor["since"], complete)
+	return fetched, upserted, next, nil
 }
 
 func joinComma(items []string) string {
@@ -259,4 +299,4 @@ func joinComma(items []string) string {
 	return out
 }
 
-var _ socialimport.Provider = YouTubeProvider{}
+var _ socialimport.IncrementalProvider = YouTubeProvider{}
@@ -2,7 +2,9 @@ package socialimport
 
 import (
 	"context"
-	"log"
+	"errors"
+	"math/rand"
+	"sync"
 	"time"
 )
 
@@ -13,46 +15,112 @@ type ProviderRunResult struct {
 	Skipped  bool
 	Reason   string
 	Error    string
+	// NextRunAt is set after a failure: the background worker won't retry this user before then.
+	NextRunAt  *time.Time
+	DurationMs int64
+}
+
+// Status maps a result onto the run status stored in social_import_runs.
+func (res ProviderRunResult) Status() string {
+	switch {
+	case res.Error != "":
+		return RunStatusFailed
+	case res.Skipped:
+		return RunStatusSkipped
+	}
+	return RunStatusCompleted
 }
 
 // SyncAll does an on-demand import for a set of providers for a single user.
 func (r *Runner) SyncAll(ctx context.Context, userID string, providers []Provider) []ProviderRunResult {
+	return r.syncAll(ctx, userID, providers, TriggerManual)
+}
+
+func (r *Runner) syncAll(ctx context.Context, userID string, providers []Provider, trigger string) []ProviderRunResult {
 	r.EnsureDefaults()
 	out := make([]ProviderRunResult, 0, len(providers))
 	for _, p := range providers {
-		name := p.Name()
-		lim, cfg := r.limiterForProvider(name)
 		start := time.Now()
-		r.Logger.Printf("[SocialSync] start provider=%s userId=%s", name, userID)
-
-		// One request "budget" for the sync attempt itself; providers should account for their internal calls too.
-		if r.DB != nil && cfg.DailyRequestsMax > 0 {
-			ok, used, err := ConsumeRequests(ctx, r.DB, name, 1, cfg.DailyRequestsMax)
-			if err != nil {
-				out = append(out, ProviderRunResult{Provider: name, Error: err.Error()})
-				r.Logger.Printf("[SocialSync] quota check failed provider=%s userId=%s err=%v", name, userID, err)
-				continue
-			}
-			if !ok {
-				out = append(out, ProviderRunResult{Provider: name, Skipped: true, Reason: "daily_quota_exceeded"})
-				r.Logger.Printf("[SocialSync] quota exceeded provider=%s userId=%s used=%d max=%d", name, userID, used, cfg.DailyRequestsMax)
-				continue
+		r.progress(ProgressEvent{UserID: userID, Provider: p.Name(), Trigger: trigger, Status: RunStatusStarted})
+		res := r.syncOne(ctx, userID, p, start)
+		res.DurationMs = time.Since(start).Milliseconds()
+		if r.DB != nil {
+			// ctx may be a per-user budget that has just run out; the bookkeeping must still land.
+			if err := RecordRun(context.WithoutCancel(ctx), r.DB, userID, trigger, start, res); err != nil {
+				r.Logger.Printf("[SocialSync] record run failed provider=%s userId=%s err=%v", res.Provider, userID, err)
 			}
 		}
+		rr := res
+		r.progress(ProgressEvent{UserID: userID, Provider: res.Provider, Trigger: trigger, Status: res.Status(), Result: &rr})
+		out = append(out, res)
+	}
+	return out
+}
 
-		fetched, upserted, err := p.SyncUser(ctx, r.DB, userID, r.Client, lim, r.Logger)
+func (r *Runner) progress(ev ProgressEvent) {
+	if r.OnProgress != nil {
+		r.OnProgress(ev)
+	}
+}
+
+func (r *Runner) syncOne(ctx context.Context, userID string, p Provider, start time.Time) ProviderRunResult {
+	name := p.Name()
+	lim, cfg := r.limiterForProvider(name)
+	r.Logger.Printf("[SocialSync] start provider=%s userId=%s", name, userID)
+
+	// One request "budget" for the sync attempt itself; providers should account for their internal calls too.
+	if r.DB != nil && cfg.DailyRequestsMax > 0 {
+		ok, used, err := ConsumeRequests(ctx, r.DB, name, 1, cfg.DailyRequestsMax)
 		if err != nil {
-			out = append(out, ProviderRunResult{Provider: name, Fetched: fetched, Upserted: upserted, Error: err.Error()})
-			r.Logger.Printf("[SocialSync] error provider=%s userId=%s fetched=%d upserted=%d dur=%s err=%v", name, userID, fetched, upserted, time.Since(start), err)
-			continue
+			r.Logger.Printf("[SocialSync] quota check failed provider=%s userId=%s err=%v", name, userID, err)
+			return ProviderRunResult{Provider: name, Error: err.Error()}
+		}
+		if !ok {
+			r.Logger.Printf("[SocialSync] quota exceeded provider=%s userId=%s used=%d max=%d", name, userID, used, cfg.DailyRequestsMax)
+			return ProviderRunResult{Provider: name, Skipped: true, Reason: "daily_quota_exceeded"}
 		}
-		out = append(out, ProviderRunResult{Provider: name, Fetched: fetched, Upserted: upserted})
-		r.Logger.Printf("[SocialSync] done provider=%s userId=%s fetched=%d upserted=%d dur=%s", name, userID, fetched, upserted, time.Since(start))
 	}
-	return out
+
+	var (
+		fetched, upserted int
+		err               error
+	)
+	if ip, ok := p.(IncrementalProvider); ok && r.DB != nil {
+		fetched, upserted, err = SyncIncremental(ctx, r.DB, ip, userID, r.Client, lim, r.Logger)
+	} else {
+		fetched, upserted, err = p.SyncUser(ctx, r.DB, userID, r.Client, lim, r.Logger)
+	}
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = errors.New("user_time_budget_exceeded")
+	}
+	if err != nil {
+		res := ProviderRunResult{Provider: name, Fetched: fetched, Upserted: upserted, Error: err.Error()}
+		if r.DB != nil {
+			next, serr := RecordRunFailure(context.WithoutCancel(ctx), r.DB, userID, name, start, err.Error(), r.BackoffBase, r.BackoffMax)
+			if serr != nil {
+				r.Logger.Printf("[SocialSync] record failure failed provider=%s userId=%s err=%v", name, userID, serr)
+			} else {
+				res.NextRunAt = &next
+			}
+		}
+		r.Logger.Printf("[SocialSync] error provider=%s userId=%s fetched=%d upserted=%d dur=%s err=%v", name, userID, fetched, upserted, time.Since(start), err)
+		return res
+	}
+	if r.DB != nil {
+		if serr := RecordRunSuccess(context.WithoutCancel(ctx), r.DB, userID, name, start); serr != nil {
+			r.Logger.Printf("[SocialSync] record success failed provider=%s userId=%s err=%v", name, userID, serr)
+		}
+	}
+	r.Logger.Printf("[SocialSync] done provider=%s userId=%s fetched=%d upserted=%d dur=%s", name, userID, fetched, upserted, time.Since(start))
+	return ProviderRunResult{Provider: name, Fetched: fetched, Upserted: upserted}
 }
 
 // StartProviderWorker runs a periodic importer loop for a single provider with its own limiter/quota settings.
+//
+// Each sweep materializes the list of due users first (least recently synced first), then syncs them on a
+// bounded pool of Concurrency goroutines sharing the provider's rate limiter. Every user gets at most
+// UserBudget per sync so a single large library can't stall the sweep, and the wait between sweeps is
+// jittered so several instances don't sweep in lockstep.
 func (r *Runner) StartProviderWorker(ctx context.Context, provider Provider, interval time.Duration) {
 	r.EnsureDefaults()
 	if interval <= 0 {
@@ -60,49 +128,98 @@ func (r *Runner) StartProviderWorker(ctx context.Context, provider Provider, int
 	}
 	name := provider.Name()
 	_, cfg := r.limiterForProvider(name)
-	r.Logger.Printf("[SocialWorker] started provider=%s interval=%s rps=%.3f burst=%d dailyMax=%d", name, interval, cfg.RequestsPerSecond, cfg.Burst, cfg.DailyRequestsMax)
-
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+	r.Logger.Printf("[SocialWorker] started provider=%s interval=%s concurrency=%d userBudget=%s jitter=%s rps=%.3f burst=%d dailyMax=%d",
+		name, interval, r.Concurrency, r.UserBudget, r.Jitter, cfg.RequestsPerSecond, cfg.Burst, cfg.DailyRequestsMax)
 
 	run := func() {
 		if r.DB == nil {
 			return
 		}
-		// Find users that have an oauth token for that provider in UserSettings.
-		key := name + "_oauth"
-		rows, err := r.DB.QueryContext(ctx, `SELECT DISTINCT user_id FROM public.user_settings WHERE key = $1 AND value IS NOT NULL`, key)
+		start := time.Now()
+		users, err := r.dueUsers(ctx, name)
 		if err != nil {
 			r.Logger.Printf("[SocialWorker] list users failed provider=%s err=%v", name, err)
 			return
 		}
-		defer rows.Close()
 
-		countUsers := 0
-		for rows.Next() {
-			var userID string
-			if err := rows.Scan(&userID); err != nil {
-				continue
+		queue := make(chan string)
+		var wg sync.WaitGroup
+		for i := 0; i < r.Concurrency && i < len(users); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for userID := range queue {
+					userCtx, cancel := context.WithTimeout(ctx, r.UserBudget)
+					_ = r.syncAll(userCtx, userID, []Provider{provider}, TriggerWorker)
+					cancel()
+				}
+			}()
+		}
+	feed:
+		for _, userID := range users {
+			select {
+			case queue <- userID:
+			case <-ctx.Done():
+				break feed
 			}
-			countUsers++
-			// Per user run uses its own internal accounting/logging
-			_ = r.SyncAll(ctx, userID, []Provider{provider})
 		}
-		r.Logger.Printf("[SocialWorker] sweep complete provider=%s users=%d", name, countUsers)
+		close(queue)
+		wg.Wait()
+
+		r.Logger.Printf("[SocialWorker] sweep complete provider=%s users=%d dur=%s", name, len(users), time.Since(start))
+		if n, err := PruneRuns(ctx, r.DB, name, runHistoryRetention); err != nil {
+			r.Logger.Printf("[SocialWorker] prune runs failed provider=%s err=%v", name, err)
+		} else if n > 0 {
+			r.Logger.Printf("[SocialWorker] pruned runs provider=%s deleted=%d", name, n)
+		}
 	}
 
-	run()
+	timer := time.NewTimer(r.jitter())
+	defer timer.Stop()
 	for {
 		select {
 		case <-ctx.Done():
-			if r.Logger == nil {
-				log.Default().Printf("[SocialWorker] stopped provider=%s err=%v", name, ctx.Err())
-			} else {
-				r.Logger.Printf("[SocialWorker] stopped provider=%s err=%v", name, ctx.Err())
-			}
+			r.Logger.Printf("[SocialWorker] stopped provider=%s err=%v", name, ctx.Err())
 			return
-		case <-ticker.C:
+		case <-timer.C:
 			run()
+			timer.Reset(interval + r.jitter())
+		}
+	}
+}
+
+// dueUsers lists users with an oauth token for the provider that aren't backing off after a failed sync
+// (next_run_at in the future), least recently synced first. The rows are read fully before any syncing
+// starts so the sweep doesn't hold a connection open.
+func (r *Runner) dueUsers(ctx context.Context, provider string) ([]string, error) {
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT us.user_id
+		  FROM public.user_settings us
+		  LEFT JOIN public.social_import_states s ON s.user_id = us.user_id AND s.provider = $2
+		 WHERE us.key = $1
+		   AND us.value IS NOT NULL
+		   AND (s.next_run_at IS NULL OR s.next_run_at <= NOW())
+		 ORDER BY s.last_run_at ASC NULLS FIRST, us.user_id
+	`, provider+"_oauth", provider)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := make([]string, 0)
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			continue
 		}
+		users = append(users, userID)
+	}
+	return users, rows.Err()
+}
+
+// jitter returns a random delay in [0, Jitter).
+func (r *Runner) jitter() time.Duration {
+	if r.Jitter <= 0 {
+		return 0
 	}
+	return time.Duration(rand.Int63n(int64(r.Jitter)))
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"io"
 	"log"
 	"net/http"
 	"os"
@@ -25,6 +26,15 @@ func (p fakeProvider) SyncUser(ctx context.Context, db *sql.DB, userID string, c
 	return p.fn(ctx, db, userID, client, limiter, logger)
 }
 
+type fakeIncrementalProvider struct {
+	fakeProvider
+	incr func(cursor map[string]string) (int, int, map[string]string, error)
+}
+
+func (p fakeIncrementalProvider) SyncUserIncremental(ctx context.Context, db *sql.DB, userID string, cursor map[string]string, client *http.Client, limiter *rate.Limiter, logger *log.Logger) (int, int, map[string]string, error) {
+	return p.incr(cursor)
+}
+
 func TestRunnerSyncAll_QuotaExceededAndProviderError(t *testing.T) {
 	// Force daily max via env for provider x.
 	os.Setenv("SOCIAL_IMPORT_X_DAILY_MAX", "1")
@@ -42,6 +52,9 @@ func TestRunnerSyncAll_QuotaExceededAndProviderError(t *testing.T) {
 	mock.ExpectQuery(`INSERT INTO public\.social_import_usage`).
 		WithArgs(sqlmock.AnyArg(), "x", sqlmock.AnyArg(), int64(1)).
 		WillReturnRows(sqlmock.NewRows([]string{"requests_used"}).AddRow(int64(2)))
+	mock.ExpectExec(`INSERT INTO public\.social_import_runs`).
+		WithArgs(sqlmock.AnyArg(), "u1", "x", TriggerManual, RunStatusSkipped, 0, 0, "daily_quota_exceeded", "", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	out := r.SyncAll(context.Background(), "u1", []Provider{
 		fakeProvider{name: "x", fn: func(ctx context.Context, db *sql.DB, userID string, client *http.Client, limiter *rate.Limiter, logger *log.Logger) (int, int, error) {
@@ -82,9 +95,18 @@ func TestStartProviderWorker_StopsOnContextCancel(t *testing.T) {
 	}}
 
 	// StartProviderWorker queries users from UserSettings for oauth key.
-	mock.ExpectQuery(`SELECT DISTINCT user_id FROM public\.user_settings WHERE key = \$1 AND value IS NOT NULL`).
-		WithArgs("x_oauth").
+	mock.ExpectQuery(`SELECT us\.user_id\s+FROM public\.user_settings us[\s\S]*s\.next_run_at IS NULL OR s\.next_run_at <= NOW\(\)[\s\S]*ORDER BY s\.last_run_at ASC NULLS FIRST`).
+		WithArgs("x_oauth", "x").
 		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow("u1"))
+	mock.ExpectExec(`INSERT INTO public\.social_import_states`).
+		WithArgs("x:u1", "u1", "x", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO public\.social_import_runs`).
+		WithArgs(sqlmock.AnyArg(), "u1", "x", TriggerWorker, RunStatusCompleted, 0, 0, "", "", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`DELETE FROM public\.social_import_runs`).
+		WithArgs("x", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 3))
 
 	ctx, cancel := context.WithCancel(context.Background())
 	r := &Runner{DB: db, Logger: log.Default()}
@@ -112,3 +134,168 @@ func TestStartProviderWorker_StopsOnContextCancel(t *testing.T) {
 		t.Fatalf("sql expectations: %v", err)
 	}
 }
+
+func TestRunnerSyncAll_IncrementalCursorAndSuccessState(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectQuery(`FROM public\.social_import_states`).
+		WithArgs("u1", "threads").
+		WillReturnRows(sqlmock.NewRows([]string{"cursor"}).AddRow([]byte(`{"since":"10"}`)))
+	mock.ExpectExec(`INSERT INTO public\.social_import_states \(id, user_id, provider, cursor`).
+		WithArgs("threads:u1", "u1", "threads", `{"since":"20"}`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO public\.social_import_states[\s\S]*last_success_at[\s\S]*next_run_at = NULL`).
+		WithArgs("threads:u1", "u1", "threads", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO public\.social_import_runs`).
+		WithArgs(sqlmock.AnyArg(), "u1", "threads", TriggerManual, RunStatusCompleted, 2, 2, "", "", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	var got map[string]string
+	var events []ProgressEvent
+	r := &Runner{DB: db, Logger: log.Default(), OnProgress: func(ev ProgressEvent) { events = append(events, ev) }}
+	out := r.SyncAll(context.Background(), "u1", []Provider{
+		fakeIncrementalProvider{
+			fakeProvider: fakeProvider{name: "threads"},
+			incr: func(cursor map[string]string) (int, int, map[string]string, error) {
+				got = cursor
+				return 2, 2, map[string]string{"since": "20"}, nil
+			},
+		},
+	})
+	if len(out) != 1 || out[0].Error != "" || out[0].Fetched != 2 || out[0].NextRunAt != nil {
+		t.Fatalf("unexpected result: %+v", out)
+	}
+	if got["since"] != "10" {
+		t.Fatalf("expected stored cursor passed to provider, got %#v", got)
+	}
+	if len(events) != 2 || events[0].Status != RunStatusStarted || events[0].Result != nil ||
+		events[1].Status != RunStatusCompleted || events[1].Result == nil || events[1].Result.Upserted != 2 {
+		t.Fatalf("unexpected progress events: %+v", events)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestRunnerSyncAll_FailureSchedulesBackoff(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	next := time.Now().Add(30 * time.Minute).UTC()
+	mock.ExpectQuery(`INSERT INTO public\.social_import_states[\s\S]*consecutive_failures = public\.social_import_states\.consecutive_failures \+ 1[\s\S]*RETURNING next_run_at`).
+		WithArgs("instagram:u1", "u1", "instagram", sqlmock.AnyArg(), "token expired", float64(600), float64(7200)).
+		WillReturnRows(sqlmock.NewRows([]string{"next_run_at"}).AddRow(next))
+	mock.ExpectExec(`INSERT INTO public\.social_import_runs`).
+		WithArgs(sqlmock.AnyArg(), "u1", "instagram", TriggerManual, RunStatusFailed, 0, 0, "", "token expired", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	r := &Runner{DB: db, Logger: log.Default(), BackoffBase: 10 * time.Minute, BackoffMax: 2 * time.Hour}
+	out := r.SyncAll(context.Background(), "u1", []Provider{
+		fakeProvider{name: "instagram", fn: func(ctx context.Context, db *sql.DB, userID string, client *http.Client, limiter *rate.Limiter, logger *log.Logger) (int, int, error) {
+			return 0, 0, errors.New("token expired")
+		}},
+	})
+	if len(out) != 1 || out[0].Error != "token expired" || out[0].NextRunAt == nil || !out[0].NextRunAt.Equal(next) {
+		t.Fatalf("unexpected result: %+v", out)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestStartProviderWorker_BoundedPoolAndUserBudget(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	mock.MatchExpectationsInOrder(false)
+
+	mock.ExpectQuery(`SELECT us\.user_id\s+FROM public\.user_settings us`).
+		WithArgs("x_oauth", "x").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow("u1").AddRow("u2").AddRow("u3").AddRow("slow"))
+	for _, u := range []string{"u1", "u2", "u3"} {
+		mock.ExpectExec(`INSERT INTO public\.social_import_states[\s\S]*last_success_at`).
+			WithArgs("x:"+u, u, "x", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectQuery(`INSERT INTO public\.social_import_states[\s\S]*RETURNING next_run_at`).
+		WithArgs("x:slow", "slow", "x", sqlmock.AnyArg(), "user_time_budget_exceeded", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"next_run_at"}).AddRow(time.Now().Add(time.Hour)))
+	for i := 0; i < 4; i++ {
+		mock.ExpectExec(`INSERT INTO public\.social_import_runs`).WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectExec(`DELETE FROM public\.social_import_runs`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	var inFlight, maxInFlight, calls atomic.Int32
+	p := fakeProvider{name: "x", fn: func(ctx context.Context, db *sql.DB, userID string, client *http.Client, limiter *rate.Limiter, logger *log.Logger) (int, int, error) {
+		calls.Add(1)
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		if userID == "slow" {
+			<-ctx.Done()
+			return 0, 0, ctx.Err()
+		}
+		time.Sleep(10 * time.Millisecond)
+		return 1, 1, nil
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Runner{DB: db, Logger: log.New(io.Discard, "", 0), Concurrency: 2, UserBudget: 30 * time.Millisecond}
+	done := make(chan struct{})
+	go func() {
+		r.StartProviderWorker(ctx, p, 24*time.Hour)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for mock.ExpectationsWereMet() != nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if calls.Load() != 4 {
+		t.Fatalf("expected 4 syncs, got %d", calls.Load())
+	}
+	if maxInFlight.Load() > 2 {
+		t.Fatalf("expected at most 2 concurrent syncs, got %d", maxInFlight.Load())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestRunnerLimiterSharedPerProvider(t *testing.T) {
+	r := &Runner{}
+	a, _ := r.limiterForProvider("x")
+	b, _ := r.limiterForProvider("x")
+	c, _ := r.limiterForProvider("youtube")
+	if a != b || a == c {
+		t.Fatalf("expected one limiter per provider")
+	}
+	r.Jitter = 0
+	if r.jitter() != 0 {
+		t.Fatalf("expected no jitter when disabled")
+	}
+	r.Jitter = time.Second
+	for i := 0; i < 20; i++ {
+		if d := r.jitter(); d < 0 || d >= time.Second {
+			t.Fatalf("jitter out of range: %s", d)
+		}
+	}
+}
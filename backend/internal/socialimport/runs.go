@@ -0,0 +1,72 @@
+package socialimport
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Run statuses stored in social_import_runs and sent with progress events.
+const (
+	RunStatusStarted   = "started"
+	RunStatusCompleted = "completed"
+	RunStatusFailed    = "failed"
+	RunStatusSkipped   = "skipped"
+)
+
+// What started a sync: the periodic provider worker or a user-initiated sync.
+const (
+	TriggerWorker = "worker"
+	TriggerManual = "manual"
+)
+
+// runHistoryRetention is how long social_import_runs rows are kept; pruned after each worker sweep.
+const runHistoryRetention = 30 * 24 * time.Hour
+
+// ProgressEvent is reported to Runner.OnProgress when a provider sync starts and when it finishes.
+// Result is nil for RunStatusStarted.
+type ProgressEvent struct {
+	UserID   string
+	Provider string
+	Trigger  string
+	Status   string
+	Result   *ProviderRunResult
+}
+
+// RecordRun appends one finished provider sync to social_import_runs.
+func RecordRun(ctx context.Context, db *sql.DB, userID, trigger string, startedAt time.Time, res ProviderRunResult) error {
+	if db == nil {
+		return fmt.Errorf("db is nil")
+	}
+	errText := res.Error
+	if len(errText) > 1000 {
+		errText = errText[:1000]
+	}
+	finishedAt := startedAt.Add(time.Duration(res.DurationMs) * time.Millisecond)
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO public.social_import_runs
+		  (id, user_id, provider, trigger, status, fetched, upserted, skipped_reason, error, started_at, finished_at, duration_ms, created_at)
+		VALUES
+		  ($1, $2, $3, $4, $5, $6, $7, NULLIF($8,''), NULLIF($9,''), $10, $11, $12, NOW())
+	`, fmt.Sprintf("%s:%s:%d", res.Provider, userID, startedAt.UnixNano()), userID, res.Provider, trigger, res.Status(),
+		res.Fetched, res.Upserted, res.Reason, errText, startedAt.UTC(), finishedAt.UTC(), res.DurationMs)
+	return err
+}
+
+// PruneRuns deletes run history for a provider older than the retention window.
+func PruneRuns(ctx context.Context, db *sql.DB, provider string, olderThan time.Duration) (int64, error) {
+	if db == nil {
+		return 0, fmt.Errorf("db is nil")
+	}
+	res, err := db.ExecContext(ctx, `
+		DELETE FROM public.social_import_runs
+		 WHERE provider = $1
+		   AND started_at < $2
+	`, provider, time.Now().Add(-olderThan).UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return n, nil
+}
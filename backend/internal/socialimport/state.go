@@ -0,0 +1,131 @@
+package socialimport
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// LoadCursor returns the stored import cursor for (userID, provider) from social_import_states.
+// A missing row yields an empty cursor.
+func LoadCursor(ctx context.Context, db *sql.DB, userID, provider string) (map[string]string, error) {
+	out := map[string]string{}
+	if db == nil {
+		return out, fmt.Errorf("db is nil")
+	}
+	var raw []byte
+	err := db.QueryRowContext(ctx, `
+		SELECT COALESCE(cursor, '{}'::jsonb)
+		  FROM public.social_import_states
+		 WHERE user_id = $1 AND provider = $2
+	`, userID, provider).Scan(&raw)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return out, nil
+		}
+		return out, err
+	}
+	if len(raw) > 0 {
+		// Cursor values are stored as strings; tolerate other JSON shapes by ignoring them.
+		var m map[string]any
+		if err := json.Unmarshal(raw, &m); err == nil {
+			for k, v := range m {
+				if s, ok := v.(string); ok {
+					out[k] = s
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+// SaveCursor upserts the import cursor for (userID, provider).
+func SaveCursor(ctx context.Context, db *sql.DB, userID, provider string, cursor map[string]string) error {
+	if db == nil {
+		return fmt.Errorf("db is nil")
+	}
+	if cursor == nil {
+		cursor = map[string]string{}
+	}
+	b, err := json.Marshal(cursor)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO public.social_import_states (id, user_id, provider, cursor, created_at, updated_at)
+		VALUES ($1, $2, $3, $4::jsonb, NOW(), NOW())
+		ON CONFLICT (user_id, provider) DO UPDATE SET
+		  cursor = EXCLUDED.cursor,
+		  updated_at = NOW()
+	`, fmt.Sprintf("%s:%s", provider, userID), userID, provider, string(b))
+	return err
+}
+
+// SyncIncremental runs an IncrementalProvider against the cursor stored in social_import_states and
+// saves the cursor it returns. A nil returned cursor leaves the stored one untouched.
+func SyncIncremental(ctx context.Context, db *sql.DB, p IncrementalProvider, userID string, client *http.Client, limiter *rate.Limiter, logger *log.Logger) (int, int, error) {
+	if db == nil {
+		return 0, 0, fmt.Errorf("db is nil")
+	}
+	cursor, err := LoadCursor(ctx, db, userID, p.Name())
+	if err != nil {
+		return 0, 0, err
+	}
+	fetched, upserted, next, err := p.SyncUserIncremental(ctx, db, userID, cursor, client, limiter, logger)
+	if next != nil {
+		if serr := SaveCursor(ctx, db, userID, p.Name(), next); serr != nil && err == nil {
+			err = serr
+		}
+	}
+	return fetched, upserted, err
+}
+
+// RecordRunSuccess marks a sync as successful: clears the error/failure count and makes the user
+// eligible again on the next sweep.
+func RecordRunSuccess(ctx context.Context, db *sql.DB, userID, provider string, startedAt time.Time) error {
+	if db == nil {
+		return fmt.Errorf("db is nil")
+	}
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO public.social_import_states (id, user_id, provider, last_run_at, last_success_at, last_error, consecutive_failures, next_run_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NULL, 0, NULL, NOW(), NOW())
+		ON CONFLICT (user_id, provider) DO UPDATE SET
+		  last_run_at = EXCLUDED.last_run_at,
+		  last_success_at = EXCLUDED.last_success_at,
+		  last_error = NULL,
+		  consecutive_failures = 0,
+		  next_run_at = NULL,
+		  updated_at = NOW()
+	`, fmt.Sprintf("%s:%s", provider, userID), userID, provider, startedAt.UTC())
+	return err
+}
+
+// RecordRunFailure stores the error and pushes next_run_at out exponentially: base after the first
+// failure, doubling per consecutive failure, capped at max. It returns the new next_run_at.
+func RecordRunFailure(ctx context.Context, db *sql.DB, userID, provider string, startedAt time.Time, errText string, base, max time.Duration) (time.Time, error) {
+	if db == nil {
+		return time.Time{}, fmt.Errorf("db is nil")
+	}
+	if len(errText) > 1000 {
+		errText = errText[:1000]
+	}
+	var next time.Time
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO public.social_import_states (id, user_id, provider, last_run_at, last_error, consecutive_failures, next_run_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, 1, NOW() + LEAST($6::double precision, $7::double precision) * INTERVAL '1 second', NOW(), NOW())
+		ON CONFLICT (user_id, provider) DO UPDATE SET
+		  last_run_at = EXCLUDED.last_run_at,
+		  last_error = EXCLUDED.last_error,
+		  consecutive_failures = public.social_import_states.consecutive_failures + 1,
+		  next_run_at = NOW() + LEAST($6::double precision * power(2, LEAST(public.social_import_states.consecutive_failures, 20)), $7::double precision) * INTERVAL '1 second',
+		  updated_at = NOW()
+		RETURNING next_run_at
+	`, fmt.Sprintf("%s:%s", provider, userID), userID, provider, startedAt.UTC(), errText, base.Seconds(), max.Seconds()).Scan(&next)
+	return next, err
+}
@@ -0,0 +1,238 @@
+package workers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// leaderAppNamePrefix tags the Postgres session holding a worker lock so Status can show which instance has it.
+const leaderAppNamePrefix = "sst-worker:"
+
+// Leader runs named background workers on exactly one instance at a time using Postgres session-level
+// advisory locks. Every instance calls Run for the same names; the instance that holds the lock runs the
+// worker and the others retry periodically. The lock lives on a dedicated connection, so if the leader
+// process dies (or its connection drops) Postgres releases the lock and another instance takes over.
+type Leader struct {
+	DB *sql.DB
+	// InstanceID identifies this process in Status (default: hostname:pid).
+	InstanceID string
+	// RetryInterval is how often standby instances try to acquire a lock (default: 15s).
+	RetryInterval time.Duration
+	// CheckInterval is how often the leader verifies its lock connection is alive (default: 10s).
+	CheckInterval time.Duration
+
+	mu    sync.Mutex
+	locks map[string]*time.Time // name -> leader since (nil when standby)
+}
+
+// LockStatus describes one named worker lock.
+type LockStatus struct {
+	Name string `json:"name"`
+	Key  int64  `json:"key"`
+	// Leader is true when this instance holds the lock.
+	Leader      bool       `json:"leader"`
+	LeaderSince *time.Time `json:"leaderSince,omitempty"`
+	// Holder is the instance holding the lock (from any instance), empty when nobody does.
+	Holder    string `json:"holder,omitempty"`
+	HolderPID int    `json:"holderPid,omitempty"`
+}
+
+// LockKey maps a worker name onto the advisory lock key (kept positive so it splits cleanly into pg_locks columns).
+func LockKey(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("simple-social-thing:worker:" + name))
+	return int64(h.Sum64() & 0x7fffffffffffffff)
+}
+
+// DefaultInstanceID identifies this process as hostname:pid.
+func DefaultInstanceID() string {
+	host, _ := os.Hostname()
+	host = strings.TrimSpace(host)
+	if host == "" {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}
+
+func (l *Leader) ensureDefaults() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.InstanceID == "" {
+		l.InstanceID = DefaultInstanceID()
+	}
+	if l.RetryInterval <= 0 {
+		l.RetryInterval = 15 * time.Second
+	}
+	if l.CheckInterval <= 0 {
+		l.CheckInterval = 10 * time.Second
+	}
+}
+
+func (l *Leader) setState(name string, since *time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.locks == nil {
+		l.locks = map[string]*time.Time{}
+	}
+	l.locks[name] = since
+}
+
+// Run blocks until ctx is done, running fn whenever this instance holds the lock for name.
+// fn must return once its context is canceled (leadership lost or shutdown).
+// Without a DB (or a nil Leader) fn simply runs unguarded, which matches single-instance behavior.
+func (l *Leader) Run(ctx context.Context, name string, fn func(ctx context.Context)) {
+	if l == nil || l.DB == nil {
+		fn(ctx)
+		return
+	}
+	l.ensureDefaults()
+	l.setState(name, nil)
+	key := LockKey(name)
+	for {
+		conn, err := l.tryAcquire(ctx, key)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[Leader] acquire failed name=%s instance=%s err=%v", name, l.InstanceID, err)
+		}
+		if conn != nil {
+			l.lead(ctx, name, key, conn, fn)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(l.RetryInterval):
+		}
+	}
+}
+
+// tryAcquire returns a dedicated connection holding the advisory lock, or nil when another instance has it.
+func (l *Leader) tryAcquire(ctx context.Context, key int64) (*sql.Conn, error) {
+	conn, err := l.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ok bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&ok); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if !ok {
+		_ = conn.Close()
+		return nil, nil
+	}
+	if _, err := conn.ExecContext(ctx, `SELECT set_config('application_name', $1, false)`, leaderAppNamePrefix+l.InstanceID); err != nil {
+		log.Printf("[Leader] set application_name failed key=%d err=%v", key, err)
+	}
+	return conn, nil
+}
+
+func (l *Leader) lead(ctx context.Context, name string, key int64, conn *sql.Conn, fn func(ctx context.Context)) {
+	since := time.Now().UTC()
+	l.setState(name, &since)
+	log.Printf("[Leader] acquired name=%s instance=%s", name, l.InstanceID)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(runCtx)
+	}()
+
+	ticker := time.NewTicker(l.CheckInterval)
+	defer ticker.Stop()
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-done:
+			log.Printf("[Leader] worker exited name=%s instance=%s", name, l.InstanceID)
+			break loop
+		case <-ticker.C:
+			if _, err := conn.ExecContext(ctx, `SELECT 1`); err != nil && ctx.Err() == nil {
+				log.Printf("[Leader] lost name=%s instance=%s err=%v", name, l.InstanceID, err)
+				break loop
+			}
+		}
+	}
+	cancel()
+	<-done
+
+	// Release explicitly: the connection goes back to the pool and would otherwise keep the lock.
+	// On a dead connection this fails harmlessly and the pool discards it.
+	relCtx, relCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if _, err := conn.ExecContext(relCtx, `SELECT pg_advisory_unlock($1)`, key); err != nil {
+		log.Printf("[Leader] unlock failed name=%s err=%v", name, err)
+	}
+	_, _ = conn.ExecContext(relCtx, `RESET application_name`)
+	relCancel()
+	_ = conn.Close()
+	l.setState(name, nil)
+	log.Printf("[Leader] released name=%s instance=%s", name, l.InstanceID)
+}
+
+// Status reports every worker lock registered on this instance, including which instance currently holds it.
+func (l *Leader) Status(ctx context.Context) ([]LockStatus, error) {
+	if l == nil {
+		return []LockStatus{}, nil
+	}
+	l.mu.Lock()
+	out := make([]LockStatus, 0, len(l.locks))
+	for name, since := range l.locks {
+		st := LockStatus{Name: name, Key: LockKey(name), Leader: since != nil}
+		if since != nil {
+			t := *since
+			st.LeaderSince = &t
+		}
+		out = append(out, st)
+	}
+	l.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	if l.DB == nil || len(out) == 0 {
+		return out, nil
+	}
+
+	// Session advisory locks on a bigint key appear in pg_locks split into classid (high) / objid (low).
+	rows, err := l.DB.QueryContext(ctx, `
+		SELECT (l.classid::bigint << 32) | l.objid::bigint, COALESCE(a.application_name, ''), l.pid
+		  FROM pg_locks l
+		  LEFT JOIN pg_stat_activity a ON a.pid = l.pid
+		 WHERE l.locktype = 'advisory'
+		   AND l.granted
+		   AND l.objsubid = 1
+	`)
+	if err != nil {
+		return out, err
+	}
+	defer rows.Close()
+	type holder struct {
+		name string
+		pid  int
+	}
+	holders := map[int64]holder{}
+	for rows.Next() {
+		var (
+			key     int64
+			appName string
+			pid     int
+		)
+		if err := rows.Scan(&key, &appName, &pid); err != nil {
+			return out, err
+		}
+		holders[key] = holder{name: strings.TrimPrefix(appName, leaderAppNamePrefix), pid: pid}
+	}
+	for i := range out {
+		if h, ok := holders[out[i].Key]; ok {
+			out[i].Holder = h.name
+			out[i].HolderPID = h.pid
+		}
+	}
+	return out, rows.Err()
+}
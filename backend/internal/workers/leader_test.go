@@ -0,0 +1,151 @@
+package workers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestLeaderRun_NoDB_RunsUnguarded(t *testing.T) {
+	ran := false
+	var l *Leader
+	l.Run(context.Background(), "x", func(ctx context.Context) { ran = true })
+	if !ran {
+		t.Fatalf("expected fn to run without a DB")
+	}
+}
+
+func TestLeaderRun_AcquiresRunsAndReleases(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	key := LockKey("scheduled_posts")
+	mock.ExpectQuery(`SELECT pg_try_advisory_lock\(\$1\)`).
+		WithArgs(key).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+	mock.ExpectExec(`set_config\('application_name'`).
+		WithArgs("sst-worker:i1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).
+		WithArgs(key).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`RESET application_name`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	l := &Leader{DB: db, InstanceID: "i1", RetryInterval: time.Hour, CheckInterval: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		l.Run(ctx, "scheduled_posts", func(ctx context.Context) {
+			close(started)
+			<-ctx.Done()
+		})
+		close(done)
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatalf("worker did not start")
+	}
+	l.mu.Lock()
+	leading := l.locks["scheduled_posts"] != nil
+	l.mu.Unlock()
+	if !leading {
+		t.Fatalf("expected this instance to lead scheduled_posts")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("leader did not stop")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestLeaderRun_StandbyDoesNotRun(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectQuery(`SELECT pg_try_advisory_lock\(\$1\)`).
+		WithArgs(LockKey("product_archival")).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false))
+
+	l := &Leader{DB: db, InstanceID: "i2", RetryInterval: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	ran := make(chan struct{}, 1)
+	go func() {
+		l.Run(ctx, "product_archival", func(ctx context.Context) { ran <- struct{}{} })
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for mock.ExpectationsWereMet() != nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	select {
+	case <-ran:
+		t.Fatalf("standby instance must not run the worker")
+	default:
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestLeaderStatus_ReportsHolders(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	since := time.Now().UTC()
+	l := &Leader{DB: db, InstanceID: "i1", locks: map[string]*time.Time{"scheduled_posts": &since, "product_archival": nil}}
+	mock.ExpectQuery(`FROM pg_locks l`).
+		WillReturnRows(sqlmock.NewRows([]string{"key", "application_name", "pid"}).
+			AddRow(LockKey("scheduled_posts"), "sst-worker:i1", 101).
+			AddRow(LockKey("product_archival"), "sst-worker:other:7", 202).
+			AddRow(int64(42), "psql", 303))
+
+	st, err := l.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if len(st) != 2 || st[0].Name != "product_archival" || st[1].Name != "scheduled_posts" {
+		t.Fatalf("unexpected status order %#v", st)
+	}
+	if st[0].Leader || st[0].Holder != "other:7" || st[0].HolderPID != 202 {
+		t.Fatalf("unexpected product_archival status %#v", st[0])
+	}
+	if !st[1].Leader || st[1].LeaderSince == nil || st[1].Holder != "i1" {
+		t.Fatalf("unexpected scheduled_posts status %#v", st[1])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sql expectations: %v", err)
+	}
+}
+
+func TestLockKey_StableAndPositive(t *testing.T) {
+	if LockKey("a") != LockKey("a") || LockKey("a") == LockKey("b") {
+		t.Fatalf("expected stable, distinct keys")
+	}
+	if LockKey("social_import:x") < 0 {
+		t.Fatalf("expected positive key")
+	}
+}